@@ -22,6 +22,7 @@ func main() {
 		logFormat  = flag.String("log-format", "text", "Log format (text, json)")
 		verbose    = flag.Bool("verbose", false, "Enable verbose logging")
 		noColor    = flag.Bool("no-color", false, "Disable color output")
+		forceColor = flag.Bool("force-color", false, "Enable color output even when not writing to a terminal")
 	)
 	flag.Parse()
 
@@ -32,8 +33,11 @@ func main() {
 
 	// Initialize logger
 	log := logger.New(logger.Level(*logLevel), *logFormat)
-	if !*noColor {
-		log.ForceColor() // Force color output for better visibility
+	switch {
+	case *noColor:
+		log.DisableColor()
+	case *forceColor:
+		log.ForceColor()
 	}
 
 	log.Info("Starting Nina Engine")
@@ -43,6 +47,9 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to load configuration", "error", err)
 	}
+	if err := cfg.ValidateAddrs(); err != nil {
+		log.Fatal("Invalid configuration", "error", err)
+	}
 
 	log.Info("Configuration loaded", "config_path", *configPath)
 
@@ -53,7 +60,10 @@ func main() {
 	}
 
 	// Initialize Engine server
-	server := engine.NewEngine(cfg, log, st)
+	server, err := engine.NewEngine(cfg, log, st)
+	if err != nil {
+		log.Fatal("Failed to initialize Engine", "error", err)
+	}
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -21,6 +21,8 @@ func main() {
 		logLevel   = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
 		logFormat  = flag.String("log-format", "text", "Log format (text, json)")
 		verbose    = flag.Bool("verbose", false, "Enable verbose logging")
+		noColor    = flag.Bool("no-color", false, "Disable color output")
+		forceColor = flag.Bool("force-color", false, "Enable color output even when not writing to a terminal")
 	)
 	flag.Parse()
 
@@ -31,7 +33,12 @@ func main() {
 
 	// Initialize logger
 	log := logger.New(logger.Level(*logLevel), *logFormat)
-	log.ForceColor() // Force color output for better visibility
+	switch {
+	case *noColor:
+		log.DisableColor()
+	case *forceColor:
+		log.ForceColor()
+	}
 	log.Info("Starting Nina Ingress")
 
 	// Load configuration
@@ -39,6 +46,9 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to load configuration", "error", err)
 	}
+	if err := cfg.ValidateAddrs(); err != nil {
+		log.Fatal("Invalid configuration", "error", err)
+	}
 
 	log.Info("Configuration loaded", "config_path", *configPath)
 
@@ -5,15 +5,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/matiasinsaurralde/nina/pkg/cli"
+	"github.com/matiasinsaurralde/nina/pkg/cli/table"
 	"github.com/matiasinsaurralde/nina/pkg/config"
 	"github.com/matiasinsaurralde/nina/pkg/logger"
+	"github.com/matiasinsaurralde/nina/pkg/store"
 	"github.com/matiasinsaurralde/nina/pkg/types"
 	"github.com/spf13/cobra"
 )
@@ -23,6 +26,8 @@ var (
 	logLevel   string
 	logFormat  string
 	verbose    bool
+	noColor    bool
+	forceColor bool
 )
 
 func main() {
@@ -40,6 +45,8 @@ This CLI allows you to interact with the Nina Engine server to manage container
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format (text, json)")
 	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable color output")
+	rootCmd.PersistentFlags().BoolVar(&forceColor, "force-color", false, "Enable color output even when not writing to a terminal")
 
 	// Add subcommands
 	rootCmd.AddCommand(deployCmd())
@@ -47,7 +54,13 @@ This CLI allows you to interact with the Nina Engine server to manage container
 	rootCmd.AddCommand(deleteCmd())
 	rootCmd.AddCommand(statusCmd())
 	rootCmd.AddCommand(listCmd())
+	rootCmd.AddCommand(appsCmd())
+	rootCmd.AddCommand(inspectCmd())
+	rootCmd.AddCommand(containersCmd())
+	rootCmd.AddCommand(statsCmd())
+	rootCmd.AddCommand(activityCmd())
 	rootCmd.AddCommand(healthCmd())
+	rootCmd.AddCommand(migrateCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -56,6 +69,18 @@ This CLI allows you to interact with the Nina Engine server to manage container
 }
 
 func getCLI() (*cli.CLI, *logger.Logger, error) {
+	cfg, log, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Initialize CLI
+	c := cli.NewCLI(cfg, log)
+	return c, log, nil
+}
+
+// loadConfig initializes the logger from the global flags and loads the configuration
+func loadConfig() (*config.Config, *logger.Logger, error) {
 	// Set log level based on verbose flag
 	if verbose {
 		logLevel = "debug"
@@ -63,43 +88,99 @@ func getCLI() (*cli.CLI, *logger.Logger, error) {
 
 	// Initialize logger
 	log := logger.New(logger.Level(logLevel), logFormat)
-	log.ForceColor() // Force color output for better visibility
+	// Colors are kept out of piped output such as CI logs unless explicitly forced
+	switch {
+	case noColor:
+		log.DisableColor()
+	case forceColor:
+		log.ForceColor()
+	case !logger.IsStdoutTerminal():
+		log.DisableColor()
+	}
 
 	// Load configuration
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
 	}
-
-	// Initialize CLI
-	c := cli.NewCLI(cfg, log)
-	return c, log, nil
+	return cfg, log, nil
 }
 
 func deployCmd() *cobra.Command {
-	var replicas int
+	var (
+		replicas int
+		preview  bool
+		node     string
+		labels   []string
+		pull     string
+		domains  []string
+		diff     bool
+		image    string
+		webhooks []string
+		file     string
+	)
 
 	cmd := &cobra.Command{
-		Use:   "deploy",
+		Use:   "deploy [path]",
 		Short: "Deploy applications",
-		Long: `Deploy applications. Use 'deploy' to deploy the current directory, ` +
-			`'deploy ls' to list deployments, or 'deploy rm' to remove deployments.`,
-		RunE: func(_ *cobra.Command, _ []string) error {
+		Long: `Deploy applications. Use 'deploy' to deploy the current directory or 'deploy <path>' to deploy another ` +
+			`directory, e.g. 'deploy ./services/api', 'deploy ls' to list deployments, or 'deploy rm' to remove deployments.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			parsedLabels, err := types.ParseLabels(labels)
+			if err != nil {
+				return err
+			}
+			progress := cli.NewProgress(os.Stdout, logger.IsTerminal())
+			opts := &cli.DeployOptions{
+				Preview:    preview,
+				Node:       node,
+				Labels:     parsedLabels,
+				PullPolicy: types.PullPolicy(pull),
+				Domains:    domains,
+				Image:      image,
+				Webhooks:   webhooks,
+			}
+
 			cli, log, err := getCLI()
 			if err != nil {
 				return err
 			}
+			cli.SetProgress(progress)
 
-			// Get current working directory
-			workingDir, err := os.Getwd()
+			workingDir, err := projectDir(args)
 			if err != nil {
-				return fmt.Errorf("failed to get current working directory: %w", err)
+				return err
+			}
+
+			// Only an explicit --replicas flag overrides the manifest
+			if cmd.Flags().Changed("replicas") {
+				opts.Replicas = replicas
+			}
+
+			if file != "" {
+				log.Info("Deploying stack", "file", file)
+				results, err := cli.DeployStack(context.Background(), file, opts)
+				if err != nil {
+					return fmt.Errorf("failed to deploy stack: %w", err)
+				}
+				return printStackResults(results)
 			}
 
-			log.Info("Deploying project from directory", "dir", workingDir, "replicas", replicas)
+			if diff {
+				deployDiff, err := cli.Diff(context.Background(), workingDir, opts)
+				if err != nil {
+					return fmt.Errorf("failed to compare with the current deployment: %w", err)
+				}
+				printDeployDiff(deployDiff)
+				return nil
+			}
+
+			log.Info("Deploying project from directory", "dir", workingDir, "replicas", opts.Replicas, "preview", opts.Preview,
+				"image", opts.Image)
 
 			startTime := time.Now()
-			deployment, err := cli.Deploy(context.Background(), workingDir, replicas)
+			deployment, err := cli.Deploy(context.Background(), workingDir, opts)
 			if err != nil {
 				return fmt.Errorf("failed to deploy application: %w", err)
 			}
@@ -110,10 +191,24 @@ func deployCmd() *cobra.Command {
 			fmt.Printf("✅ Deployment completed successfully!\n")
 			fmt.Printf("🆔 Deployment ID: %s\n", deployment.ID)
 			fmt.Printf("📱 App Name: %s\n", deployment.AppName)
-			fmt.Printf("🔗 Commit Hash: %s\n", deployment.CommitHash)
-			fmt.Printf("👤 Author: %s\n", deployment.Author)
-			fmt.Printf("📝 Commit Message: %s\n", deployment.CommitMessage)
-			fmt.Printf("📊 Status: %s\n", deployment.Status)
+			if deployment.Image != "" {
+				fmt.Printf("📦 Image: %s\n", deployment.Image)
+			}
+			if deployment.CommitHash != "" {
+				fmt.Printf("🔗 Commit Hash: %s\n", deployment.CommitHash)
+				fmt.Printf("👤 Author: %s\n", deployment.Author)
+				fmt.Printf("📝 Commit Message: %s\n", deployment.CommitMessage)
+			}
+			fmt.Printf("📊 Status: %s (%s replicas healthy)\n", formatStatus(string(deployment.Status)), formatReplicas(deployment))
+			if deployment.Node != "" {
+				fmt.Printf("🖥️  Node: %s\n", deployment.Node)
+			}
+			if len(deployment.Labels) > 0 {
+				fmt.Printf("🏷️  Labels: %s\n", formatLabels(deployment.Labels))
+			}
+			if len(deployment.Domains) > 0 {
+				fmt.Printf("🌐 Domains: %s\n", strings.Join(deployment.Domains, ", "))
+			}
 			fmt.Printf("⏱️  Elapsed Time: %s\n", elapsed)
 
 			if len(deployment.Containers) > 0 {
@@ -125,12 +220,34 @@ func deployCmd() *cobra.Command {
 			}
 
 			fmt.Printf("\nThe application has been successfully deployed.\n")
+			if len(deployment.URLs) > 0 {
+				fmt.Printf("🌍 Reachable at:\n")
+				for _, u := range deployment.URLs {
+					fmt.Printf("  %s\n", u)
+				}
+			}
 			return nil
 		},
 	}
 
 	// Add flags
-	cmd.Flags().IntVar(&replicas, "replicas", 1, "Number of container replicas to deploy")
+	cmd.Flags().IntVar(&replicas, "replicas", 1, "Number of container replicas to deploy (overrides nina.yaml)")
+	cmd.Flags().BoolVar(&preview, "preview", false, "Deploy the current branch as a separate <app>-<branch> preview deployment")
+	cmd.Flags().StringVar(&node, "node", "", "Deploy to the named engine node instead of the default Docker daemon (overrides nina.yaml)")
+	cmd.Flags().StringArrayVar(&labels, "label", nil, "Attach a label (KEY=VALUE) to the deployment and its containers, can be repeated")
+	cmd.Flags().StringArrayVar(&domains, "domain", nil, "Route an additional host, e.g. www.example.com or *.example.com, to the app, can be repeated")
+	cmd.Flags().StringVar(&pull, "pull", "", "Image pull policy: always, if-not-present or never (default if-not-present)")
+	cmd.Flags().BoolVar(&diff, "diff", false, "Show the commits and files that would change since the current deployment, without deploying")
+	cmd.Flags().StringVar(&image, "image", "",
+		"Deploy this pre-built image, e.g. registry.example.com/app:1.2, instead of the build of the current commit")
+	cmd.Flags().StringArrayVar(&webhooks, "webhook", nil,
+		"Notify this URL when the deployment becomes ready or fails, can be repeated (overrides nina.yaml)")
+	cmd.Flags().StringVarP(&file, "file", "f", "",
+		"Build and deploy every service of a stack file, e.g. nina-stack.yaml, in dependency order")
+	cmd.MarkFlagsMutuallyExclusive("diff", "image")
+	cmd.MarkFlagsMutuallyExclusive("file", "diff")
+	cmd.MarkFlagsMutuallyExclusive("file", "image")
+	cmd.MarkFlagsMutuallyExclusive("file", "replicas")
 
 	// Add subcommands
 	cmd.AddCommand(deployLsCmd())
@@ -139,12 +256,159 @@ func deployCmd() *cobra.Command {
 	return cmd
 }
 
+// printBuildResults prints a table of the builds of several directories, failing when any build failed
+func printBuildResults(results []*cli.BuildResult) error {
+	if err := buildResultsTable(results).Render(os.Stdout); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	fmt.Printf("\n%d of %d builds succeeded.\n", len(results)-failed, len(results))
+	if failed > 0 {
+		return fmt.Errorf("%d builds failed", failed)
+	}
+	return nil
+}
+
+// buildResultsTable builds the table of the outcome of every build of a batch
+func buildResultsTable(results []*cli.BuildResult) *table.Table {
+	t := table.New(
+		table.Column{Header: "DIRECTORY"},
+		table.Column{Header: "COMMIT", MaxWidth: 8, Clip: true},
+		table.Column{Header: "STATUS", Style: statusStyle()},
+		table.Column{Header: "IMAGE TAG"},
+		table.Column{Header: "SIZE"},
+		table.Column{Header: "ERROR"},
+	)
+	for _, result := range results {
+		commit := result.CommitHash
+		if commit == "" {
+			commit = "-"
+		}
+		if result.Err != nil {
+			t.AddRow(result.Dir, commit, string(types.BuildStatusFailed), "-", "-", result.Err.Error())
+			continue
+		}
+		t.AddRow(result.Dir, commit, string(types.BuildStatusBuilt), result.Image.ImageTag,
+			formatBytes(result.Image.Size), "-")
+	}
+	return t
+}
+
+// projectDir returns the directory to build or deploy: the path argument when one is given, or the current
+// working directory
+func projectDir(args []string) (string, error) {
+	if len(args) == 0 {
+		workingDir, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to get current working directory: %w", err)
+		}
+		return workingDir, nil
+	}
+
+	dir, err := filepath.Abs(args[0])
+	if err != nil {
+		return "", fmt.Errorf("invalid path %s: %w", args[0], err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid path %s: %w", args[0], err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("invalid path %s: not a directory", args[0])
+	}
+	return dir, nil
+}
+
+// printStackResults prints the outcome of every service of a stack deployment, failing when any service failed
+func printStackResults(results []*cli.StackServiceResult) error {
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Printf("❌ %s: %v\n", result.Service, result.Err)
+			continue
+		}
+		build := "built"
+		if !result.Built {
+			build = "existing build"
+		}
+		fmt.Printf("✅ %s: %s (%s replicas healthy, %s)\n", result.Service, formatStatus(string(result.Deployment.Status)),
+			formatReplicas(result.Deployment), build)
+		for _, u := range result.Deployment.URLs {
+			fmt.Printf("  %s\n", u)
+		}
+	}
+
+	fmt.Printf("\n%d of %d services deployed.\n", len(results)-failed, len(results))
+	if failed > 0 {
+		return fmt.Errorf("%d services failed", failed)
+	}
+	return nil
+}
+
+// printDeployDiff prints what deploying the working directory would change
+func printDeployDiff(diff *cli.DeployDiff) {
+	fmt.Printf("📱 App Name: %s\n", diff.AppName)
+	fmt.Printf("🔗 Local Commit: %s\n", shortHash(diff.Commit.Hash))
+	if diff.DeployedCommit == "" && diff.RangeErr == nil {
+		fmt.Printf("\nThe app is not deployed yet, deploying would create it.\n")
+		return
+	}
+	fmt.Printf("🚀 Deployed Commit: %s\n", shortHash(diff.DeployedCommit))
+	if diff.RangeErr != nil {
+		fmt.Printf("\n⚠️  Cannot compare with the deployed commit: %v\n", diff.RangeErr)
+		fmt.Printf("Fetch the deployed commit with 'git fetch' and try again.\n")
+		return
+	}
+
+	commitRange := diff.Range
+	if len(commitRange.Commits) == 0 && commitRange.ChangedFiles == 0 {
+		fmt.Printf("\nThe deployed commit is up to date, deploying would not change anything.\n")
+		return
+	}
+	if !commitRange.FromIsAncestor {
+		fmt.Printf("\n⚠️  The deployed commit is not an ancestor of the local commit, " +
+			"deploying would drop the changes that are only in the deployed commit.\n")
+	}
+	fmt.Printf("\n📝 Commits (%d):\n", len(commitRange.Commits))
+	for _, commit := range commitRange.Commits {
+		fmt.Printf("  %s %s (%s)\n", shortHash(commit.Hash), commit.Message, commit.Author)
+	}
+	fmt.Printf("📄 Changed Files: %d\n", commitRange.ChangedFiles)
+}
+
+// shortHash abbreviates a commit hash the way git does
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
 func deployLsCmd() *cobra.Command {
+	opts := &types.ListOptions{}
+	var labels []string
+
 	cmd := &cobra.Command{
 		Use:   "ls",
 		Short: "List all deployments",
 		Long:  `List all deployments in a tabular format.`,
 		RunE: func(_ *cobra.Command, _ []string) error {
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+			parsedLabels, err := types.ParseLabels(labels)
+			if err != nil {
+				return err
+			}
+			opts.Labels = parsedLabels
+
 			cli, log, err := getCLI()
 			if err != nil {
 				return err
@@ -152,121 +416,134 @@ func deployLsCmd() *cobra.Command {
 
 			log.Info("Listing deployments")
 
-			deployments, err := cli.ListDeployments(context.Background())
+			deployments, err := cli.ListDeployments(context.Background(), opts)
 			if err != nil {
 				return fmt.Errorf("failed to list deployments: %w", err)
 			}
 
-			if len(deployments) == 0 {
-				fmt.Println("No deployments found.")
-				return nil
-			}
-
-			// Print header
-			fmt.Printf("%-20s %-12s %-20s %-40s %-15s %-10s\n", "APP NAME", "COMMIT HASH", "AUTHOR", "COMMIT MESSAGE", "STATUS", "REPLICAS")
-			fmt.Println(strings.Repeat("-", 120))
-
-			// Print deployments
-			for _, deployment := range deployments {
-				// Truncate commit message if too long
-				commitMsg := deployment.CommitMessage
-				if len(commitMsg) > 37 {
-					commitMsg = commitMsg[:37] + "..."
-				}
-
-				// Truncate commit hash to 12 characters
-				commitHash := deployment.CommitHash
-				if len(commitHash) > 12 {
-					commitHash = commitHash[:12]
-				}
-
-				// Get replica count (number of containers)
-				replicaCount := len(deployment.Containers)
-
-				fmt.Printf("%-20s %-12s %-20s %-40s %-15s %-10d\n",
-					deployment.AppName,
-					commitHash,
-					deployment.Author,
-					commitMsg,
-					deployment.Status,
-					replicaCount)
-			}
-
-			fmt.Printf("\nTotal deployments: %d\n", len(deployments))
-			return nil
+			return printDeployments(deployments)
 		},
 	}
 
+	addListFlags(cmd, opts)
+	cmd.Flags().StringArrayVar(&labels, "label", nil, "Only list deployments with this label (KEY=VALUE), can be repeated")
+
 	return cmd
 }
 
 func deployRmCmd() *cobra.Command {
+	var (
+		prefix        string
+		ignoreMissing bool
+	)
+
 	cmd := &cobra.Command{
 		Use:   "rm [id]",
-		Short: "Remove deployments by ID",
-		Long:  `Remove deployments by ID. This will delete the deployment with the given ID.`,
-		Args:  cobra.ExactArgs(1),
+		Short: "Remove deployments by ID or app name prefix",
+		Long: `Remove deployments by ID. This will delete the deployment with the given ID.
+With --prefix, all deployments whose app name starts with the prefix are deleted instead.`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(_ *cobra.Command, args []string) error {
+			if (prefix == "") == (len(args) == 0) {
+				return fmt.Errorf("either a deployment ID or a non-empty --prefix is required")
+			}
+
 			cli, log, err := getCLI()
 			if err != nil {
 				return err
 			}
-			id := args[0]
-			url := fmt.Sprintf("http://%s/api/v1/deployments/%s", cli.Config().GetServerAddr(), id)
-			req, err := http.NewRequestWithContext(context.Background(), "DELETE", url, http.NoBody)
-			if err != nil {
-				return fmt.Errorf("failed to create request: %w", err)
-			}
-			resp, err := cli.Client().Do(req)
-			if err != nil {
-				return fmt.Errorf("failed to send request: %w", err)
-			}
-			defer func() {
-				if closeErr := resp.Body.Close(); closeErr != nil {
-					log.Error("Failed to close response body", "error", closeErr)
-				}
-			}()
 
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return fmt.Errorf("failed to read response: %w", err)
-			}
-			if resp.StatusCode != http.StatusOK {
-				return fmt.Errorf("delete failed: %s (status: %d)", string(body), resp.StatusCode)
+			if prefix != "" {
+				deleted, err := cli.DeleteDeploymentsByPrefix(context.Background(), prefix)
+				if err != nil {
+					return fmt.Errorf("failed to delete deployments: %w", err)
+				}
+				for _, appName := range deleted {
+					fmt.Printf("Deployment %s deleted successfully\n", appName)
+				}
+				fmt.Printf("Deleted %d deployments\n", len(deleted))
+				return nil
 			}
-			fmt.Printf("Deployment %s deleted successfully\n", id)
-			return nil
+
+			id := args[0]
+			log.Info("Deleting deployment", "id", id)
+			return deleteDeployment(cli, id, ignoreMissing)
 		},
 	}
+
+	cmd.Flags().StringVar(&prefix, "prefix", "", "Delete all deployments whose app name starts with this prefix")
+	cmd.Flags().BoolVar(&ignoreMissing, "ignore-missing", false, "Succeed when the deployment does not exist")
+
 	return cmd
 }
 
 func buildCmd() *cobra.Command {
+	var (
+		buildArgs   []string
+		buildpack   string
+		compression string
+		secrets     []string
+		callbackURL string
+		parallel    int
+	)
+
 	cmd := &cobra.Command{
-		Use:   "build",
+		Use:   "build [path...]",
 		Short: "Build projects",
-		Long:  `Build projects. Use 'build' to create a new build from the current directory, or 'build ls' to list existing builds.`,
-		RunE: func(_ *cobra.Command, _ []string) error {
+		Long: `Build projects. Use 'build' to create a new build from the current directory or 'build <path>' to build
+another directory, e.g. 'build ./services/api', 'build ls' to list existing builds, 'build status' to see why a
+build failed, or 'build logs' to show the output of a build. Several paths, e.g. the worktrees of the branches of a
+CI matrix, are built concurrently and summarized in a table.`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			parsedBuildArgs, err := parseBuildArgs(buildArgs)
+			if err != nil {
+				return err
+			}
+			opts := &cli.BuildOptions{
+				BuildArgs:   parsedBuildArgs,
+				Buildpack:   buildpack,
+				Compression: compression,
+				Secrets:     secrets,
+				CallbackURL: callbackURL,
+			}
+			progress := cli.NewProgress(os.Stdout, logger.IsTerminal())
+
 			cli, log, err := getCLI()
 			if err != nil {
 				return err
 			}
+			cli.SetProgress(progress)
+
+			if len(args) > 1 {
+				dirs := make([]string, 0, len(args))
+				for _, arg := range args {
+					dir, err := projectDir([]string{arg})
+					if err != nil {
+						return err
+					}
+					dirs = append(dirs, dir)
+				}
+				log.Info("Building projects", "dirs", len(dirs), "parallel", parallel)
+				return printBuildResults(cli.BuildAll(context.Background(), dirs, opts, parallel))
+			}
 
-			// Get current working directory
-			workingDir, err := os.Getwd()
+			workingDir, err := projectDir(args)
 			if err != nil {
-				return fmt.Errorf("failed to get current working directory: %w", err)
+				return err
 			}
 
-			log.Info("Building project from directory", "dir", workingDir)
+			log.Info("Building project from directory", "dir", workingDir, "build_args", len(opts.BuildArgs))
 
-			builtImage, err := cli.Build(context.Background(), workingDir)
+			builtImage, err := cli.Build(context.Background(), workingDir, opts)
 			if err != nil {
 				return fmt.Errorf("failed to build deployment: %w", err)
 			}
 
 			// Output friendly success message
 			fmt.Printf("✅ Build completed successfully!\n")
+			if builtImage.Cached {
+				fmt.Printf("♻️  Reused the image of an earlier build with identical contents\n")
+			}
 			fmt.Printf("📦 Image Tag: %s\n", builtImage.ImageTag)
 			fmt.Printf("🆔 Image ID: %s\n", builtImage.ImageID)
 			fmt.Printf("📏 Size: %s\n", formatBytes(builtImage.Size))
@@ -275,95 +552,160 @@ func buildCmd() *cobra.Command {
 		},
 	}
 
+	// Add flags
+	cmd.Flags().StringArrayVar(&buildArgs, "build-arg", nil, "Set a build-time variable (KEY=VALUE), can be repeated")
+	cmd.Flags().StringVar(&buildpack, "buildpack", "", "Use the named buildpack instead of detecting one (overrides nina.yaml)")
+	cmd.Flags().StringArrayVar(&secrets, "secret", nil,
+		"Make the named build secret of the Engine available to the build without baking it into the image, can be repeated")
+	cmd.Flags().StringVar(&compression, "compression", "default",
+		"Bundle compression level: 0-9, none, fastest, default or best")
+	cmd.Flags().StringVar(&callbackURL, "callback-url", "", "URL the Engine POSTs the build result to once the build succeeds or fails")
+	cmd.Flags().IntVar(&parallel, "parallel", 4, "Number of builds run at once when several paths are given")
+
 	// Add subcommands
 	cmd.AddCommand(buildLsCmd())
+	cmd.AddCommand(buildStatusCmd())
+	cmd.AddCommand(buildLogsCmd())
 	cmd.AddCommand(buildRmCmd())
 
 	return cmd
 }
 
-// formatTableItem formats a single item for table display
-func formatTableItem(item interface{}) (appName, commitHash, author, commitMsg, status string) {
-	switch v := item.(type) {
-	case *types.Build:
-		appName = v.AppName
-		commitHash = v.CommitHash
-		author = v.Author
-		commitMsg = v.CommitMessage
-		status = string(v.Status)
-	case *types.Deployment:
-		appName = v.AppName
-		commitHash = v.CommitHash
-		author = v.Author
-		commitMsg = v.CommitMessage
-		status = string(v.Status)
+// parseBuildArgs parses KEY=VALUE build arguments into a map
+func parseBuildArgs(args []string) (map[string]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	buildArgs := make(map[string]string, len(args))
+	for _, arg := range args {
+		key, value, found := strings.Cut(arg, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid build argument %q, expected KEY=VALUE", arg)
+		}
+		buildArgs[key] = value
 	}
+	return buildArgs, nil
+}
 
-	// Truncate commit message if too long
-	if len(commitMsg) > 37 {
-		commitMsg = commitMsg[:37] + "..."
+// formatReplicas formats the healthy and requested replica counts of a deployment as healthy/total.
+// Deployments created before the requested count was recorded report their containers as the total.
+func formatReplicas(deployment *types.Deployment) string {
+	total := deployment.Replicas
+	if total == 0 {
+		total = len(deployment.Containers)
 	}
+	return fmt.Sprintf("%d/%d", len(deployment.Containers), total)
+}
 
-	// Truncate commit hash to 12 characters
-	if len(commitHash) > 12 {
-		commitHash = commitHash[:12]
+// formatLabels formats labels as comma separated key=value pairs, sorted by key
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	return appName, commitHash, author, commitMsg, status
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ",")
 }
 
-// printTableData is a helper function to print tabular data for builds and deployments
-func printTableData(items interface{}, itemType string) error {
-	var data []interface{}
-	var count int
+// colorEnabled reports whether statuses are printed in color: on a terminal or with --force-color,
+// unless --no-color or NO_COLOR is set
+func colorEnabled() bool {
+	return !noColor && !logger.NoColor() && (forceColor || logger.IsStdoutTerminal())
+}
 
-	switch v := items.(type) {
-	case []*types.Build:
-		data = make([]interface{}, len(v))
-		for i, item := range v {
-			data[i] = item
-		}
-		count = len(v)
-	case []*types.Deployment:
-		data = make([]interface{}, len(v))
-		for i, item := range v {
-			data[i] = item
-		}
-		count = len(v)
-	default:
-		return fmt.Errorf("unsupported item type: %T", items)
+// formatStatus formats a deployment or build status for display
+func formatStatus(status string) string {
+	if !colorEnabled() {
+		return status
 	}
+	return cli.ColorizeStatus(status)
+}
 
-	if count == 0 {
-		fmt.Printf("No %s found.\n", itemType)
+// statusStyle returns the table style of status columns, or nil when colors are disabled
+func statusStyle() func(string) string {
+	if !colorEnabled() {
 		return nil
 	}
+	return cli.ColorizeStatus
+}
 
-	// Print header
-	fmt.Printf("%-20s %-12s %-20s %-40s %-15s\n", "APP NAME", "COMMIT HASH", "AUTHOR", "COMMIT MESSAGE", "STATUS")
-	fmt.Println(strings.Repeat("-", 110))
+// commitColumns are the leading columns shared by the build and deployment tables
+func commitColumns() []table.Column {
+	return []table.Column{
+		{Header: "APP NAME"},
+		{Header: "COMMIT HASH", MaxWidth: 12, Clip: true},
+		{Header: "AUTHOR", MaxWidth: 20},
+		{Header: "COMMIT MESSAGE", MaxWidth: 40},
+	}
+}
 
-	// Print items
-	for _, item := range data {
-		appName, commitHash, author, commitMsg, status := formatTableItem(item)
-		fmt.Printf("%-20s %-12s %-20s %-40s %-15s\n",
-			appName,
-			commitHash,
-			author,
-			commitMsg,
-			status)
+// deploymentsTable builds the table listing deployments
+func deploymentsTable(deployments []*types.Deployment, now time.Time) *table.Table {
+	columns := append(commitColumns(),
+		table.Column{Header: "STATUS", Style: statusStyle()},
+		table.Column{Header: "REPLICAS", Align: table.AlignRight},
+		table.Column{Header: "CREATED"},
+	)
+	t := table.New(columns...)
+	for _, d := range deployments {
+		t.AddRow(d.AppName, d.CommitHash, d.Author, d.CommitMessage, string(d.Status), formatReplicas(d), formatAge(d.CreatedAt, now))
 	}
+	return t
+}
 
-	fmt.Printf("\nTotal %s: %d\n", itemType, count)
+// buildsTable builds the table listing builds
+func buildsTable(builds []*types.Build, now time.Time) *table.Table {
+	columns := append(commitColumns(),
+		table.Column{Header: "STATUS", Style: statusStyle()},
+		table.Column{Header: "CREATED"},
+	)
+	t := table.New(columns...)
+	for _, b := range builds {
+		t.AddRow(b.AppName, b.CommitHash, b.Author, b.CommitMessage, string(b.Status), formatAge(b.CreatedAt, now))
+	}
+	return t
+}
+
+// printDeployments prints the deployments table followed by the total
+func printDeployments(deployments []*types.Deployment) error {
+	return printTable(deploymentsTable(deployments, time.Now()), "deployments")
+}
+
+// printBuilds prints the builds table followed by the total
+func printBuilds(builds []*types.Build) error {
+	return printTable(buildsTable(builds, time.Now()), "builds")
+}
+
+// printTable prints a list table, or a notice when it has no rows
+func printTable(t *table.Table, itemType string) error {
+	if t.Len() == 0 {
+		fmt.Printf("No %s found.\n", itemType)
+		return nil
+	}
+	if err := t.Render(os.Stdout); err != nil {
+		return err
+	}
+	fmt.Printf("\nTotal %s: %d\n", itemType, t.Len())
 	return nil
 }
 
 func buildLsCmd() *cobra.Command {
+	opts := &types.ListOptions{}
+
 	cmd := &cobra.Command{
 		Use:   "ls",
 		Short: "List all builds",
 		Long:  `List all builds in a tabular format.`,
 		RunE: func(_ *cobra.Command, _ []string) error {
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
 			cli, log, err := getCLI()
 			if err != nil {
 				return err
@@ -371,65 +713,150 @@ func buildLsCmd() *cobra.Command {
 
 			log.Info("Listing builds")
 
-			builds, err := cli.ListBuilds(context.Background())
+			builds, err := cli.ListBuilds(context.Background(), opts)
 			if err != nil {
 				return fmt.Errorf("failed to list builds: %w", err)
 			}
 
-			return printTableData(builds, "builds")
+			return printBuilds(builds)
 		},
 	}
 
+	addListFlags(cmd, opts)
+
 	return cmd
 }
 
-func buildRmCmd() *cobra.Command {
+func buildStatusCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "rm [id]",
-		Short: "Remove builds by app name or commit hash",
-		Long:  `Remove builds by app name or commit hash. This will delete all builds that match the given app name or commit hash.`,
-		Args:  cobra.ExactArgs(1),
+		Use:   "status [commit-hash]",
+		Short: "Show the status of a build",
+		Long: `Show the status of the build for a commit hash, or for the last commit of the current directory when
+no commit hash is given. A commit hash prefix of at least 4 characters is accepted when it matches a single
+build. Failed builds include the end of the build output.`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(_ *cobra.Command, args []string) error {
 			cli, log, err := getCLI()
 			if err != nil {
 				return err
 			}
-			id := args[0]
-			url := fmt.Sprintf("http://%s/api/v1/builds/%s", cli.Config().GetServerAddr(), id)
-			req, err := http.NewRequestWithContext(context.Background(), "DELETE", url, http.NoBody)
+
+			workingDir, err := os.Getwd()
 			if err != nil {
-				return fmt.Errorf("failed to create request: %w", err)
+				return fmt.Errorf("failed to get current working directory: %w", err)
+			}
+
+			commitHash := ""
+			if len(args) > 0 {
+				commitHash = args[0]
 			}
-			resp, err := cli.Client().Do(req)
+			log.Info("Getting build status", "commit_hash", commitHash)
+
+			build, err := cli.BuildStatus(context.Background(), workingDir, commitHash)
 			if err != nil {
-				return fmt.Errorf("failed to send request: %w", err)
+				return fmt.Errorf("failed to get build status: %w", err)
 			}
-			defer func() {
-				if closeErr := resp.Body.Close(); closeErr != nil {
-					log.Error("Failed to close response body", "error", closeErr)
-				}
-			}()
 
-			body, err := io.ReadAll(resp.Body)
+			printBuildStatus(build, time.Now())
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func buildLogsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs [commit-hash]",
+		Short: "Show the output of a build",
+		Long: `Show the stored output of the build for a commit hash, or for the last commit of the current directory
+when no commit hash is given. Logs are kept for completed and failed builds until their retention expires.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			cli, log, err := getCLI()
 			if err != nil {
-				return fmt.Errorf("failed to read response: %w", err)
+				return err
 			}
-			if resp.StatusCode != http.StatusOK {
-				return fmt.Errorf("delete failed: %s (status: %d)", string(body), resp.StatusCode)
+
+			workingDir, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %w", err)
 			}
-			var response struct {
-				Deleted []string `json:"deleted"`
-				Count   int      `json:"count"`
+
+			commitHash := ""
+			if len(args) > 0 {
+				commitHash = args[0]
 			}
-			if err := json.Unmarshal(body, &response); err != nil {
-				return fmt.Errorf("failed to unmarshal response: %w", err)
+			log.Info("Getting build logs", "commit_hash", commitHash)
+
+			logs, err := cli.BuildLogs(context.Background(), workingDir, commitHash)
+			if err != nil {
+				return fmt.Errorf("failed to get build logs: %w", err)
 			}
-			if response.Count == 0 {
+
+			fmt.Print(logs)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+// printBuildStatus prints the details of a build, including the failure log of failed builds
+func printBuildStatus(build *types.Build, now time.Time) {
+	fmt.Printf("📱 App Name: %s\n", build.AppName)
+	fmt.Printf("🔗 Commit Hash: %s\n", build.CommitHash)
+	fmt.Printf("📊 Status: %s\n", formatStatus(string(build.Status)))
+	if build.ImageTag != "" {
+		fmt.Printf("📦 Image Tag: %s\n", build.ImageTag)
+		fmt.Printf("📏 Size: %s\n", formatBytes(build.Size))
+	}
+	fmt.Printf("Created: %s\n", formatAge(build.CreatedAt, now))
+	if !build.FinishedAt.IsZero() {
+		fmt.Printf("Finished: %s\n", formatAge(build.FinishedAt, now))
+	}
+
+	if build.FailureLog != "" {
+		fmt.Printf("\n❌ Failure:\n")
+		for _, line := range strings.Split(build.FailureLog, "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+}
+
+// addListFlags adds the sorting and filtering flags shared by the list commands
+func addListFlags(cmd *cobra.Command, opts *types.ListOptions) {
+	cmd.Flags().StringVar(&opts.SortBy, "sort", "", "Sort by field (created_at, app_name)")
+	cmd.Flags().StringVar(&opts.Order, "order", "", "Sort order (asc, desc)")
+	cmd.Flags().StringVar(&opts.Status, "status", "", "Only list items with this status")
+	cmd.Flags().StringVar(&opts.Since, "since", "", "Only list items created within a duration (e.g. 1h, 24h) or after an RFC3339 timestamp")
+}
+
+func buildRmCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rm [id]",
+		Short: "Remove builds by app name or commit hash",
+		Long: `Remove builds by app name or commit hash. This will delete all builds that match the given app name or commit hash.
+A commit hash prefix of at least 4 characters is accepted when it matches a single build.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			cli, log, err := getCLI()
+			if err != nil {
+				return err
+			}
+			id := args[0]
+			log.Info("Removing builds", "id", id)
+
+			deleted, err := cli.DeleteBuilds(context.Background(), id)
+			if err != nil {
+				return fmt.Errorf("failed to delete builds: %w", err)
+			}
+			if len(deleted) == 0 {
 				fmt.Printf("No builds matched '%s'.\n", id)
 				return nil
 			}
-			fmt.Printf("Deleted %d build(s):\n", response.Count)
-			for _, key := range response.Deleted {
+			fmt.Printf("Deleted %d build(s):\n", len(deleted))
+			for _, key := range deleted {
 				fmt.Printf("- %s\n", key)
 			}
 			return nil
@@ -438,7 +865,23 @@ func buildRmCmd() *cobra.Command {
 	return cmd
 }
 
+// deleteDeployment deletes a single deployment and prints the outcome
+func deleteDeployment(c *cli.CLI, id string, ignoreMissing bool) error {
+	deleted, err := c.DeleteDeployment(context.Background(), id, ignoreMissing)
+	if err != nil {
+		return fmt.Errorf("failed to delete deployment: %w", err)
+	}
+	if !deleted {
+		fmt.Printf("Deployment %s not found, nothing to delete\n", id)
+		return nil
+	}
+	fmt.Printf("Deployment %s deleted successfully\n", id)
+	return nil
+}
+
 func deleteCmd() *cobra.Command {
+	var ignoreMissing bool
+
 	cmd := &cobra.Command{
 		Use:   "delete [deployment-id]",
 		Short: "Delete a deployment",
@@ -452,35 +895,32 @@ func deleteCmd() *cobra.Command {
 
 			id := args[0]
 			log.Info("Deleting deployment", "id", id)
-
-			if err := cli.DeleteDeployment(context.Background(), id); err != nil {
-				return fmt.Errorf("failed to delete deployment: %w", err)
-			}
-
-			fmt.Printf("Deployment %s deleted successfully\n", id)
-			return nil
+			return deleteDeployment(cli, id, ignoreMissing)
 		},
 	}
 
+	cmd.Flags().BoolVar(&ignoreMissing, "ignore-missing", false, "Succeed when the deployment does not exist")
+
 	return cmd
 }
 
 func statusCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "status [deployment-id]",
+		Use:   "status [app-name]",
 		Short: "Get deployment status",
-		Long:  `Get the status of a deployment by its ID.`,
-		Args:  cobra.ExactArgs(1),
+		Long: `Get the status of the deployment of an app. A deployment reported as in progress is still
+starting its containers in the background.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(_ *cobra.Command, args []string) error {
 			cli, log, err := getCLI()
 			if err != nil {
 				return err
 			}
 
-			id := args[0]
-			log.Info("Getting deployment status", "id", id)
+			appName := args[0]
+			log.Info("Getting deployment status", "app_name", appName)
 
-			deployment, err := cli.GetDeploymentStatus(context.Background(), id)
+			deployment, err := cli.GetDeploymentStatus(context.Background(), appName)
 			if err != nil {
 				return fmt.Errorf("failed to get deployment status: %w", err)
 			}
@@ -492,6 +932,18 @@ func statusCmd() *cobra.Command {
 			}
 
 			fmt.Println(string(data))
+			now := time.Now()
+			fmt.Printf("\nStatus: %s - %s\n", formatStatus(string(deployment.Status)), deployment.Message)
+			if deployment.InProgress {
+				fmt.Printf("The containers are still being started, check again in a few seconds.\n")
+			}
+			if deployment.CommitHash != "" {
+				fmt.Printf("Commit: %s %s (%s)\n", shortHash(deployment.CommitHash), deployment.CommitMessage,
+					deployment.Author)
+			}
+			fmt.Printf("Replicas: %s\n", formatReplicas(&deployment.Deployment))
+			fmt.Printf("Created: %s\n", formatAge(deployment.CreatedAt, now))
+			fmt.Printf("Updated: %s\n", formatAge(deployment.UpdatedAt, now))
 			return nil
 		},
 	}
@@ -512,18 +964,353 @@ func listCmd() *cobra.Command {
 
 			log.Info("Listing deployments")
 
-			deployments, err := cli.ListDeployments(context.Background())
+			deployments, err := cli.ListDeployments(context.Background(), nil)
 			if err != nil {
 				return fmt.Errorf("failed to list deployments: %w", err)
 			}
 
-			return printTableData(deployments, "deployments")
+			return printDeployments(deployments)
 		},
 	}
 
 	return cmd
 }
 
+func appsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apps [app-name]",
+		Short: "List apps or show the details of an app",
+		Long: `List the apps known to the Engine with their latest build and current deployment.
+When an app name is given, show its domains, environment, latest build and deployment.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			cli, log, err := getCLI()
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 1 {
+				log.Info("Getting app", "app_name", args[0])
+				app, err := cli.GetApp(context.Background(), args[0])
+				if err != nil {
+					return fmt.Errorf("failed to get app: %w", err)
+				}
+				printApp(app, time.Now())
+				return nil
+			}
+
+			log.Info("Listing apps")
+			apps, err := cli.ListApps(context.Background())
+			if err != nil {
+				return fmt.Errorf("failed to list apps: %w", err)
+			}
+			return printTable(appsTable(apps), "apps")
+		},
+	}
+
+	return cmd
+}
+
+// appsTable builds the table listing apps
+func appsTable(apps []*types.App) *table.Table {
+	t := table.New(
+		table.Column{Header: "APP NAME"},
+		table.Column{Header: "DOMAINS"},
+		table.Column{Header: "LATEST BUILD", MaxWidth: 12, Clip: true},
+		table.Column{Header: "BUILD STATUS", Style: statusStyle()},
+		table.Column{Header: "DEPLOYED COMMIT", MaxWidth: 12, Clip: true},
+		table.Column{Header: "DEPLOYMENT STATUS", Style: statusStyle()},
+		table.Column{Header: "REPLICAS", Align: table.AlignRight},
+		table.Column{Header: "BUILDS", Align: table.AlignRight},
+	)
+	for _, app := range apps {
+		buildCommit, buildStatus := "-", "-"
+		if app.LatestBuild != nil {
+			buildCommit, buildStatus = app.LatestBuild.CommitHash, string(app.LatestBuild.Status)
+		}
+		deployedCommit, deploymentStatus, replicas := "-", "-", "-"
+		if app.Deployment != nil {
+			deployedCommit, deploymentStatus = app.Deployment.CommitHash, string(app.Deployment.Status)
+			replicas = formatReplicas(app.Deployment)
+		}
+		t.AddRow(app.Name, strings.Join(app.Domains, ","), buildCommit, buildStatus, deployedCommit, deploymentStatus,
+			replicas, strconv.Itoa(app.Builds))
+	}
+	return t
+}
+
+// printApp prints the details of an app
+func printApp(app *types.App, now time.Time) {
+	fmt.Printf("📱 App Name: %s\n", app.Name)
+	fmt.Printf("🌐 Domains: %s\n", strings.Join(app.Domains, ", "))
+	fmt.Printf("Builds: %d\n", app.Builds)
+
+	if app.LatestBuild != nil {
+		fmt.Printf("\n🔨 Latest Build:\n")
+		fmt.Printf("  Commit Hash: %s\n", app.LatestBuild.CommitHash)
+		fmt.Printf("  Status: %s\n", formatStatus(string(app.LatestBuild.Status)))
+		fmt.Printf("  Created: %s\n", formatAge(app.LatestBuild.CreatedAt, now))
+	}
+
+	if app.Deployment != nil {
+		fmt.Printf("\n🚀 Deployment:\n")
+		fmt.Printf("  Commit Hash: %s\n", app.Deployment.CommitHash)
+		fmt.Printf("  Status: %s\n", formatStatus(string(app.Deployment.Status)))
+		fmt.Printf("  Replicas: %s\n", formatReplicas(app.Deployment))
+		for _, failure := range app.Deployment.FailedReplicas {
+			fmt.Printf("  ⚠️  Replica %d failed: %s\n", failure.Replica, failure.Error)
+		}
+		fmt.Printf("  Created: %s\n", formatAge(app.Deployment.CreatedAt, now))
+	}
+
+	if len(app.Env) > 0 {
+		fmt.Printf("\n🔧 Environment:\n")
+		keys := make([]string, 0, len(app.Env))
+		for key := range app.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("  %s=%s\n", key, app.Env[key])
+		}
+	}
+}
+
+func inspectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect [app-name] [container-id]",
+		Short: "Inspect a replica container of an app",
+		Long: `Show the Docker state of a replica container of an app deployment: its status, published ports,
+start time and restart count. The container ID may be abbreviated as long as it is unambiguous.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			cli, log, err := getCLI()
+			if err != nil {
+				return err
+			}
+
+			log.Info("Inspecting container", "app_name", args[0], "container_id", args[1])
+			details, err := cli.InspectContainer(context.Background(), args[0], args[1])
+			if err != nil {
+				return fmt.Errorf("failed to inspect container: %w", err)
+			}
+			printContainerDetails(details, time.Now())
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func containersCmd() *cobra.Command {
+	var orphaned bool
+
+	cmd := &cobra.Command{
+		Use:   "containers",
+		Short: "List the containers created by Nina",
+		Long: `List the containers created by Nina on the default Docker daemon and the nodes, stopped ones included,
+with the app they belong to. Containers that no deployment references are flagged as orphaned.`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cli, log, err := getCLI()
+			if err != nil {
+				return err
+			}
+
+			log.Info("Listing containers", "orphaned", orphaned)
+			containers, err := cli.ListContainers(context.Background())
+			if err != nil {
+				return fmt.Errorf("failed to list containers: %w", err)
+			}
+			if orphaned {
+				kept := containers[:0]
+				for _, c := range containers {
+					if c.Orphaned {
+						kept = append(kept, c)
+					}
+				}
+				containers = kept
+			}
+			return printTable(containersTable(containers, time.Now()), "containers")
+		},
+	}
+
+	cmd.Flags().BoolVar(&orphaned, "orphaned", false, "Only list containers that no deployment references")
+
+	return cmd
+}
+
+// containersTable builds the table listing the containers created by Nina
+func containersTable(containers []types.ManagedContainer, now time.Time) *table.Table {
+	t := table.New(
+		table.Column{Header: "CONTAINER ID", MaxWidth: 12, Clip: true},
+		table.Column{Header: "NAME"},
+		table.Column{Header: "APP NAME"},
+		table.Column{Header: "NODE"},
+		table.Column{Header: "STATE", Style: statusStyle()},
+		table.Column{Header: "CREATED"},
+		table.Column{Header: "ORPHANED"},
+	)
+	for _, c := range containers {
+		appName, node, orphaned := c.AppName, c.Node, "no"
+		if appName == "" {
+			appName = "-"
+		}
+		if node == "" {
+			node = "-"
+		}
+		if c.Orphaned {
+			orphaned = "yes"
+		}
+		t.AddRow(c.ContainerID, c.Name, appName, node, c.State, formatAge(c.CreatedAt, now), orphaned)
+	}
+	return t
+}
+
+// printContainerDetails prints the Docker state of a container
+func printContainerDetails(details *types.ContainerDetails, now time.Time) {
+	fmt.Printf("📦 Container: %s\n", details.ContainerID)
+	fmt.Printf("Name: %s\n", details.Name)
+	fmt.Printf("Image: %s\n", details.Image)
+	if details.Node != "" {
+		fmt.Printf("Node: %s\n", details.Node)
+	}
+	fmt.Printf("Status: %s\n", formatStatus(details.Status))
+	if details.Health != "" {
+		fmt.Printf("Health: %s\n", details.Health)
+	}
+	if details.Running {
+		fmt.Printf("Started: %s\n", formatAge(details.StartedAt, now))
+	} else {
+		fmt.Printf("Exit Code: %d\n", details.ExitCode)
+		fmt.Printf("Finished: %s\n", formatAge(details.FinishedAt, now))
+	}
+	if details.Error != "" {
+		fmt.Printf("Error: %s\n", details.Error)
+	}
+	fmt.Printf("Restart Count: %d\n", details.RestartCount)
+	fmt.Printf("Ports: %s\n", formatPortBindings(details.Ports))
+}
+
+// formatPortBindings formats published ports as host-ip:host-port->container-port
+func formatPortBindings(ports []types.PortBinding) string {
+	if len(ports) == 0 {
+		return "-"
+	}
+	formatted := make([]string, 0, len(ports))
+	for _, port := range ports {
+		formatted = append(formatted, fmt.Sprintf("%s:%s->%s", port.HostIP, port.HostPort, port.ContainerPort))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func statsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show aggregate Engine stats",
+		Long: `Show the number of apps, deployments and builds known to the Engine, the total size of the
+built images and the number of running app containers.`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cli, log, err := getCLI()
+			if err != nil {
+				return err
+			}
+
+			log.Info("Getting stats")
+			stats, err := cli.GetStats(context.Background())
+			if err != nil {
+				return fmt.Errorf("failed to get stats: %w", err)
+			}
+			printStats(stats)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func activityCmd() *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "activity",
+		Short: "Show recent build and deployment activity",
+		Long: `Show the most recent build and deployment status transitions kept in memory by the Engine,
+newest first.`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cli, log, err := getCLI()
+			if err != nil {
+				return err
+			}
+
+			log.Info("Listing activity", "limit", limit)
+			events, err := cli.ListActivity(context.Background(), limit)
+			if err != nil {
+				return fmt.Errorf("failed to list activity: %w", err)
+			}
+			return printTable(activityTable(events, time.Now()), "events")
+		},
+	}
+
+	cmd.Flags().IntVar(&limit, "limit", 50, "Number of events to show, up to 500")
+
+	return cmd
+}
+
+// activityTable builds the table listing recent build and deployment events
+func activityTable(events []types.ActivityEvent, now time.Time) *table.Table {
+	t := table.New(
+		table.Column{Header: "WHEN"},
+		table.Column{Header: "TYPE"},
+		table.Column{Header: "APP NAME"},
+		table.Column{Header: "COMMIT", MaxWidth: 8, Clip: true},
+		table.Column{Header: "STATUS", Style: statusStyle()},
+	)
+	for _, event := range events {
+		commit := event.CommitHash
+		if commit == "" {
+			commit = "-"
+		}
+		t.AddRow(formatAge(event.Time, now), string(event.Type), event.AppName, commit, event.Status)
+	}
+	return t
+}
+
+// printStats prints the aggregate Engine stats
+func printStats(stats *types.Stats) {
+	fmt.Printf("📱 Apps: %d\n", stats.Apps)
+	fmt.Printf("🚀 Deployments: %d%s\n", stats.Deployments, formatStatusCounts(stats.DeploymentsByStatus))
+	fmt.Printf("🔨 Builds: %d%s\n", stats.Builds, formatStatusCounts(stats.BuildsByStatus))
+	fmt.Printf("💾 Image Size: %s\n", formatBytes(stats.ImageBytes))
+	if stats.RunningContainers != nil {
+		fmt.Printf("📦 Running Containers: %d\n", *stats.RunningContainers)
+	} else {
+		fmt.Printf("📦 Running Containers: unknown\n")
+	}
+}
+
+// formatStatusCounts formats counts by status as " (status: count, ...)" sorted by status,
+// or an empty string when there are none
+func formatStatusCounts[S ~string](counts map[S]int) string {
+	statuses := make([]string, 0, len(counts))
+	for status, count := range counts {
+		if count > 0 {
+			statuses = append(statuses, string(status))
+		}
+	}
+	if len(statuses) == 0 {
+		return ""
+	}
+	sort.Strings(statuses)
+	formatted := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		formatted = append(formatted, fmt.Sprintf("%s: %d", status, counts[S(status)]))
+	}
+	return " (" + strings.Join(formatted, ", ") + ")"
+}
+
 func healthCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "health",
@@ -549,6 +1336,77 @@ func healthCmd() *cobra.Command {
 	return cmd
 }
 
+func migrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate stored records",
+		Long:  `Migrate records stored in Redis to their current format.`,
+	}
+
+	cmd.AddCommand(migrateDeploymentsCmd())
+
+	return cmd
+}
+
+func migrateDeploymentsCmd() *cobra.Command {
+	var deleteOld bool
+
+	cmd := &cobra.Command{
+		Use:   "deployments",
+		Short: "Migrate legacy deployment records",
+		Long: `Copy legacy deployment:* records to nina-deployment-* records.
+Deployments that were already migrated are skipped, so the command can be run more than once.`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, log, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			st, err := store.NewStore(cfg, log)
+			if err != nil {
+				return fmt.Errorf("failed to connect to Redis: %w", err)
+			}
+			defer st.Close() //nolint:errcheck
+
+			result, err := st.MigrateLegacyDeployments(context.Background(), deleteOld)
+			if err != nil {
+				return fmt.Errorf("failed to migrate deployments: %w", err)
+			}
+
+			fmt.Printf("Migrated: %d\n", result.Migrated)
+			fmt.Printf("Already migrated: %d\n", result.Skipped)
+			if deleteOld {
+				fmt.Printf("Legacy records deleted: %d\n", result.Deleted)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&deleteOld, "delete-old", false, "Delete legacy records after migrating them")
+
+	return cmd
+}
+
+// formatAge formats the time elapsed since t as a short relative age such as "3m ago"
+func formatAge(t, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	elapsed := now.Sub(t)
+	switch {
+	case elapsed < time.Second:
+		return "just now"
+	case elapsed < time.Minute:
+		return fmt.Sprintf("%ds ago", int(elapsed/time.Second))
+	case elapsed < time.Hour:
+		return fmt.Sprintf("%dm ago", int(elapsed/time.Minute))
+	case elapsed < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(elapsed/time.Hour))
+	default:
+		return fmt.Sprintf("%dd ago", int(elapsed/(24*time.Hour)))
+	}
+}
+
 // formatBytes formats bytes into a human-readable string
 func formatBytes(bytes int64) string {
 	const unit = 1024
@@ -1,8 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/cli"
+	"github.com/matiasinsaurralde/nina/pkg/types"
 )
 
 func TestFormatBytes(t *testing.T) {
@@ -31,6 +41,300 @@ func TestFormatBytes(t *testing.T) {
 	}
 }
 
+func TestFormatAge(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		t        time.Time
+		expected string
+	}{
+		{"zero time", time.Time{}, "-"},
+		{"just now", now, "just now"},
+		{"future", now.Add(time.Minute), "just now"},
+		{"seconds", now.Add(-45 * time.Second), "45s ago"},
+		{"minutes", now.Add(-3*time.Minute - 20*time.Second), "3m ago"},
+		{"hours", now.Add(-5*time.Hour - 59*time.Minute), "5h ago"},
+		{"days", now.Add(-50 * time.Hour), "2d ago"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := formatAge(tt.t, now)
+			if result != tt.expected {
+				t.Errorf("formatAge(%v) = %s, want %s", tt.t, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	if got := formatLabels(map[string]string{"team": "payments", "env": "prod"}); got != "env=prod,team=payments" {
+		t.Errorf("Expected labels sorted by key, got %q", got)
+	}
+}
+
+func TestFormatPortBindings(t *testing.T) {
+	if got := formatPortBindings(nil); got != "-" {
+		t.Errorf("Expected - without ports, got %q", got)
+	}
+	ports := []types.PortBinding{
+		{ContainerPort: "8080/tcp", HostIP: "0.0.0.0", HostPort: "32768"},
+		{ContainerPort: "8080/tcp", HostIP: "::", HostPort: "32768"},
+	}
+	if got := formatPortBindings(ports); got != "0.0.0.0:32768->8080/tcp, :::32768->8080/tcp" {
+		t.Errorf("Unexpected ports %q", got)
+	}
+}
+
+func TestFormatStatusCounts(t *testing.T) {
+	if got := formatStatusCounts(map[types.BuildStatus]int{}); got != "" {
+		t.Errorf("Expected no counts, got %q", got)
+	}
+	counts := map[types.BuildStatus]int{
+		types.BuildStatusFailed:   2,
+		types.BuildStatusBuilt:    5,
+		types.BuildStatusBuilding: 0,
+	}
+	if got := formatStatusCounts(counts); got != " (built: 5, failed: 2)" {
+		t.Errorf("Expected sorted counts, got %q", got)
+	}
+}
+
+func TestFormatReplicas(t *testing.T) {
+	containers := []types.Container{{ContainerID: "c1"}, {ContainerID: "c2"}}
+	tests := []struct {
+		name       string
+		deployment *types.Deployment
+		expected   string
+	}{
+		{"all healthy", &types.Deployment{Containers: containers, Replicas: 2}, "2/2"},
+		{"partially ready", &types.Deployment{Containers: containers[:1], Replicas: 3}, "1/3"},
+		{"none yet", &types.Deployment{Replicas: 2}, "0/2"},
+		{"no recorded replicas", &types.Deployment{Containers: containers}, "2/2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := formatReplicas(tt.deployment); result != tt.expected {
+				t.Errorf("formatReplicas() = %s, want %s", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDeploymentsTable(t *testing.T) {
+	now := time.Now()
+	deployments := []*types.Deployment{{
+		AppName:       "my-app",
+		CommitHash:    "0123456789abcdef",
+		Author:        "Jane Doe",
+		CommitMessage: "Fix a bug that only shows up when the commit message is very long",
+		Status:        types.DeploymentStatusPartiallyReady,
+		Containers:    []types.Container{{ContainerID: "c1"}},
+		Replicas:      2,
+		CreatedAt:     now.Add(-2 * time.Hour),
+	}}
+
+	var buf bytes.Buffer
+	if err := deploymentsTable(deployments, now).Render(&buf); err != nil {
+		t.Fatalf("Failed to render table: %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+
+	for _, header := range []string{"APP NAME", "COMMIT HASH", "STATUS", "REPLICAS", "CREATED"} {
+		if !strings.Contains(lines[0], header) {
+			t.Errorf("Expected header %q in %q", header, lines[0])
+		}
+	}
+	row := lines[2]
+	for _, value := range []string{"0123456789ab ", "Fix a bug that only shows up when the...", "partially_ready", "1/2", "2h"} {
+		if !strings.Contains(row, value) {
+			t.Errorf("Expected %q in row %q", value, row)
+		}
+	}
+	if strings.Contains(row, "0123456789abc") {
+		t.Errorf("Expected commit hash to be cut at 12 characters, got %q", row)
+	}
+}
+
+func TestBuildsTable(t *testing.T) {
+	builds := []*types.Build{{AppName: "my-app", CommitHash: "abc123", Status: types.BuildStatusBuilt}}
+
+	tbl := buildsTable(builds, time.Now())
+	if tbl.Len() != 1 {
+		t.Fatalf("Expected 1 row, got %d", tbl.Len())
+	}
+	var buf bytes.Buffer
+	if err := tbl.Render(&buf); err != nil {
+		t.Fatalf("Failed to render table: %v", err)
+	}
+	if strings.Contains(buf.String(), "REPLICAS") {
+		t.Errorf("Expected builds table without a replicas column, got %q", buf.String())
+	}
+}
+
+func TestBuildResultsTable(t *testing.T) {
+	results := []*cli.BuildResult{
+		{Dir: "/src/main", CommitHash: "abc123", Image: &types.DeploymentImage{ImageTag: "nina-my-app-abc123", Size: 2048}},
+		{Dir: "/src/feature", CommitHash: "def456", Err: errors.New("build failed")},
+	}
+
+	tbl := buildResultsTable(results)
+	if tbl.Len() != 2 {
+		t.Fatalf("Expected 2 rows, got %d", tbl.Len())
+	}
+	var buf bytes.Buffer
+	if err := tbl.Render(&buf); err != nil {
+		t.Fatalf("Failed to render table: %v", err)
+	}
+	for _, want := range []string{"nina-my-app-abc123", "2.0 KB", "failed", "build failed"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the table, got %q", want, buf.String())
+		}
+	}
+}
+
+func TestAppsTable(t *testing.T) {
+	apps := []*types.App{
+		{
+			Name:        "my-app",
+			Domains:     []string{"my-app"},
+			LatestBuild: &types.Build{CommitHash: "0123456789abcdef", Status: types.BuildStatusBuilt},
+			Deployment: &types.Deployment{
+				CommitHash: "0123456789abcdef",
+				Status:     types.DeploymentStatusReady,
+				Containers: []types.Container{{ContainerID: "c1"}},
+				Replicas:   1,
+			},
+			Builds: 3,
+		},
+		{Name: "worker", Domains: []string{"worker"}},
+	}
+
+	var buf bytes.Buffer
+	if err := appsTable(apps).Render(&buf); err != nil {
+		t.Fatalf("Failed to render table: %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	for _, value := range []string{"my-app", "0123456789ab ", "built", "ready", "1/1", "3"} {
+		if !strings.Contains(lines[2], value) {
+			t.Errorf("Expected %q in row %q", value, lines[2])
+		}
+	}
+	if !strings.Contains(lines[3], "worker") || !strings.Contains(lines[3], "-") {
+		t.Errorf("Expected placeholders for an app without builds or deployment, got %q", lines[3])
+	}
+}
+
+func TestContainersTable(t *testing.T) {
+	now := time.Now()
+	containers := []types.ManagedContainer{
+		{ContainerID: "0123456789abcdef", Name: "nina-my-app-1-42", AppName: "my-app", State: "running", CreatedAt: now},
+		{ContainerID: "fedcba9876543210", Name: "nina-old-1-7", Node: "worker-1", State: "exited", CreatedAt: now, Orphaned: true},
+	}
+
+	var buf bytes.Buffer
+	if err := containersTable(containers, now).Render(&buf); err != nil {
+		t.Fatalf("Failed to render table: %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	for _, value := range []string{"0123456789ab ", "nina-my-app-1-42", "my-app", "running", "no"} {
+		if !strings.Contains(lines[2], value) {
+			t.Errorf("Expected %q in row %q", value, lines[2])
+		}
+	}
+	for _, value := range []string{"nina-old-1-7", "worker-1", "exited", "yes"} {
+		if !strings.Contains(lines[3], value) {
+			t.Errorf("Expected %q in row %q", value, lines[3])
+		}
+	}
+}
+
+func TestActivityTable(t *testing.T) {
+	now := time.Now()
+	events := []types.ActivityEvent{
+		{Time: now.Add(-2 * time.Minute), Type: types.ActivityTypeDeployment, AppName: "my-app", CommitHash: "1a2b3c4d5e6f", Status: "ready"},
+		{Time: now.Add(-time.Hour), Type: types.ActivityTypeBuild, AppName: "other", Status: "failed"},
+	}
+
+	var buf bytes.Buffer
+	if err := activityTable(events, now).Render(&buf); err != nil {
+		t.Fatalf("Failed to render table: %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	for _, value := range []string{"2m ago", "deployment", "my-app", "1a2b3c4d ", "ready"} {
+		if !strings.Contains(lines[2], value) {
+			t.Errorf("Expected %q in row %q", value, lines[2])
+		}
+	}
+	for _, value := range []string{"1h ago", "build", "other", "failed"} {
+		if !strings.Contains(lines[3], value) {
+			t.Errorf("Expected %q in row %q", value, lines[3])
+		}
+	}
+}
+
+func TestFormatStatus_NoColor(t *testing.T) {
+	noColor = true
+	t.Cleanup(func() { noColor = false })
+
+	if got := formatStatus("failed"); got != "failed" {
+		t.Errorf("Expected plain status with --no-color, got %q", got)
+	}
+	if statusStyle() != nil {
+		t.Error("Expected no status column style with --no-color")
+	}
+}
+
+func TestFormatStatus_NoColorEnv(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	forceColor = true
+	t.Cleanup(func() { forceColor = false })
+
+	if got := formatStatus("ready"); got != "ready" {
+		t.Errorf("Expected NO_COLOR to win over --force-color, got %q", got)
+	}
+}
+
+func TestFormatStatus_ForceColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	forceColor = true
+	t.Cleanup(func() { forceColor = false })
+
+	if got := formatStatus("ready"); got != "\033[32mready\033[0m" {
+		t.Errorf("Expected ready in green with --force-color, got %q", got)
+	}
+}
+
+func TestParseBuildArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected map[string]string
+		wantErr  bool
+	}{
+		{"no args", nil, nil, false},
+		{"single arg", []string{"VERSION=1.2.3"}, map[string]string{"VERSION": "1.2.3"}, false},
+		{"empty value", []string{"FLAG="}, map[string]string{"FLAG": ""}, false},
+		{"value with equals", []string{"OPTS=a=b"}, map[string]string{"OPTS": "a=b"}, false},
+		{"last value wins", []string{"A=1", "A=2"}, map[string]string{"A": "2"}, false},
+		{"missing separator", []string{"VERSION"}, nil, true},
+		{"empty key", []string{"=value"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseBuildArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBuildArgs(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("parseBuildArgs(%v) = %v, want %v", tt.args, result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestCLIErrorHandling(t *testing.T) {
 	// Skip this test if not running integration tests
 	if testing.Short() {
@@ -82,3 +386,32 @@ func contains(s, substr string) bool {
 			len(s) > len(substr) && (s[:len(substr)] == substr ||
 				contains(s[1:], substr)))
 }
+
+func TestProjectDir(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if dir, err := projectDir(nil); err != nil || dir != cwd {
+		t.Errorf("Expected the working directory %s, got %q (%v)", cwd, dir, err)
+	}
+
+	root := t.TempDir()
+	service := filepath.Join(root, "services", "api")
+	if err := os.MkdirAll(service, 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if dir, err := projectDir([]string{service}); err != nil || dir != service {
+		t.Errorf("Expected %s, got %q (%v)", service, dir, err)
+	}
+
+	file := filepath.Join(root, "nina.yaml")
+	if err := os.WriteFile(file, []byte("replicas: 1\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, path := range []string{file, filepath.Join(root, "missing")} {
+		if _, err := projectDir([]string{path}); err == nil {
+			t.Errorf("Expected an error for %s", path)
+		}
+	}
+}
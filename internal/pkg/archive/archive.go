@@ -10,6 +10,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -134,53 +136,123 @@ func walkAndArchive(sourceDir string, tarWriter *tar.Writer) error {
 	return nil
 }
 
+// FileSize is the size of a file, relative to the directory it was found in
+type FileSize struct {
+	Path string
+	Size int64
+}
+
+// LargestFiles returns the n largest regular files in sourceDir, excluding the .git directory,
+// largest first.
+func LargestFiles(sourceDir string, n int) ([]FileSize, error) {
+	var files []FileSize
+	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to walk path %s: %w", path, err)
+		}
+		if info.IsDir() && info.Name() == gitDirName {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		files = append(files, FileSize{Path: relPath, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory: %w", err)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Size > files[j].Size })
+	if len(files) > n {
+		files = files[:n]
+	}
+	return files, nil
+}
+
+// ParseCompressionLevel converts a compression setting into a gzip level.
+// It accepts a level from 0 to 9 or one of "default", "none", "fastest" and "best".
+// An empty value selects the default level.
+func ParseCompressionLevel(value string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "default":
+		return gzip.DefaultCompression, nil
+	case "none":
+		return gzip.NoCompression, nil
+	case "fastest":
+		return gzip.BestSpeed, nil
+	case "best":
+		return gzip.BestCompression, nil
+	}
+
+	level, err := strconv.Atoi(value)
+	if err != nil || level < gzip.NoCompression || level > gzip.BestCompression {
+		return 0, fmt.Errorf("invalid compression level %q, expected 0-9, default, none, fastest or best", value)
+	}
+	return level, nil
+}
+
 // CreateGzippedTarBase64 creates a TAR archive of the given directory, compresses it with gzip,
 // and returns the Base64 encoded representation.
 func CreateGzippedTarBase64(sourceDir string) (string, error) {
+	return CreateGzippedTarBase64WithLevel(sourceDir, gzip.DefaultCompression)
+}
+
+// CreateGzippedTarBase64WithLevel is like CreateGzippedTarBase64 but compresses with the given gzip level.
+func CreateGzippedTarBase64WithLevel(sourceDir string, level int) (string, error) {
 	// Create a buffer to hold the TAR archive
 	var buf bytes.Buffer
+	if err := WriteGzippedTar(sourceDir, &buf, level); err != nil {
+		return "", err
+	}
+
+	// Encode to Base64
+	base64Data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	return base64Data, nil
+}
 
+// WriteGzippedTar writes a TAR archive of the given directory, compressed with the given gzip level, to w.
+func WriteGzippedTar(sourceDir string, w io.Writer, level int) error {
 	// Create a gzip writer
-	gzipWriter := gzip.NewWriter(&buf)
-	defer func() {
-		if err := gzipWriter.Close(); err != nil {
-			// Log error but don't fail the function
-			fmt.Printf("Warning: failed to close gzip writer: %v\n", err)
-		}
-	}()
+	gzipWriter, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return fmt.Errorf("failed to create gzip writer: %w", err)
+	}
 
 	// Create a TAR writer
 	tarWriter := tar.NewWriter(gzipWriter)
-	defer func() {
-		if err := tarWriter.Close(); err != nil {
-			// Log error but don't fail the function
-			fmt.Printf("Warning: failed to close tar writer: %v\n", err)
-		}
-	}()
 
 	// Walk through the source directory and archive files
 	if err := walkAndArchive(sourceDir, tarWriter); err != nil {
-		return "", fmt.Errorf("failed to walk directory: %w", err)
+		return fmt.Errorf("failed to walk directory: %w", err)
 	}
 
 	// Close the writers to ensure all data is written
 	if err := tarWriter.Close(); err != nil {
-		return "", fmt.Errorf("failed to close tar writer: %w", err)
+		return fmt.Errorf("failed to close tar writer: %w", err)
 	}
 	if err := gzipWriter.Close(); err != nil {
-		return "", fmt.Errorf("failed to close gzip writer: %w", err)
+		return fmt.Errorf("failed to close gzip writer: %w", err)
 	}
-
-	// Encode to Base64
-	base64Data := base64.StdEncoding.EncodeToString(buf.Bytes())
-	return base64Data, nil
+	return nil
 }
 
 // CreateTempDirAndCopy creates a temporary directory and copies all contents
 // from the current working directory to it, excluding the .git directory.
-func CreateTempDirAndCopy(sourceDir string) (string, error) {
+// The directory is created inside tempRoot, which is created if needed, or in the OS default when empty.
+func CreateTempDirAndCopy(sourceDir, tempRoot string) (string, error) {
+	if tempRoot != "" {
+		if err := os.MkdirAll(tempRoot, 0o750); err != nil {
+			return "", fmt.Errorf("failed to create temp root %s: %w", tempRoot, err)
+		}
+	}
+
 	// Create a temporary directory
-	tempDir, err := os.MkdirTemp("", "nina-build-*")
+	tempDir, err := os.MkdirTemp(tempRoot, "nina-build-*")
 	if err != nil {
 		return "", fmt.Errorf("failed to create temp directory: %w", err)
 	}
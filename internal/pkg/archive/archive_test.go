@@ -4,9 +4,12 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"encoding/base64"
+	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -151,7 +154,7 @@ func TestCreateTempDirAndCopy(t *testing.T) {
 	}
 
 	// Create temp directory and copy contents
-	tempDir, err := CreateTempDirAndCopy(sourceDir)
+	tempDir, err := CreateTempDirAndCopy(sourceDir, "")
 	if err != nil {
 		t.Fatalf("CreateTempDirAndCopy failed: %v", err)
 	}
@@ -188,6 +191,55 @@ func TestCreateTempDirAndCopy(t *testing.T) {
 	}
 }
 
+func TestLargestFiles(t *testing.T) {
+	sourceDir := t.TempDir()
+	files := map[string]int{
+		"small.txt":         10,
+		"assets/video.mp4":  300,
+		"data.bin":          200,
+		".git/objects/pack": 1000, // The .git directory is ignored
+	}
+	for path, size := range files {
+		fullPath := filepath.Join(sourceDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0o750); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(fullPath, make([]byte, size), 0o600); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	largest, err := LargestFiles(sourceDir, 2)
+	if err != nil {
+		t.Fatalf("LargestFiles failed: %v", err)
+	}
+	want := []FileSize{{Path: filepath.Join("assets", "video.mp4"), Size: 300}, {Path: "data.bin", Size: 200}}
+	if !reflect.DeepEqual(largest, want) {
+		t.Errorf("Expected %v, got %v", want, largest)
+	}
+}
+
+func TestCreateTempDirAndCopy_TempRoot(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte("package main"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// The configured root is created when it does not exist yet
+	tempRoot := filepath.Join(t.TempDir(), "nina", "tmp")
+	tempDir, err := CreateTempDirAndCopy(sourceDir, tempRoot)
+	if err != nil {
+		t.Fatalf("CreateTempDirAndCopy failed: %v", err)
+	}
+
+	if filepath.Dir(tempDir) != tempRoot {
+		t.Errorf("Expected temp directory inside %s, got %s", tempRoot, tempDir)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "main.go")); err != nil {
+		t.Errorf("Expected main.go to be copied: %v", err)
+	}
+}
+
 func TestCreateGzippedTarBase64WithEmptyDir(t *testing.T) {
 	// Create an empty temporary directory
 	testDir, err := os.MkdirTemp("", "test-empty-*")
@@ -218,3 +270,114 @@ func TestCreateGzippedTarBase64WithEmptyDir(t *testing.T) {
 		t.Fatalf("Failed to decode base64 data: %v", err)
 	}
 }
+
+func TestParseCompressionLevel(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+		wantErr  bool
+	}{
+		{"", gzip.DefaultCompression, false},
+		{"default", gzip.DefaultCompression, false},
+		{"none", gzip.NoCompression, false},
+		{"fastest", gzip.BestSpeed, false},
+		{"BEST", gzip.BestCompression, false},
+		{"0", 0, false},
+		{"6", 6, false},
+		{"10", 0, true},
+		{"-1", 0, true},
+		{"max", 0, true},
+	}
+
+	for _, tt := range tests {
+		level, err := ParseCompressionLevel(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Expected error for %q, got level %d", tt.value, level)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tt.value, err)
+			continue
+		}
+		if level != tt.expected {
+			t.Errorf("Expected level %d for %q, got %d", tt.expected, tt.value, level)
+		}
+	}
+}
+
+func TestCreateGzippedTarBase64WithLevel(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "main.go"), []byte(strings.Repeat("package main\n", 100)), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	fastest, err := CreateGzippedTarBase64WithLevel(testDir, gzip.NoCompression)
+	if err != nil {
+		t.Fatalf("Failed to create archive without compression: %v", err)
+	}
+	best, err := CreateGzippedTarBase64WithLevel(testDir, gzip.BestCompression)
+	if err != nil {
+		t.Fatalf("Failed to create archive with best compression: %v", err)
+	}
+	if len(best) >= len(fastest) {
+		t.Errorf("Expected best compression (%d) to be smaller than no compression (%d)", len(best), len(fastest))
+	}
+
+	if _, err := CreateGzippedTarBase64WithLevel(testDir, 42); err == nil {
+		t.Error("Expected error for invalid compression level")
+	}
+}
+
+// createBenchmarkProject writes a directory resembling a small Go project with some binary assets
+func createBenchmarkProject(b *testing.B) string {
+	b.Helper()
+	dir := b.TempDir()
+	source := strings.Repeat("func handler(w http.ResponseWriter, r *http.Request) {\n\tfmt.Fprintln(w, \"hello\")\n}\n", 200)
+	rng := rand.New(rand.NewSource(1)) //nolint:gosec
+	for i := 0; i < 50; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("pkg%d", i%5), fmt.Sprintf("file%d.go", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			b.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o600); err != nil {
+			b.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		asset := make([]byte, 64*1024)
+		rng.Read(asset)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("asset%d.bin", i)), asset, 0o600); err != nil {
+			b.Fatalf("Failed to write asset: %v", err)
+		}
+	}
+	return dir
+}
+
+func BenchmarkCreateGzippedTarBase64Levels(b *testing.B) {
+	dir := createBenchmarkProject(b)
+	levels := []struct {
+		name  string
+		level int
+	}{
+		{"none", gzip.NoCompression},
+		{"fastest", gzip.BestSpeed},
+		{"default", gzip.DefaultCompression},
+		{"best", gzip.BestCompression},
+	}
+
+	for _, l := range levels {
+		b.Run(l.name, func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				data, err := CreateGzippedTarBase64WithLevel(dir, l.level)
+				if err != nil {
+					b.Fatalf("Failed to create archive: %v", err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "bundle_bytes")
+		})
+	}
+}
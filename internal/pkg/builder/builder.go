@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/docker/docker/client"
 	"github.com/matiasinsaurralde/nina/pkg/config"
@@ -16,6 +17,21 @@ var availableBuildpacks = []Buildpack{
 	&BuildpackGolang{BaseBuildpack: &BaseBuildpack{}, name: "golang"},
 }
 
+// ErrBuildpackMismatch is returned when the buildpack named by a build request does not match its project
+var ErrBuildpackMismatch = errors.New("does not match this project")
+
+// ValidateBuildpack checks that name is one of the available buildpacks
+func ValidateBuildpack(name string) error {
+	names := make([]string, 0, len(availableBuildpacks))
+	for _, buildpack := range availableBuildpacks {
+		if buildpack.Name() == name {
+			return nil
+		}
+		names = append(names, buildpack.Name())
+	}
+	return fmt.Errorf("buildpack %q does not exist, expected one of %s", name, strings.Join(names, ", "))
+}
+
 // Builder is the interface that wraps the MatchBuildpack method.
 type Builder interface {
 	ExtractBundle(ctx context.Context, req *types.BuildRequest) (*Bundle, error)
@@ -38,6 +54,9 @@ type BaseBuilder struct {
 func (b *BaseBuilder) Init(ctx context.Context, cfg *config.Config, log *logger.Logger) error {
 	b.cfg = cfg
 	b.logger = log
+	if err := validateImageTagTemplate(cfg); err != nil {
+		return fmt.Errorf("invalid build.image_tag_template: %w", err)
+	}
 	b.buildpacks = make(map[string]Buildpack)
 	for _, buildpack := range availableBuildpacks {
 		if err := buildpack.SetConfig(ctx, cfg); err != nil {
@@ -53,7 +72,7 @@ func (b *BaseBuilder) Init(ctx context.Context, cfg *config.Config, log *logger.
 // ExtractBundle extracts a bundle from the given request.
 func (b *BaseBuilder) ExtractBundle(_ context.Context, req *types.BuildRequest) (*Bundle, error) {
 	b.logger.Info("Extracting bundle", "app_name", req.AppName, "commit_hash", req.CommitHash)
-	bundle, err := NewBundle(req, b.logger)
+	bundle, err := NewBundle(req, b.tempDir(), b.logger)
 	if err != nil {
 		b.logger.Error("Failed to extract bundle", "app_name", req.AppName, "error", err)
 		return nil, err
@@ -62,26 +81,73 @@ func (b *BaseBuilder) ExtractBundle(_ context.Context, req *types.BuildRequest)
 	return bundle, nil
 }
 
+// tempDir returns the directory bundles are extracted to, empty for the OS default
+func (b *BaseBuilder) tempDir() string {
+	if b.cfg == nil {
+		return ""
+	}
+	return b.cfg.Engine.TempDir
+}
+
 // MatchBuildpack matches the buildpack for the given request.
+// The bundle is extracted only for matching and removed again before returning.
 func (b *BaseBuilder) MatchBuildpack(ctx context.Context, req *types.BuildRequest) (Buildpack, error) {
-	var err error
-	var bundle *Bundle
-	bundle, err = b.ExtractBundle(ctx, req)
+	bundle, err := b.ExtractBundle(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	for name, buildpack := range availableBuildpacks {
+	defer func() {
+		if cleanupErr := bundle.Cleanup(); cleanupErr != nil {
+			b.logger.Warn("Failed to cleanup bundle used for matching", "app_name", req.AppName, "error", cleanupErr)
+		}
+	}()
+	if req.Buildpack != "" {
+		return b.lookupBuildpack(ctx, bundle, req.Buildpack)
+	}
+	return b.selectBuildpack(ctx, bundle, availableBuildpacks)
+}
+
+// lookupBuildpack returns the buildpack registered under the given name, skipping detection.
+// The buildpack must still match the bundle.
+func (b *BaseBuilder) lookupBuildpack(ctx context.Context, bundle *Bundle, name string) (Buildpack, error) {
+	buildpack, exists := b.buildpacks[name]
+	if !exists {
+		return nil, ValidateBuildpack(name)
+	}
+	isMatched, err := buildpack.Match(ctx, bundle)
+	if err != nil {
+		return nil, fmt.Errorf("buildpack %q %w: %w", name, ErrBuildpackMismatch, err)
+	}
+	if !isMatched {
+		return nil, fmt.Errorf("buildpack %q %w", name, ErrBuildpackMismatch)
+	}
+	b.logger.Info("Buildpack selected explicitly", "buildpack_name", name)
+	return buildpack, nil
+}
+
+// selectBuildpack returns the matching buildpack with the highest priority.
+// Ties are resolved in favor of the buildpack that appears first in the list.
+func (b *BaseBuilder) selectBuildpack(ctx context.Context, bundle *Bundle, buildpacks []Buildpack) (Buildpack, error) {
+	var selected Buildpack
+	for _, buildpack := range buildpacks {
 		isMatched, err := buildpack.Match(ctx, bundle)
 		if err != nil {
-			b.logger.Error("Failed to match buildpack", "buildpack_name", name, "error", err)
+			b.logger.Error("Failed to match buildpack", "buildpack_name", buildpack.Name(), "error", err)
 			continue
 		}
-		if isMatched {
-			b.logger.Info("Buildpack matched", "buildpack_name", name)
-			return buildpack, nil
+		if !isMatched {
+			continue
 		}
+		b.logger.Debug("Buildpack candidate matched", "buildpack_name", buildpack.Name(), "priority", buildpack.Priority())
+		if selected == nil || buildpack.Priority() > selected.Priority() {
+			selected = buildpack
+		}
+	}
+	if selected == nil {
+		return nil, errors.New("no buildpack matched")
 	}
-	return nil, errors.New("no buildpack matched")
+	b.logger.Info("Buildpack matched", "buildpack_name", selected.Name(), "priority", selected.Priority())
+	return selected, nil
 }
 
 // Build builds the application using the specified buildpack.
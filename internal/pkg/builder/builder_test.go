@@ -0,0 +1,275 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/go-connections/nat"
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBuildpack is a buildpack with a fixed match result and priority.
+type fakeBuildpack struct {
+	*BaseBuildpack
+	name     string
+	priority int
+	matched  bool
+	matchErr error
+}
+
+func (f *fakeBuildpack) Build(_ context.Context, _ *Bundle) (*types.DeploymentImage, error) {
+	return &types.DeploymentImage{}, nil
+}
+
+func (f *fakeBuildpack) Match(_ context.Context, _ *Bundle) (bool, error) {
+	return f.matched, f.matchErr
+}
+
+func (f *fakeBuildpack) Name() string { return f.name }
+
+func (f *fakeBuildpack) Priority() int { return f.priority }
+
+func TestBaseBuilder_SelectBuildpack(t *testing.T) {
+	b := &BaseBuilder{logger: logger.New(logger.LevelDebug, "text")}
+
+	tests := []struct {
+		name       string
+		buildpacks []Buildpack
+		expected   string
+		wantErr    bool
+	}{
+		{
+			name: "highest priority wins",
+			buildpacks: []Buildpack{
+				&fakeBuildpack{name: "low", priority: 1, matched: true},
+				&fakeBuildpack{name: "high", priority: 20, matched: true},
+				&fakeBuildpack{name: "mid", priority: 10, matched: true},
+			},
+			expected: "high",
+		},
+		{
+			name: "non matching buildpacks are ignored",
+			buildpacks: []Buildpack{
+				&fakeBuildpack{name: "high", priority: 20, matched: false},
+				&fakeBuildpack{name: "failing", priority: 30, matchErr: errors.New("boom")},
+				&fakeBuildpack{name: "low", priority: 1, matched: true},
+			},
+			expected: "low",
+		},
+		{
+			name: "ties keep the first buildpack",
+			buildpacks: []Buildpack{
+				&fakeBuildpack{name: "first", priority: 5, matched: true},
+				&fakeBuildpack{name: "second", priority: 5, matched: true},
+			},
+			expected: "first",
+		},
+		{
+			name: "no match",
+			buildpacks: []Buildpack{
+				&fakeBuildpack{name: "none", priority: 5, matched: false},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := b.selectBuildpack(context.Background(), &Bundle{}, tt.buildpacks)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, selected)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, selected.Name())
+		})
+	}
+}
+
+func TestDockerBuildArgs(t *testing.T) {
+	assert.Nil(t, dockerBuildArgs(nil))
+
+	buildArgs := dockerBuildArgs(map[string]string{"PORT": "9090", "VERSION": "1.0"})
+	assert.Len(t, buildArgs, 2)
+	assert.Equal(t, "9090", *buildArgs["PORT"])
+	assert.Equal(t, "1.0", *buildArgs["VERSION"])
+}
+
+func TestExposedTCPPorts(t *testing.T) {
+	assert.Nil(t, ExposedTCPPorts(nil))
+	assert.Nil(t, ExposedTCPPorts(&container.Config{}))
+
+	ports := ExposedTCPPorts(&container.Config{ExposedPorts: nat.PortSet{
+		"9090/tcp": struct{}{},
+		"3000/tcp": struct{}{},
+		"53/udp":   struct{}{},
+	}})
+	assert.Equal(t, []int{3000, 9090}, ports)
+}
+
+func TestBaseBuilder_LookupBuildpack(t *testing.T) {
+	b := &BaseBuilder{
+		logger: logger.New(logger.LevelDebug, "text"),
+		buildpacks: map[string]Buildpack{
+			"golang":     &fakeBuildpack{name: "golang", priority: 10, matched: true},
+			"dockerfile": &fakeBuildpack{name: "dockerfile", priority: 1, matched: true},
+			"static":     &fakeBuildpack{name: "static", priority: 1, matched: false},
+		},
+	}
+
+	// The named buildpack is used even when one with a higher priority matches
+	selected, err := b.lookupBuildpack(context.Background(), &Bundle{}, "dockerfile")
+	assert.NoError(t, err)
+	assert.Equal(t, "dockerfile", selected.Name())
+
+	_, err = b.lookupBuildpack(context.Background(), &Bundle{}, "missing")
+	assert.ErrorContains(t, err, "does not exist")
+
+	_, err = b.lookupBuildpack(context.Background(), &Bundle{}, "static")
+	assert.ErrorIs(t, err, ErrBuildpackMismatch)
+	assert.ErrorContains(t, err, `buildpack "static" does not match this project`)
+}
+
+func TestValidateBuildpack(t *testing.T) {
+	assert.NoError(t, ValidateBuildpack("golang"))
+	assert.EqualError(t, ValidateBuildpack("rust"), `buildpack "rust" does not exist, expected one of golang`)
+}
+
+func TestRetryBuild(t *testing.T) {
+	log := logger.New(logger.LevelDebug, "text")
+
+	// Transient failures are retried until the build succeeds
+	attempts := 0
+	imageID, err := retryBuild(context.Background(), log, 2, time.Millisecond, func() (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "", &BuildError{Err: errdefs.Unavailable(errors.New("daemon unavailable"))}
+		}
+		return "sha256:abc", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:abc", imageID)
+	assert.Equal(t, 3, attempts)
+
+	// The error of the last attempt is returned once the retries are used up
+	attempts = 0
+	_, err = retryBuild(context.Background(), log, 1, time.Millisecond, func() (string, error) {
+		attempts++
+		return "", fmt.Errorf("attempt %d failed: %w", attempts, syscall.ECONNRESET)
+	})
+	assert.EqualError(t, err, "attempt 2 failed: connection reset by peer")
+	assert.Equal(t, 2, attempts)
+
+	// Failures reported in the build output are not retried
+	attempts = 0
+	_, err = retryBuild(context.Background(), log, 5, time.Millisecond, func() (string, error) {
+		attempts++
+		return "", &BuildError{Err: &jsonmessage.JSONError{Code: 1, Message: "exit code 1"}, Log: []string{"undefined: foo"}}
+	})
+	assert.ErrorContains(t, err, "undefined: foo")
+	assert.Equal(t, 1, attempts)
+
+	// A cancelled context stops retrying
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts = 0
+	_, err = retryBuild(ctx, log, 5, time.Millisecond, func() (string, error) {
+		attempts++
+		return "", errdefs.Unavailable(errors.New("failed"))
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestIsTransientBuildError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"daemon unreachable", &BuildError{Err: fmt.Errorf("dial unix: %w", syscall.ECONNREFUSED)}, true},
+		{"daemon unavailable", &BuildError{Err: errdefs.Unavailable(errors.New("daemon is shutting down"))}, true},
+		{"daemon internal error", &BuildError{Err: errdefs.System(errors.New("layer store failure"))}, true},
+		{"stream cut", &BuildError{Err: io.ErrUnexpectedEOF}, true},
+		{"failing RUN step", &BuildError{Err: &jsonmessage.JSONError{Code: 1, Message: "exit code 1"}}, false},
+		{"compile error", &BuildError{
+			Err: &jsonmessage.JSONError{Code: 1, Message: "The command '/bin/sh -c go build' returned a non-zero code: 1"},
+			Log: []string{"./main.go:5:2: undefined: foo"},
+		}, false},
+		{"base image pull timeout", &BuildError{
+			Err: &jsonmessage.JSONError{Message: `Get "https://registry-1.docker.io/v2/": net/http: TLS handshake timeout`},
+			Log: []string{"Step 1/6 : FROM golang:1.24-alpine"},
+		}, true},
+		{"base image pull failure", &BuildError{
+			Err: &jsonmessage.JSONError{Message: "error pulling image configuration: download failed after attempts=6: EOF"},
+			Log: []string{"Step 1/6 : FROM golang:1.24-alpine"},
+		}, true},
+		{"base image not found", &BuildError{
+			Err: &jsonmessage.JSONError{Message: "manifest for golang:0.1 not found: manifest unknown"},
+			Log: []string{"Step 1/6 : FROM golang:0.1"},
+		}, false},
+		{"output captured", &BuildError{Err: errors.New("no image ID in build output"), Log: []string{"Step 1/4"}}, false},
+		{"invalid Dockerfile", &BuildError{Err: errdefs.InvalidParameter(errors.New("unknown instruction"))}, false},
+		{"missing main.go", errors.New("no main.go found"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.transient, isTransientBuildError(tt.err))
+		})
+	}
+}
+
+func TestBuildRetryPolicy(t *testing.T) {
+	b := &BaseBuildpack{}
+	retries, delay := b.buildRetryPolicy()
+	assert.Equal(t, 0, retries)
+	assert.Equal(t, defaultBuildRetryDelay, delay)
+
+	b.Config = &config.Config{Engine: config.EngineConfig{BuildRetries: 3, BuildRetryDelay: 5}}
+	retries, delay = b.buildRetryPolicy()
+	assert.Equal(t, 3, retries)
+	assert.Equal(t, 5*time.Second, delay)
+}
+
+func TestBuildLogTail(t *testing.T) {
+	output := strings.Join([]string{
+		`{"stream":"Step 1/3 : FROM golang:1.24-alpine AS builder\n"}`,
+		`{"stream":" ---> 1a2b3c4d\n"}`,
+		`{"stream":"Step 2/3 : RUN go build -o myapp\n"}`,
+		`{"stream":"\n"}`,
+		`{"stream":"./main.go:5:2: undefined: foo\n"}`,
+		`{"errorDetail":{"message":"The command '/bin/sh -c go build -o myapp' returned a non-zero code: 1"},` +
+			`"error":"The command '/bin/sh -c go build -o myapp' returned a non-zero code: 1"}`,
+	}, "\n")
+
+	lines := buildLogTail([]byte(output), 3)
+	assert.Equal(t, []string{
+		"Step 2/3 : RUN go build -o myapp",
+		"./main.go:5:2: undefined: foo",
+		"The command '/bin/sh -c go build -o myapp' returned a non-zero code: 1",
+	}, lines)
+
+	assert.Empty(t, buildLogTail(nil, 3))
+}
+
+func TestBuildError(t *testing.T) {
+	cause := errors.New("exit code 1")
+	err := &BuildError{Err: cause, Log: []string{"step 2", "undefined: foo"}}
+	assert.Equal(t, "failed to build Docker image: exit code 1\nstep 2\nundefined: foo", err.Error())
+	assert.ErrorIs(t, err, cause)
+
+	assert.Equal(t, "failed to build Docker image: exit code 1", (&BuildError{Err: cause}).Error())
+}
@@ -0,0 +1,286 @@
+package builder
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Headers the Docker daemon reads the description of a BuildKit session from
+const (
+	sessionIDHeader        = "X-Docker-Expose-Session-Uuid"
+	sessionNameHeader      = "X-Docker-Expose-Session-Name"
+	sessionSharedKeyHeader = "X-Docker-Expose-Session-Sharedkey"
+	sessionMethodHeader    = "X-Docker-Expose-Session-Grpc-Method"
+)
+
+// gRPC methods served to BuildKit over the session
+const (
+	getSecretMethod   = "/moby.buildkit.secrets.v1.Secrets/GetSecret"
+	healthCheckMethod = "/grpc.health.v1.Health/Check"
+)
+
+// gRPC status codes returned by the session
+const (
+	grpcOK            = 0
+	grpcInvalidArg    = 3
+	grpcNotFound      = 5
+	grpcUnimplemented = 12
+)
+
+// healthServing is the SERVING status of a gRPC health check response
+const healthServing = 1
+
+// buildkitTraceID is the ID of the build output messages carrying BuildKit progress
+const buildkitTraceID = "moby.buildkit.trace"
+
+// secretsSession serves build secrets to BuildKit. The daemon calls back into the session when a
+// RUN --mount=type=secret step runs, so the secrets are never part of the build context.
+type secretsSession struct {
+	secrets map[string][]byte
+}
+
+// startSecretsSession opens a BuildKit session on the Docker daemon serving secrets, returning its ID
+// for the build options and a function closing it once the build is over
+func startSecretsSession(
+	ctx context.Context,
+	dockerClient *client.Client,
+	secrets map[string][]byte,
+	log *logger.Logger,
+) (string, func(), error) {
+	var raw [16]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	id := hex.EncodeToString(raw[:])
+
+	conn, err := dockerClient.DialHijack(ctx, "/session", "h2c", map[string][]string{
+		sessionIDHeader:        {id},
+		sessionNameHeader:      {"nina"},
+		sessionSharedKeyHeader: {id},
+		sessionMethodHeader:    {getSecretMethod, healthCheckMethod},
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open build session: %w", err)
+	}
+
+	session := &secretsSession{secrets: secrets}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The daemon is the HTTP/2 client of the session
+		(&http2.Server{}).ServeConn(conn, &http2.ServeConnOpts{Context: context.WithoutCancel(ctx), Handler: session})
+	}()
+	log.Debug("Build session opened", "session_id", id)
+
+	return id, func() {
+		if closeErr := conn.Close(); closeErr != nil {
+			log.Debug("Failed to close build session", "error", closeErr)
+		}
+		<-done
+	}, nil
+}
+
+// ServeHTTP serves the unary gRPC calls BuildKit makes on the session
+func (s *secretsSession) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := readGRPCMessage(r.Body)
+	if err != nil {
+		writeGRPCResponse(w, nil, grpcInvalidArg, err.Error())
+		return
+	}
+
+	switch r.URL.Path {
+	case healthCheckMethod:
+		resp := protowire.AppendTag(nil, 1, protowire.VarintType)
+		writeGRPCResponse(w, protowire.AppendVarint(resp, healthServing), grpcOK, "")
+	case getSecretMethod:
+		name := secretRequestID(req)
+		value, ok := s.secrets[name]
+		if !ok {
+			writeGRPCResponse(w, nil, grpcNotFound, "secret "+name+" not found")
+			return
+		}
+		resp := protowire.AppendTag(nil, 1, protowire.BytesType)
+		writeGRPCResponse(w, protowire.AppendBytes(resp, value), grpcOK, "")
+	default:
+		writeGRPCResponse(w, nil, grpcUnimplemented, "unknown method "+r.URL.Path)
+	}
+}
+
+// readGRPCMessage reads a length-prefixed, uncompressed gRPC message
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+	if prefix[0] != 0 {
+		return nil, errors.New("compressed messages are not supported")
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+	return msg, nil
+}
+
+// writeGRPCResponse writes the response message of a unary gRPC call, if any, and its status
+func writeGRPCResponse(w http.ResponseWriter, msg []byte, code int, message string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	if msg != nil {
+		var prefix [5]byte
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg))) //nolint:gosec // secrets are far below 4GB
+		_, _ = w.Write(prefix[:])
+		_, _ = w.Write(msg)
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", message)
+	}
+}
+
+// secretRequestID returns the ID of the secret asked for by a GetSecretRequest
+func secretRequestID(req []byte) string {
+	var id string
+	forEachField(req, func(num protowire.Number, value []byte) {
+		if num == 1 {
+			id = string(value)
+		}
+	})
+	return id
+}
+
+// buildkitProgress writes the progress BuildKit reports in the build output as plain text
+type buildkitProgress struct {
+	w       io.Writer
+	started map[string]bool
+	failed  map[string]bool
+}
+
+// newBuildkitProgress returns a buildkitProgress writing to w
+func newBuildkitProgress(w io.Writer) *buildkitProgress {
+	return &buildkitProgress{w: w, started: map[string]bool{}, failed: map[string]bool{}}
+}
+
+// write writes the steps, their output and their errors from the aux payload of a trace message,
+// a base64 encoded StatusResponse
+func (p *buildkitProgress) write(aux *json.RawMessage) {
+	if aux == nil {
+		return
+	}
+	var status []byte
+	if err := json.Unmarshal(*aux, &status); err != nil {
+		return
+	}
+	forEachField(status, func(num protowire.Number, value []byte) {
+		switch num {
+		case 1: // vertexes
+			p.writeVertex(value)
+		case 3: // logs
+			forEachField(value, func(num protowire.Number, msg []byte) {
+				if num == 4 {
+					_, _ = p.w.Write(msg)
+				}
+			})
+		}
+	})
+}
+
+// writeVertex writes a build step once it has started, and its error if it failed
+func (p *buildkitProgress) writeVertex(vertex []byte) {
+	var digest, name, vertexErr string
+	var cached, started bool
+	forEachField(vertex, func(num protowire.Number, value []byte) {
+		switch num {
+		case 1:
+			digest = string(value)
+		case 3:
+			name = string(value)
+		case 4:
+			cached = len(value) > 0 && value[0] != 0
+		case 5:
+			started = true
+		case 7:
+			vertexErr = string(value)
+		}
+	})
+
+	if (started || cached) && !p.started[digest] {
+		p.started[digest] = true
+		prefix := "=> "
+		if cached {
+			prefix += "CACHED "
+		}
+		_, _ = fmt.Fprintln(p.w, prefix+name)
+	}
+	if vertexErr != "" && !p.failed[digest] {
+		p.failed[digest] = true
+		_, _ = fmt.Fprintf(p.w, "ERROR %s: %s\n", name, vertexErr)
+	}
+}
+
+// forEachField calls fn with the number and raw value of every length-delimited or varint field of msg.
+// Varint values are passed as a single byte, non-zero when set.
+func forEachField(msg []byte, fn func(num protowire.Number, value []byte)) {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return
+		}
+		msg = msg[n:]
+		switch typ {
+		case protowire.BytesType:
+			value, m := protowire.ConsumeBytes(msg)
+			if m < 0 {
+				return
+			}
+			fn(num, value)
+			msg = msg[m:]
+		case protowire.VarintType:
+			value, m := protowire.ConsumeVarint(msg)
+			if m < 0 {
+				return
+			}
+			var set byte
+			if value != 0 {
+				set = 1
+			}
+			fn(num, []byte{set})
+			msg = msg[m:]
+		default:
+			m := protowire.ConsumeFieldValue(num, typ, msg)
+			if m < 0 {
+				return
+			}
+			msg = msg[m:]
+		}
+	}
+}
+
+// buildkitLogTail returns the last n lines of the BuildKit progress, followed by the build output
+func buildkitLogTail(progress, output []byte, n int) []string {
+	var lines []string
+	for _, line := range strings.Split(string(progress), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	lines = append(lines, buildLogTail(output, n)...)
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
@@ -2,13 +2,56 @@
 package builder
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
 	"github.com/matiasinsaurralde/nina/pkg/types"
 )
 
+// maxBuildLogLines is the number of trailing build output lines kept when an image build fails.
+const maxBuildLogLines = 20
+
+// defaultBuildRetryDelay is the wait between image build attempts when none is configured.
+const defaultBuildRetryDelay = 2 * time.Second
+
+// removePartialImageTimeout bounds the removal of the image left behind by a cancelled build.
+const removePartialImageTimeout = 30 * time.Second
+
+// BuildError is returned when an image build fails, carrying the last lines of the build output.
+type BuildError struct {
+	Err error
+	Log []string
+}
+
+// Error implements the error interface, appending the captured build output.
+func (e *BuildError) Error() string {
+	msg := fmt.Sprintf("failed to build Docker image: %v", e.Err)
+	if len(e.Log) > 0 {
+		msg += "\n" + strings.Join(e.Log, "\n")
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
 // Buildpack defines the interface for buildpacks.
 type Buildpack interface {
 	// Build builds the project:
@@ -17,6 +60,9 @@ type Buildpack interface {
 	Match(ctx context.Context, bundle *Bundle) (bool, error)
 	// Name returns the name of the buildpack:
 	Name() string
+	// Priority returns the precedence of the buildpack when several of them match,
+	// higher values win:
+	Priority() int
 	SetConfig(ctx context.Context, cfg *config.Config) error
 	GetConfig() *config.Config
 	SetDockerClient(cli *client.Client)
@@ -49,3 +95,145 @@ func (b *BaseBuildpack) SetDockerClient(cli *client.Client) {
 func (b *BaseBuildpack) GetDockerClient() *client.Client {
 	return b.DockerClient
 }
+
+// buildRetryPolicy returns how many times a failed image build is retried and the wait between attempts.
+func (b *BaseBuildpack) buildRetryPolicy() (retries int, delay time.Duration) {
+	delay = defaultBuildRetryDelay
+	if b.Config == nil {
+		return 0, delay
+	}
+	if b.Config.Engine.BuildRetryDelay > 0 {
+		delay = time.Duration(b.Config.Engine.BuildRetryDelay) * time.Second
+	}
+	return max(b.Config.Engine.BuildRetries, 0), delay
+}
+
+// retryBuild runs build until it succeeds, fails with an error that is not transient, the retries are used up
+// or the context is done, returning the error of the last attempt.
+func retryBuild(ctx context.Context, log *logger.Logger, retries int, delay time.Duration,
+	build func() (string, error),
+) (string, error) {
+	for attempt := 0; ; attempt++ {
+		imageID, err := build()
+		if err == nil {
+			return imageID, nil
+		}
+		if attempt >= retries || ctx.Err() != nil || !isTransientBuildError(err) {
+			return "", err
+		}
+
+		log.Warn("Image build failed, retrying", "attempt", attempt+1, "retries", retries, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return "", err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Parts of the build output errors of network failures, such as a registry hiccup while the base image is pulled
+var transientBuildMessages = []string{
+	"tls handshake timeout", "i/o timeout", "connection reset", "connection refused", "unexpected eof", ": eof",
+	"no such host", "toomanyrequests", "too many requests", "service unavailable", "bad gateway", "gateway timeout",
+}
+
+// Parts of the build output errors of base image pulls, and of the pull failures that would fail again
+var (
+	pullBuildMessages          = []string{"pull", "manifest", "resolve source metadata", "registry"}
+	permanentPullBuildMessages = []string{"not found", "unknown", "denied", "unauthorized", "does not exist"}
+)
+
+// isTransientBuildError reports whether an image build failed because the Docker daemon or a registry could not be
+// reached, or the daemon failed internally. Other failures reported in the build output, such as a failing RUN step
+// or a compile error, would fail the same way again.
+func isTransientBuildError(err error) bool {
+	var jsonErr *jsonmessage.JSONError
+	if errors.As(err, &jsonErr) {
+		return isTransientBuildMessage(jsonErr.Message)
+	}
+	var buildErr *BuildError
+	if errors.As(err, &buildErr) && len(buildErr.Log) > 0 {
+		return false
+	}
+	if client.IsErrConnectionFailed(err) || errdefs.IsUnavailable(err) || errdefs.IsSystem(err) || errdefs.IsDeadline(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE)
+}
+
+// isTransientBuildMessage reports whether an error reported in the build output is a network failure, or a failed
+// pull of the base image that may succeed on another attempt
+func isTransientBuildMessage(message string) bool {
+	message = strings.ToLower(message)
+	containsAny := func(parts []string) bool {
+		for _, part := range parts {
+			if strings.Contains(message, part) {
+				return true
+			}
+		}
+		return false
+	}
+	return containsAny(transientBuildMessages) || (containsAny(pullBuildMessages) && !containsAny(permanentPullBuildMessages))
+}
+
+// buildLogTail returns the last meaningful lines of a Docker build output stream,
+// skipping blank lines and the intermediate layer bookkeeping Docker prints between steps.
+func buildLogTail(output []byte, n int) []string {
+	var lines []string
+	dec := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var m jsonmessage.JSONMessage
+		if err := dec.Decode(&m); err != nil {
+			break
+		}
+
+		text := m.Stream
+		if m.Error != nil {
+			text = m.Error.Message
+		} else if text == "" {
+			text = m.ErrorMessage
+		}
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "--->") || strings.HasPrefix(line, "Removing intermediate container") {
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// dockerBuildArgs converts the request build arguments into the format expected by the Docker API.
+func dockerBuildArgs(args map[string]string) map[string]*string {
+	if len(args) == 0 {
+		return nil
+	}
+	buildArgs := make(map[string]*string, len(args))
+	for key, value := range args {
+		buildArgs[key] = &value
+	}
+	return buildArgs
+}
+
+// ExposedTCPPorts returns the TCP ports declared with EXPOSE in an image config, in ascending order.
+func ExposedTCPPorts(cfg *container.Config) []int {
+	if cfg == nil || len(cfg.ExposedPorts) == 0 {
+		return nil
+	}
+	ports := make([]int, 0, len(cfg.ExposedPorts))
+	for port := range cfg.ExposedPorts {
+		if port.Proto() != "tcp" || port.Int() <= 0 {
+			continue
+		}
+		ports = append(ports, port.Int())
+	}
+	sort.Ints(ports)
+	return ports
+}
@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 
 	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/matiasinsaurralde/nina/pkg/logger"
@@ -25,6 +26,9 @@ type BuildpackGolang struct {
 	name string
 }
 
+// buildpackGolangPriority is the priority of the Golang buildpack.
+const buildpackGolangPriority = 10
+
 var buildpackGolangDockerfile = `
 # Build stage
 FROM golang:1.24-alpine AS builder
@@ -63,13 +67,14 @@ func (b *BuildpackGolang) findMainGoFile(tempDir string, log *logger.Logger) (st
 	return mainGoPath, nil
 }
 
-// createDockerfile creates the Dockerfile in the main directory
-func (b *BuildpackGolang) createDockerfile(mainDir string, log *logger.Logger) error {
+// createDockerfile creates the Dockerfile in the main directory, unless the project has its own
+func (b *BuildpackGolang) createDockerfile(mainDir string, secrets []string, log *logger.Logger) error {
 	dockerfilePath := filepath.Join(mainDir, "Dockerfile")
 	if _, statErr := os.Stat(dockerfilePath); statErr == nil {
-		log.Info("Overwriting existing Dockerfile", "path", dockerfilePath)
+		log.Info("Using the project's Dockerfile", "path", dockerfilePath)
+		return nil
 	}
-	writeErr := os.WriteFile(dockerfilePath, []byte(buildpackGolangDockerfile), 0o600)
+	writeErr := os.WriteFile(dockerfilePath, []byte(golangDockerfile(secrets)), 0o600)
 	if writeErr != nil {
 		log.Error("Failed to write Dockerfile", "error", writeErr)
 		return fmt.Errorf("failed to write Dockerfile: %w", writeErr)
@@ -79,7 +84,14 @@ func (b *BuildpackGolang) createDockerfile(mainDir string, log *logger.Logger) e
 }
 
 // buildDockerImage builds the Docker image
-func (b *BuildpackGolang) buildDockerImage(ctx context.Context, contextDir, imageTag string, log *logger.Logger) (string, error) {
+func (b *BuildpackGolang) buildDockerImage(
+	ctx context.Context,
+	contextDir, imageTag string,
+	buildArgs map[string]string,
+	secrets map[string][]byte,
+	output io.Writer,
+	log *logger.Logger,
+) (string, error) {
 	contextTar, err := archive.TarWithOptions(contextDir, &archive.TarOptions{})
 	if err != nil {
 		log.Error("Failed to create build context tar", "error", err)
@@ -93,39 +105,112 @@ func (b *BuildpackGolang) buildDockerImage(ctx context.Context, contextDir, imag
 
 	dockerClient := b.GetDockerClient()
 	buildOptions := dockertypes.ImageBuildOptions{
-		Tags:       []string{imageTag},
-		Dockerfile: "Dockerfile",
-		Remove:     true,
-		PullParent: true,
+		Tags:        []string{imageTag},
+		Dockerfile:  "Dockerfile",
+		Remove:      true,
+		ForceRemove: true,
+		PullParent:  true,
+		BuildArgs:   dockerBuildArgs(buildArgs),
+	}
+	// Secrets are only handed to BuildKit, over a session, for the RUN steps mounting them
+	if len(secrets) > 0 {
+		sessionID, closeSession, sessionErr := startSecretsSession(ctx, dockerClient, secrets, log)
+		if sessionErr != nil {
+			log.Error("Failed to open build session", "error", sessionErr)
+			return "", &BuildError{Err: sessionErr}
+		}
+		defer closeSession()
+		buildOptions.Version = dockertypes.BuilderBuildKit
+		buildOptions.SessionID = sessionID
 	}
+	// A rebuild may be tagging over an image in use, which must survive the cancellation of the rebuild
+	previousImageID := b.taggedImageID(ctx, imageTag)
 	resp, err := dockerClient.ImageBuild(ctx, contextTar, buildOptions)
 	if err != nil {
+		if ctx.Err() != nil {
+			// The daemon may already have started building when the request was cancelled
+			return "", b.cancelBuild(ctx, imageTag, previousImageID, log)
+		}
 		log.Error("Docker build failed", "error", err)
-		return "", fmt.Errorf("failed to build Docker image: %w", err)
+		return "", &BuildError{Err: err}
 	}
 	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
+		if closeErr := resp.Body.Close(); closeErr != nil && ctx.Err() == nil {
 			log.Error("Failed to close response body", "error", closeErr)
 		}
 	}()
+	// Closing the stream on cancellation unblocks the output loop, which makes the daemon stop the build
+	stopClosing := context.AfterFunc(ctx, func() { _ = resp.Body.Close() })
+	defer stopClosing()
 
 	// Read and log the build output
 	var buildOutput bytes.Buffer
 	tee := io.TeeReader(resp.Body, &buildOutput)
-	if displayErr := jsonmessage.DisplayJSONMessagesStream(tee, os.Stdout, 0, false, nil); displayErr != nil {
-		log.Error("Failed to display Docker build output", "error", displayErr)
+	display := io.MultiWriter(os.Stdout, output)
+	// BuildKit reports its progress in aux messages, which are written as plain text
+	var progress bytes.Buffer
+	trace := newBuildkitProgress(io.MultiWriter(display, &progress))
+	auxCallback := func(m jsonmessage.JSONMessage) {
+		if m.ID == buildkitTraceID {
+			trace.write(m.Aux)
+		}
+	}
+	if displayErr := jsonmessage.DisplayJSONMessagesStream(tee, display, 0, false, auxCallback); displayErr != nil {
+		if ctx.Err() != nil {
+			return "", b.cancelBuild(ctx, imageTag, previousImageID, log)
+		}
+		// Errors reported by the build itself, such as a failing RUN step, end up here
+		log.Error("Docker build failed", "error", displayErr)
+		return "", &BuildError{Err: displayErr, Log: buildkitLogTail(progress.Bytes(), buildOutput.Bytes(), maxBuildLogLines)}
 	}
 
 	// Parse the last line for image ID
+	logTail := buildkitLogTail(progress.Bytes(), buildOutput.Bytes(), maxBuildLogLines)
 	imageID := b.extractImageID(&buildOutput)
 	if imageID == "" {
 		log.Error("Failed to get image ID from build output")
-		return "", errors.New("failed to get image ID from build output")
+		return "", &BuildError{Err: errors.New("no image ID in build output"), Log: logTail}
 	}
 
 	return imageID, nil
 }
 
+// cancelBuild cleans up after a cancelled build and returns the error reporting the cancellation
+func (b *BuildpackGolang) cancelBuild(ctx context.Context, imageTag, previousImageID string, log *logger.Logger) error {
+	log.Warn("Docker build cancelled", "image_tag", imageTag, "error", ctx.Err())
+	b.removePartialImage(ctx, imageTag, previousImageID, log)
+	return fmt.Errorf("build cancelled: %w", ctx.Err())
+}
+
+// taggedImageID returns the ID of the image imageTag points to, empty when there is none
+func (b *BuildpackGolang) taggedImageID(ctx context.Context, imageTag string) string {
+	inspect, err := b.GetDockerClient().ImageInspect(ctx, imageTag)
+	if err != nil {
+		return ""
+	}
+	return inspect.ID
+}
+
+// removePartialImage removes the image of a cancelled build, in case the daemon tagged it before stopping.
+// The tag is left alone while it still points to previousImageID, the image it pointed to before the build.
+func (b *BuildpackGolang) removePartialImage(ctx context.Context, imageTag, previousImageID string, log *logger.Logger) {
+	removeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), removePartialImageTimeout)
+	defer cancel()
+	imageID := b.taggedImageID(removeCtx, imageTag)
+	if imageID == "" || imageID == previousImageID {
+		// Most of the time the build stopped before the image was tagged
+		log.Debug("No partial image to remove", "image_tag", imageTag, "image_id", imageID)
+		return
+	}
+	// Removing the tag only deletes the image when no other tag points to it
+	_, err := b.GetDockerClient().ImageRemove(removeCtx, imageTag, image.RemoveOptions{PruneChildren: true})
+	if err != nil {
+		log.Error("Failed to remove partial image", "image_tag", imageTag, "image_id", imageID, "error", err)
+		return
+	}
+	log.Info("Removed partial image", "image_tag", imageTag, "image_id", imageID)
+}
+
 // extractImageID extracts the image ID from the build output
 func (b *BuildpackGolang) extractImageID(buildOutput *bytes.Buffer) string {
 	var imageID string
@@ -158,15 +243,32 @@ func (b *BuildpackGolang) Build(ctx context.Context, bundle *Bundle) (*types.Dep
 	mainDir := filepath.Dir(mainGoPath)
 
 	// Create Dockerfile
-	if createErr := b.createDockerfile(mainDir, log); createErr != nil {
+	if createErr := b.createDockerfile(mainDir, request.Secrets, log); createErr != nil {
 		return nil, createErr
 	}
 
+	// Secrets are read once and kept out of the build context
+	var secrets map[string][]byte
+	if len(request.Secrets) > 0 {
+		var secretsErr error
+		secrets, secretsErr = loadBuildSecrets(b.GetConfig(), request.Secrets)
+		if secretsErr != nil {
+			return nil, secretsErr
+		}
+		log.Info("Build secrets loaded", "secrets", len(request.Secrets))
+	}
+
 	// Build image name
-	imageTag := fmt.Sprintf("nina-%s-%s", request.AppName, request.CommitHash)
+	imageTag, err := b.imageTag(request)
+	if err != nil {
+		return nil, err
+	}
 
 	// Build the image
-	imageID, buildErr := b.buildDockerImage(ctx, mainDir, imageTag, log)
+	retries, delay := b.buildRetryPolicy()
+	imageID, buildErr := retryBuild(ctx, log, retries, delay, func() (string, error) {
+		return b.buildDockerImage(ctx, mainDir, imageTag, request.BuildArgs, secrets, bundle.GetBuildOutput(), log)
+	})
 	if buildErr != nil {
 		return nil, buildErr
 	}
@@ -180,11 +282,13 @@ func (b *BuildpackGolang) Build(ctx context.Context, bundle *Bundle) (*types.Dep
 	}
 
 	deploymentImage := &types.DeploymentImage{
-		ImageTag: imageTag,
-		ImageID:  imageID,
-		Size:     imageInspect.Size,
+		ImageTag:     imageTag,
+		ImageID:      imageID,
+		Size:         imageInspect.Size,
+		ExposedPorts: ExposedTCPPorts(imageInspect.Config),
 	}
-	log.Info("Docker image built successfully", "image_tag", imageTag, "image_id", imageID, "size", imageInspect.Size)
+	log.Info("Docker image built successfully", "image_tag", imageTag, "image_id", imageID, "size", imageInspect.Size,
+		"exposed_ports", deploymentImage.ExposedPorts)
 	return deploymentImage, nil
 }
 
@@ -264,3 +368,8 @@ func (b *BuildpackGolang) Match(_ context.Context, bundle *Bundle) (bool, error)
 func (b *BuildpackGolang) Name() string {
 	return b.name
 }
+
+// Priority returns the priority of the buildpack.
+func (b *BuildpackGolang) Priority() int {
+	return buildpackGolangPriority
+}
@@ -3,10 +3,17 @@ package builder
 import (
 	"context"
 	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/docker/docker/client"
 	"github.com/matiasinsaurralde/nina/pkg/logger"
 	"github.com/matiasinsaurralde/nina/pkg/types"
 	"github.com/stretchr/testify/assert"
@@ -45,10 +52,123 @@ func TestBuildpackGolang_Match(t *testing.T) {
 
 	bundle, err := NewBundle(&types.BuildRequest{
 		BundleContents: bundleContents,
-	}, log)
+	}, "", log)
 	assert.NoError(t, err)
 
 	match, err := buildpack.Match(context.Background(), bundle)
 	assert.NoError(t, err)
 	assert.True(t, match)
 }
+
+func TestBuildpackGolang_Priority(t *testing.T) {
+	buildpack := &BuildpackGolang{
+		BaseBuildpack: &BaseBuildpack{},
+	}
+	assert.Equal(t, buildpackGolangPriority, buildpack.Priority())
+}
+
+// hangingBuildDocker is a Docker API whose image builds print one step and then hang until the client goes away
+type hangingBuildDocker struct {
+	mu      sync.Mutex
+	removed []string
+	started chan struct{}
+	// tags maps the tags of the daemon to image IDs
+	tags map[string]string
+	// partialImageID is the image ID a build tags before hanging, none when empty
+	partialImageID string
+}
+
+// ServeHTTP implements the subset of the Docker API used by the buildpack
+func (f *hangingBuildDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/build"):
+		_, _ = io.Copy(io.Discard, r.Body)
+		if f.partialImageID != "" {
+			f.mu.Lock()
+			f.tags[r.URL.Query().Get("t")] = f.partialImageID
+			f.mu.Unlock()
+		}
+		_, _ = io.WriteString(w, `{"stream":"Step 1/6 : FROM golang:1.24-alpine AS builder\n"}`+"\n")
+		w.(http.Flusher).Flush()
+		close(f.started)
+		<-r.Context().Done()
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/json") && strings.Contains(r.URL.Path, "/images/"):
+		name := strings.TrimSuffix(r.URL.Path[strings.Index(r.URL.Path, "/images/")+len("/images/"):], "/json")
+		f.mu.Lock()
+		id, ok := f.tags[name]
+		f.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"message":"No such image: `+name+`"}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"Id":"`+id+`"}`)
+	case r.Method == http.MethodDelete && strings.Contains(r.URL.Path, "/images/"):
+		name := r.URL.Path[strings.Index(r.URL.Path, "/images/")+len("/images/"):]
+		f.mu.Lock()
+		f.removed = append(f.removed, name)
+		delete(f.tags, name)
+		f.mu.Unlock()
+		_, _ = io.WriteString(w, `[{"Untagged":"`+name+`"}]`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"message":"not implemented"}`)
+	}
+}
+
+// removedImages returns the images removed so far
+func (f *hangingBuildDocker) removedImages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.removed...)
+}
+
+// cancelHangingBuild builds against fake and cancels the build once the daemon has started it
+func cancelHangingBuild(t *testing.T, fake *hangingBuildDocker) error {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(server.URL, "http://")),
+		client.WithHTTPClient(server.Client()))
+	assert.NoError(t, err)
+
+	buildpack := &BuildpackGolang{BaseBuildpack: &BaseBuildpack{DockerClient: cli}}
+	log := logger.New(logger.LevelDebug, "text")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-fake.started
+		cancel()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, buildErr := buildpack.buildDockerImage(ctx, t.TempDir(), "nina-app-abc123", nil, nil, io.Discard, log)
+		done <- buildErr
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Build did not stop after the context was cancelled")
+	}
+	return err
+}
+
+func TestBuildpackGolang_BuildDockerImageCancelled(t *testing.T) {
+	fake := &hangingBuildDocker{started: make(chan struct{}), tags: map[string]string{}, partialImageID: "sha256:partial"}
+	err := cancelHangingBuild(t, fake)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"nina-app-abc123"}, fake.removedImages())
+}
+
+func TestBuildpackGolang_BuildDockerImageCancelledKeepsLiveImage(t *testing.T) {
+	// The tag points to the image of a running deployment, and the rebuild is cancelled before retagging it
+	fake := &hangingBuildDocker{started: make(chan struct{}), tags: map[string]string{"nina-app-abc123": "sha256:live"}}
+	err := cancelHangingBuild(t, fake)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, fake.removedImages())
+	assert.Equal(t, "sha256:live", fake.tags["nina-app-abc123"])
+}
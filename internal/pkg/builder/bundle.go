@@ -4,7 +4,10 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -15,12 +18,16 @@ import (
 	"github.com/matiasinsaurralde/nina/pkg/types"
 )
 
+// ErrBundleChecksumMismatch is returned when a bundle does not match the checksum sent with the build request.
+var ErrBundleChecksumMismatch = errors.New("bundle checksum mismatch")
+
 // Bundle represents a bundle of contents.
 type Bundle struct {
 	Contents []byte
 	req      *types.BuildRequest
 	tempDir  string
 	logger   *logger.Logger
+	output   io.Writer
 }
 
 // GetTempDir returns the temporary directory where the bundle was extracted
@@ -38,6 +45,19 @@ func (b *Bundle) GetRequest() *types.BuildRequest {
 	return b.req
 }
 
+// SetBuildOutput sets the writer that receives the build output, in addition to stdout
+func (b *Bundle) SetBuildOutput(w io.Writer) {
+	b.output = w
+}
+
+// GetBuildOutput returns the writer that receives the build output
+func (b *Bundle) GetBuildOutput() io.Writer {
+	if b.output == nil {
+		return io.Discard
+	}
+	return b.output
+}
+
 // Cleanup removes the temporary directory and its contents
 func (b *Bundle) Cleanup() error {
 	if b.tempDir != "" {
@@ -64,9 +84,39 @@ func decodeBundleContents(req *types.BuildRequest, log *logger.Logger) ([]byte,
 	return contents, nil
 }
 
+// verifyBundleChecksum reads the gzipped bundle and compares its SHA-256 with the checksum of the request.
+// Requests without a checksum, sent by older clients, are not verified.
+func verifyBundleChecksum(bundle io.Reader, req *types.BuildRequest, log *logger.Logger) error {
+	if req.BundleChecksum == "" {
+		return nil
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, bundle); err != nil {
+		return fmt.Errorf("failed to read bundle for checksum: %w", err)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(checksum, req.BundleChecksum) {
+		log.Error("Bundle checksum mismatch", "app_name", req.AppName, "expected", req.BundleChecksum, "actual", checksum)
+		return fmt.Errorf("%w: expected %s, got %s", ErrBundleChecksumMismatch, req.BundleChecksum, checksum)
+	}
+	log.Info("Bundle checksum verified", "app_name", req.AppName, "checksum", checksum)
+	return nil
+}
+
+// openBundleFile opens the uploaded bundle file referenced by the request
+func openBundleFile(req *types.BuildRequest, log *logger.Logger) (*os.File, error) {
+	file, err := os.Open(req.BundlePath)
+	if err != nil {
+		log.Error("Failed to open bundle file", "app_name", req.AppName, "path", req.BundlePath, "error", err)
+		return nil, fmt.Errorf("failed to open bundle file: %w", err)
+	}
+	log.Info("Starting bundle extraction", "app_name", req.AppName, "bundle_path", req.BundlePath)
+	return file, nil
+}
+
 // createGzipReader creates a gzip reader for the bundle contents
-func createGzipReader(contents []byte, req *types.BuildRequest, log *logger.Logger) (*gzip.Reader, error) {
-	gz, err := gzip.NewReader(bytes.NewReader(contents))
+func createGzipReader(contents io.Reader, req *types.BuildRequest, log *logger.Logger) (*gzip.Reader, error) {
+	gz, err := gzip.NewReader(contents)
 	if err != nil {
 		log.Error("Failed to create gzip reader", "app_name", req.AppName, "error", err)
 		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
@@ -75,9 +125,10 @@ func createGzipReader(contents []byte, req *types.BuildRequest, log *logger.Logg
 	return gz, nil
 }
 
-// createTempDirectory creates a temporary directory for bundle extraction
-func createTempDirectory(req *types.BuildRequest, log *logger.Logger) (string, error) {
-	tempDir, err := os.MkdirTemp("", "nina-bundle")
+// createTempDirectory creates a temporary directory for bundle extraction inside tempRoot,
+// or in the OS default when it is empty
+func createTempDirectory(req *types.BuildRequest, tempRoot string, log *logger.Logger) (string, error) {
+	tempDir, err := os.MkdirTemp(tempRoot, "nina-bundle")
 	if err != nil {
 		log.Error("Failed to create temporary directory", "app_name", req.AppName, "error", err)
 		return "", fmt.Errorf("failed to create temporary directory: %w", err)
@@ -176,20 +227,45 @@ func extractTarContents(tarReader *tar.Reader, tempDir string, req *types.BuildR
 	return nil
 }
 
-// NewBundle creates a new bundle from the given request.
-func NewBundle(req *types.BuildRequest, log *logger.Logger) (bundle *Bundle, err error) {
+// NewBundle creates a new bundle from the given request, extracting it inside tempRoot.
+// An empty tempRoot uses the OS default temp directory.
+func NewBundle(req *types.BuildRequest, tempRoot string, log *logger.Logger) (bundle *Bundle, err error) {
 	bundle = &Bundle{
 		logger: log,
 	}
 
-	// Decode bundle contents
-	bundle.Contents, err = decodeBundleContents(req, log)
-	if err != nil {
-		return nil, err
+	// Read the bundle from the uploaded file when present, otherwise decode the inline contents
+	var source io.Reader
+	if req.BundlePath != "" {
+		file, openErr := openBundleFile(req, log)
+		if openErr != nil {
+			return nil, openErr
+		}
+		defer func() {
+			if closeErr := file.Close(); closeErr != nil {
+				log.Error("Failed to close bundle file", "app_name", req.AppName, "error", closeErr)
+			}
+		}()
+		if err = verifyBundleChecksum(file, req, log); err != nil {
+			return nil, err
+		}
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to rewind bundle file: %w", err)
+		}
+		source = file
+	} else {
+		bundle.Contents, err = decodeBundleContents(req, log)
+		if err != nil {
+			return nil, err
+		}
+		if err = verifyBundleChecksum(bytes.NewReader(bundle.Contents), req, log); err != nil {
+			return nil, err
+		}
+		source = bytes.NewReader(bundle.Contents)
 	}
 
 	// Create gzip reader
-	gz, err := createGzipReader(bundle.Contents, req, log)
+	gz, err := createGzipReader(source, req, log)
 	if err != nil {
 		return nil, err
 	}
@@ -199,15 +275,23 @@ func NewBundle(req *types.BuildRequest, log *logger.Logger) (bundle *Bundle, err
 		}
 	}()
 
-	// Create temporary directory
-	bundle.tempDir, err = createTempDirectory(req, log)
+	// Create temporary directory, removed again when the extraction fails
+	bundle.tempDir, err = createTempDirectory(req, tempRoot, log)
 	if err != nil {
 		return nil, err
 	}
+	extracted := bundle
+	defer func() {
+		if err != nil {
+			if cleanupErr := extracted.Cleanup(); cleanupErr != nil {
+				log.Error("Failed to cleanup bundle after extraction error", "app_name", req.AppName, "error", cleanupErr)
+			}
+		}
+	}()
 
 	// Extract tar contents
 	tarReader := tar.NewReader(gz)
-	if err := extractTarContents(tarReader, bundle.tempDir, req, log); err != nil {
+	if err = extractTarContents(tarReader, bundle.tempDir, req, log); err != nil {
 		return nil, err
 	}
 
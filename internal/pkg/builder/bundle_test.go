@@ -4,7 +4,12 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/matiasinsaurralde/nina/pkg/logger"
@@ -64,7 +69,7 @@ func TestNewBundleWithLogging(t *testing.T) { //nolint: funlen
 	}
 
 	// Test bundle extraction
-	bundle, err := NewBundle(req, log)
+	bundle, err := NewBundle(req, "", log)
 	if err != nil {
 		t.Fatalf("Failed to create bundle: %v", err)
 	}
@@ -88,3 +93,119 @@ func TestNewBundleWithLogging(t *testing.T) { //nolint: funlen
 		t.Errorf("Failed to cleanup bundle: %v", err)
 	}
 }
+
+func TestNewBundleFromFile(t *testing.T) {
+	log := logger.New(logger.LevelDebug, "text")
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	content := []byte("package main")
+	if err := tw.WriteHeader(&tar.Header{Name: "main.go", Mode: 0o644, Size: int64(len(content))}); err != nil {
+		t.Fatalf("Failed to write tar header: %v", err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatalf("Failed to write tar content: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("Failed to close gzip writer: %v", err)
+	}
+
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(bundlePath, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("Failed to write bundle file: %v", err)
+	}
+
+	// The bundle is extracted inside the given temp root
+	tempRoot := t.TempDir()
+	bundle, err := NewBundle(&types.BuildRequest{AppName: "test-app", CommitHash: "abc123", BundlePath: bundlePath}, tempRoot, log)
+	if err != nil {
+		t.Fatalf("Failed to create bundle: %v", err)
+	}
+	defer func() {
+		if err := bundle.Cleanup(); err != nil {
+			t.Logf("Failed to cleanup bundle: %v", err)
+		}
+	}()
+	if filepath.Dir(bundle.GetTempDir()) != tempRoot {
+		t.Errorf("Expected bundle to be extracted inside %s, got %s", tempRoot, bundle.GetTempDir())
+	}
+
+	extracted, err := os.ReadFile(filepath.Join(bundle.GetTempDir(), "main.go"))
+	if err != nil {
+		t.Fatalf("Failed to read extracted file: %v", err)
+	}
+	if string(extracted) != string(content) {
+		t.Errorf("Expected extracted content %q, got %q", content, extracted)
+	}
+
+	if _, err := NewBundle(&types.BuildRequest{AppName: "test-app", BundlePath: filepath.Join(t.TempDir(), "missing")}, "", log); err == nil {
+		t.Error("Expected error for missing bundle file")
+	}
+}
+
+func TestNewBundleChecksum(t *testing.T) {
+	log := logger.New(logger.LevelDebug, "text")
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	content := []byte("package main")
+	if err := tw.WriteHeader(&tar.Header{Name: "main.go", Mode: 0o644, Size: int64(len(content))}); err != nil {
+		t.Fatalf("Failed to write tar header: %v", err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatalf("Failed to write tar content: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("Failed to close gzip writer: %v", err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	checksum := hex.EncodeToString(sum[:])
+
+	// A matching checksum is accepted, for inline contents and uploaded files alike
+	bundle, err := NewBundle(&types.BuildRequest{
+		AppName:        "test-app",
+		BundleContents: base64.StdEncoding.EncodeToString(buf.Bytes()),
+		BundleChecksum: checksum,
+	}, t.TempDir(), log)
+	if err != nil {
+		t.Fatalf("Expected matching checksum to be accepted, got %v", err)
+	}
+	_ = bundle.Cleanup()
+
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(bundlePath, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("Failed to write bundle file: %v", err)
+	}
+	bundle, err = NewBundle(&types.BuildRequest{AppName: "test-app", BundlePath: bundlePath, BundleChecksum: checksum}, t.TempDir(), log)
+	if err != nil {
+		t.Fatalf("Expected matching checksum to be accepted, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(bundle.GetTempDir(), "main.go")); err != nil {
+		t.Errorf("Expected the verified file to be extracted from the start: %v", err)
+	}
+	_ = bundle.Cleanup()
+
+	// A corrupted payload is rejected before extraction
+	corrupted := bytes.Clone(buf.Bytes())
+	corrupted[len(corrupted)/2] ^= 0xff
+	tempRoot := t.TempDir()
+	_, err = NewBundle(&types.BuildRequest{
+		AppName:        "test-app",
+		BundleContents: base64.StdEncoding.EncodeToString(corrupted),
+		BundleChecksum: checksum,
+	}, tempRoot, log)
+	if !errors.Is(err, ErrBundleChecksumMismatch) {
+		t.Fatalf("Expected checksum mismatch, got %v", err)
+	}
+	if entries, _ := os.ReadDir(tempRoot); len(entries) != 0 {
+		t.Errorf("Expected nothing to be extracted, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,91 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ContentHash returns the hex encoded SHA-256 identifying what building the extracted bundle with the
+// named buildpack produces: the paths, modes and contents of its files along with the build arguments
+// and the names of the build secrets.
+// Unlike the bundle checksum it does not depend on archive metadata such as modification times,
+// so the same sources hash the same way whichever commit or app they are built for.
+func (b *Bundle) ContentHash(buildpack string) (string, error) {
+	if b.tempDir == "" {
+		return "", errors.New("bundle is not extracted")
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "buildpack\x00%s\n", buildpack)
+	if b.req != nil {
+		keys := make([]string, 0, len(b.req.BuildArgs))
+		for key := range b.req.BuildArgs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(hash, "arg\x00%s\x00%s\n", key, b.req.BuildArgs[key])
+		}
+		// Only the names of secrets are hashed, their values are never stored
+		secrets := append([]string{}, b.req.Secrets...)
+		sort.Strings(secrets)
+		for _, name := range secrets {
+			fmt.Fprintf(hash, "secret\x00%s\n", name)
+		}
+	}
+
+	// WalkDir visits entries in lexical order, so the hash does not depend on the file system
+	err := filepath.WalkDir(b.tempDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(b.tempDir, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		rel = filepath.ToSlash(rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", rel, err)
+		}
+		switch {
+		case entry.IsDir():
+			fmt.Fprintf(hash, "dir\x00%s\n", rel)
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("failed to read link %s: %w", rel, err)
+			}
+			fmt.Fprintf(hash, "link\x00%s\x00%s\n", rel, target)
+		case info.Mode().IsRegular():
+			fmt.Fprintf(hash, "file\x00%s\x00%o\x00%d\n", rel, info.Mode().Perm(), info.Size())
+			if err := hashFile(hash, path); err != nil {
+				return fmt.Errorf("failed to hash %s: %w", rel, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash bundle contents: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashFile writes the contents of the file at path to w
+func hashFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+	_, err = io.Copy(w, file)
+	return err
+}
@@ -0,0 +1,64 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// extractedBundle returns a bundle whose extracted contents are the given files
+func extractedBundle(t *testing.T, req *types.BuildRequest, files map[string]string) *Bundle {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	return &Bundle{req: req, tempDir: dir}
+}
+
+func TestBundleContentHash(t *testing.T) {
+	files := map[string]string{"main.go": "package main", "cmd/tool/main.go": "package main\n"}
+	req := &types.BuildRequest{AppName: "app", CommitHash: "abc123"}
+
+	hash, err := extractedBundle(t, req, files).ContentHash("golang")
+	if err != nil {
+		t.Fatalf("Failed to hash bundle: %v", err)
+	}
+
+	// Another commit of another app with the same sources, extracted at another time, hashes the same
+	other := extractedBundle(t, &types.BuildRequest{AppName: "other", CommitHash: "def456"}, files)
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(other.tempDir, "main.go"), past, past); err != nil {
+		t.Fatalf("Failed to change file times: %v", err)
+	}
+	if otherHash, err := other.ContentHash("golang"); err != nil || otherHash != hash {
+		t.Errorf("Expected identical contents to hash to %s, got %s (err %v)", hash, otherHash, err)
+	}
+
+	changed := map[string]string{"main.go": "package main // changed", "cmd/tool/main.go": "package main\n"}
+	argsReq := &types.BuildRequest{AppName: "app", CommitHash: "abc123", BuildArgs: map[string]string{"VERSION": "1.2.3"}}
+	for name, bundle := range map[string]*Bundle{
+		"changed file": extractedBundle(t, req, changed),
+		"build args":   extractedBundle(t, argsReq, files),
+	} {
+		if otherHash, err := bundle.ContentHash("golang"); err != nil || otherHash == hash {
+			t.Errorf("%s: expected a different hash, got %s (err %v)", name, otherHash, err)
+		}
+	}
+	if otherHash, _ := extractedBundle(t, req, files).ContentHash("other"); otherHash == hash {
+		t.Error("Expected the buildpack to be part of the hash")
+	}
+
+	if _, err := (&Bundle{}).ContentHash("golang"); err == nil {
+		t.Error("Expected an error for a bundle that is not extracted")
+	}
+}
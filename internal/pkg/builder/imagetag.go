@@ -0,0 +1,73 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// DefaultImageTagTemplate is the image tag template used when none is configured.
+const DefaultImageTagTemplate = "nina-{app}-{commit}"
+
+// shortCommitLength is the number of commit hash characters substituted for {short_commit}.
+const shortCommitLength = 7
+
+// imageTagTemplate returns the configured image tag template and registry.
+func imageTagTemplate(cfg *config.Config) (template, registry string) {
+	template = DefaultImageTagTemplate
+	if cfg == nil {
+		return template, ""
+	}
+	if cfg.Build.ImageTagTemplate != "" {
+		template = cfg.Build.ImageTagTemplate
+	}
+	return template, strings.TrimSuffix(cfg.Build.Registry, "/")
+}
+
+// renderImageTag substitutes the {app}, {commit}, {short_commit} and {registry} placeholders of template
+// and checks that the result is a valid Docker image reference. Without a registry "{registry}/" is dropped.
+func renderImageTag(template, registry string, req *types.BuildRequest) (string, error) {
+	shortCommit := req.CommitHash
+	if len(shortCommit) > shortCommitLength {
+		shortCommit = shortCommit[:shortCommitLength]
+	}
+	if registry == "" {
+		template = strings.ReplaceAll(template, "{registry}/", "")
+	}
+
+	tag := strings.NewReplacer(
+		"{app}", req.AppName,
+		"{commit}", req.CommitHash,
+		"{short_commit}", shortCommit,
+		"{registry}", registry,
+	).Replace(template)
+
+	if _, err := reference.ParseNormalizedNamed(tag); err != nil {
+		return "", fmt.Errorf("image tag %q from template %q is not a valid image reference: %w", tag, template, err)
+	}
+	return tag, nil
+}
+
+// validateImageTagTemplate checks that the configured image tag template renders a valid image reference.
+func validateImageTagTemplate(cfg *config.Config) error {
+	template, registry := imageTagTemplate(cfg)
+	sample := &types.BuildRequest{AppName: "app", CommitHash: "0123456789abcdef0123456789abcdef01234567"}
+	if _, err := renderImageTag(template, registry, sample); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ImageTag returns the tag of the image built for the request with the configured image tag template.
+func ImageTag(cfg *config.Config, req *types.BuildRequest) (string, error) {
+	template, registry := imageTagTemplate(cfg)
+	return renderImageTag(template, registry, req)
+}
+
+// imageTag returns the tag of the image built for the request.
+func (b *BaseBuildpack) imageTag(req *types.BuildRequest) (string, error) {
+	return ImageTag(b.Config, req)
+}
@@ -0,0 +1,52 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderImageTag(t *testing.T) {
+	req := &types.BuildRequest{AppName: "my-app", CommitHash: "0123456789abcdef"}
+
+	tests := []struct {
+		name     string
+		template string
+		registry string
+		expected string
+	}{
+		{"default", DefaultImageTagTemplate, "", "nina-my-app-0123456789abcdef"},
+		{"short commit as tag", "{app}:{short_commit}", "", "my-app:0123456"},
+		{"registry", "{registry}/{app}:{short_commit}", "registry.example.com/team", "registry.example.com/team/my-app:0123456"},
+		{"registry omitted when unset", "{registry}/{app}:{commit}", "", "my-app:0123456789abcdef"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tag, err := renderImageTag(tt.template, tt.registry, req)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, tag)
+		})
+	}
+}
+
+func TestRenderImageTag_Invalid(t *testing.T) {
+	req := &types.BuildRequest{AppName: "my-app", CommitHash: "0123456789abcdef"}
+
+	for _, template := range []string{"{app} {commit}", "Nina-{app}", "{app}:{commit}:{commit}", "{app}:"} {
+		_, err := renderImageTag(template, "", req)
+		assert.Error(t, err, template)
+	}
+}
+
+func TestValidateImageTagTemplate(t *testing.T) {
+	assert.NoError(t, validateImageTagTemplate(nil))
+	assert.NoError(t, validateImageTagTemplate(&config.Config{}))
+
+	cfg := &config.Config{Build: config.BuildConfig{ImageTagTemplate: "{registry}/{app}:{short_commit}", Registry: "ghcr.io/acme/"}}
+	assert.NoError(t, validateImageTagTemplate(cfg))
+
+	cfg.Build.ImageTagTemplate = "{app}/{unknown}"
+	assert.Error(t, validateImageTagTemplate(cfg))
+}
@@ -0,0 +1,66 @@
+package builder
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/matiasinsaurralde/nina/pkg/config"
+)
+
+const (
+	// BuildSecretsPath is where BuildKit mounts the secrets of a build
+	BuildSecretsPath = "/run/secrets"
+	// netrcSecret is the secret Go reads credentials for private modules from
+	netrcSecret = "netrc"
+)
+
+// buildSecretNamePattern matches valid build secret names; config keys are case-insensitive, so names are lowercase
+var buildSecretNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// ValidateBuildSecret checks that name is a valid build secret name configured in the Engine's secret store
+func ValidateBuildSecret(cfg *config.Config, name string) error {
+	if !buildSecretNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid build secret name %q, it must be lowercase letters, digits, '.', '_' or '-'", name)
+	}
+	if cfg == nil || cfg.Build.Secrets[name] == "" {
+		return fmt.Errorf("unknown build secret %q", name)
+	}
+	return nil
+}
+
+// loadBuildSecrets reads the named secrets from the Engine's secret store, keyed by name
+func loadBuildSecrets(cfg *config.Config, names []string) (map[string][]byte, error) {
+	secrets := make(map[string][]byte, len(names))
+	for _, name := range names {
+		if err := ValidateBuildSecret(cfg, name); err != nil {
+			return nil, err
+		}
+		value, err := os.ReadFile(cfg.Build.Secrets[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read build secret %q: %w", name, err)
+		}
+		secrets[name] = value
+	}
+	return secrets, nil
+}
+
+// golangDockerfile returns the Dockerfile of the Golang buildpack. Build secrets are BuildKit secret
+// mounts of the go build step, so they are neither part of the build context nor of any layer, and the
+// final stage only copies the binary.
+func golangDockerfile(secrets []string) string {
+	if len(secrets) == 0 {
+		return buildpackGolangDockerfile
+	}
+	var step strings.Builder
+	step.WriteString("RUN")
+	for _, name := range secrets {
+		fmt.Fprintf(&step, " --mount=type=secret,id=%s", name)
+	}
+	if slices.Contains(secrets, netrcSecret) {
+		fmt.Fprintf(&step, " NETRC=%s/%s", BuildSecretsPath, netrcSecret)
+	}
+	return strings.Replace(buildpackGolangDockerfile, "RUN go build", step.String()+" go build", 1)
+}
@@ -0,0 +1,304 @@
+package builder
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestGolangDockerfile_Secrets(t *testing.T) {
+	if golangDockerfile(nil) != buildpackGolangDockerfile {
+		t.Error("Expected builds without secrets to use the plain Dockerfile")
+	}
+
+	dockerfile := golangDockerfile([]string{"netrc", "token"})
+	lastStage := strings.LastIndex(dockerfile, "\nFROM ")
+	buildStage, finalStage := dockerfile[:lastStage], dockerfile[lastStage:]
+
+	// The secrets are mounted for the go build step only, and NETRC points Go at the netrc secret
+	step := "RUN --mount=type=secret,id=netrc --mount=type=secret,id=token NETRC=" + BuildSecretsPath + "/netrc go build"
+	if !strings.Contains(buildStage, step) {
+		t.Errorf("Expected the go build step to mount the secrets, got:\n%s", buildStage)
+	}
+
+	// The final image only receives the binary, so no secret nor the steps handling them end up in it
+	for _, leak := range []string{"--mount", BuildSecretsPath, "NETRC", "COPY . "} {
+		if strings.Contains(finalStage, leak) {
+			t.Errorf("Expected the final stage not to reference %q, got:\n%s", leak, finalStage)
+		}
+	}
+	if !strings.Contains(finalStage, "COPY --from=builder /app/myapp /myapp") {
+		t.Errorf("Expected the final stage to copy the binary only, got:\n%s", finalStage)
+	}
+
+	if strings.Contains(golangDockerfile([]string{"token"}), "NETRC") {
+		t.Error("Expected NETRC to be set only for the netrc secret")
+	}
+}
+
+func TestLoadBuildSecrets(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(secretFile, []byte("s3cr3t"), 0o600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+	cfg := &config.Config{Build: config.BuildConfig{Secrets: map[string]string{
+		"token":   secretFile,
+		"missing": filepath.Join(t.TempDir(), "missing"),
+	}}}
+
+	secrets, err := loadBuildSecrets(cfg, []string{"token"})
+	if err != nil {
+		t.Fatalf("Failed to load secrets: %v", err)
+	}
+	if string(secrets["token"]) != "s3cr3t" {
+		t.Errorf("Expected the secret value, got %q", secrets["token"])
+	}
+
+	// Unknown, invalid and unreadable secrets fail the build
+	for _, name := range []string{"unknown", "../token", "missing"} {
+		if _, err := loadBuildSecrets(cfg, []string{"token", name}); err == nil {
+			t.Errorf("Expected an error for secret %q", name)
+		}
+	}
+}
+
+func TestBuildpackGolang_CreateDockerfileKeepsProjectDockerfile(t *testing.T) {
+	dir := t.TempDir()
+	own := "FROM busybox\nRUN --mount=type=secret,id=token true\n"
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(own), 0o600); err != nil {
+		t.Fatalf("Failed to write Dockerfile: %v", err)
+	}
+
+	buildpack := &BuildpackGolang{BaseBuildpack: &BaseBuildpack{}}
+	if err := buildpack.createDockerfile(dir, []string{"token"}, logger.New(logger.LevelDebug, "text")); err != nil {
+		t.Fatalf("Failed to create Dockerfile: %v", err)
+	}
+	if dockerfile, _ := os.ReadFile(filepath.Join(dir, "Dockerfile")); string(dockerfile) != own {
+		t.Errorf("Expected the project's Dockerfile to be kept, got:\n%s", dockerfile)
+	}
+}
+
+// secretMountPattern matches the secret mounts of a Dockerfile
+var secretMountPattern = regexp.MustCompile(`--mount=type=secret,id=([a-z0-9_.-]+)`)
+
+// buildkitDocker is a Docker API building images like BuildKit does: secret mounts are fetched from the
+// client's session while the build runs, and the image is made of the files of the build context
+type buildkitDocker struct {
+	t        *testing.T
+	mu       sync.Mutex
+	sessions map[string]*http2.ClientConn
+	query    map[string]string
+	fetched  map[string]string
+	image    []byte
+}
+
+// ServeHTTP implements the subset of the Docker API used by the buildpack
+func (f *buildkitDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/session":
+		f.openSession(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/build"):
+		f.build(w, r)
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/images/get"):
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		_, _ = w.Write(f.image)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"message":"not implemented"}`)
+	}
+}
+
+// openSession upgrades the request to the session connection, on which the daemon is the HTTP/2 client
+func (f *buildkitDocker) openSession(w http.ResponseWriter, r *http.Request) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		f.t.Errorf("Failed to hijack session: %v", err)
+		return
+	}
+	_, _ = io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\n")
+	cc, err := (&http2.Transport{AllowHTTP: true}).NewClientConn(conn)
+	if err != nil {
+		f.t.Errorf("Failed to start session client: %v", err)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions[r.Header.Get(sessionIDHeader)] = cc
+}
+
+// build builds the uploaded context, fetching the secrets mounted by its Dockerfile from the session
+func (f *buildkitDocker) build(w http.ResponseWriter, r *http.Request) {
+	var image bytes.Buffer
+	imageTar := tar.NewWriter(&image)
+	var dockerfile []byte
+	contextTar := tar.NewReader(r.Body)
+	for {
+		header, err := contextTar.Next()
+		if err != nil {
+			break
+		}
+		content, _ := io.ReadAll(contextTar)
+		if header.Name == "Dockerfile" {
+			dockerfile = content
+		}
+		_ = imageTar.WriteHeader(header)
+		_, _ = imageTar.Write(content)
+	}
+	_ = imageTar.Close()
+
+	f.mu.Lock()
+	f.query = map[string]string{"version": r.URL.Query().Get("version"), "session": r.URL.Query().Get("session")}
+	session := f.sessions[r.URL.Query().Get("session")]
+	f.image = image.Bytes()
+	f.mu.Unlock()
+
+	for _, mount := range secretMountPattern.FindAllStringSubmatch(string(dockerfile), -1) {
+		value := "secret unavailable"
+		if session != nil {
+			value = getSessionSecret(f.t, session, mount[1])
+		}
+		f.mu.Lock()
+		f.fetched[mount[1]] = value
+		f.mu.Unlock()
+	}
+
+	vertex := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "sha256:step")
+	vertex = protowire.AppendString(protowire.AppendTag(vertex, 3, protowire.BytesType), "[builder 4/4] RUN go build")
+	vertex = protowire.AppendBytes(protowire.AppendTag(vertex, 5, protowire.BytesType), nil)
+	log := protowire.AppendBytes(protowire.AppendTag(nil, 4, protowire.BytesType), []byte("go: downloading private module\n"))
+	status := protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), vertex)
+	status = protowire.AppendBytes(protowire.AppendTag(status, 3, protowire.BytesType), log)
+	trace, _ := json.Marshal(map[string]any{"id": buildkitTraceID, "aux": status})
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(append(trace, '\n'))
+	_, _ = io.WriteString(w, `{"id":"moby.image.id","aux":{"ID":"sha256:built"}}`+"\n")
+}
+
+// getSessionSecret calls GetSecret on a session, returning the secret or the gRPC status of the call
+func getSessionSecret(t *testing.T, session *http2.ClientConn, id string) string {
+	msg := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), id)
+	body := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg))) //nolint:gosec // short test message
+	req, _ := http.NewRequest(http.MethodPost, "http://session"+getSecretMethod, bytes.NewReader(append(body, msg...)))
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := session.RoundTrip(req)
+	if err != nil {
+		t.Errorf("Failed to get secret %s: %v", id, err)
+		return ""
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Errorf("Failed to read secret %s: %v", id, err)
+		return ""
+	}
+	if code := resp.Trailer.Get("Grpc-Status"); code != "0" {
+		return "status " + code
+	}
+	secret, err := readGRPCMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Errorf("Failed to decode secret %s: %v", id, err)
+		return ""
+	}
+	var value string
+	forEachField(secret, func(num protowire.Number, field []byte) {
+		if num == 1 {
+			value = string(field)
+		}
+	})
+	return value
+}
+
+func TestBuildpackGolang_BuildDockerImageSecrets(t *testing.T) {
+	fake := &buildkitDocker{t: t, sessions: map[string]*http2.ClientConn{}, fetched: map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(server.URL, "http://")),
+		client.WithHTTPClient(server.Client()))
+	assert.NoError(t, err)
+
+	log := logger.New(logger.LevelDebug, "text")
+	buildpack := &BuildpackGolang{BaseBuildpack: &BaseBuildpack{DockerClient: cli}}
+	contextDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(contextDir, "main.go"), []byte("package main\n"), 0o600))
+	assert.NoError(t, buildpack.createDockerfile(contextDir, []string{"token", "unknown"}, log))
+
+	var output bytes.Buffer
+	secrets := map[string][]byte{"token": []byte("s3cr3t-value")}
+	imageID, err := buildpack.buildDockerImage(context.Background(), contextDir, "nina-app-abc123", nil, secrets, &output, log)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:built", imageID)
+
+	// The build ran on BuildKit, which got the secret over the session, and unknown secrets were not found
+	fake.mu.Lock()
+	assert.Equal(t, "2", fake.query["version"])
+	assert.NotEmpty(t, fake.query["session"])
+	assert.Equal(t, map[string]string{"token": "s3cr3t-value", "unknown": "status 5"}, fake.fetched)
+	fake.mu.Unlock()
+	assert.Contains(t, output.String(), "=> [builder 4/4] RUN go build\ngo: downloading private module\n")
+
+	// The secret is nowhere in the built image
+	saved, err := cli.ImageSave(context.Background(), []string{imageID})
+	assert.NoError(t, err)
+	image, err := io.ReadAll(saved)
+	assert.NoError(t, err)
+	assert.NoError(t, saved.Close())
+	assert.Contains(t, string(image), "package main")
+	assert.NotContains(t, string(image), "s3cr3t-value")
+}
+
+func TestBuildpackGolang_BuildSecretsNotInImage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	assert.NoError(t, err)
+	if _, pingErr := cli.Ping(context.Background()); pingErr != nil {
+		t.Skipf("Docker daemon not available: %v", pingErr)
+	}
+
+	// The build fails unless the secret is mounted, and writes something derived from it to the image
+	contextDir := t.TempDir()
+	dockerfile := "FROM busybox\nRUN --mount=type=secret,id=token,required=true test -s " + BuildSecretsPath +
+		"/token && wc -c " + BuildSecretsPath + "/token > /secret-size\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(contextDir, "Dockerfile"), []byte(dockerfile), 0o600))
+
+	buildpack := &BuildpackGolang{BaseBuildpack: &BaseBuildpack{DockerClient: cli}}
+	secrets := map[string][]byte{"token": []byte("s3cr3t-value")}
+	imageID, err := buildpack.buildDockerImage(context.Background(), contextDir, "nina-secrets-test", nil, secrets,
+		io.Discard, logger.New(logger.LevelDebug, "text"))
+	if err != nil {
+		t.Skipf("BuildKit build not available: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = cli.ImageRemove(context.Background(), imageID, image.RemoveOptions{Force: true, PruneChildren: true})
+	})
+
+	saved, err := cli.ImageSave(context.Background(), []string{imageID})
+	assert.NoError(t, err)
+	layers, err := io.ReadAll(saved)
+	assert.NoError(t, err)
+	assert.NoError(t, saved.Close())
+	assert.NotContains(t, string(layers), "s3cr3t-value")
+}
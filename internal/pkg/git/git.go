@@ -2,9 +2,12 @@
 package git
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
 )
 
 // CommitInfo represents Git commit information
@@ -15,6 +18,43 @@ type CommitInfo struct {
 	Message string `json:"message"`
 }
 
+// Git reads the information of the Git repository in a directory
+type Git interface {
+	IsGitRepository(path string) bool
+	GetRepoURL(repoPath string) (string, error)
+	GetLastCommitInfo(repoPath string) (*CommitInfo, error)
+	GetCurrentBranch(repoPath string) (string, error)
+	GetCommitRange(repoPath, from, to string) (*CommitRange, error)
+}
+
+// Exec implements Git by running the git command
+type Exec struct{}
+
+// IsGitRepository implements Git.IsGitRepository
+func (Exec) IsGitRepository(path string) bool {
+	return IsGitRepository(path)
+}
+
+// GetRepoURL implements Git.GetRepoURL
+func (Exec) GetRepoURL(repoPath string) (string, error) {
+	return GetRepoURL(repoPath)
+}
+
+// GetLastCommitInfo implements Git.GetLastCommitInfo
+func (Exec) GetLastCommitInfo(repoPath string) (*CommitInfo, error) {
+	return GetLastCommitInfo(repoPath)
+}
+
+// GetCurrentBranch implements Git.GetCurrentBranch
+func (Exec) GetCurrentBranch(repoPath string) (string, error) {
+	return GetCurrentBranch(repoPath)
+}
+
+// GetCommitRange implements Git.GetCommitRange
+func (Exec) GetCommitRange(repoPath, from, to string) (*CommitRange, error) {
+	return GetCommitRange(repoPath, from, to)
+}
+
 // GetRepoURL gets the repository URL from the current Git repository
 func GetRepoURL(repoPath string) (string, error) {
 	cmd := exec.Command("git", "config", "--get", "remote.origin.url")
@@ -33,18 +73,37 @@ func GetRepoURL(repoPath string) (string, error) {
 	return url, nil
 }
 
+// repoPathFromURL strips the scheme, user, host, port, query string, fragment and trailing
+// slashes from a repository URL, leaving only the repository path
+func repoPathFromURL(repoURL string) string {
+	path := strings.TrimSpace(repoURL)
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+
+	if i := strings.Index(path, "://"); i >= 0 {
+		// URL syntax: scheme://[user@]host[:port]/path
+		rest := path[i+len("://"):]
+		path = ""
+		if j := strings.Index(rest, "/"); j >= 0 {
+			path = rest[j:]
+		}
+	} else if i := strings.Index(path, ":"); i >= 0 {
+		// scp-like syntax: [user@]host:path
+		path = path[i+1:]
+	}
+
+	return strings.TrimRight(path, "/")
+}
+
 // ExtractAppNameFromRepoURL extracts the application name from a repository URL
 func ExtractAppNameFromRepoURL(repoURL string) (string, error) {
 	if repoURL == "" {
 		return "", fmt.Errorf("repository URL is empty")
 	}
 
-	// Split by "/" and get the last part
-	parts := strings.Split(repoURL, "/")
-	if len(parts) == 0 {
-		return "", fmt.Errorf("invalid repository URL format")
-	}
-
+	// Split the repository path by "/" and get the last part
+	parts := strings.Split(repoPathFromURL(repoURL), "/")
 	lastPart := parts[len(parts)-1]
 
 	// Remove ".git" suffix if present
@@ -111,3 +170,117 @@ func IsGitRepository(path string) bool {
 	err := cmd.Run()
 	return err == nil
 }
+
+// GetCurrentBranch gets the name of the branch checked out in the repository
+func GetCurrentBranch(repoPath string) (string, error) {
+	// symbolic-ref fails on a detached HEAD, which has no branch to report
+	cmd := exec.Command("git", "symbolic-ref", "--short", "HEAD")
+	cmd.Dir = repoPath
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	branch := strings.TrimSpace(string(output))
+	if branch == "" {
+		return "", fmt.Errorf("repository is not on a branch")
+	}
+
+	return branch, nil
+}
+
+// SanitizeBranchName turns a branch name into a lowercase DNS label fragment,
+// using the same rules as app names
+func SanitizeBranchName(branch string) string {
+	return types.SanitizeAppName(branch)
+}
+
+// PreviewAppName returns the app name used for a preview deployment of the given branch,
+// truncated so that it stays a valid DNS label
+func PreviewAppName(appName, branch string) (string, error) {
+	suffix := SanitizeBranchName(branch)
+	if suffix == "" {
+		return "", fmt.Errorf("branch name %q has no usable characters", branch)
+	}
+
+	name := appName + "-" + suffix
+	if len(name) > types.MaxAppNameLength {
+		name = strings.TrimRight(name[:types.MaxAppNameLength], "-")
+	}
+	return name, nil
+}
+
+// CommitRange describes the changes between two commits
+type CommitRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Commits are the commits reachable from To but not from From, newest first
+	Commits []CommitInfo `json:"commits"`
+	// ChangedFiles is the number of files that differ between From and To
+	ChangedFiles int `json:"changed_files"`
+	// FromIsAncestor is false when From is not an ancestor of To, e.g. after a rebase or when
+	// going back to an older commit; Commits then only lists the commits missing from From
+	FromIsAncestor bool `json:"from_is_ancestor"`
+}
+
+// commitFieldSeparator separates the fields of the commits listed by GetCommitRange
+const commitFieldSeparator = "\x1f"
+
+// GetCommitRange gets the commits and the number of changed files between two commits of the repository.
+// Both commits must exist in the local repository.
+func GetCommitRange(repoPath, from, to string) (*CommitRange, error) {
+	for _, commit := range []string{from, to} {
+		verifyCmd := exec.Command("git", "rev-parse", "--verify", "--quiet", commit+"^{commit}")
+		verifyCmd.Dir = repoPath
+		if err := verifyCmd.Run(); err != nil {
+			return nil, fmt.Errorf("commit %s is not in the local repository", commit)
+		}
+	}
+
+	// merge-base exits with 1 when from is not an ancestor of to
+	ancestorCmd := exec.Command("git", "merge-base", "--is-ancestor", from, to)
+	ancestorCmd.Dir = repoPath
+	isAncestor := true
+	if err := ancestorCmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			return nil, fmt.Errorf("failed to check commit ancestry: %w", err)
+		}
+		isAncestor = false
+	}
+
+	logCmd := exec.Command("git", "log", "--pretty=format:%H%x1f%an%x1f%ae%x1f%s", from+".."+to)
+	logCmd.Dir = repoPath
+	logOutput, err := logCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %w", err)
+	}
+	commits := []CommitInfo{}
+	for _, line := range strings.Split(strings.TrimSpace(string(logOutput)), "\n") {
+		fields := strings.Split(line, commitFieldSeparator)
+		if len(fields) != 4 {
+			continue
+		}
+		commits = append(commits, CommitInfo{Hash: fields[0], Author: fields[1], Email: fields[2], Message: fields[3]})
+	}
+
+	diffCmd := exec.Command("git", "diff", "--name-only", from, to)
+	diffCmd.Dir = repoPath
+	diffOutput, err := diffCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+	changedFiles := 0
+	if files := strings.TrimSpace(string(diffOutput)); files != "" {
+		changedFiles = len(strings.Split(files, "\n"))
+	}
+
+	return &CommitRange{
+		From:           from,
+		To:             to,
+		Commits:        commits,
+		ChangedFiles:   changedFiles,
+		FromIsAncestor: isAncestor,
+	}, nil
+}
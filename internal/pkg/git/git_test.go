@@ -1,7 +1,13 @@
 package git
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
 )
 
 func TestExtractAppNameFromRepoURL(t *testing.T) { //nolint: funlen
@@ -41,6 +47,54 @@ func TestExtractAppNameFromRepoURL(t *testing.T) { //nolint: funlen
 			expected: "my-app-name",
 			wantErr:  false,
 		},
+		{
+			name:     "SSH URL with scheme and port",
+			repoURL:  "ssh://git@github.com:22/org/repo.git",
+			expected: "repo",
+			wantErr:  false,
+		},
+		{
+			name:     "Nested path",
+			repoURL:  "https://gitlab.com/group/subgroup/project.git",
+			expected: "project",
+			wantErr:  false,
+		},
+		{
+			name:     "Query string and fragment",
+			repoURL:  "https://github.com/org/repo.git?ref=main#readme",
+			expected: "repo",
+			wantErr:  false,
+		},
+		{
+			name:     "Trailing slashes",
+			repoURL:  "https://github.com/org/repo.git//",
+			expected: "repo",
+			wantErr:  false,
+		},
+		{
+			name:     "SCP-like URL without organization",
+			repoURL:  "git@example.com:repo.git",
+			expected: "repo",
+			wantErr:  false,
+		},
+		{
+			name:     "Git protocol with port",
+			repoURL:  "git://example.com:9418/org/repo",
+			expected: "repo",
+			wantErr:  false,
+		},
+		{
+			name:     "Host without path",
+			repoURL:  "ssh://git@github.com:22",
+			expected: "",
+			wantErr:  true,
+		},
+		{
+			name:     "Only a .git suffix",
+			repoURL:  "https://github.com/org/.git",
+			expected: "",
+			wantErr:  true,
+		},
 		{
 			name:     "Empty URL",
 			repoURL:  "",
@@ -87,6 +141,9 @@ func TestExtractAppNameFromRepoURLEdgeCases(t *testing.T) {
 		"git://github.com/user/repo.git",
 		"https://gitlab.com/user/repo.git",
 		"git@gitlab.com:user/repo.git",
+		"ssh://git@github.com:22/user/repo.git",
+		"https://github.com/user/repo/",
+		"https://github.com/user/repo.git?ref=main",
 	}
 
 	for _, url := range testCases {
@@ -122,3 +179,131 @@ func TestExtractAppNameFromRepoURLWithComplexNames(t *testing.T) {
 		}
 	}
 }
+
+func TestSanitizeBranchName(t *testing.T) {
+	testCases := map[string]string{
+		"main":                   "main",
+		"feature/login-page":     "feature-login-page",
+		"Feature/Login_Page":     "feature-login-page",
+		"fix//double--dashes":    "fix-double-dashes",
+		"-leading-and-trailing-": "leading-and-trailing",
+		"release/v1.2.3":         "release-v1-2-3",
+		"///":                    "",
+	}
+
+	for branch, expected := range testCases {
+		if got := SanitizeBranchName(branch); got != expected {
+			t.Errorf("SanitizeBranchName(%q) = %q, want %q", branch, got, expected)
+		}
+	}
+}
+
+func TestPreviewAppName(t *testing.T) {
+	name, err := PreviewAppName("nina", "Feature/Login")
+	if err != nil {
+		t.Fatalf("PreviewAppName() unexpected error: %v", err)
+	}
+	if name != "nina-feature-login" {
+		t.Errorf("PreviewAppName() = %q, want %q", name, "nina-feature-login")
+	}
+
+	name, err = PreviewAppName("nina", "feature/"+strings.Repeat("a", 100))
+	if err != nil {
+		t.Fatalf("PreviewAppName() unexpected error: %v", err)
+	}
+	if len(name) > types.MaxAppNameLength {
+		t.Errorf("PreviewAppName() length = %d, want at most %d", len(name), types.MaxAppNameLength)
+	}
+	if !strings.HasPrefix(name, "nina-feature-") {
+		t.Errorf("PreviewAppName() = %q, expected the app name prefix to be kept", name)
+	}
+
+	// A name cut right after a separator doesn't end with a dash
+	name, err = PreviewAppName(strings.Repeat("b", 57), "feature/x")
+	if err != nil {
+		t.Fatalf("PreviewAppName() unexpected error: %v", err)
+	}
+	if strings.HasSuffix(name, "-") {
+		t.Errorf("PreviewAppName() = %q, should not end with a dash", name)
+	}
+
+	if _, err := PreviewAppName("nina", "///"); err == nil {
+		t.Error("PreviewAppName() expected error for a branch without usable characters")
+	}
+}
+
+func TestGetCurrentBranch(t *testing.T) {
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("git %v failed: %v: %s", args, err, output)
+		}
+	}
+	run("init", "-q")
+	run("checkout", "-q", "-b", "feature/preview")
+
+	branch, err := GetCurrentBranch(dir)
+	if err != nil {
+		t.Fatalf("GetCurrentBranch() unexpected error: %v", err)
+	}
+	if branch != "feature/preview" {
+		t.Errorf("GetCurrentBranch() = %q, want %q", branch, "feature/preview")
+	}
+}
+
+func TestGetCommitRange(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Skipf("git %v failed: %v: %s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	commit := func(file, message string) string {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(message), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", file, err)
+		}
+		git("add", file)
+		git("commit", "-q", "-m", message)
+		return git("rev-parse", "HEAD")
+	}
+	git("init", "-q")
+	git("checkout", "-q", "-b", "main")
+	deployed := commit("a.txt", "First")
+	commit("b.txt", "Second")
+	head := commit("a.txt", "Third")
+
+	commitRange, err := GetCommitRange(dir, deployed, head)
+	if err != nil {
+		t.Fatalf("GetCommitRange() unexpected error: %v", err)
+	}
+	if !commitRange.FromIsAncestor || commitRange.ChangedFiles != 2 || len(commitRange.Commits) != 2 {
+		t.Fatalf("GetCommitRange() = %+v, want 2 commits and 2 changed files", commitRange)
+	}
+	if commitRange.Commits[0].Hash != head || commitRange.Commits[0].Message != "Third" || commitRange.Commits[1].Message != "Second" {
+		t.Errorf("GetCommitRange() commits = %+v, want Third and Second", commitRange.Commits)
+	}
+
+	// A commit on another branch is not an ancestor of HEAD
+	git("checkout", "-q", "-b", "other", deployed)
+	diverged := commit("c.txt", "Diverged")
+	commitRange, err = GetCommitRange(dir, diverged, head)
+	if err != nil {
+		t.Fatalf("GetCommitRange() unexpected error: %v", err)
+	}
+	if commitRange.FromIsAncestor || len(commitRange.Commits) != 2 || commitRange.ChangedFiles != 3 {
+		t.Errorf("GetCommitRange() = %+v, want a diverged range with 2 commits and 3 changed files", commitRange)
+	}
+
+	if _, err := GetCommitRange(dir, strings.Repeat("0", 40), head); err == nil || !strings.Contains(err.Error(), "not in the local repository") {
+		t.Errorf("GetCommitRange() expected a not in the local repository error, got %v", err)
+	}
+}
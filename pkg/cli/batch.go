@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// defaultBuildConcurrency is the number of builds BuildAll runs at once when no concurrency is given
+const defaultBuildConcurrency = 4
+
+// BuildResult is the outcome of building a directory of a batch
+type BuildResult struct {
+	Dir string
+	// CommitHash is the last commit of the directory, empty when it could not be read
+	CommitHash string
+	Image      *types.DeploymentImage
+	Err        error
+}
+
+// BuildAll builds the last commit of every directory, e.g. the worktrees of the branches of a CI matrix,
+// running at most concurrency builds at once. A failed build does not stop the others, its error is kept on
+// its result, and builds that did not start before ctx was cancelled fail with the context error.
+// The results are in the order of dirs.
+func (c *CLI) BuildAll(ctx context.Context, dirs []string, opts *BuildOptions, concurrency int) []*BuildResult {
+	if concurrency <= 0 {
+		concurrency = defaultBuildConcurrency
+	}
+
+	// The progress output of concurrent builds would interleave
+	worker := *c
+	worker.progress = nil
+
+	results := make([]*BuildResult, len(dirs))
+	commits := map[string]string{}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, dir := range dirs {
+		result := &BuildResult{Dir: dir}
+		results[i] = result
+
+		if err := c.validateGitRepository(dir); err != nil {
+			result.Err = err
+			continue
+		}
+		commitInfo, err := c.repo.GetLastCommitInfo(dir)
+		if err != nil {
+			result.Err = fmt.Errorf("failed to get last commit information: %w", err)
+			continue
+		}
+		result.CommitHash = commitInfo.Hash
+		// Builds are identified by their commit, so a commit is only built once
+		if other, ok := commits[commitInfo.Hash]; ok {
+			result.Err = fmt.Errorf("commit %s is already built from %s", commitInfo.Hash, other)
+			continue
+		}
+		commits[commitInfo.Hash] = dir
+
+		if !acquireSlot(ctx, slots) {
+			result.Err = fmt.Errorf("build not started: %w", ctx.Err())
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result.Image, result.Err = worker.Build(ctx, dir, opts)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// acquireSlot waits for a free slot, returning false when ctx is done first
+func acquireSlot(ctx context.Context, slots chan<- struct{}) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case slots <- struct{}{}:
+		return true
+	}
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/internal/pkg/git"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// batchDirs creates a source directory per commit and a fake Git where every directory is at its commit
+func batchDirs(t *testing.T, commits ...string) ([]string, *fakeGit) {
+	t.Helper()
+	repo := newFakeGit()
+	repo.commits = map[string]git.CommitInfo{}
+	root := t.TempDir()
+	dirs := make([]string, 0, len(commits))
+	for i, commit := range commits {
+		dir := filepath.Join(root, string(rune('a'+i)))
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o600); err != nil {
+			t.Fatalf("Failed to write source: %v", err)
+		}
+		repo.commits[dir] = git.CommitInfo{Hash: commit}
+		dirs = append(dirs, dir)
+	}
+	return dirs, repo
+}
+
+func TestBuildAll(t *testing.T) {
+	dirs, repo := batchDirs(t, "c1", "c2", "c3", "c4", "c2")
+
+	var (
+		mu               sync.Mutex
+		running, maxSeen int
+		built            []string
+	)
+	c := newTestCLI(t, repo, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/builds":
+			_, _ = w.Write([]byte(`{"builds":[]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/build":
+			reader, _ := r.MultipartReader()
+			part, _ := reader.NextPart()
+			var req types.BuildRequest
+			_ = json.NewDecoder(part).Decode(&req)
+
+			mu.Lock()
+			running++
+			maxSeen = max(maxSeen, running)
+			built = append(built, req.CommitHash)
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+
+			if req.CommitHash == "c3" {
+				http.Error(w, `{"error":"build failed"}`, http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"image_tag":"nina-my-app-` + req.CommitHash + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	results := c.BuildAll(context.Background(), dirs, nil, 2)
+	if len(results) != len(dirs) {
+		t.Fatalf("Expected %d results, got %d", len(dirs), len(results))
+	}
+	for i, result := range results {
+		if result.Dir != dirs[i] {
+			t.Errorf("Expected result %d for %s, got %s", i, dirs[i], result.Dir)
+		}
+	}
+	for _, i := range []int{0, 1, 3} {
+		if results[i].Err != nil || results[i].Image.ImageTag != "nina-my-app-"+results[i].CommitHash {
+			t.Errorf("Expected %s to be built, got %+v", results[i].CommitHash, results[i])
+		}
+	}
+	if results[2].Err == nil {
+		t.Error("Expected the failed build of c3 to be reported")
+	}
+	if results[4].Err == nil || results[4].CommitHash != "c2" {
+		t.Errorf("Expected the second directory at c2 not to be built, got %+v", results[4])
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(built) != 4 {
+		t.Errorf("Expected 4 builds, got %v", built)
+	}
+	if maxSeen > 2 {
+		t.Errorf("Expected at most 2 builds at once, got %d", maxSeen)
+	}
+}
+
+func TestBuildAll_Cancelled(t *testing.T) {
+	dirs, repo := batchDirs(t, "c1", "c2")
+	c := newTestCLI(t, repo, func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("Expected no request to the Engine")
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, result := range c.BuildAll(ctx, dirs, nil, 1) {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("Expected %s not to be built after cancellation, got %v", result.Dir, result.Err)
+		}
+	}
+}
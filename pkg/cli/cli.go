@@ -2,29 +2,77 @@
 package cli
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
-	"reflect"
+	"strings"
 	"time"
 
+	"github.com/distribution/reference"
+	"github.com/docker/go-units"
 	"github.com/matiasinsaurralde/nina/internal/pkg/archive"
 	"github.com/matiasinsaurralde/nina/internal/pkg/git"
+	"github.com/matiasinsaurralde/nina/pkg/client"
 	"github.com/matiasinsaurralde/nina/pkg/config"
 	"github.com/matiasinsaurralde/nina/pkg/logger"
-	"github.com/matiasinsaurralde/nina/pkg/store"
+	"github.com/matiasinsaurralde/nina/pkg/manifest"
 	"github.com/matiasinsaurralde/nina/pkg/types"
 )
 
+// largestFilesShown is the number of files listed when a bundle is too large
+const largestFilesShown = 5
+
 // CLI represents the command line interface
 type CLI struct {
-	config *config.Config
-	logger *logger.Logger
-	client *http.Client
+	config   *config.Config
+	logger   *logger.Logger
+	api      *client.Client
+	progress *Progress
+	// repo reads the Git information of working directories
+	repo git.Git
+}
+
+// BuildOptions holds optional settings for a build
+type BuildOptions struct {
+	// BuildArgs are passed to the image build as Docker build arguments
+	BuildArgs map[string]string
+	// Buildpack forces the named buildpack instead of detecting one
+	Buildpack string
+	// Secrets name build secrets of the Engine's secret store made available to the build
+	Secrets []string
+	// Compression is the gzip level used for the bundle, see archive.ParseCompressionLevel
+	Compression string
+	// CallbackURL receives a POST from the Engine once the build succeeds or fails
+	CallbackURL string
+	// Overrides are applied on top of nina.yaml before the flags, e.g. the settings of a stack service
+	Overrides *manifest.Manifest
+}
+
+// DeployOptions holds optional settings for a deployment
+type DeployOptions struct {
+	// Replicas takes precedence over the manifest when non-zero
+	Replicas int
+	// Preview deploys the current branch separately as <app>-<branch>
+	Preview bool
+	// Node is the engine node to deploy to, taking precedence over the manifest when set
+	Node string
+	// Labels are attached to the deployment and its containers
+	Labels map[string]string
+	// PullPolicy controls whether the Engine pulls the image, empty leaves it to the Engine default
+	PullPolicy types.PullPolicy
+	// Domains are additional hosts the ingress routes to the app
+	Domains []string
+	// Image is a pre-built image deployed instead of the build of the current commit
+	Image string
+	// Webhooks are notified when the deployment becomes ready or fails, taking precedence over the manifest when set
+	Webhooks []string
+	// Overrides are applied on top of nina.yaml before the flags, e.g. the settings of a stack service
+	Overrides *manifest.Manifest
 }
 
 // NewCLI creates a new CLI instance
@@ -32,30 +80,31 @@ func NewCLI(cfg *config.Config, log *logger.Logger) *CLI {
 	return &CLI{
 		config: cfg,
 		logger: log,
-		client: &http.Client{
+		api: client.New("http://"+cfg.GetServerAddr(), client.WithHTTPClient(&http.Client{
 			Timeout: 5 * time.Minute,
-		},
+		})),
+		repo: git.Exec{},
 	}
 }
 
-// Provision provisions a new deployment
-func (c *CLI) Provision(ctx context.Context, req *store.ProvisionRequest) (*store.Deployment, error) {
-	body, err := c.makeJSONRequest(ctx, "provision", req, "provision")
-	if err != nil {
-		return nil, err
-	}
+// SetGit sets how the Git information of working directories is read, running git by default
+func (c *CLI) SetGit(repo git.Git) {
+	c.repo = repo
+}
 
-	var deployment store.Deployment
-	if err := json.Unmarshal(body, &deployment); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
+// SetProgress sets the progress reporter used by Build and Deploy
+func (c *CLI) SetProgress(p *Progress) {
+	c.progress = p
+}
 
-	return &deployment, nil
+// Provision provisions a new deployment
+func (c *CLI) Provision(ctx context.Context, req *types.ProvisionRequest) (*types.LegacyDeployment, error) {
+	return c.api.Provision(ctx, req) //nolint:wrapcheck
 }
 
 // validateGitRepository validates that the working directory is a Git repository
 func (c *CLI) validateGitRepository(workingDir string) error {
-	if !git.IsGitRepository(workingDir) {
+	if !c.repo.IsGitRepository(workingDir) {
 		return fmt.Errorf("directory is not a Git repository: %s", workingDir)
 	}
 	return nil
@@ -64,7 +113,7 @@ func (c *CLI) validateGitRepository(workingDir string) error {
 // getRepositoryInfo gets repository information from the working directory
 func (c *CLI) getRepositoryInfo(workingDir string) (string, *git.CommitInfo, error) {
 	// Get repository URL
-	repoURL, err := git.GetRepoURL(workingDir)
+	repoURL, err := c.repo.GetRepoURL(workingDir)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to get repository URL: %w", err)
 	}
@@ -76,7 +125,7 @@ func (c *CLI) getRepositoryInfo(workingDir string) (string, *git.CommitInfo, err
 	}
 
 	// Get last commit information
-	commitInfo, err := git.GetLastCommitInfo(workingDir)
+	commitInfo, err := c.repo.GetLastCommitInfo(workingDir)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to get last commit information: %w", err)
 	}
@@ -84,47 +133,68 @@ func (c *CLI) getRepositoryInfo(workingDir string) (string, *git.CommitInfo, err
 	return appName, commitInfo, nil
 }
 
-// createDeploymentRequest creates a deployment request from repository info
-func (c *CLI) createDeploymentRequest(appName string, commitInfo *git.CommitInfo, replicas int) *types.DeploymentRequest {
-	return &types.DeploymentRequest{
-		AppName:       appName,
-		CommitHash:    commitInfo.Hash,
-		Author:        commitInfo.Author,
-		AuthorEmail:   commitInfo.Email,
-		CommitMessage: commitInfo.Message,
-		Replicas:      replicas,
+// loadManifest loads the manifest from the working directory and applies the given overrides on top of it
+func (c *CLI) loadManifest(workingDir string, overrides *manifest.Manifest) (*manifest.Manifest, error) {
+	m, err := manifest.Load(workingDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load manifest: %w", err)
 	}
+	return m.Merge(overrides), nil
 }
 
-// sendDeploymentRequest sends the deployment request to the API
-func (c *CLI) sendDeploymentRequest(ctx context.Context, req *types.DeploymentRequest) (*types.Deployment, error) {
-	body, err := c.makeJSONRequest(ctx, "deploy", req, "deploy")
-	if err != nil {
-		return nil, err
+// resolveReplicas picks the replica count: flag (merged into the manifest) > manifest > config > types.DefaultReplicas
+func (c *CLI) resolveReplicas(m *manifest.Manifest) int {
+	if m.Replicas > 0 {
+		return m.Replicas
+	}
+	if c.config.Defaults.Replicas > 0 {
+		return c.config.Defaults.Replicas
 	}
+	return types.DefaultReplicas
+}
 
-	var deployment types.Deployment
-	if err := json.Unmarshal(body, &deployment); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+// createDeploymentRequest creates a deployment request from repository info and manifest values
+func (c *CLI) createDeploymentRequest(appName string, commitInfo *git.CommitInfo, m *manifest.Manifest) (*types.DeploymentRequest, error) {
+	memory, err := m.MemoryBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse memory limit: %w", err)
 	}
 
-	return &deployment, nil
+	return &types.DeploymentRequest{
+		AppName:       appName,
+		CommitHash:    commitInfo.Hash,
+		Author:        commitInfo.Author,
+		AuthorEmail:   commitInfo.Email,
+		CommitMessage: commitInfo.Message,
+		Replicas:      c.resolveReplicas(m),
+		Port:          m.Port,
+		Env:           m.Env,
+		CPU:           m.CPU,
+		Memory:        memory,
+		Node:          m.Node,
+		WebhookURLs:   m.Webhooks,
+	}, nil
 }
 
 // Deploy deploys an application from the current directory
-func (c *CLI) Deploy(ctx context.Context, workingDir string, replicas int) (*types.Deployment, error) {
-	// Validate Git repository
-	if err := c.validateGitRepository(workingDir); err != nil {
-		return nil, err
+func (c *CLI) Deploy(ctx context.Context, workingDir string, opts *DeployOptions) (*types.Deployment, error) {
+	if opts == nil {
+		opts = &DeployOptions{}
+	}
+	if !opts.PullPolicy.Valid() {
+		return nil, fmt.Errorf("invalid pull policy %q: must be %s, %s or %s", opts.PullPolicy,
+			types.PullPolicyAlways, types.PullPolicyIfNotPresent, types.PullPolicyNever)
 	}
 
-	// Get repository information
-	appName, commitInfo, err := c.getRepositoryInfo(workingDir)
+	appName, commitInfo, m, err := c.resolveDeployment(workingDir, opts)
 	if err != nil {
 		return nil, err
 	}
 
+	defer c.progress.Stop()
+
 	// Check if deployment already exists for this app
+	c.progress.Phase("Checking existing deployments...")
 	exists, err := c.DeploymentExists(ctx, appName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check if deployment exists: %w", err)
@@ -133,110 +203,181 @@ func (c *CLI) Deploy(ctx context.Context, workingDir string, replicas int) (*typ
 		return nil, fmt.Errorf("a deployment for app %s already exists", appName)
 	}
 
+	// A pre-built image is not the build of the checked out commit, so no commit is recorded for it
+	if opts.Image != "" {
+		commitInfo = &git.CommitInfo{}
+	}
+
 	// Create and send deployment request
-	req := c.createDeploymentRequest(appName, commitInfo, replicas)
-	return c.sendDeploymentRequest(ctx, req)
+	req, err := c.createDeploymentRequest(appName, commitInfo, m)
+	if err != nil {
+		return nil, err
+	}
+	req.Labels = opts.Labels
+	req.PullPolicy = opts.PullPolicy
+	req.Domains = opts.Domains
+	req.Image = opts.Image
+	c.progress.Phase("Starting replicas...")
+	return c.api.Deploy(ctx, req) //nolint:wrapcheck
 }
 
-// DeleteDeployment deletes a deployment
-func (c *CLI) DeleteDeployment(ctx context.Context, id string) error {
-	url := fmt.Sprintf("http://%s/api/v1/deployments/%s", c.config.GetServerAddr(), id)
+// resolveDeployment reads the app name, the commit and the manifest a deployment of the working directory uses
+func (c *CLI) resolveDeployment(workingDir string, opts *DeployOptions) (string, *git.CommitInfo, *manifest.Manifest, error) {
+	var (
+		appName    string
+		commitInfo = &git.CommitInfo{}
+		err        error
+	)
+	// A pre-built image can be deployed from outside a Git repository
+	if opts.Image == "" || c.repo.IsGitRepository(workingDir) {
+		// Validate Git repository
+		if err := c.validateGitRepository(workingDir); err != nil {
+			return "", nil, nil, err
+		}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "DELETE", url, http.NoBody)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		// Get repository information
+		if appName, commitInfo, err = c.getRepositoryInfo(workingDir); err != nil {
+			return "", nil, nil, err
+		}
 	}
 
-	resp, err := c.client.Do(httpReq)
+	// Load the manifest, flags win over manifest values
+	overrides := &manifest.Manifest{Replicas: opts.Replicas, Node: opts.Node, Webhooks: opts.Webhooks}
+	if opts.Overrides != nil {
+		overrides = opts.Overrides.Merge(overrides)
+	}
+	m, err := c.loadManifest(workingDir, overrides)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return "", nil, nil, err
 	}
-	defer resp.Body.Close() //nolint:errcheck
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("delete failed: %s (status: %d)", string(body), resp.StatusCode)
+	if m.AppName != "" {
+		appName = m.AppName
 	}
-
-	return nil
+	if appName == "" {
+		if appName, err = imageAppName(opts.Image); err != nil {
+			return "", nil, nil, err
+		}
+	}
+	if appName, err = normalizeAppName(appName); err != nil {
+		return "", nil, nil, err
+	}
+	if opts.Preview {
+		if appName, err = c.previewAppName(workingDir, appName); err != nil {
+			return "", nil, nil, err
+		}
+	}
+	return appName, commitInfo, m, nil
 }
 
-// GetDeploymentStatus gets the status of a deployment
-func (c *CLI) GetDeploymentStatus(ctx context.Context, id string) (*store.Deployment, error) {
-	url := fmt.Sprintf("http://%s/api/v1/deployments/%s/status", c.config.GetServerAddr(), id)
-
-	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, http.NoBody)
+// imageAppName derives an app name from the last path component of an image reference,
+// e.g. app for registry.example.com/team/app:1.2
+func imageAppName(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("invalid image %q: %w", image, err)
 	}
+	path := reference.Path(named)
+	return path[strings.LastIndex(path, "/")+1:], nil
+}
+
+// DeployDiff describes what deploying the working directory would change
+type DeployDiff struct {
+	AppName string
+	// Commit is the commit that would be deployed
+	Commit *git.CommitInfo
+	// DeployedCommit is the commit of the current deployment, empty when the app is not deployed
+	DeployedCommit string
+	// Range holds the changes since the deployed commit, nil when the app is not deployed or
+	// the changes could not be computed, in which case RangeErr tells why
+	Range    *git.CommitRange
+	RangeErr error
+}
 
-	resp, err := c.client.Do(httpReq)
+// Diff compares the commit of the working directory with the commit currently deployed for its app,
+// using the local repository. Nothing is deployed.
+func (c *CLI) Diff(ctx context.Context, workingDir string, opts *DeployOptions) (*DeployDiff, error) {
+	if opts == nil {
+		opts = &DeployOptions{}
+	}
+	appName, commitInfo, _, err := c.resolveDeployment(workingDir, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close() //nolint:errcheck
 
-	body, err := io.ReadAll(resp.Body)
+	diff := &DeployDiff{AppName: appName, Commit: commitInfo}
+	deployment, err := c.api.GetDeployment(ctx, appName)
+	if client.IsNotFound(err) {
+		return diff, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to get current deployment: %w", err)
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("get status failed: %s (status: %d)", string(body), resp.StatusCode)
+	diff.DeployedCommit = deployment.CommitHash
+	if diff.DeployedCommit == "" {
+		diff.RangeErr = fmt.Errorf("the current deployment does not record its commit")
+		return diff, nil
 	}
+	diff.Range, diff.RangeErr = c.repo.GetCommitRange(workingDir, diff.DeployedCommit, commitInfo.Hash)
+	return diff, nil
+}
 
-	var deployment store.Deployment
-	if err := json.Unmarshal(body, &deployment); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+// normalizeAppName sanitizes the app name the same way the engine does, so that the
+// name used for existence checks matches the one the deployment is stored under
+func normalizeAppName(appName string) (string, error) {
+	sanitized := types.SanitizeAppName(appName)
+	if sanitized == "" {
+		return "", fmt.Errorf("app name %q has no valid characters", appName)
 	}
-
-	return &deployment, nil
+	return sanitized, nil
 }
 
-// ListDeployments lists all deployments
-func (c *CLI) ListDeployments(ctx context.Context) ([]*types.Deployment, error) {
-	body, err := c.makeListRequest(ctx, "deployments", "deployments")
+// previewAppName namespaces the app name with the current branch
+func (c *CLI) previewAppName(workingDir, appName string) (string, error) {
+	branch, err := c.repo.GetCurrentBranch(workingDir)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("failed to get current branch for preview deployment: %w", err)
 	}
-
-	response, err := unmarshalListResponse(body, "deployments")
+	previewName, err := git.PreviewAppName(appName, branch)
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("failed to build preview app name: %w", err)
 	}
-
-	return response.([]*types.Deployment), nil
+	c.logger.Info("Deploying preview", "branch", branch, "app_name", previewName)
+	return previewName, nil
 }
 
-// HealthCheck checks if the Engine server is healthy
-func (c *CLI) HealthCheck(ctx context.Context) error {
-	url := fmt.Sprintf("http://%s/health", c.config.GetServerAddr())
+// DeleteDeployment deletes a deployment and reports whether it existed.
+// With ignoreMissing an absent deployment is not an error.
+func (c *CLI) DeleteDeployment(ctx context.Context, id string, ignoreMissing bool) (deleted bool, err error) {
+	return c.api.DeleteDeployment(ctx, id, ignoreMissing) //nolint:wrapcheck
+}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, http.NoBody)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
+// DeleteDeploymentsByPrefix deletes all deployments whose app name starts with prefix and returns their names
+func (c *CLI) DeleteDeploymentsByPrefix(ctx context.Context, prefix string) ([]string, error) {
+	return c.api.DeleteDeploymentsByPrefix(ctx, prefix) //nolint:wrapcheck
+}
 
-	resp, err := c.client.Do(httpReq)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close() //nolint:errcheck
+// GetDeploymentStatus gets the status of the deployment of an app
+func (c *CLI) GetDeploymentStatus(ctx context.Context, id string) (*types.DeploymentStatusReport, error) {
+	return c.api.GetDeploymentStatus(ctx, id) //nolint:wrapcheck
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("health check failed: %s (status: %d)", string(body), resp.StatusCode)
-	}
+// ListDeployments lists deployments, filtered and sorted according to opts when set
+func (c *CLI) ListDeployments(ctx context.Context, opts *types.ListOptions) ([]*types.Deployment, error) {
+	return c.api.ListDeployments(ctx, opts) //nolint:wrapcheck
+}
 
-	return nil
+// HealthCheck checks if the Engine server is healthy
+func (c *CLI) HealthCheck(ctx context.Context) error {
+	return c.api.Health(ctx) //nolint:wrapcheck
 }
 
-// createBuildBundle creates a build bundle from the working directory
-func (c *CLI) createBuildBundle(workingDir string) (string, error) {
+// createBuildBundle creates a gzipped tarball of the working directory and returns its path
+// and the hex encoded SHA-256 of its contents. The caller is responsible for removing the file.
+func (c *CLI) createBuildBundle(workingDir string, level int) (path, checksum string, err error) {
 	// Create temporary directory and copy contents
-	tempDir, err := archive.CreateTempDirAndCopy(workingDir)
+	tempDir, err := archive.CreateTempDirAndCopy(workingDir, c.config.Engine.TempDir)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+		return "", "", fmt.Errorf("failed to create temporary directory: %w", err)
 	}
 	defer func() {
 		if removeErr := os.RemoveAll(tempDir); removeErr != nil {
@@ -244,71 +385,112 @@ func (c *CLI) createBuildBundle(workingDir string) (string, error) {
 		}
 	}()
 
-	// Create gzipped tar base64
-	bundleContents, err := archive.CreateGzippedTarBase64(tempDir)
+	// Write the gzipped tar next to the copy rather than holding it in memory
+	bundleFile, err := os.CreateTemp(c.config.Engine.TempDir, "nina-bundle-*.tar.gz")
 	if err != nil {
-		return "", fmt.Errorf("failed to create gzipped tar archive: %w", err)
+		return "", "", fmt.Errorf("failed to create bundle file: %w", err)
+	}
+	hash := sha256.New()
+	if err := archive.WriteGzippedTar(tempDir, io.MultiWriter(bundleFile, hash), level); err != nil {
+		_ = bundleFile.Close()
+		_ = os.Remove(bundleFile.Name())
+		return "", "", fmt.Errorf("failed to create gzipped tar archive: %w", err)
+	}
+	if err := bundleFile.Close(); err != nil {
+		_ = os.Remove(bundleFile.Name())
+		return "", "", fmt.Errorf("failed to write bundle file: %w", err)
 	}
 
-	return bundleContents, nil
+	return bundleFile.Name(), hex.EncodeToString(hash.Sum(nil)), nil
 }
 
-// createBuildRequest creates a build request from repository info and bundle contents
-func (c *CLI) createBuildRequest(appName, repoURL, bundleContents string, commitInfo *git.CommitInfo) *types.BuildRequest {
-	return &types.BuildRequest{
-		AppName:        appName,
-		RepoURL:        repoURL,
-		Author:         commitInfo.Author,
-		AuthorEmail:    commitInfo.Email,
-		CommitHash:     commitInfo.Hash,
-		CommitMessage:  commitInfo.Message,
-		BundleContents: bundleContents,
+// maxBundleSize returns the largest bundle the Engine accepts, zero meaning no limit
+func (c *CLI) maxBundleSize() int64 {
+	switch limit := c.config.Engine.MaxBundleSize; {
+	case limit < 0:
+		return 0
+	case limit == 0:
+		return types.DefaultMaxBundleSize
+	default:
+		return limit
 	}
 }
 
-// sendBuildRequest sends the build request to the API
-func (c *CLI) sendBuildRequest(ctx context.Context, req *types.BuildRequest) (*types.DeploymentImage, error) {
-	url := fmt.Sprintf("http://%s/api/v1/build", c.config.GetServerAddr())
-
-	data, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+// checkBundleSize fails before uploading a bundle the Engine would reject,
+// listing the largest files of the working directory as candidates for removal
+func (c *CLI) checkBundleSize(workingDir, bundlePath string) error {
+	limit := c.maxBundleSize()
+	if limit == 0 {
+		return nil
 	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
+	info, err := os.Stat(bundlePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to stat bundle file: %w", err)
 	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	if info.Size() <= limit {
+		return nil
 	}
-	defer resp.Body.Close() //nolint:errcheck
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "bundle is %s, larger than the %s limit (engine.max_bundle_size)",
+		units.BytesSize(float64(info.Size())), units.BytesSize(float64(limit)))
+	if files, err := archive.LargestFiles(workingDir, largestFilesShown); err == nil && len(files) > 0 {
+		msg.WriteString("; largest files:")
+		for _, file := range files {
+			fmt.Fprintf(&msg, "\n  %s (%s)", file.Path, units.BytesSize(float64(file.Size)))
+		}
 	}
+	return errors.New(msg.String())
+}
 
-	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("build failed: %s (status: %d)", string(body), resp.StatusCode)
+// createBuildRequest creates a build request from repository info
+func (c *CLI) createBuildRequest(
+	appName, repoURL string,
+	commitInfo *git.CommitInfo,
+	opts *BuildOptions,
+) *types.BuildRequest {
+	req := &types.BuildRequest{
+		AppName:       appName,
+		RepoURL:       repoURL,
+		Author:        commitInfo.Author,
+		AuthorEmail:   commitInfo.Email,
+		CommitHash:    commitInfo.Hash,
+		CommitMessage: commitInfo.Message,
 	}
+	if opts != nil {
+		req.BuildArgs = opts.BuildArgs
+		req.Secrets = opts.Secrets
+		req.CallbackURL = opts.CallbackURL
+	}
+	return req
+}
 
-	var deploymentImage types.DeploymentImage
-	if err := json.Unmarshal(body, &deploymentImage); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+// sendBuildRequest streams the build metadata and bundle file to the API
+func (c *CLI) sendBuildRequest(ctx context.Context, req *types.BuildRequest, bundlePath string) (*types.DeploymentImage, error) {
+	bundleFile, err := os.Open(bundlePath) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle file: %w", err)
 	}
+	defer bundleFile.Close() //nolint:errcheck
 
-	return &deploymentImage, nil
+	// The server starts building once the whole bundle has been uploaded
+	bundle := &phaseReader{r: bundleFile, progress: c.progress, next: "Building..."}
+	return c.api.Build(ctx, req, bundle) //nolint:wrapcheck
 }
 
 // Build builds a deployment from the current directory
-func (c *CLI) Build(ctx context.Context, workingDir string) (*types.DeploymentImage, error) {
+func (c *CLI) Build(ctx context.Context, workingDir string, opts *BuildOptions) (*types.DeploymentImage, error) {
+	compression := ""
+	if opts != nil {
+		compression = opts.Compression
+	}
+	level, err := archive.ParseCompressionLevel(compression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse compression level: %w", err)
+	}
+
 	// Validate Git repository
-	if err := c.validateGitRepository(workingDir); err != nil {
+	if err = c.validateGitRepository(workingDir); err != nil {
 		return nil, err
 	}
 
@@ -319,12 +501,34 @@ func (c *CLI) Build(ctx context.Context, workingDir string) (*types.DeploymentIm
 	}
 
 	// Get repository URL
-	repoURL, err := git.GetRepoURL(workingDir)
+	repoURL, err := c.repo.GetRepoURL(workingDir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get repository URL: %w", err)
 	}
 
+	// Load the manifest, flags win over manifest values
+	overrides := &manifest.Manifest{}
+	if opts != nil {
+		overrides.Buildpack = opts.Buildpack
+		if opts.Overrides != nil {
+			overrides = opts.Overrides.Merge(overrides)
+		}
+	}
+	m, err := c.loadManifest(workingDir, overrides)
+	if err != nil {
+		return nil, err
+	}
+	if m.AppName != "" {
+		appName = m.AppName
+	}
+	if appName, err = normalizeAppName(appName); err != nil {
+		return nil, err
+	}
+
+	defer c.progress.Stop()
+
 	// Check if build already exists for this commit
+	c.progress.Phase("Checking existing builds...")
 	exists, err := c.BuildExists(ctx, commitInfo.Hash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check if build exists: %w", err)
@@ -334,167 +538,121 @@ func (c *CLI) Build(ctx context.Context, workingDir string) (*types.DeploymentIm
 	}
 
 	// Create build bundle
-	bundleContents, err := c.createBuildBundle(workingDir)
+	c.progress.Phase("Archiving...")
+	bundlePath, checksum, err := c.createBuildBundle(workingDir, level)
 	if err != nil {
 		return nil, err
 	}
-
-	// Create and send build request
-	req := c.createBuildRequest(appName, repoURL, bundleContents, commitInfo)
-	return c.sendBuildRequest(ctx, req)
-}
-
-// ListBuilds lists all builds
-func (c *CLI) ListBuilds(ctx context.Context) ([]*types.Build, error) {
-	body, err := c.makeListRequest(ctx, "builds", "builds")
-	if err != nil {
-		return nil, err
-	}
-
-	response, err := unmarshalListResponse(body, "builds")
-	if err != nil {
+	defer func() {
+		if removeErr := os.Remove(bundlePath); removeErr != nil {
+			c.logger.Error("Failed to remove bundle file", "error", removeErr)
+		}
+	}()
+	if err := c.checkBundleSize(workingDir, bundlePath); err != nil {
 		return nil, err
 	}
 
-	return response.([]*types.Build), nil
+	// Create and send build request
+	req := c.createBuildRequest(appName, repoURL, commitInfo, opts)
+	req.Buildpack = m.Buildpack
+	req.BundleChecksum = checksum
+	c.progress.Phase("Uploading bundle...")
+	return c.sendBuildRequest(ctx, req, bundlePath)
 }
 
-// BuildExists checks if a build exists for the given commit hash
-func (c *CLI) BuildExists(ctx context.Context, commitHash string) (bool, error) {
-	return c.makeExistsRequest(ctx, "builds", "commit_hash", commitHash, "builds")
+// ListBuilds lists builds, filtered and sorted according to opts when set
+func (c *CLI) ListBuilds(ctx context.Context, opts *types.ListOptions) ([]*types.Build, error) {
+	return c.api.ListBuilds(ctx, opts) //nolint:wrapcheck
 }
 
-// DeploymentExists checks if a deployment exists for the given app name
-func (c *CLI) DeploymentExists(ctx context.Context, appName string) (bool, error) {
-	return c.makeExistsRequest(ctx, "deployments", "app_name", appName, "deployments")
+// DeleteBuilds deletes the builds of an app name or commit hash and returns the deleted keys
+func (c *CLI) DeleteBuilds(ctx context.Context, id string) ([]string, error) {
+	return c.api.DeleteBuilds(ctx, id) //nolint:wrapcheck
 }
 
-// Config returns the CLI configuration.
-func (c *CLI) Config() *config.Config { return c.config }
-
-// Client returns the HTTP client.
-func (c *CLI) Client() *http.Client { return c.client }
-
-// makeHTTPRequest is a helper function to make HTTP requests and handle common response processing
-func (c *CLI) makeHTTPRequest(ctx context.Context, url string) ([]byte, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, http.NoBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := c.client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close() //nolint:errcheck
+// GetBuild gets the build for the given commit hash
+func (c *CLI) GetBuild(ctx context.Context, commitHash string) (*types.Build, error) {
+	return c.api.GetBuild(ctx, commitHash) //nolint:wrapcheck
+}
 
-	body, err := io.ReadAll(resp.Body)
+// BuildStatus gets the build for the given commit hash, or for the last commit of the
+// repository in workingDir when no commit hash is given
+func (c *CLI) BuildStatus(ctx context.Context, workingDir, commitHash string) (*types.Build, error) {
+	commitHash, err := c.resolveCommitHash(workingDir, commitHash)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed: %s (status: %d)", string(body), resp.StatusCode)
+		return nil, err
 	}
-
-	return body, nil
+	return c.GetBuild(ctx, commitHash)
 }
 
-// makeListRequest is a helper function to make list requests
-func (c *CLI) makeListRequest(ctx context.Context, endpoint, responseType string) ([]byte, error) {
-	url := fmt.Sprintf("http://%s/api/v1/%s", c.config.GetServerAddr(), endpoint)
-
-	body, err := c.makeHTTPRequest(ctx, url)
+// BuildLogs gets the stored output of the build for the given commit hash, or for the last
+// commit of the repository in workingDir when no commit hash is given
+func (c *CLI) BuildLogs(ctx context.Context, workingDir, commitHash string) (string, error) {
+	commitHash, err := c.resolveCommitHash(workingDir, commitHash)
 	if err != nil {
-		return nil, fmt.Errorf("list %s failed: %w", responseType, err)
+		return "", err
 	}
 
-	return body, nil
+	return c.api.GetBuildLogs(ctx, commitHash) //nolint:wrapcheck
 }
 
-// unmarshalListResponse is a helper function to unmarshal list responses
-func unmarshalListResponse(body []byte, responseType string) (interface{}, error) {
-	var response interface{}
-
-	switch responseType {
-	case "deployments":
-		var resp struct {
-			Deployments []*types.Deployment `json:"deployments"`
-			Count       int                 `json:"count"`
-		}
-		if err := json.Unmarshal(body, &resp); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-		}
-		response = resp.Deployments
-	case "builds":
-		var resp struct {
-			Builds []*types.Build `json:"builds"`
-			Count  int            `json:"count"`
-		}
-		if err := json.Unmarshal(body, &resp); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-		}
-		response = resp.Builds
-	default:
-		return nil, fmt.Errorf("unknown response type: %s", responseType)
+// resolveCommitHash returns commitHash, or the last commit of the repository in workingDir when it is empty
+func (c *CLI) resolveCommitHash(workingDir, commitHash string) (string, error) {
+	if commitHash != "" {
+		return commitHash, nil
 	}
-
-	return response, nil
-}
-
-// makeExistsRequest is a helper function to make exists requests
-func (c *CLI) makeExistsRequest(ctx context.Context, endpoint, param, value, responseType string) (bool, error) {
-	url := fmt.Sprintf("http://%s/api/v1/%s?%s=%s", c.config.GetServerAddr(), endpoint, param, value)
-
-	body, err := c.makeHTTPRequest(ctx, url)
-	if err != nil {
-		return false, fmt.Errorf("check %s exists failed: %w", responseType, err)
+	if err := c.validateGitRepository(workingDir); err != nil {
+		return "", err
 	}
-
-	response, err := unmarshalListResponse(body, responseType)
+	_, commitInfo, err := c.getRepositoryInfo(workingDir)
 	if err != nil {
-		return false, err
+		return "", err
 	}
+	return commitInfo.Hash, nil
+}
 
-	// Use reflection to get the length of the slice
-	responseValue := reflect.ValueOf(response)
-	if responseValue.Kind() == reflect.Slice {
-		return responseValue.Len() > 0, nil
-	}
+// BuildExists checks if a build exists for the given commit hash
+func (c *CLI) BuildExists(ctx context.Context, commitHash string) (bool, error) {
+	return c.api.BuildExists(ctx, commitHash) //nolint:wrapcheck
+}
 
-	return false, nil
+// DeploymentExists checks if a deployment exists for the given app name
+func (c *CLI) DeploymentExists(ctx context.Context, appName string) (bool, error) {
+	return c.api.DeploymentExists(ctx, appName) //nolint:wrapcheck
 }
 
-// makeJSONRequest is a generic helper for making JSON HTTP requests
-func (c *CLI) makeJSONRequest(ctx context.Context, endpoint string, req interface{}, responseType string) ([]byte, error) {
-	url := fmt.Sprintf("http://%s/api/v1/%s", c.config.GetServerAddr(), endpoint)
+// ListApps lists the apps with their latest build and current deployment
+func (c *CLI) ListApps(ctx context.Context) ([]*types.App, error) {
+	return c.api.ListApps(ctx) //nolint:wrapcheck
+}
 
-	data, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
+// GetApp gets an app with its latest build and current deployment
+func (c *CLI) GetApp(ctx context.Context, name string) (*types.App, error) {
+	return c.api.GetApp(ctx, name) //nolint:wrapcheck
+}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+// GetStats gets the aggregate counts of apps, deployments, builds and running containers
+func (c *CLI) GetStats(ctx context.Context) (*types.Stats, error) {
+	return c.api.GetStats(ctx) //nolint:wrapcheck
+}
 
-	httpReq.Header.Set("Content-Type", "application/json")
+// ListContainers lists the containers created by Nina, including the ones no deployment references
+func (c *CLI) ListContainers(ctx context.Context) ([]types.ManagedContainer, error) {
+	return c.api.ListContainers(ctx) //nolint:wrapcheck
+}
 
-	resp, err := c.client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close() //nolint:errcheck
+// ListActivity lists up to limit recent build and deployment status transitions, newest first
+func (c *CLI) ListActivity(ctx context.Context, limit int) ([]types.ActivityEvent, error) {
+	return c.api.ListActivity(ctx, limit) //nolint:wrapcheck
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+// InspectContainer gets the Docker state of a replica container of an app deployment
+func (c *CLI) InspectContainer(ctx context.Context, appName, containerID string) (*types.ContainerDetails, error) {
+	return c.api.InspectContainer(ctx, appName, containerID) //nolint:wrapcheck
+}
 
-	if resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("%s failed: %s (status: %d)", responseType, string(body), resp.StatusCode)
-	}
+// Config returns the CLI configuration.
+func (c *CLI) Config() *config.Config { return c.config }
 
-	return body, nil
-}
+// API returns the Engine API client.
+func (c *CLI) API() *client.Client { return c.api }
@@ -2,10 +2,25 @@ package cli
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/matiasinsaurralde/nina/internal/pkg/git"
 	"github.com/matiasinsaurralde/nina/pkg/config"
 	"github.com/matiasinsaurralde/nina/pkg/logger"
+	"github.com/matiasinsaurralde/nina/pkg/manifest"
+	"github.com/matiasinsaurralde/nina/pkg/types"
 )
 
 func TestDeploy(t *testing.T) {
@@ -20,12 +35,21 @@ func TestDeploy(t *testing.T) {
 	c := NewCLI(cfg, log)
 
 	// Test that Deploy returns an error for non-Git directory
-	_, err := c.Deploy(context.Background(), "/tmp", 1)
+	_, err := c.Deploy(context.Background(), "/tmp", &DeployOptions{Replicas: 1})
 	if err == nil {
 		t.Error("Expected error for non-Git directory, got nil")
 	}
 }
 
+func TestDeploy_InvalidPullPolicy(t *testing.T) {
+	c := NewCLI(&config.Config{Server: config.ServerConfig{Host: "localhost", Port: 9999}}, logger.New(logger.LevelInfo, "text"))
+
+	_, err := c.Deploy(context.Background(), t.TempDir(), &DeployOptions{PullPolicy: "sometimes"})
+	if err == nil || !strings.Contains(err.Error(), "invalid pull policy") {
+		t.Errorf("Expected an invalid pull policy error, got %v", err)
+	}
+}
+
 func TestDeploymentExists(t *testing.T) {
 	// Create a test CLI instance
 	cfg := &config.Config{
@@ -93,7 +117,7 @@ func TestListDeployments(t *testing.T) {
 	c := NewCLI(cfg, log)
 
 	// Test that ListDeployments returns an error when server is not available
-	deployments, err := c.ListDeployments(context.Background())
+	deployments, err := c.ListDeployments(context.Background(), nil)
 	if err == nil {
 		t.Error("Expected error when server is not available, got nil")
 	}
@@ -114,7 +138,7 @@ func TestListBuilds(t *testing.T) {
 	c := NewCLI(cfg, log)
 
 	// Test that ListBuilds returns an error when server is not available
-	builds, err := c.ListBuilds(context.Background())
+	builds, err := c.ListBuilds(context.Background(), nil)
 	if err == nil {
 		t.Error("Expected error when server is not available, got nil")
 	}
@@ -153,8 +177,618 @@ func TestProvision(t *testing.T) {
 	c := NewCLI(cfg, log)
 
 	// Test that Deploy returns an error when server is not available
-	_, err := c.Deploy(context.Background(), "/tmp", 1)
+	_, err := c.Deploy(context.Background(), "/tmp", &DeployOptions{Replicas: 1})
 	if err == nil {
 		t.Error("Expected error when server is not available, got nil")
 	}
 }
+
+func TestResolveReplicas(t *testing.T) {
+	tests := []struct {
+		name           string
+		flag           int
+		manifest       int
+		configReplicas int
+		expected       int
+	}{
+		{"built-in default", 0, 0, 0, types.DefaultReplicas},
+		{"config", 0, 0, 3, 3},
+		{"manifest over config", 0, 2, 3, 2},
+		{"flag over manifest and config", 5, 2, 3, 5},
+		{"flag over config", 4, 0, 3, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Defaults: config.DefaultsConfig{Replicas: tt.configReplicas}}
+			c := NewCLI(cfg, logger.New(logger.LevelInfo, "text"))
+			m := (&manifest.Manifest{Replicas: tt.manifest}).Merge(&manifest.Manifest{Replicas: tt.flag})
+			if got := c.resolveReplicas(m); got != tt.expected {
+				t.Errorf("Expected %d replicas, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeAppName(t *testing.T) {
+	name, err := normalizeAppName("My_Service.API")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if name != "my-service-api" {
+		t.Errorf("Expected 'my-service-api', got '%s'", name)
+	}
+
+	if _, err := normalizeAppName("..."); err == nil {
+		t.Error("Expected error for app name without valid characters, got nil")
+	}
+}
+
+func TestCreateDeploymentRequest(t *testing.T) {
+	log := logger.New(logger.LevelInfo, "text")
+	c := NewCLI(&config.Config{}, log)
+	commitInfo := &git.CommitInfo{Hash: "abc123", Author: "Test User", Email: "test@example.com", Message: "Initial commit"}
+
+	// Without a manifest the default replica count is used
+	req, err := c.createDeploymentRequest("test-app", commitInfo, &manifest.Manifest{})
+	if err != nil {
+		t.Fatalf("Failed to create deployment request: %v", err)
+	}
+	if req.Replicas != types.DefaultReplicas {
+		t.Errorf("Expected %d replicas, got %d", types.DefaultReplicas, req.Replicas)
+	}
+
+	// Manifest values are carried over into the request
+	m := (&manifest.Manifest{
+		Replicas: 2,
+		Port:     9090,
+		Env:      map[string]string{"REGION": "eu"},
+		CPU:      0.5,
+		Memory:   "64m",
+	}).Merge(&manifest.Manifest{Replicas: 4})
+	req, err = c.createDeploymentRequest("test-app", commitInfo, m)
+	if err != nil {
+		t.Fatalf("Failed to create deployment request: %v", err)
+	}
+	if req.Replicas != 4 {
+		t.Errorf("Expected flag replicas 4 to win, got %d", req.Replicas)
+	}
+	if req.Port != 9090 || req.Env["REGION"] != "eu" || req.CPU != 0.5 {
+		t.Errorf("Expected manifest values in request, got %+v", req)
+	}
+	if req.Memory != 64*1024*1024 {
+		t.Errorf("Expected memory %d, got %d", 64*1024*1024, req.Memory)
+	}
+	if req.CommitHash != commitInfo.Hash {
+		t.Errorf("Expected commit hash %s, got %s", commitInfo.Hash, req.CommitHash)
+	}
+}
+
+func TestDeleteDeploymentsByPrefix(t *testing.T) {
+	var gotQuery string
+	c := newTestCLI(t, newFakeGit(), func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query().Get("prefix")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"deleted":["preview-1","preview-2"],"count":2}`))
+	})
+
+	deleted, err := c.DeleteDeploymentsByPrefix(context.Background(), "preview-")
+	if err != nil {
+		t.Fatalf("Failed to delete deployments: %v", err)
+	}
+	if gotQuery != "preview-" {
+		t.Errorf("Expected prefix 'preview-', got '%s'", gotQuery)
+	}
+	if len(deleted) != 2 || deleted[0] != "preview-1" {
+		t.Errorf("Unexpected deleted deployments: %v", deleted)
+	}
+
+	// An empty prefix is rejected before sending a request
+	if _, err := c.DeleteDeploymentsByPrefix(context.Background(), ""); err == nil {
+		t.Error("Expected error for empty prefix, got nil")
+	}
+}
+
+func TestSendBuildRequestStreamsMultipart(t *testing.T) {
+	var (
+		gotMetadata types.BuildRequest
+		gotBundle   string
+	)
+	c := newTestCLI(t, newFakeGit(), func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			switch part.FormName() {
+			case types.BuildFormMetadata:
+				_ = json.NewDecoder(part).Decode(&gotMetadata)
+			case types.BuildFormBundle:
+				data, _ := io.ReadAll(part)
+				gotBundle = string(data)
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"image_tag":"app:abc123"}`))
+	})
+
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(bundlePath, []byte("bundle-data"), 0o600); err != nil {
+		t.Fatalf("Failed to write bundle file: %v", err)
+	}
+
+	image, err := c.sendBuildRequest(context.Background(), &types.BuildRequest{AppName: "app", CommitHash: "abc123"}, bundlePath)
+	if err != nil {
+		t.Fatalf("Failed to send build request: %v", err)
+	}
+	if image.ImageTag != "app:abc123" {
+		t.Errorf("Expected image tag 'app:abc123', got '%s'", image.ImageTag)
+	}
+	if gotMetadata.AppName != "app" || gotMetadata.CommitHash != "abc123" {
+		t.Errorf("Unexpected metadata: %+v", gotMetadata)
+	}
+	if gotBundle != "bundle-data" {
+		t.Errorf("Expected bundle 'bundle-data', got '%s'", gotBundle)
+	}
+}
+
+func TestCreateBuildBundleChecksum(t *testing.T) {
+	workingDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workingDir, "main.go"), []byte("package main"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	c := NewCLI(&config.Config{Engine: config.EngineConfig{TempDir: t.TempDir()}}, logger.New(logger.LevelInfo, "text"))
+
+	bundlePath, checksum, err := c.createBuildBundle(workingDir, 6)
+	if err != nil {
+		t.Fatalf("Failed to create bundle: %v", err)
+	}
+	data, err := os.ReadFile(bundlePath) //nolint:gosec
+	if err != nil {
+		t.Fatalf("Failed to read bundle: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	if want := hex.EncodeToString(sum[:]); checksum != want {
+		t.Errorf("Expected checksum %s, got %s", want, checksum)
+	}
+}
+
+func TestCheckBundleSize(t *testing.T) {
+	workingDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workingDir, "main.go"), []byte("package main"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workingDir, "dump.sql"), make([]byte, 4096), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	bundlePath := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(bundlePath, make([]byte, 2048), 0o600); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+
+	c := NewCLI(&config.Config{Engine: config.EngineConfig{MaxBundleSize: 1024}}, logger.New(logger.LevelInfo, "text"))
+	err := c.checkBundleSize(workingDir, bundlePath)
+	if err == nil {
+		t.Fatal("Expected bundle over the limit to be rejected")
+	}
+	if !strings.Contains(err.Error(), "larger than the 1KiB limit") || !strings.Contains(err.Error(), "dump.sql (4KiB)") {
+		t.Errorf("Expected the limit and the largest files in the error, got %v", err)
+	}
+
+	// A negative limit disables the check
+	c.config.Engine.MaxBundleSize = -1
+	if err := c.checkBundleSize(workingDir, bundlePath); err != nil {
+		t.Errorf("Expected no limit, got %v", err)
+	}
+}
+
+func TestDeleteDeploymentIgnoreMissing(t *testing.T) {
+	var gotQuery string
+	c := newTestCLI(t, newFakeGit(), func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/missing-app") {
+			if r.URL.Query().Get("ignore_missing") != "true" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"Deployment not found","id":"missing-app"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"message":"Deployment not found, nothing to delete","id":"missing-app","missing":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"message":"Deployment deleted successfully","id":"app"}`))
+	})
+
+	deleted, err := c.DeleteDeployment(context.Background(), "app", false)
+	if err != nil || !deleted {
+		t.Errorf("Expected existing deployment to be deleted, got deleted=%v err=%v", deleted, err)
+	}
+	if gotQuery != "" {
+		t.Errorf("Expected no query without ignoreMissing, got %q", gotQuery)
+	}
+
+	if _, err := c.DeleteDeployment(context.Background(), "missing-app", false); err == nil {
+		t.Error("Expected error for a missing deployment without ignoreMissing")
+	}
+
+	deleted, err = c.DeleteDeployment(context.Background(), "missing-app", true)
+	if err != nil {
+		t.Fatalf("Expected missing deployment to be ignored, got %v", err)
+	}
+	if deleted {
+		t.Error("Expected missing deployment to be reported as not deleted")
+	}
+}
+
+func TestGetBuild(t *testing.T) {
+	c := newTestCLI(t, newFakeGit(), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/api/v1/builds/abc123" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"build not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"app_name":"app","commit_hash":"abc123","status":"failed",` +
+			`"failure_log":"failed to build Docker image: exit code 1\nundefined: foo"}`))
+	})
+
+	build, err := c.BuildStatus(context.Background(), t.TempDir(), "abc123")
+	if err != nil {
+		t.Fatalf("Expected build, got error %v", err)
+	}
+	if build.Status != types.BuildStatusFailed || !strings.Contains(build.FailureLog, "undefined: foo") {
+		t.Errorf("Expected failed build with its failure log, got %+v", build)
+	}
+
+	if _, err := c.GetBuild(context.Background(), "missing"); err == nil {
+		t.Error("Expected error for a missing build, got nil")
+	}
+}
+
+func TestBuildLogs(t *testing.T) {
+	c := newTestCLI(t, &fakeGit{notRepository: true}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/api/v1/builds/abc123/logs" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"build logs not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"commit_hash":"abc123","logs":"Step 1/3 : FROM golang\n"}`))
+	})
+
+	logs, err := c.BuildLogs(context.Background(), t.TempDir(), "abc123")
+	if err != nil {
+		t.Fatalf("Expected build logs, got error %v", err)
+	}
+	if logs != "Step 1/3 : FROM golang\n" {
+		t.Errorf("Expected stored logs, got %q", logs)
+	}
+
+	if _, err := c.BuildLogs(context.Background(), t.TempDir(), "missing"); err == nil {
+		t.Error("Expected error for missing build logs, got nil")
+	}
+
+	// Without a commit hash the working directory must be a Git repository
+	if _, err := c.BuildLogs(context.Background(), t.TempDir(), ""); err == nil {
+		t.Error("Expected error outside a Git repository, got nil")
+	}
+}
+
+// fakeGit is a Git whose working directories are repositories with a fixed remote, commit and branch
+type fakeGit struct {
+	notRepository bool
+	repoURL       string
+	commit        git.CommitInfo
+	// commits are the commits of specific directories, the others are at commit
+	commits map[string]git.CommitInfo
+	branch  string
+	// commitRange is returned by GetCommitRange, which fails when it is nil
+	commitRange *git.CommitRange
+}
+
+func (f *fakeGit) IsGitRepository(_ string) bool { return !f.notRepository }
+
+func (f *fakeGit) GetRepoURL(_ string) (string, error) { return f.repoURL, nil }
+
+func (f *fakeGit) GetLastCommitInfo(dir string) (*git.CommitInfo, error) {
+	commit, ok := f.commits[dir]
+	if !ok {
+		commit = f.commit
+	}
+	return &commit, nil
+}
+
+func (f *fakeGit) GetCurrentBranch(_ string) (string, error) {
+	if f.branch == "" {
+		return "", errors.New("repository is not on a branch")
+	}
+	return f.branch, nil
+}
+
+func (f *fakeGit) GetCommitRange(_, from, to string) (*git.CommitRange, error) {
+	if f.commitRange == nil {
+		return nil, fmt.Errorf("commit %s is not in the local repository", from)
+	}
+	commitRange := *f.commitRange
+	commitRange.From, commitRange.To = from, to
+	return &commitRange, nil
+}
+
+// newFakeGit returns a fakeGit for the my-app repository
+func newFakeGit() *fakeGit {
+	return &fakeGit{
+		repoURL: "git@github.com:example/my-app.git",
+		commit:  git.CommitInfo{Hash: "abc123", Author: "Test User", Email: "test@example.com", Message: "Initial commit"},
+		branch:  "feature/login",
+	}
+}
+
+// newTestCLI creates a CLI using a fake Git and an Engine served by handler
+func newTestCLI(t *testing.T, repo git.Git, handler http.HandlerFunc) *CLI {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	host, portStr, _ := strings.Cut(strings.TrimPrefix(server.URL, "http://"), ":")
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("Failed to parse test server port: %v", err)
+	}
+	cfg := &config.Config{
+		Server: config.ServerConfig{Host: host, Port: port},
+		Engine: config.EngineConfig{TempDir: t.TempDir()},
+	}
+	c := NewCLI(cfg, logger.New(logger.LevelInfo, "text"))
+	c.SetGit(repo)
+	return c
+}
+
+func TestGetDeploymentStatus(t *testing.T) {
+	c := newTestCLI(t, newFakeGit(), func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/deployments/app/status" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(&types.Deployment{
+			AppName: "app", CommitHash: "abc123", CommitMessage: "Fix the homepage", Author: "Test Author",
+			Containers: []types.Container{{ContainerID: "container1", Port: 8080}}, Replicas: 2,
+			Status: types.DeploymentStatusPartiallyReady,
+		})
+	})
+
+	deployment, err := c.GetDeploymentStatus(context.Background(), "app")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if deployment.Status != types.DeploymentStatusPartiallyReady || deployment.CommitHash != "abc123" ||
+		deployment.CommitMessage != "Fix the homepage" || deployment.Author != "Test Author" {
+		t.Errorf("Unexpected deployment %+v", deployment)
+	}
+	if len(deployment.Containers) != 1 || deployment.Containers[0].ContainerID != "container1" {
+		t.Errorf("Expected the deployment containers, got %+v", deployment.Containers)
+	}
+
+	if _, err := c.GetDeploymentStatus(context.Background(), "missing"); err == nil {
+		t.Error("Expected an error for a missing deployment")
+	}
+}
+
+func TestDeploy_FakeGit(t *testing.T) {
+	var got types.DeploymentRequest
+	c := newTestCLI(t, newFakeGit(), func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/deployments":
+			_, _ = w.Write([]byte(`{"deployments":[]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/deploy":
+			_ = json.NewDecoder(r.Body).Decode(&got)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(&types.Deployment{ID: "d1", AppName: got.AppName, CommitHash: got.CommitHash})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	deployment, err := c.Deploy(context.Background(), t.TempDir(), &DeployOptions{Replicas: 2, Preview: true, Domains: []string{"www.example.com"}})
+	if err != nil {
+		t.Fatalf("Failed to deploy: %v", err)
+	}
+	if deployment.ID != "d1" {
+		t.Errorf("Expected the deployment returned by the Engine, got %+v", deployment)
+	}
+	if got.AppName != "my-app-feature-login" || got.CommitHash != "abc123" || got.Author != "Test User" {
+		t.Errorf("Expected the preview app and commit of the repository, got %+v", got)
+	}
+	if got.Replicas != 2 || len(got.Domains) != 1 {
+		t.Errorf("Expected the options in the request, got %+v", got)
+	}
+}
+
+func TestDeploy_FakeGitNotRepository(t *testing.T) {
+	c := newTestCLI(t, &fakeGit{notRepository: true}, func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("Expected no request to the Engine")
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	if _, err := c.Deploy(context.Background(), t.TempDir(), nil); err == nil || !strings.Contains(err.Error(), "not a Git repository") {
+		t.Errorf("Expected a not a Git repository error, got %v", err)
+	}
+}
+
+func TestBuild_FakeGit(t *testing.T) {
+	var (
+		got       types.BuildRequest
+		gotBundle []byte
+	)
+	c := newTestCLI(t, newFakeGit(), func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/builds":
+			_, _ = w.Write([]byte(`{"builds":[]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/build":
+			reader, err := r.MultipartReader()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for part, err := reader.NextPart(); err == nil; part, err = reader.NextPart() {
+				switch part.FormName() {
+				case types.BuildFormMetadata:
+					_ = json.NewDecoder(part).Decode(&got)
+				case types.BuildFormBundle:
+					gotBundle, _ = io.ReadAll(part)
+				}
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"image_tag":"nina-my-app-abc123"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	workingDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workingDir, "main.go"), []byte("package main\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+
+	image, err := c.Build(context.Background(), workingDir, &BuildOptions{BuildArgs: map[string]string{"VERSION": "1.0"}})
+	if err != nil {
+		t.Fatalf("Failed to build: %v", err)
+	}
+	if image.ImageTag != "nina-my-app-abc123" {
+		t.Errorf("Expected the image returned by the Engine, got %+v", image)
+	}
+	if got.AppName != "my-app" || got.CommitHash != "abc123" || got.RepoURL != "git@github.com:example/my-app.git" {
+		t.Errorf("Expected the app and commit of the repository, got %+v", got)
+	}
+	if got.BuildArgs["VERSION"] != "1.0" || got.BundleChecksum == "" {
+		t.Errorf("Expected the build arguments and bundle checksum, got %+v", got)
+	}
+	if len(gotBundle) == 0 {
+		t.Error("Expected the bundle to be uploaded")
+	}
+}
+
+func TestDiff_FakeGit(t *testing.T) {
+	repo := newFakeGit()
+	repo.commitRange = &git.CommitRange{
+		Commits:        []git.CommitInfo{{Hash: "abc123", Message: "Initial commit"}},
+		ChangedFiles:   3,
+		FromIsAncestor: true,
+	}
+	deployed := true
+	c := newTestCLI(t, repo, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/deployments/my-app/status":
+			if !deployed {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"deployment not found"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":"d1","app_name":"my-app","commit_hash":"def456","status":"ready"}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	})
+
+	diff, err := c.Diff(context.Background(), t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Failed to diff: %v", err)
+	}
+	if diff.AppName != "my-app" || diff.DeployedCommit != "def456" || diff.RangeErr != nil {
+		t.Fatalf("Unexpected diff %+v", diff)
+	}
+	if diff.Range.From != "def456" || diff.Range.To != "abc123" || diff.Range.ChangedFiles != 3 {
+		t.Errorf("Expected the range from the deployed commit to the local commit, got %+v", diff.Range)
+	}
+
+	// The deployed commit may be missing from the local repository
+	repo.commitRange = nil
+	if diff, err = c.Diff(context.Background(), t.TempDir(), nil); err != nil {
+		t.Fatalf("Failed to diff: %v", err)
+	}
+	if diff.Range != nil || diff.RangeErr == nil {
+		t.Errorf("Expected a range error, got %+v", diff)
+	}
+
+	// Apps that are not deployed have nothing to compare with
+	deployed = false
+	if diff, err = c.Diff(context.Background(), t.TempDir(), nil); err != nil {
+		t.Fatalf("Failed to diff: %v", err)
+	}
+	if diff.DeployedCommit != "" || diff.Range != nil || diff.RangeErr != nil {
+		t.Errorf("Expected an empty diff for an app that is not deployed, got %+v", diff)
+	}
+}
+
+func TestDeploy_ImageOutsideRepository(t *testing.T) {
+	var got types.DeploymentRequest
+	c := newTestCLI(t, &fakeGit{notRepository: true}, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/deployments":
+			_, _ = w.Write([]byte(`{"deployments":[]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/deploy":
+			_ = json.NewDecoder(r.Body).Decode(&got)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(&types.Deployment{ID: "d1", AppName: got.AppName, Image: got.Image})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	if _, err := c.Deploy(context.Background(), t.TempDir(), &DeployOptions{Image: "registry.example.com/team/web-app:1.2"}); err != nil {
+		t.Fatalf("Failed to deploy: %v", err)
+	}
+	if got.AppName != "web-app" || got.Image != "registry.example.com/team/web-app:1.2" || got.CommitHash != "" {
+		t.Errorf("Expected the image and the app name derived from it, got %+v", got)
+	}
+}
+
+func TestDeploy_ImageInRepository(t *testing.T) {
+	var got types.DeploymentRequest
+	c := newTestCLI(t, newFakeGit(), func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/deployments":
+			_, _ = w.Write([]byte(`{"deployments":[]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/deploy":
+			_ = json.NewDecoder(r.Body).Decode(&got)
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(&types.Deployment{ID: "d1", AppName: got.AppName, Image: got.Image})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	// The checked out commit is not what the image was built from
+	if _, err := c.Deploy(context.Background(), t.TempDir(), &DeployOptions{Image: "registry.example.com/team/web-app:1.2"}); err != nil {
+		t.Fatalf("Failed to deploy: %v", err)
+	}
+	if got.Image != "registry.example.com/team/web-app:1.2" || got.CommitHash != "" || got.CommitMessage != "" {
+		t.Errorf("Expected the image without the local commit, got %+v", got)
+	}
+}
+
+func TestImageAppName(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                             "nginx",
+		"nginx:1.27":                        "nginx",
+		"registry.example.com/team/app:1.2": "app",
+		"localhost:5000/app@sha256:" + strings.Repeat("a", 64): "app",
+	}
+	for image, expected := range tests {
+		if got, err := imageAppName(image); err != nil || got != expected {
+			t.Errorf("imageAppName(%q) = %q, %v, want %q", image, got, err, expected)
+		}
+	}
+	if _, err := imageAppName("Not An Image"); err == nil {
+		t.Error("Expected an error for an invalid image, got nil")
+	}
+}
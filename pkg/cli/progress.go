@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// spinnerInterval is the time between two spinner frames
+const spinnerInterval = 100 * time.Millisecond
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Progress reports the phases of a long running command.
+// With animation a spinner is redrawn on a single line, otherwise each phase is printed once.
+// A nil Progress discards all output.
+type Progress struct {
+	w       io.Writer
+	animate bool
+
+	mu      sync.Mutex
+	phase   string
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewProgress creates a progress reporter writing to w
+func NewProgress(w io.Writer, animate bool) *Progress {
+	return &Progress{w: w, animate: animate}
+}
+
+// Phase switches the progress output to a new phase
+func (p *Progress) Phase(phase string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if phase == p.phase {
+		return
+	}
+	p.phase = phase
+
+	if !p.animate {
+		fmt.Fprintln(p.w, phase) //nolint:errcheck
+		return
+	}
+	if p.stop == nil {
+		p.stop = make(chan struct{})
+		p.stopped = make(chan struct{})
+		go p.spin(p.stop, p.stopped)
+	}
+}
+
+// Stop ends the progress output and clears the spinner line
+func (p *Progress) Stop() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	stop, stopped := p.stop, p.stopped
+	p.stop, p.stopped = nil, nil
+	p.phase = ""
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+}
+
+// spin redraws the spinner with the current phase until Stop is called
+func (p *Progress) spin(stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+
+	for frame := 0; ; frame++ {
+		p.mu.Lock()
+		fmt.Fprintf(p.w, "\r\033[K%s %s", spinnerFrames[frame%len(spinnerFrames)], p.phase) //nolint:errcheck
+		p.mu.Unlock()
+
+		select {
+		case <-stop:
+			fmt.Fprint(p.w, "\r\033[K") //nolint:errcheck
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// phaseReader switches the progress to the next phase once the wrapped reader is exhausted
+type phaseReader struct {
+	r        io.Reader
+	progress *Progress
+	next     string
+}
+
+// Read implements io.Reader
+func (r *phaseReader) Read(data []byte) (int, error) {
+	n, err := r.r.Read(data)
+	if err == io.EOF {
+		r.progress.Phase(r.next)
+	}
+	return n, err //nolint:wrapcheck
+}
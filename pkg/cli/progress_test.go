@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for use by the spinner goroutine
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(data []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(data)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestProgress_Plain(t *testing.T) {
+	var out bytes.Buffer
+	p := NewProgress(&out, false)
+
+	p.Phase("Archiving...")
+	p.Phase("Archiving...")
+	p.Phase("Uploading bundle...")
+	p.Stop()
+
+	if got := out.String(); got != "Archiving...\nUploading bundle...\n" {
+		t.Errorf("Unexpected progress output: %q", got)
+	}
+}
+
+func TestProgress_Animated(t *testing.T) {
+	var out syncBuffer
+	p := NewProgress(&out, true)
+
+	p.Phase("Building...")
+	time.Sleep(3 * spinnerInterval)
+	p.Stop()
+
+	got := out.String()
+	if !strings.Contains(got, spinnerFrames[0]+" Building...") {
+		t.Errorf("Expected spinner with phase, got %q", got)
+	}
+	if !strings.HasSuffix(got, "\r\033[K") {
+		t.Errorf("Expected spinner line to be cleared on stop, got %q", got)
+	}
+
+	// Stopping twice is harmless
+	p.Stop()
+}
+
+func TestProgress_Nil(t *testing.T) {
+	var p *Progress
+	p.Phase("Building...")
+	p.Stop()
+}
+
+func TestPhaseReader(t *testing.T) {
+	var out bytes.Buffer
+	p := NewProgress(&out, false)
+	p.Phase("Uploading bundle...")
+
+	r := &phaseReader{r: strings.NewReader("bundle"), progress: p, next: "Building..."}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if string(data) != "bundle" {
+		t.Errorf("Expected data to be passed through, got %q", data)
+	}
+	if got := out.String(); got != "Uploading bundle...\nBuilding...\n" {
+		t.Errorf("Unexpected progress output: %q", got)
+	}
+}
@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/stack"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// stackPollInterval is how often the deployment of a stack service is checked while waiting for it to come up
+var stackPollInterval = time.Second
+
+// StackServiceResult is the outcome of building and deploying a service of a stack
+type StackServiceResult struct {
+	Service string
+	// Built is false when the build of the service commit already existed and was reused
+	Built      bool
+	Deployment *types.Deployment
+	Err        error
+}
+
+// DeployStack builds and deploys the services of a stack file in dependency order. Every service is deployed
+// once the services it depends on are up, and a service is skipped when one of its dependencies failed.
+// opts applies to every service. The returned error is only set when the stack cannot be deployed at all.
+func (c *CLI) DeployStack(ctx context.Context, stackPath string, opts *DeployOptions) ([]*StackServiceResult, error) {
+	if opts == nil {
+		opts = &DeployOptions{}
+	}
+	s, err := stack.Load(stackPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stack: %w", err)
+	}
+	order, err := s.Order()
+	if err != nil {
+		return nil, fmt.Errorf("failed to order services: %w", err)
+	}
+	if err := c.checkStackCommits(s, order); err != nil {
+		return nil, err
+	}
+
+	failed := map[string]bool{}
+	results := make([]*StackServiceResult, 0, len(order))
+	for _, name := range order {
+		result := &StackServiceResult{Service: name}
+		results = append(results, result)
+		for _, dep := range s.Services[name].DependsOn {
+			if failed[dep] {
+				result.Err = fmt.Errorf("skipped, dependency %s failed", dep)
+				break
+			}
+		}
+		if result.Err == nil {
+			result.Built, result.Deployment, result.Err = c.deployStackService(ctx, s, name, opts)
+		}
+		failed[name] = result.Err != nil
+	}
+	return results, nil
+}
+
+// checkStackCommits enforces that every service of a stack is its own Git repository, which no two
+// services at the same commit can be. Builds are identified by their commit, so services sharing a
+// repository would be deployed with the image of the first one.
+func (c *CLI) checkStackCommits(s *stack.Stack, order []string) error {
+	services := map[string]string{}
+	for _, name := range order {
+		dir := s.Dir(name)
+		if err := c.validateGitRepository(dir); err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		_, commitInfo, err := c.getRepositoryInfo(dir)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		if other, ok := services[commitInfo.Hash]; ok {
+			return fmt.Errorf("services %s and %s are both at commit %s, every service of a stack must be its own Git repository",
+				other, name, commitInfo.Hash)
+		}
+		services[commitInfo.Hash] = name
+	}
+	return nil
+}
+
+// deployStackService builds the service unless its commit was already built, deploys it and waits for its
+// containers to come up
+func (c *CLI) deployStackService(ctx context.Context, s *stack.Stack, name string, opts *DeployOptions,
+) (built bool, deployment *types.Deployment, err error) {
+	dir, overrides := s.Dir(name), s.Services[name].Manifest(name)
+
+	_, commitInfo, err := c.getRepositoryInfo(dir)
+	if err != nil {
+		return false, nil, err
+	}
+	exists, err := c.BuildExists(ctx, commitInfo.Hash)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to check if build exists: %w", err)
+	}
+	if !exists {
+		if _, err := c.Build(ctx, dir, &BuildOptions{Overrides: overrides}); err != nil {
+			return false, nil, fmt.Errorf("failed to build: %w", err)
+		}
+	}
+
+	deployOpts := *opts
+	deployOpts.Overrides = overrides
+	deployment, err = c.Deploy(ctx, dir, &deployOpts)
+	if err != nil {
+		return !exists, nil, fmt.Errorf("failed to deploy: %w", err)
+	}
+	deployment, err = c.waitForDeployment(ctx, deployment.AppName)
+	return !exists, deployment, err
+}
+
+// waitForDeployment polls the deployment of an app until its containers are up or it failed
+func (c *CLI) waitForDeployment(ctx context.Context, appName string) (*types.Deployment, error) {
+	for {
+		deployment, err := c.api.GetDeployment(ctx, appName)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+		switch deployment.Status {
+		case types.DeploymentStatusReady, types.DeploymentStatusPartiallyReady:
+			return deployment, nil
+		case types.DeploymentStatusFailed:
+			return deployment, fmt.Errorf("deployment of %s failed", appName)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for the deployment of %s: %w", appName, ctx.Err())
+		case <-time.After(stackPollInterval):
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/internal/pkg/git"
+	"github.com/matiasinsaurralde/nina/pkg/stack"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// writeStack writes a stack file and a source directory for every service, returning the stack file path
+func writeStack(t *testing.T, contents string, services ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, service := range services {
+		serviceDir := filepath.Join(dir, service)
+		if err := os.MkdirAll(serviceDir, 0o750); err != nil {
+			t.Fatalf("Failed to create service directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(serviceDir, "main.go"), []byte("package main\n"), 0o600); err != nil {
+			t.Fatalf("Failed to write source: %v", err)
+		}
+	}
+	path := filepath.Join(dir, stack.FileName)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("Failed to write stack file: %v", err)
+	}
+	return path
+}
+
+// stackGit returns a fake Git where every service directory of the stack is a repository at its own commit
+func stackGit(stackPath string, services ...string) *fakeGit {
+	repo := newFakeGit()
+	repo.commits = map[string]git.CommitInfo{}
+	for _, service := range services {
+		repo.commits[filepath.Join(filepath.Dir(stackPath), service)] = git.CommitInfo{Hash: service + "-commit"}
+	}
+	return repo
+}
+
+func TestDeployStack(t *testing.T) {
+	stackPollInterval = time.Millisecond
+	path := writeStack(t, `
+services:
+  web:
+    path: ./web
+    replicas: 2
+    depends_on: [api]
+  api:
+    path: ./api
+    port: 9090
+    env:
+      MODE: stack
+  worker:
+    path: ./worker
+    depends_on: [web]
+  cron:
+    path: ./cron
+`, "web", "api", "worker", "cron")
+
+	var built, deployed []string
+	deployRequests := map[string]types.DeploymentRequest{}
+	c := newTestCLI(t, stackGit(path, "web", "api", "worker", "cron"), func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/builds":
+			// The api commit was built before
+			if r.URL.Query().Get("commit_hash") == "api-commit" {
+				_, _ = w.Write([]byte(`{"builds":[{"commit_hash":"api-commit","status":"built"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"builds":[]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/build":
+			reader, _ := r.MultipartReader()
+			part, _ := reader.NextPart()
+			var req types.BuildRequest
+			_ = json.NewDecoder(part).Decode(&req)
+			built = append(built, req.AppName)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"image_tag":"nina-` + req.AppName + `"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/deployments":
+			_, _ = w.Write([]byte(`{"deployments":[]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/deploy":
+			var req types.DeploymentRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			deployed = append(deployed, req.AppName)
+			deployRequests[req.AppName] = req
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(&types.Deployment{AppName: req.AppName, Status: types.DeploymentStatusDeploying})
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/status"):
+			appName := strings.Split(r.URL.Path, "/")[4]
+			status := types.DeploymentStatusReady
+			if appName == "web" {
+				status = types.DeploymentStatusFailed
+			}
+			_ = json.NewEncoder(w).Encode(&types.Deployment{AppName: appName, Status: status})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	results, err := c.DeployStack(context.Background(), path, nil)
+	if err != nil {
+		t.Fatalf("Failed to deploy stack: %v", err)
+	}
+
+	// Dependencies come first, the rest in name order, and worker is skipped as web failed
+	if strings.Join(deployed, ",") != "api,cron,web" {
+		t.Errorf("Expected api, cron and web to be deployed in order, got %v", deployed)
+	}
+	if strings.Join(built, ",") != "cron,web" {
+		t.Errorf("Expected the existing api build to be reused, got builds %v", built)
+	}
+	if req := deployRequests["api"]; req.Port != 9090 || req.Env["MODE"] != "stack" || req.CommitHash != "api-commit" {
+		t.Errorf("Expected the api service settings in its deployment, got %+v", req)
+	}
+	if req := deployRequests["web"]; req.Replicas != 2 {
+		t.Errorf("Expected 2 web replicas, got %d", req.Replicas)
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("Expected a result per service, got %d", len(results))
+	}
+	byService := map[string]*StackServiceResult{}
+	for _, result := range results {
+		byService[result.Service] = result
+	}
+	if r := byService["api"]; r.Err != nil || r.Built || r.Deployment.Status != types.DeploymentStatusReady {
+		t.Errorf("Unexpected api result %+v", r)
+	}
+	if r := byService["web"]; r.Err == nil || !r.Built {
+		t.Errorf("Expected the web deployment to fail, got %+v", r)
+	}
+	if r := byService["worker"]; r.Err == nil || !strings.Contains(r.Err.Error(), "dependency web failed") {
+		t.Errorf("Expected worker to be skipped, got %+v", r)
+	}
+}
+
+func TestDeployStack_SharedCommit(t *testing.T) {
+	path := writeStack(t, "services:\n  a:\n    path: ./a\n  b:\n    path: ./b\n", "a", "b")
+	c := newTestCLI(t, newFakeGit(), http.NotFound)
+
+	_, err := c.DeployStack(context.Background(), path, nil)
+	if err == nil || !strings.Contains(err.Error(), "services a and b are both at commit abc123") {
+		t.Errorf("Expected a shared commit error, got %v", err)
+	}
+}
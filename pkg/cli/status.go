@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// StatusColor returns the color a deployment or build status is displayed in, or an empty string for none
+func StatusColor(status string) string {
+	switch status {
+	case string(types.DeploymentStatusReady), string(types.BuildStatusBuilt):
+		return "green"
+	case string(types.DeploymentStatusDeploying), string(types.DeploymentStatusPartiallyReady),
+		string(types.BuildStatusBuilding), string(types.BuildStatusPending):
+		return "yellow"
+	case string(types.DeploymentStatusFailed):
+		return "red"
+	default:
+		return ""
+	}
+}
+
+// ColorizeStatus wraps a deployment or build status in the ANSI codes of its color
+func ColorizeStatus(status string) string {
+	return logger.Colorize(status, StatusColor(status))
+}
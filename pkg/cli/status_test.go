@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestStatusColor(t *testing.T) {
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{string(types.DeploymentStatusReady), "green"},
+		{string(types.BuildStatusBuilt), "green"},
+		{string(types.DeploymentStatusDeploying), "yellow"},
+		{string(types.DeploymentStatusPartiallyReady), "yellow"},
+		{string(types.BuildStatusBuilding), "yellow"},
+		{string(types.BuildStatusPending), "yellow"},
+		{string(types.DeploymentStatusFailed), "red"},
+		{string(types.DeploymentStatusUnavailable), ""},
+	}
+	for _, tt := range tests {
+		if got := StatusColor(tt.status); got != tt.want {
+			t.Errorf("StatusColor(%q) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestColorizeStatus(t *testing.T) {
+	if got := ColorizeStatus("failed"); got != "\033[31mfailed\033[0m" {
+		t.Errorf("Expected failed in red, got %q", got)
+	}
+	if got := ColorizeStatus("unavailable"); got != "unavailable" {
+		t.Errorf("Expected statuses without a color to stay plain, got %q", got)
+	}
+}
@@ -0,0 +1,143 @@
+// Package table renders the aligned text tables printed by the CLI list commands.
+package table
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ellipsis marks values that were truncated to fit their column
+const ellipsis = "..."
+
+// columnSeparator is printed between two columns
+const columnSeparator = " "
+
+// Alignment is the horizontal alignment of the values in a column
+type Alignment int
+
+const (
+	// AlignLeft pads values on the right
+	AlignLeft Alignment = iota
+	// AlignRight pads values on the left
+	AlignRight
+)
+
+// Column describes a table column
+type Column struct {
+	Header string
+	// MaxWidth truncates longer values, zero leaves values untouched
+	MaxWidth int
+	// Clip cuts values at MaxWidth instead of ending them with an ellipsis
+	Clip  bool
+	Align Alignment
+	// Style decorates values, e.g. with ANSI colors, without the decoration counting towards the width
+	Style func(value string) string
+}
+
+// Table holds the columns and rows of a table.
+// Columns are as wide as their widest value, up to their MaxWidth.
+type Table struct {
+	columns []Column
+	rows    [][]string
+}
+
+// New creates a table with the given columns
+func New(columns ...Column) *Table {
+	return &Table{columns: columns}
+}
+
+// AddRow appends a row. Missing values are left empty and extra values are ignored.
+func (t *Table) AddRow(values ...string) {
+	row := make([]string, len(t.columns))
+	for i := range row {
+		if i < len(values) {
+			row[i] = truncate(values[i], t.columns[i].MaxWidth, t.columns[i].Clip)
+		}
+	}
+	t.rows = append(t.rows, row)
+}
+
+// Len returns the number of rows
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+// Render writes the header, a separator line and the rows to w
+func (t *Table) Render(w io.Writer) error {
+	widths := t.widths()
+
+	headers := make([]string, len(t.columns))
+	total := 0
+	for i, col := range t.columns {
+		headers[i] = truncate(col.Header, col.MaxWidth, col.Clip)
+		total += widths[i]
+	}
+	total += len(columnSeparator) * max(len(t.columns)-1, 0)
+
+	if err := t.renderRow(w, headers, widths, false); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, strings.Repeat("-", total)); err != nil {
+		return fmt.Errorf("failed to write table: %w", err)
+	}
+	for _, row := range t.rows {
+		if err := t.renderRow(w, row, widths, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// widths returns the width of every column
+func (t *Table) widths() []int {
+	widths := make([]int, len(t.columns))
+	for i, col := range t.columns {
+		widths[i] = utf8.RuneCountInString(truncate(col.Header, col.MaxWidth, col.Clip))
+	}
+	for _, row := range t.rows {
+		for i, value := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(value))
+		}
+	}
+	return widths
+}
+
+// renderRow writes a single row padded to the column widths, without trailing spaces.
+// Column styles are applied when styled is set.
+func (t *Table) renderRow(w io.Writer, values []string, widths []int, styled bool) error {
+	var b strings.Builder
+	for i, value := range values {
+		if i > 0 {
+			b.WriteString(columnSeparator)
+		}
+		padding := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(value))
+		if styled && t.columns[i].Style != nil && value != "" {
+			value = t.columns[i].Style(value)
+		}
+		if t.columns[i].Align == AlignRight {
+			b.WriteString(padding)
+			b.WriteString(value)
+		} else {
+			b.WriteString(value)
+			b.WriteString(padding)
+		}
+	}
+	if _, err := fmt.Fprintln(w, strings.TrimRight(b.String(), " ")); err != nil {
+		return fmt.Errorf("failed to write table: %w", err)
+	}
+	return nil
+}
+
+// truncate shortens value to at most width runes, ending it with an ellipsis unless clip is set
+func truncate(value string, width int, clip bool) string {
+	if width <= 0 || utf8.RuneCountInString(value) <= width {
+		return value
+	}
+	runes := []rune(value)
+	if clip || width <= len(ellipsis) {
+		return string(runes[:width])
+	}
+	return string(runes[:width-len(ellipsis)]) + ellipsis
+}
@@ -0,0 +1,124 @@
+package table
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, tbl *Table) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tbl.Render(&buf); err != nil {
+		t.Fatalf("Failed to render table: %v", err)
+	}
+	return buf.String()
+}
+
+func TestRender_Alignment(t *testing.T) {
+	tbl := New(
+		Column{Header: "NAME"},
+		Column{Header: "COUNT", Align: AlignRight},
+		Column{Header: "STATUS"},
+	)
+	tbl.AddRow("api", "3", "ready")
+	tbl.AddRow("worker-long", "12", "failed")
+
+	want := "NAME        COUNT STATUS\n" +
+		"------------------------\n" +
+		"api             3 ready\n" +
+		"worker-long    12 failed\n"
+	if got := render(t, tbl); got != want {
+		t.Errorf("Unexpected table:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRender_Truncation(t *testing.T) {
+	tbl := New(
+		Column{Header: "HASH", MaxWidth: 6, Clip: true},
+		Column{Header: "MESSAGE", MaxWidth: 10},
+	)
+	tbl.AddRow("abcdef123456", "a very long commit message")
+	tbl.AddRow("abc", "short")
+
+	want := "HASH   MESSAGE\n" +
+		"-----------------\n" +
+		"abcdef a very ...\n" +
+		"abc    short\n"
+	if got := render(t, tbl); got != want {
+		t.Errorf("Unexpected table:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRender_HeaderWiderThanValues(t *testing.T) {
+	tbl := New(Column{Header: "COMMIT HASH"}, Column{Header: "X"})
+	tbl.AddRow("abc", "1")
+
+	lines := strings.Split(strings.TrimSuffix(render(t, tbl), "\n"), "\n")
+	if lines[2] != "abc         1" {
+		t.Errorf("Expected values padded to the header width, got %q", lines[2])
+	}
+}
+
+func TestRender_NoRows(t *testing.T) {
+	tbl := New(Column{Header: "A"}, Column{Header: "B"})
+	if got := render(t, tbl); got != "A B\n---\n" {
+		t.Errorf("Expected only the header, got %q", got)
+	}
+	if tbl.Len() != 0 {
+		t.Errorf("Expected no rows, got %d", tbl.Len())
+	}
+}
+
+func TestAddRow_MissingAndExtraValues(t *testing.T) {
+	tbl := New(Column{Header: "A"}, Column{Header: "B"})
+	tbl.AddRow("1")
+	tbl.AddRow("2", "3", "ignored")
+
+	want := "A B\n---\n1\n2 3\n"
+	if got := render(t, tbl); got != want {
+		t.Errorf("Unexpected table %q, want %q", got, want)
+	}
+}
+
+func TestRender_StyleKeepsAlignment(t *testing.T) {
+	tbl := New(
+		Column{Header: "STATUS", Style: func(v string) string { return "<" + v + ">" }},
+		Column{Header: "AGE"},
+	)
+	tbl.AddRow("ready", "1m")
+	tbl.AddRow("failed", "2m")
+
+	want := "STATUS AGE\n" +
+		"----------\n" +
+		"<ready>  1m\n" +
+		"<failed> 2m\n"
+	if got := render(t, tbl); got != want {
+		t.Errorf("Unexpected table:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		width int
+		clip  bool
+		want  string
+	}{
+		{"unlimited", "hello world", 0, false, "hello world"},
+		{"fits exactly", "hello", 5, false, "hello"},
+		{"ellipsis", "hello world", 8, false, "hello..."},
+		{"clip", "hello world", 5, true, "hello"},
+		{"narrower than ellipsis", "hello", 2, false, "he"},
+		{"multibyte", "héllo wörld", 7, false, "héll..."},
+		{"empty", "", 3, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncate(tt.value, tt.width, tt.clip); got != tt.want {
+				t.Errorf("truncate(%q, %d, %v) = %q, want %q", tt.value, tt.width, tt.clip, got, tt.want)
+			}
+		})
+	}
+}
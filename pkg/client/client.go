@@ -0,0 +1,421 @@
+// Package client provides a Go client for the Nina Engine API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// Client calls the API of a Nina Engine
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests, http.DefaultClient by default
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New creates a client for the Engine at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// BaseURL returns the URL of the Engine
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// maxErrorBodyLength bounds the raw response body reported in an APIError, so pages returned by
+// proxies in front of the Engine do not flood the output
+const maxErrorBodyLength = 200
+
+// APIError is returned when the Engine answers with an unexpected status code
+type APIError struct {
+	StatusCode int
+	// Message is the error reported by the Engine, or the raw response body
+	Message string
+	// Fields maps the invalid request fields to the reason they were rejected, for validation errors
+	Fields map[string]string
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (status: %d)", e.Message, e.StatusCode)
+}
+
+// IsNotFound reports whether err is an APIError for a missing resource
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// newAPIError builds an APIError from a response, using the error and fields of JSON error bodies.
+// Other bodies are reported as is, truncated, and empty ones as the status text.
+func newAPIError(statusCode int, body []byte) *APIError {
+	var resp struct {
+		Error  string            `json:"error"`
+		Fields map[string]string `json:"fields"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Error != "" {
+		return &APIError{StatusCode: statusCode, Message: resp.Error, Fields: resp.Fields}
+	}
+
+	message := strings.TrimSpace(string(body))
+	switch {
+	case message == "":
+		message = http.StatusText(statusCode)
+	case len(message) > maxErrorBodyLength:
+		message = strings.ToValidUTF8(message[:maxErrorBodyLength], "") + "..."
+	}
+	return &APIError{StatusCode: statusCode, Message: message}
+}
+
+// Health checks that the Engine is healthy
+func (c *Client) Health(ctx context.Context) error {
+	if err := c.do(ctx, http.MethodGet, "/health", nil, "", http.StatusOK, nil); err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	return nil
+}
+
+// Provision creates a legacy deployment
+func (c *Client) Provision(ctx context.Context, req *types.ProvisionRequest) (*types.LegacyDeployment, error) {
+	var deployment types.LegacyDeployment
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v1/provision", req, http.StatusCreated, &deployment); err != nil {
+		return nil, fmt.Errorf("provision failed: %w", err)
+	}
+	return &deployment, nil
+}
+
+// Deploy deploys a built commit. The containers are started in the background,
+// poll GetDeploymentStatus until the deployment is ready.
+func (c *Client) Deploy(ctx context.Context, req *types.DeploymentRequest) (*types.Deployment, error) {
+	var deployment types.Deployment
+	if err := c.doJSON(ctx, http.MethodPost, "/api/v1/deploy", req, http.StatusCreated, &deployment); err != nil {
+		return nil, fmt.Errorf("deploy failed: %w", err)
+	}
+	return &deployment, nil
+}
+
+// Build uploads a gzipped tarball of the application together with the build metadata and waits for the image.
+// The bundle is streamed, it is never held in memory as a whole.
+func (c *Client) Build(ctx context.Context, req *types.BuildRequest, bundle io.Reader) (*types.DeploymentImage, error) {
+	pr, pw := io.Pipe()
+	defer pr.Close() //nolint:errcheck
+	writer := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeBuildForm(writer, req, bundle))
+	}()
+
+	var image types.DeploymentImage
+	if err := c.do(ctx, http.MethodPost, "/api/v1/build", pr, writer.FormDataContentType(), http.StatusCreated, &image); err != nil {
+		return nil, fmt.Errorf("build failed: %w", err)
+	}
+	return &image, nil
+}
+
+// writeBuildForm writes the build metadata and the bundle as multipart parts
+func writeBuildForm(writer *multipart.Writer, req *types.BuildRequest, bundle io.Reader) error {
+	metadataPart, err := writer.CreateFormField(types.BuildFormMetadata)
+	if err != nil {
+		return fmt.Errorf("failed to create metadata part: %w", err)
+	}
+	if err := json.NewEncoder(metadataPart).Encode(req); err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	bundlePart, err := writer.CreateFormFile(types.BuildFormBundle, "bundle.tar.gz")
+	if err != nil {
+		return fmt.Errorf("failed to create bundle part: %w", err)
+	}
+	if _, err := io.Copy(bundlePart, bundle); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close multipart body: %w", err)
+	}
+	return nil
+}
+
+// ListBuilds lists builds, filtered and sorted according to opts when set
+func (c *Client) ListBuilds(ctx context.Context, opts *types.ListOptions) ([]*types.Build, error) {
+	return c.listBuilds(ctx, opts.Query())
+}
+
+// BuildExists reports whether a build exists for the given commit hash
+func (c *Client) BuildExists(ctx context.Context, commitHash string) (bool, error) {
+	builds, err := c.listBuilds(ctx, url.Values{"commit_hash": {commitHash}})
+	if err != nil {
+		return false, err
+	}
+	return len(builds) > 0, nil
+}
+
+// listBuilds lists the builds matching the given query
+func (c *Client) listBuilds(ctx context.Context, query url.Values) ([]*types.Build, error) {
+	var resp struct {
+		Builds []*types.Build `json:"builds"`
+	}
+	if err := c.get(ctx, withQuery("/api/v1/builds", query), &resp); err != nil {
+		return nil, fmt.Errorf("list builds failed: %w", err)
+	}
+	return resp.Builds, nil
+}
+
+// GetBuild gets the build for the given commit hash
+func (c *Client) GetBuild(ctx context.Context, commitHash string) (*types.Build, error) {
+	var build types.Build
+	if err := c.get(ctx, "/api/v1/builds/"+url.PathEscape(commitHash), &build); err != nil {
+		return nil, fmt.Errorf("failed to get build: %w", err)
+	}
+	return &build, nil
+}
+
+// GetBuildLogs gets the stored output of the build for the given commit hash
+func (c *Client) GetBuildLogs(ctx context.Context, commitHash string) (string, error) {
+	var resp struct {
+		Logs string `json:"logs"`
+	}
+	if err := c.get(ctx, "/api/v1/builds/"+url.PathEscape(commitHash)+"/logs", &resp); err != nil {
+		return "", fmt.Errorf("failed to get build logs: %w", err)
+	}
+	return resp.Logs, nil
+}
+
+// DeleteBuilds deletes the builds of an app name or commit hash and returns the deleted keys
+func (c *Client) DeleteBuilds(ctx context.Context, id string) ([]string, error) {
+	var resp struct {
+		Deleted []string `json:"deleted"`
+	}
+	if err := c.do(ctx, http.MethodDelete, "/api/v1/builds/"+url.PathEscape(id), nil, "", http.StatusOK, &resp); err != nil {
+		return nil, fmt.Errorf("delete failed: %w", err)
+	}
+	return resp.Deleted, nil
+}
+
+// ListDeployments lists deployments, filtered and sorted according to opts when set
+func (c *Client) ListDeployments(ctx context.Context, opts *types.ListOptions) ([]*types.Deployment, error) {
+	return c.listDeployments(ctx, opts.Query())
+}
+
+// DeploymentExists reports whether a deployment exists for the given app name
+func (c *Client) DeploymentExists(ctx context.Context, appName string) (bool, error) {
+	deployments, err := c.listDeployments(ctx, url.Values{"app_name": {appName}})
+	if err != nil {
+		return false, err
+	}
+	return len(deployments) > 0, nil
+}
+
+// listDeployments lists the deployments matching the given query
+func (c *Client) listDeployments(ctx context.Context, query url.Values) ([]*types.Deployment, error) {
+	var resp struct {
+		Deployments []*types.Deployment `json:"deployments"`
+	}
+	if err := c.get(ctx, withQuery("/api/v1/deployments", query), &resp); err != nil {
+		return nil, fmt.Errorf("list deployments failed: %w", err)
+	}
+	return resp.Deployments, nil
+}
+
+// GetDeploymentStatus gets the status of the deployment of an app, described so that a deployment whose
+// containers are still being started can be told apart from a failed one
+func (c *Client) GetDeploymentStatus(ctx context.Context, id string) (*types.DeploymentStatusReport, error) {
+	var deployment types.DeploymentStatusReport
+	if err := c.get(ctx, "/api/v1/deployments/"+url.PathEscape(id)+"/status", &deployment); err != nil {
+		return nil, fmt.Errorf("get status failed: %w", err)
+	}
+	return &deployment, nil
+}
+
+// GetDeployment gets the current deployment of an app, including its deployed commit
+func (c *Client) GetDeployment(ctx context.Context, appName string) (*types.Deployment, error) {
+	var deployment types.Deployment
+	if err := c.get(ctx, "/api/v1/deployments/"+url.PathEscape(appName)+"/status", &deployment); err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	return &deployment, nil
+}
+
+// DeleteDeployment deletes a deployment and its containers and reports whether it existed.
+// With ignoreMissing an absent deployment is not an error.
+func (c *Client) DeleteDeployment(ctx context.Context, id string, ignoreMissing bool) (deleted bool, err error) {
+	path := "/api/v1/deployments/" + url.PathEscape(id)
+	if ignoreMissing {
+		path += "?ignore_missing=true"
+	}
+
+	var resp struct {
+		Missing bool `json:"missing"`
+	}
+	if err := c.do(ctx, http.MethodDelete, path, nil, "", http.StatusOK, &resp); err != nil {
+		return false, fmt.Errorf("delete failed: %w", err)
+	}
+	return !resp.Missing, nil
+}
+
+// DeleteDeploymentsByPrefix deletes all deployments whose app name starts with prefix and returns their names
+func (c *Client) DeleteDeploymentsByPrefix(ctx context.Context, prefix string) ([]string, error) {
+	if prefix == "" {
+		return nil, fmt.Errorf("prefix must not be empty")
+	}
+
+	var resp struct {
+		Deleted []string `json:"deleted"`
+	}
+	path := withQuery("/api/v1/deployments", url.Values{"prefix": {prefix}})
+	if err := c.do(ctx, http.MethodDelete, path, nil, "", http.StatusOK, &resp); err != nil {
+		return nil, fmt.Errorf("delete failed: %w", err)
+	}
+	return resp.Deleted, nil
+}
+
+// ListApps lists the apps with their latest build and current deployment
+func (c *Client) ListApps(ctx context.Context) ([]*types.App, error) {
+	var resp struct {
+		Apps []*types.App `json:"apps"`
+	}
+	if err := c.get(ctx, "/api/v1/apps", &resp); err != nil {
+		return nil, fmt.Errorf("list apps failed: %w", err)
+	}
+	return resp.Apps, nil
+}
+
+// GetApp gets an app with its latest build and current deployment
+func (c *Client) GetApp(ctx context.Context, name string) (*types.App, error) {
+	var app types.App
+	if err := c.get(ctx, "/api/v1/apps/"+url.PathEscape(name), &app); err != nil {
+		return nil, fmt.Errorf("failed to get app: %w", err)
+	}
+	return &app, nil
+}
+
+// ListContainers lists the containers created by Nina on every Docker daemon of the Engine
+func (c *Client) ListContainers(ctx context.Context) ([]types.ManagedContainer, error) {
+	var resp struct {
+		Containers []types.ManagedContainer `json:"containers"`
+	}
+	if err := c.get(ctx, "/api/v1/containers", &resp); err != nil {
+		return nil, fmt.Errorf("list containers failed: %w", err)
+	}
+	return resp.Containers, nil
+}
+
+// ListActivity lists up to limit recent build and deployment status transitions, newest first.
+// A zero limit uses the Engine default.
+func (c *Client) ListActivity(ctx context.Context, limit int) ([]types.ActivityEvent, error) {
+	query := url.Values{}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var resp struct {
+		Events []types.ActivityEvent `json:"events"`
+	}
+	if err := c.get(ctx, withQuery("/api/v1/activity", query), &resp); err != nil {
+		return nil, fmt.Errorf("list activity failed: %w", err)
+	}
+	return resp.Events, nil
+}
+
+// GetStats gets the aggregate counts of apps, deployments, builds and running containers
+func (c *Client) GetStats(ctx context.Context) (*types.Stats, error) {
+	var stats types.Stats
+	if err := c.get(ctx, "/api/v1/stats", &stats); err != nil {
+		return nil, fmt.Errorf("failed to get stats: %w", err)
+	}
+	return &stats, nil
+}
+
+// InspectContainer gets the Docker state of a replica container of a deployment
+func (c *Client) InspectContainer(ctx context.Context, id, containerID string) (*types.ContainerDetails, error) {
+	var details types.ContainerDetails
+	path := "/api/v1/deployments/" + url.PathEscape(id) + "/containers/" + url.PathEscape(containerID)
+	if err := c.get(ctx, path, &details); err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	return &details, nil
+}
+
+// withQuery appends the query to the path when it is not empty
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// get sends a GET request and decodes the JSON response into out
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	return c.do(ctx, http.MethodGet, path, nil, "", http.StatusOK, out)
+}
+
+// doJSON sends in as a JSON body and decodes the JSON response into out
+func (c *Client) doJSON(ctx context.Context, method, path string, in interface{}, wantStatus int, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	return c.do(ctx, method, path, bytes.NewReader(data), "application/json", wantStatus, out)
+}
+
+// do sends a request and decodes the JSON response into out, unless out is nil.
+// Any status other than wantStatus is returned as an APIError.
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, contentType string, wantStatus int, out interface{}) error {
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != wantStatus {
+		return newAPIError(resp.StatusCode, respBody)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
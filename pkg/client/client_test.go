@@ -0,0 +1,463 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// newTestClient starts a test server with the given handler and returns a client for it
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return New(server.URL, WithHTTPClient(server.Client()))
+}
+
+func TestNew_TrimsTrailingSlash(t *testing.T) {
+	c := New("http://localhost:8080/")
+	if c.BaseURL() != "http://localhost:8080" {
+		t.Errorf("Expected base URL without trailing slash, got %q", c.BaseURL())
+	}
+}
+
+func TestHealth(t *testing.T) {
+	healthy := true
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write([]byte(`{"status":"healthy"}`))
+	})
+
+	if err := c.Health(context.Background()); err != nil {
+		t.Errorf("Expected healthy engine, got %v", err)
+	}
+	healthy = false
+	if err := c.Health(context.Background()); err == nil {
+		t.Error("Expected error for an unhealthy engine, got nil")
+	}
+}
+
+func TestDeploy(t *testing.T) {
+	var got types.DeploymentRequest
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/deploy" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected JSON content type, got %q", ct)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"deploy-1","app_name":"app","status":"deploying","replicas":2}`))
+	})
+
+	deployment, err := c.Deploy(context.Background(), &types.DeploymentRequest{AppName: "app", CommitHash: "abc123", Replicas: 2})
+	if err != nil {
+		t.Fatalf("Failed to deploy: %v", err)
+	}
+	if got.AppName != "app" || got.CommitHash != "abc123" || got.Replicas != 2 {
+		t.Errorf("Unexpected request body: %+v", got)
+	}
+	if deployment.ID != "deploy-1" || deployment.Status != types.DeploymentStatusDeploying || deployment.Replicas != 2 {
+		t.Errorf("Unexpected deployment: %+v", deployment)
+	}
+}
+
+func TestDeploy_ValidationError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid request: replicas: too many","fields":{"replicas":"too many"}}`))
+	})
+
+	_, err := c.Deploy(context.Background(), &types.DeploymentRequest{AppName: "app"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "invalid request: replicas: too many" {
+		t.Errorf("Unexpected API error: %+v", apiErr)
+	}
+	if apiErr.Fields["replicas"] != "too many" {
+		t.Errorf("Expected the rejected fields, got %v", apiErr.Fields)
+	}
+}
+
+func TestBuild_StreamsMultipart(t *testing.T) {
+	var (
+		gotMetadata types.BuildRequest
+		gotBundle   string
+	)
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			switch part.FormName() {
+			case types.BuildFormMetadata:
+				_ = json.NewDecoder(part).Decode(&gotMetadata)
+			case types.BuildFormBundle:
+				data, _ := io.ReadAll(part)
+				gotBundle = string(data)
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"image_tag":"app:abc123","exposed_ports":[8080]}`))
+	})
+
+	req := &types.BuildRequest{AppName: "app", CommitHash: "abc123", BuildArgs: map[string]string{"VERSION": "1"}}
+	image, err := c.Build(context.Background(), req, strings.NewReader("bundle-data"))
+	if err != nil {
+		t.Fatalf("Failed to build: %v", err)
+	}
+	if image.ImageTag != "app:abc123" || len(image.ExposedPorts) != 1 {
+		t.Errorf("Unexpected image: %+v", image)
+	}
+	if gotMetadata.AppName != "app" || gotMetadata.BuildArgs["VERSION"] != "1" {
+		t.Errorf("Unexpected metadata: %+v", gotMetadata)
+	}
+	if gotBundle != "bundle-data" {
+		t.Errorf("Expected bundle 'bundle-data', got %q", gotBundle)
+	}
+}
+
+func TestBuild_Failure(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"error":"failed to build project: boom"}`))
+	})
+
+	_, err := c.Build(context.Background(), &types.BuildRequest{AppName: "app"}, strings.NewReader("x"))
+	if err == nil || err.Error() != "build failed: failed to build project: boom (status: 500)" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestListBuilds(t *testing.T) {
+	var gotQuery string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		_, _ = w.Write([]byte(`{"builds":[{"app_name":"app","commit_hash":"abc123","status":"built"}],"count":1}`))
+	})
+
+	builds, err := c.ListBuilds(context.Background(), &types.ListOptions{SortBy: "app_name", Status: "built"})
+	if err != nil {
+		t.Fatalf("Failed to list builds: %v", err)
+	}
+	if gotQuery != "sort=app_name&status=built" {
+		t.Errorf("Unexpected query %q", gotQuery)
+	}
+	if len(builds) != 1 || builds[0].Status != types.BuildStatusBuilt {
+		t.Errorf("Unexpected builds: %+v", builds)
+	}
+
+	if _, err := c.ListBuilds(context.Background(), nil); err != nil {
+		t.Errorf("Expected nil options to be accepted, got %v", err)
+	}
+	if gotQuery != "" {
+		t.Errorf("Expected no query for nil options, got %q", gotQuery)
+	}
+}
+
+func TestExists(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Query().Get("commit_hash") == "abc123":
+			_, _ = w.Write([]byte(`{"builds":[{"commit_hash":"abc123"}],"count":1}`))
+		case r.URL.Query().Get("app_name") == "app":
+			_, _ = w.Write([]byte(`{"deployments":[{"app_name":"app"}],"count":1}`))
+		case strings.HasSuffix(r.URL.Path, "/builds"):
+			_, _ = w.Write([]byte(`{"builds":[],"count":0}`))
+		default:
+			_, _ = w.Write([]byte(`{"deployments":[],"count":0}`))
+		}
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		exists func() (bool, error)
+		want   bool
+	}{
+		{"existing build", func() (bool, error) { return c.BuildExists(ctx, "abc123") }, true},
+		{"missing build", func() (bool, error) { return c.BuildExists(ctx, "def456") }, false},
+		{"existing deployment", func() (bool, error) { return c.DeploymentExists(ctx, "app") }, true},
+		{"missing deployment", func() (bool, error) { return c.DeploymentExists(ctx, "other") }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.exists()
+			if err != nil || got != tt.want {
+				t.Errorf("Expected %v, got %v (err %v)", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestGetBuildAndLogs(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/builds/abc123":
+			_, _ = w.Write([]byte(`{"commit_hash":"abc123","status":"failed","failure_log":"undefined: foo"}`))
+		case "/api/v1/builds/abc123/logs":
+			_, _ = w.Write([]byte(`{"commit_hash":"abc123","logs":"Step 1/3\n"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"build not found"}`))
+		}
+	})
+	ctx := context.Background()
+
+	build, err := c.GetBuild(ctx, "abc123")
+	if err != nil || build.FailureLog != "undefined: foo" {
+		t.Errorf("Unexpected build %+v (err %v)", build, err)
+	}
+	logs, err := c.GetBuildLogs(ctx, "abc123")
+	if err != nil || logs != "Step 1/3\n" {
+		t.Errorf("Unexpected logs %q (err %v)", logs, err)
+	}
+
+	_, err = c.GetBuild(ctx, "missing")
+	if !IsNotFound(err) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+	if _, err := c.GetBuildLogs(ctx, "missing"); !IsNotFound(err) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+func TestApps(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/apps":
+			_, _ = w.Write([]byte(`{"apps":[{"name":"app","domains":["app"],"builds":2}],"count":1}`))
+		case "/api/v1/apps/app":
+			_, _ = w.Write([]byte(`{"name":"app","domains":["app"],"latest_build":{"commit_hash":"abc123"},"builds":2}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"app not found"}`))
+		}
+	})
+	ctx := context.Background()
+
+	apps, err := c.ListApps(ctx)
+	if err != nil || len(apps) != 1 || apps[0].Builds != 2 {
+		t.Errorf("Unexpected apps %+v (err %v)", apps, err)
+	}
+	app, err := c.GetApp(ctx, "app")
+	if err != nil || app.LatestBuild == nil || app.LatestBuild.CommitHash != "abc123" {
+		t.Errorf("Unexpected app %+v (err %v)", app, err)
+	}
+	if _, err := c.GetApp(ctx, "missing"); !IsNotFound(err) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+func TestInspectContainer(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/deployments/app/containers/abc123" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"container not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"container_id":"abc123","status":"running","running":true,"restart_count":2,` +
+			`"ports":[{"container_port":"8080/tcp","host_ip":"0.0.0.0","host_port":"32768"}]}`))
+	})
+	ctx := context.Background()
+
+	details, err := c.InspectContainer(ctx, "app", "abc123")
+	if err != nil {
+		t.Fatalf("InspectContainer failed: %v", err)
+	}
+	if details.Status != "running" || details.RestartCount != 2 || len(details.Ports) != 1 || details.Ports[0].HostPort != "32768" {
+		t.Errorf("Unexpected container details %+v", details)
+	}
+	if _, err := c.InspectContainer(ctx, "app", "other"); !IsNotFound(err) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+func TestDeleteDeployment(t *testing.T) {
+	var gotQuery string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			t.Errorf("Expected DELETE, got %s", r.Method)
+		}
+		gotQuery = r.URL.RawQuery
+		if strings.HasSuffix(r.URL.Path, "/missing-app") {
+			if r.URL.Query().Get("ignore_missing") != "true" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"Deployment not found","id":"missing-app"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":"missing-app","missing":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"app","containers_removed":2}`))
+	})
+	ctx := context.Background()
+
+	deleted, err := c.DeleteDeployment(ctx, "app", false)
+	if err != nil || !deleted || gotQuery != "" {
+		t.Errorf("Expected existing deployment to be deleted, got deleted=%v err=%v query=%q", deleted, err, gotQuery)
+	}
+	if _, err := c.DeleteDeployment(ctx, "missing-app", false); !IsNotFound(err) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+	deleted, err = c.DeleteDeployment(ctx, "missing-app", true)
+	if err != nil || deleted {
+		t.Errorf("Expected missing deployment to be ignored, got deleted=%v err=%v", deleted, err)
+	}
+}
+
+func TestDeleteDeploymentsByPrefix(t *testing.T) {
+	var gotPrefix string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPrefix = r.URL.Query().Get("prefix")
+		_, _ = w.Write([]byte(`{"deleted":["preview-1","preview-2"],"count":2}`))
+	})
+
+	deleted, err := c.DeleteDeploymentsByPrefix(context.Background(), "preview-&x")
+	if err != nil {
+		t.Fatalf("Failed to delete deployments: %v", err)
+	}
+	if gotPrefix != "preview-&x" {
+		t.Errorf("Expected escaped prefix to round trip, got %q", gotPrefix)
+	}
+	if len(deleted) != 2 {
+		t.Errorf("Unexpected deleted deployments: %v", deleted)
+	}
+
+	if _, err := c.DeleteDeploymentsByPrefix(context.Background(), ""); err == nil {
+		t.Error("Expected error for empty prefix, got nil")
+	}
+}
+
+func TestDeleteBuilds(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/api/v1/builds/app" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"deleted":["nina-build-abc123"],"count":1}`))
+	})
+
+	deleted, err := c.DeleteBuilds(context.Background(), "app")
+	if err != nil || len(deleted) != 1 || deleted[0] != "nina-build-abc123" {
+		t.Errorf("Unexpected result %v (err %v)", deleted, err)
+	}
+}
+
+func TestGetDeploymentStatus(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/deployments/app/status" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"id":"deploy-1","status":"ready"}`))
+	})
+
+	deployment, err := c.GetDeploymentStatus(context.Background(), "app")
+	if err != nil || deployment.Status != "ready" {
+		t.Errorf("Unexpected deployment %+v (err %v)", deployment, err)
+	}
+}
+
+func TestListContainers(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/containers" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"containers":[{"container_id":"c1","app_name":"app","orphaned":true}],"count":1}`))
+	})
+
+	containers, err := c.ListContainers(context.Background())
+	if err != nil || len(containers) != 1 || containers[0].AppName != "app" || !containers[0].Orphaned {
+		t.Errorf("Unexpected containers %+v (err %v)", containers, err)
+	}
+}
+
+func TestGetDeployment(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/deployments/app/status" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"id":"deploy-1","app_name":"app","commit_hash":"abc123","status":"ready"}`))
+	})
+
+	deployment, err := c.GetDeployment(context.Background(), "app")
+	if err != nil || deployment.CommitHash != "abc123" {
+		t.Errorf("Unexpected deployment %+v (err %v)", deployment, err)
+	}
+}
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		expected   string
+		fields     map[string]string
+	}{
+		{"error field", 500, `{"error":"failed to build project: boom"}`, "failed to build project: boom", nil},
+		{
+			"validation error", 400, `{"error":"invalid request: replicas: too many","fields":{"replicas":"too many"}}`,
+			"invalid request: replicas: too many", map[string]string{"replicas": "too many"},
+		},
+		{"raw body", 502, "bad gateway\n", "bad gateway", nil},
+		{"JSON without error field", 500, `{"status":"down"}`, `{"status":"down"}`, nil},
+		{"empty body", 503, "", "Service Unavailable", nil},
+		{"long body", 502, strings.Repeat("x", maxErrorBodyLength+50), strings.Repeat("x", maxErrorBodyLength) + "...", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newAPIError(tt.statusCode, []byte(tt.body))
+			if got.StatusCode != tt.statusCode || got.Message != tt.expected {
+				t.Errorf("Expected %q with status %d, got %q with status %d", tt.expected, tt.statusCode, got.Message, got.StatusCode)
+			}
+			if !reflect.DeepEqual(got.Fields, tt.fields) {
+				t.Errorf("Expected fields %v, got %v", tt.fields, got.Fields)
+			}
+		})
+	}
+
+	if got := newAPIError(404, []byte(`{"error":"build not found"}`)).Error(); got != "build not found (status: 404)" {
+		t.Errorf("Unexpected error string %q", got)
+	}
+}
+
+func TestListActivity(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/activity" || r.URL.Query().Get("limit") != "5" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		_, _ = w.Write([]byte(`{"events":[{"type":"build","app_name":"app","status":"built"}],"count":1}`))
+	})
+
+	events, err := c.ListActivity(context.Background(), 5)
+	if err != nil || len(events) != 1 || events[0].Type != types.ActivityTypeBuild || events[0].Status != "built" {
+		t.Errorf("Unexpected events %+v (err %v)", events, err)
+	}
+}
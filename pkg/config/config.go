@@ -11,16 +11,33 @@ import (
 
 // Config holds the application configuration
 type Config struct {
-	Server  ServerConfig  `mapstructure:"server"`
-	Redis   RedisConfig   `mapstructure:"redis"`
-	Logging LoggingConfig `mapstructure:"logging"`
-	Ingress IngressConfig `mapstructure:"ingress"`
+	Server   ServerConfig   `mapstructure:"server"`
+	Redis    RedisConfig    `mapstructure:"redis"`
+	Logging  LoggingConfig  `mapstructure:"logging"`
+	Ingress  IngressConfig  `mapstructure:"ingress"`
+	Engine   EngineConfig   `mapstructure:"engine"`
+	Docker   DockerConfig   `mapstructure:"docker"`
+	Build    BuildConfig    `mapstructure:"build"`
+	Defaults DefaultsConfig `mapstructure:"defaults"`
 }
 
 // ServerConfig holds the Engine server configuration
 type ServerConfig struct {
-	Host string `mapstructure:"host"`
-	Port int    `mapstructure:"port"`
+	Host string     `mapstructure:"host"`
+	Port int        `mapstructure:"port"`
+	CORS CORSConfig `mapstructure:"cors"`
+}
+
+// CORSConfig holds the cross-origin resource sharing settings of the Engine API
+type CORSConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// AllowedOrigins lists the origins allowed to call the API, "*" allows any origin
+	AllowedOrigins   []string `mapstructure:"allowed_origins"`
+	AllowedMethods   []string `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string `mapstructure:"allowed_headers"`
+	AllowCredentials bool     `mapstructure:"allow_credentials"`
+	// MaxAge is the time in seconds browsers may cache a preflight response
+	MaxAge int `mapstructure:"max_age"`
 }
 
 // RedisConfig holds the Redis connection configuration
@@ -42,6 +59,128 @@ type IngressConfig struct {
 	Host                      string `mapstructure:"host"`
 	Port                      int    `mapstructure:"port"`
 	DeploymentRefreshInterval int    `mapstructure:"deployment_refresh_interval"`
+	// StaleThreshold is the time in seconds after which a cache that failed to refresh is considered stale
+	StaleThreshold int `mapstructure:"stale_threshold"`
+	// RejectWhenStale makes the ingress answer 503 instead of routing with a stale cache
+	RejectWhenStale bool `mapstructure:"reject_when_stale"`
+	// StartupTimeout is the time in seconds the ingress waits for its first successful deployments fetch
+	// before it starts listening, negative listens right away
+	StartupTimeout int `mapstructure:"startup_timeout"`
+	// DomainSuffix is stripped from request hosts before they are matched against app names,
+	// e.g. nina.local routes myapp.nina.local to myapp. Empty matches hosts against app names as is.
+	DomainSuffix string `mapstructure:"domain_suffix"`
+	// WildcardDomains are patterns such as *.preview.nina.local whose single subdomain names the app,
+	// e.g. myapp-feature.preview.nina.local routes to myapp-feature
+	WildcardDomains []string `mapstructure:"wildcard_domains"`
+	// LoadBalancing is how requests are spread over the replicas of an app: random or prefer-local
+	LoadBalancing string `mapstructure:"load_balancing"`
+	// Node is the engine node the ingress runs on, empty for the host of the default Docker daemon.
+	// The prefer-local load balancing favors the replicas on this node.
+	Node string `mapstructure:"node"`
+}
+
+// EngineConfig holds the container deployment configuration of the Engine
+type EngineConfig struct {
+	// ReadinessProbe is the probe used to check that a container accepts connections: none, tcp or http
+	ReadinessProbe string `mapstructure:"readiness_probe"`
+	// ReadinessPath is the path requested by the http readiness probe
+	ReadinessPath string `mapstructure:"readiness_path"`
+	// ReadinessTimeout is the time in seconds a container has to become ready
+	ReadinessTimeout int `mapstructure:"readiness_timeout"`
+	// DeployTimeout is the time in seconds all the replicas of a deployment have to become ready before the
+	// deployment is failed and its containers are removed, negative disables it
+	DeployTimeout int `mapstructure:"deploy_timeout"`
+	// StartupGrace is the time in seconds a started container must keep running before it counts as ready when
+	// no readiness probe is set, so that crashing containers fail the deployment. Negative disables it.
+	StartupGrace int `mapstructure:"startup_grace"`
+	// MaxReplicas is the maximum number of replicas a single deployment may request
+	MaxReplicas int `mapstructure:"max_replicas"`
+	// DisableLegacyDeployments turns off the legacy provision endpoint and deployment records
+	DisableLegacyDeployments bool `mapstructure:"disable_legacy_deployments"`
+	// RollbackPartialDeployments stops a deployment at its first failed replica and removes the healthy ones,
+	// failing the deployment instead of leaving it partially ready
+	RollbackPartialDeployments bool `mapstructure:"rollback_partial_deployments"`
+	// DisableMetrics turns off the Prometheus /metrics endpoint
+	DisableMetrics bool `mapstructure:"disable_metrics"`
+	// StopTimeout is the time in seconds a container has to exit after SIGTERM before it is killed and removed,
+	// negative removes containers right away
+	StopTimeout int `mapstructure:"stop_timeout"`
+	// RequestTimeout is the time in seconds a regular API request may take, negative disables it
+	RequestTimeout int `mapstructure:"request_timeout"`
+	// LongRequestTimeout is the time in seconds a build or deploy request may take, negative disables it
+	LongRequestTimeout int `mapstructure:"long_request_timeout"`
+	// BuildRetries is the number of times an image build failing with a transient Docker error is retried
+	BuildRetries int `mapstructure:"build_retries"`
+	// BuildRetryDelay is the time in seconds to wait between image build attempts
+	BuildRetryDelay int `mapstructure:"build_retry_delay"`
+	// CallbackTimeout is the time in seconds a build callback request may take, negative disables it
+	CallbackTimeout int `mapstructure:"callback_timeout"`
+	// CallbackRetries is the number of times a failed build callback is retried
+	CallbackRetries int `mapstructure:"callback_retries"`
+	// CallbackSecret signs build callbacks with HMAC-SHA256 in the X-Nina-Signature header when set
+	CallbackSecret string `mapstructure:"callback_secret"`
+	// BuildLogMaxSize is the maximum size in bytes of a stored build log, older output is dropped first
+	BuildLogMaxSize int `mapstructure:"build_log_max_size"`
+	// BuildLogRetention is the time in seconds build logs are kept, negative keeps them forever
+	BuildLogRetention int `mapstructure:"build_log_retention"`
+	// MinFreeDiskSpace is the free space in bytes the temp directory and the Docker data root must have
+	// before a build starts, negative disables the check
+	MinFreeDiskSpace int64 `mapstructure:"min_free_disk_space"`
+	// MaxBundleSize is the largest gzipped bundle in bytes a build may upload, negative disables the limit.
+	// The CLI checks it before uploading.
+	MaxBundleSize int64 `mapstructure:"max_bundle_size"`
+	// TempDir is the directory bundles are uploaded and extracted to, empty uses the OS default.
+	// The CLI also copies and archives the working directory there.
+	TempDir string `mapstructure:"temp_dir"`
+}
+
+// DockerConfig holds the default Docker daemon, used by the Engine and its builder,
+// and the named nodes deployments can be placed on
+type DockerConfig struct {
+	DockerNodeConfig `mapstructure:",squash"`
+	// Nodes maps node names to the Docker daemons that run their containers
+	Nodes map[string]DockerNodeConfig `mapstructure:"nodes"`
+}
+
+// DockerNodeConfig holds the connection settings of a single Docker daemon
+type DockerNodeConfig struct {
+	// Host is the daemon address, e.g. unix:///var/run/docker.sock; empty uses DOCKER_HOST or the default socket
+	Host string `mapstructure:"host"`
+	// APIVersion pins the Docker API version; empty negotiates it with the daemon
+	APIVersion string `mapstructure:"api_version"`
+	// TLS holds the client certificates used to reach a remote daemon, e.g. tcp://host:2376
+	TLS DockerTLSConfig `mapstructure:"tls"`
+}
+
+// DockerTLSConfig holds the paths of the PEM files used to talk to a Docker daemon over TLS
+type DockerTLSConfig struct {
+	// CA is the certificate authority that signed the daemon certificate; empty uses the system roots
+	CA   string `mapstructure:"ca"`
+	Cert string `mapstructure:"cert"`
+	Key  string `mapstructure:"key"`
+}
+
+// Enabled reports whether any TLS setting is configured
+func (c *DockerTLSConfig) Enabled() bool {
+	return c.CA != "" || c.Cert != "" || c.Key != ""
+}
+
+// BuildConfig holds the image settings of the builder
+type BuildConfig struct {
+	// ImageTagTemplate names built images; {app}, {commit}, {short_commit} and {registry} are substituted
+	ImageTagTemplate string `mapstructure:"image_tag_template"`
+	// Registry is substituted for {registry}, e.g. registry.example.com/team
+	Registry string `mapstructure:"registry"`
+	// DisableCache rebuilds every bundle instead of reusing the image of an earlier build of identical contents
+	DisableCache bool `mapstructure:"disable_cache"`
+	// Secrets maps build secret names to the files on the Engine host holding their values
+	Secrets map[string]string `mapstructure:"secrets"`
+}
+
+// DefaultsConfig holds the CLI defaults used when neither a flag nor the manifest sets a value
+type DefaultsConfig struct {
+	// Replicas is the number of replicas deployed by default, zero keeps the built-in default
+	Replicas int `mapstructure:"replicas"`
 }
 
 // LoadConfig loads configuration from file and environment variables
@@ -87,6 +226,12 @@ func LoadConfig(configPath string) (*Config, error) {
 func setDefaults() {
 	viper.SetDefault("server.host", "0.0.0.0")
 	viper.SetDefault("server.port", 8080)
+	viper.SetDefault("server.cors.enabled", false)
+	viper.SetDefault("server.cors.allowed_origins", []string{})
+	viper.SetDefault("server.cors.allowed_methods", []string{"GET", "POST", "DELETE", "OPTIONS"})
+	viper.SetDefault("server.cors.allowed_headers", []string{"Content-Type", "Authorization"})
+	viper.SetDefault("server.cors.allow_credentials", false)
+	viper.SetDefault("server.cors.max_age", 600)
 	viper.SetDefault("redis.host", "localhost")
 	viper.SetDefault("redis.port", 6379)
 	viper.SetDefault("redis.password", "")
@@ -96,6 +241,44 @@ func setDefaults() {
 	viper.SetDefault("ingress.host", "0.0.0.0")
 	viper.SetDefault("ingress.port", 8081)
 	viper.SetDefault("ingress.deployment_refresh_interval", 5)
+	viper.SetDefault("ingress.stale_threshold", 60)
+	viper.SetDefault("ingress.reject_when_stale", false)
+	viper.SetDefault("ingress.startup_timeout", 10)
+	viper.SetDefault("ingress.domain_suffix", "")
+	viper.SetDefault("ingress.wildcard_domains", []string{})
+	viper.SetDefault("ingress.load_balancing", "random")
+	viper.SetDefault("ingress.node", "")
+	viper.SetDefault("engine.readiness_probe", "none")
+	viper.SetDefault("engine.readiness_path", "/")
+	viper.SetDefault("engine.readiness_timeout", 30)
+	viper.SetDefault("engine.deploy_timeout", 300)
+	viper.SetDefault("engine.startup_grace", 3)
+	viper.SetDefault("engine.max_replicas", 10)
+	viper.SetDefault("engine.disable_legacy_deployments", false)
+	viper.SetDefault("engine.rollback_partial_deployments", false)
+	viper.SetDefault("engine.disable_metrics", false)
+	viper.SetDefault("engine.stop_timeout", 10)
+	viper.SetDefault("engine.request_timeout", 30)
+	viper.SetDefault("engine.long_request_timeout", 600)
+	viper.SetDefault("engine.build_retries", 0)
+	viper.SetDefault("engine.build_retry_delay", 2)
+	viper.SetDefault("engine.callback_timeout", 10)
+	viper.SetDefault("engine.callback_retries", 3)
+	viper.SetDefault("engine.callback_secret", "")
+	viper.SetDefault("engine.build_log_max_size", 1048576)
+	viper.SetDefault("engine.build_log_retention", 604800)
+	viper.SetDefault("engine.min_free_disk_space", 1073741824)
+	viper.SetDefault("engine.temp_dir", "")
+	viper.SetDefault("engine.max_bundle_size", 104857600)
+	viper.SetDefault("docker.host", "")
+	viper.SetDefault("docker.api_version", "")
+	viper.SetDefault("docker.tls.ca", "")
+	viper.SetDefault("docker.tls.cert", "")
+	viper.SetDefault("docker.tls.key", "")
+	viper.SetDefault("build.image_tag_template", "nina-{app}-{commit}")
+	viper.SetDefault("build.registry", "")
+	viper.SetDefault("build.disable_cache", false)
+	viper.SetDefault("defaults.replicas", 0)
 }
 
 // getConfigDir returns the XDG-compliant config directory
@@ -143,3 +326,20 @@ func (c *Config) GetServerAddr() string {
 func (c *Config) GetIngressAddr() string {
 	return fmt.Sprintf("%s:%d", c.Ingress.Host, c.Ingress.Port)
 }
+
+// ValidateAddrs checks that the Engine and the ingress are not configured to listen on the same address.
+// A wildcard host, e.g. 0.0.0.0, collides with every host on the same port.
+func (c *Config) ValidateAddrs() error {
+	if c.Server.Port != c.Ingress.Port {
+		return nil
+	}
+	if c.Server.Host == c.Ingress.Host || isWildcardHost(c.Server.Host) || isWildcardHost(c.Ingress.Host) {
+		return fmt.Errorf("server address %s collides with ingress address %s", c.GetServerAddr(), c.GetIngressAddr())
+	}
+	return nil
+}
+
+// isWildcardHost reports whether host makes a server listen on every interface
+func isWildcardHost(host string) bool {
+	return host == "" || host == "0.0.0.0" || host == "::" || host == "[::]"
+}
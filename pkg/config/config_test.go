@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateAddrs(t *testing.T) {
+	tests := []struct {
+		name                    string
+		serverHost, ingressHost string
+		serverPort, ingressPort int
+		expectErr               bool
+	}{
+		{"different ports", "0.0.0.0", "0.0.0.0", 8080, 8081, false},
+		{"different hosts", "127.0.0.1", "192.168.1.10", 8080, 8080, false},
+		{"same address", "127.0.0.1", "127.0.0.1", 8080, 8080, true},
+		{"wildcard server", "0.0.0.0", "127.0.0.1", 8080, 8080, true},
+		{"wildcard ingress", "127.0.0.1", "::", 8080, 8080, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Server:  ServerConfig{Host: tt.serverHost, Port: tt.serverPort},
+				Ingress: IngressConfig{Host: tt.ingressHost, Port: tt.ingressPort},
+			}
+			if err := cfg.ValidateAddrs(); (err != nil) != tt.expectErr {
+				t.Errorf("Expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestDefaultAddrsDiffer(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "nina.json")
+	if err := os.WriteFile(configPath, []byte("{}"), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.GetServerAddr() == cfg.GetIngressAddr() {
+		t.Errorf("Expected different default addresses, both are %s", cfg.GetServerAddr())
+	}
+	if err := cfg.ValidateAddrs(); err != nil {
+		t.Errorf("Expected the default addresses to be valid, got %v", err)
+	}
+}
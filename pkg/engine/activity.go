@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+const (
+	// activityLogSize is the number of recent build and deployment events kept in memory
+	activityLogSize = 500
+	// defaultActivityLimit is the number of events returned when the request does not set a limit
+	defaultActivityLimit = 50
+)
+
+// activityLog keeps the most recent build and deployment status transitions in a ring buffer.
+// The zero value is ready to use.
+type activityLog struct {
+	mu     sync.Mutex
+	events []types.ActivityEvent
+	// next is the position the next event is written to once the buffer is full
+	next int
+}
+
+// record adds an event, dropping the oldest one when the buffer is full
+func (a *activityLog) record(eventType types.ActivityType, appName, commitHash, status string) {
+	event := types.ActivityEvent{
+		Time:       time.Now().UTC(),
+		Type:       eventType,
+		AppName:    appName,
+		CommitHash: commitHash,
+		Status:     status,
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.events) < activityLogSize {
+		a.events = append(a.events, event)
+		return
+	}
+	a.events[a.next] = event
+	a.next = (a.next + 1) % activityLogSize
+}
+
+// recent returns up to limit events, newest first
+func (a *activityLog) recent(limit int) []types.ActivityEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	limit = min(limit, len(a.events))
+	events := make([]types.ActivityEvent, 0, limit)
+	// The newest event is right before next, wrapping around to the end of the buffer
+	for i := 1; i <= limit; i++ {
+		events = append(events, a.events[(a.next-i+len(a.events))%len(a.events)])
+	}
+	return events
+}
+
+// recordBuild records a status transition of a build
+func (s *BaseEngine) recordBuild(req *types.BuildRequest, status types.BuildStatus) {
+	s.activity.record(types.ActivityTypeBuild, req.AppName, req.CommitHash, string(status))
+}
+
+// recordDeployment records a status transition of a deployment
+func (s *BaseEngine) recordDeployment(deployment *types.Deployment) {
+	s.activity.record(types.ActivityTypeDeployment, deployment.AppName, deployment.CommitHash, string(deployment.Status))
+}
+
+// activityHandler lists the most recent build and deployment status transitions, newest first
+func (s *BaseEngine) activityHandler(c *gin.Context) {
+	limit := defaultActivityLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > activityLogSize {
+			errs := ValidationErrors{}
+			errs.Add("limit", fmt.Sprintf("limit must be between 1 and %d, got %q", activityLogSize, raw))
+			respondBadRequest(c, errs)
+			return
+		}
+		limit = parsed
+	}
+
+	events := s.activity.recent(limit)
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
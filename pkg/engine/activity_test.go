@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestActivityLog_Recent(t *testing.T) {
+	var log activityLog
+	if events := log.recent(10); len(events) != 0 {
+		t.Errorf("Expected no events, got %+v", events)
+	}
+
+	for i := 0; i < 3; i++ {
+		log.record(types.ActivityTypeBuild, fmt.Sprintf("app%d", i), "", string(types.BuildStatusBuilt))
+	}
+	events := log.recent(10)
+	if len(events) != 3 || events[0].AppName != "app2" || events[2].AppName != "app0" {
+		t.Errorf("Expected the 3 events newest first, got %+v", events)
+	}
+	if events := log.recent(2); len(events) != 2 || events[1].AppName != "app1" {
+		t.Errorf("Expected the 2 newest events, got %+v", events)
+	}
+}
+
+func TestActivityLog_Bounded(t *testing.T) {
+	var log activityLog
+	for i := 0; i < activityLogSize+5; i++ {
+		log.record(types.ActivityTypeDeployment, fmt.Sprintf("app%d", i), "", string(types.DeploymentStatusReady))
+	}
+
+	events := log.recent(activityLogSize + 10)
+	if len(events) != activityLogSize {
+		t.Fatalf("Expected %d events, got %d", activityLogSize, len(events))
+	}
+	if newest, oldest := events[0].AppName, events[len(events)-1].AppName; newest != fmt.Sprintf("app%d", activityLogSize+4) ||
+		oldest != "app5" {
+		t.Errorf("Expected the oldest events to be dropped, got newest %s and oldest %s", newest, oldest)
+	}
+}
+
+func TestActivityLog_Concurrent(t *testing.T) {
+	var log activityLog
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				log.record(types.ActivityTypeBuild, "app", "", string(types.BuildStatusBuilding))
+				log.recent(10)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if events := log.recent(activityLogSize); len(events) != activityLogSize {
+		t.Errorf("Expected a full buffer, got %d events", len(events))
+	}
+}
+
+// getActivity lists the recent activity through the API
+func getActivity(t *testing.T, s *BaseEngine, query string) []types.ActivityEvent {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/activity"+query, http.NoBody))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp struct {
+		Events []types.ActivityEvent `json:"events"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp.Events
+}
+
+func TestActivityHandler_Deploy(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+	postDeploy(t, s, "app", "abc123")
+	waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady)
+
+	// The outcome is recorded right after the status update
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		events := getActivity(t, s, "")
+		if len(events) == 2 {
+			if events[0].Type != types.ActivityTypeDeployment || events[0].Status != string(types.DeploymentStatusReady) ||
+				events[1].Status != string(types.DeploymentStatusUnavailable) || events[0].CommitHash != "abc123" {
+				t.Errorf("Expected the created and ready transitions, got %+v", events)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 2 events, got %+v", events)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if events := getActivity(t, s, "?limit=1"); len(events) != 1 || events[0].Status != string(types.DeploymentStatusReady) {
+		t.Errorf("Expected the newest event only, got %+v", events)
+	}
+}
+
+func TestActivityHandler_InvalidLimit(t *testing.T) {
+	s := newTestEngine(t)
+	for _, limit := range []string{"0", "abc", fmt.Sprint(activityLogSize + 1)} {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/activity?limit="+limit, http.NoBody))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for limit %q, got %d", http.StatusBadRequest, limit, w.Code)
+		}
+	}
+}
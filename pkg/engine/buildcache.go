@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"github.com/matiasinsaurralde/nina/internal/pkg/builder"
+	"github.com/matiasinsaurralde/nina/pkg/store"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// buildCacheEnabled reports whether images of earlier builds of identical contents are reused
+func (s *BaseEngine) buildCacheEnabled() bool {
+	return s.config == nil || !s.config.Build.DisableCache
+}
+
+// bundleContentHash returns the content hash the image built from the bundle is cached under,
+// or an empty string when the cache is disabled or the bundle cannot be hashed
+func (s *BaseEngine) bundleContentHash(req *types.BuildRequest, bundle *builder.Bundle, buildpack builder.Buildpack) string {
+	if !s.buildCacheEnabled() {
+		return ""
+	}
+	contentHash, err := bundle.ContentHash(buildpack.Name())
+	if err != nil {
+		s.logger.Warn("Failed to hash bundle contents, building without cache", "app_name", req.AppName, "error", err)
+		return ""
+	}
+	return contentHash
+}
+
+// cachedImage returns the image of an earlier build of identical contents, as long as Docker still has it or
+// can pull it from the configured registry. The image is tagged with the tag of this build, so that the build
+// never depends on the tag of another app or commit, which a later rebuild may move or remove.
+func (s *BaseEngine) cachedImage(ctx context.Context, req *types.BuildRequest, contentHash string) *types.DeploymentImage {
+	image, err := s.store.GetCachedImage(ctx, contentHash)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			s.logger.Warn("Failed to look up cached image", "app_name", req.AppName, "content_hash", contentHash, "error", err)
+		}
+		return nil
+	}
+	if s.dockerClient == nil {
+		return nil
+	}
+
+	present, err := s.cachedImagePresent(ctx, image)
+	if err != nil {
+		s.logger.Warn("Failed to inspect cached image", "image_id", image.ImageID, "error", err)
+		return nil
+	}
+	if !present {
+		// The image was removed since it was cached
+		s.logger.Info("Cached image no longer exists", "image_tag", image.ImageTag, "content_hash", contentHash)
+		if err := s.store.DeleteCachedImage(ctx, contentHash); err != nil {
+			s.logger.Warn("Failed to forget cached image", "content_hash", contentHash, "error", err)
+		}
+		return nil
+	}
+
+	imageTag, err := builder.ImageTag(s.config, req)
+	if err != nil {
+		s.logger.Warn("Failed to render image tag, building without cache", "app_name", req.AppName, "error", err)
+		return nil
+	}
+	if err := s.dockerClient.ImageTag(ctx, image.ImageID, imageTag); err != nil {
+		s.logger.Warn("Failed to tag cached image, building without cache", "image_id", image.ImageID,
+			"image_tag", imageTag, "error", err)
+		return nil
+	}
+
+	image.ImageTag = imageTag
+	image.Cached = true
+	return image
+}
+
+// cachedImagePresent reports whether the local daemon has the cached image. An image tagged in the configured
+// registry is pulled when the daemon no longer has it, and counts as present when the pulled tag still points
+// at the cached image.
+func (s *BaseEngine) cachedImagePresent(ctx context.Context, image *types.DeploymentImage) (bool, error) {
+	_, err := s.dockerClient.ImageInspect(ctx, image.ImageID)
+	if err == nil {
+		return true, nil
+	}
+	if !client.IsErrNotFound(err) {
+		return false, err //nolint:wrapcheck
+	}
+
+	registry := ""
+	if s.config != nil {
+		registry = strings.TrimSuffix(s.config.Build.Registry, "/")
+	}
+	if registry == "" || !strings.HasPrefix(image.ImageTag, registry+"/") {
+		return false, nil
+	}
+	s.logger.Info("Pulling cached image from the registry", "image_tag", image.ImageTag)
+	if err := pullImage(ctx, s.dockerClient, image.ImageTag); err != nil {
+		s.logger.Info("Cached image is not in the registry", "image_tag", image.ImageTag, "error", err)
+		return false, nil
+	}
+	pulled, err := s.dockerClient.ImageInspect(ctx, image.ImageTag)
+	if err != nil {
+		return false, err //nolint:wrapcheck
+	}
+	// The tag may have been pushed again since, for a rebuild of other contents
+	return pulled.ID == image.ImageID, nil
+}
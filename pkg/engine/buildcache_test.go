@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/internal/pkg/builder"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// countingBuildpack is a buildpack that counts its builds, each producing a new image
+type countingBuildpack struct {
+	*builder.BaseBuildpack
+	builds int
+	// registry prefixes the image tags, e.g. registry.example.com/team/
+	registry string
+}
+
+func (c *countingBuildpack) Build(_ context.Context, bundle *builder.Bundle) (*types.DeploymentImage, error) {
+	c.builds++
+	return &types.DeploymentImage{
+		ImageTag:     c.registry + "nina-" + bundle.GetRequest().AppName + "-" + bundle.GetRequest().CommitHash,
+		ImageID:      fmt.Sprintf("sha256:image%d", c.builds),
+		Size:         1024,
+		ExposedPorts: []int{8080},
+	}, nil
+}
+
+func (c *countingBuildpack) Match(_ context.Context, _ *builder.Bundle) (bool, error) {
+	return true, nil
+}
+
+func (c *countingBuildpack) Name() string { return "counting" }
+
+func (c *countingBuildpack) Priority() int { return 0 }
+
+// buildBundle builds the given sources as a commit of an app with buildProject
+func buildBundle(t *testing.T, s *BaseEngine, buildpack builder.Buildpack, appName, commitHash string,
+	files map[string]string,
+) *types.DeploymentImage {
+	t.Helper()
+	ctx := context.Background()
+	req := &types.BuildRequest{AppName: appName, CommitHash: commitHash, BundleContents: gzippedTar(t, files)}
+	if _, err := s.store.CreateBuild(ctx, req); err != nil {
+		t.Fatalf("Failed to create build: %v", err)
+	}
+	bundle, err := builder.NewBundle(req, t.TempDir(), s.logger)
+	if err != nil {
+		t.Fatalf("Failed to extract bundle: %v", err)
+	}
+	image, err := s.buildProject(ctx, req, bundle, buildpack)
+	if err != nil {
+		t.Fatalf("Failed to build: %v", err)
+	}
+	return image
+}
+
+func TestBuildProject_CacheHit(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	buildpack := &countingBuildpack{BaseBuildpack: &builder.BaseBuildpack{}}
+	files := map[string]string{"main.go": "package main"}
+
+	first := buildBundle(t, s, buildpack, "app", "abc123", files)
+	if first.Cached {
+		t.Error("Expected the first build not to be cached")
+	}
+
+	// The same contents deployed elsewhere reuse the image
+	second := buildBundle(t, s, buildpack, "app-staging", "def456", files)
+	if buildpack.builds != 1 {
+		t.Errorf("Expected a single build, got %d", buildpack.builds)
+	}
+	// The image is reused under the tag of the new build, another app's tag may be moved by its rebuilds
+	if !second.Cached || second.ImageID != first.ImageID || second.ImageTag != "nina-app-staging-def456" {
+		t.Errorf("Expected the cached image %+v under its own tag, got %+v", first, second)
+	}
+	if source := fake.imageTags()["nina-app-staging-def456:latest"]; source != first.ImageID {
+		t.Errorf("Expected the cached image to be tagged for the new build, got tags %v", fake.imageTags())
+	}
+	build, err := s.store.GetBuild(context.Background(), "def456")
+	if err != nil {
+		t.Fatalf("Failed to get build: %v", err)
+	}
+	if build.Status != types.BuildStatusBuilt || build.ImageID != first.ImageID || build.ImageTag != second.ImageTag {
+		t.Errorf("Expected the cache hit to be recorded as built, got %+v", build)
+	}
+
+	// Different contents are built
+	buildBundle(t, s, buildpack, "app", "fed789", map[string]string{"main.go": "package main // changed"})
+	if buildpack.builds != 2 {
+		t.Errorf("Expected changed contents to be built, got %d builds", buildpack.builds)
+	}
+
+	// An image removed from Docker is built again
+	fake.mu.Lock()
+	fake.missingImages = map[string]bool{first.ImageID: true}
+	fake.mu.Unlock()
+	if image := buildBundle(t, s, buildpack, "app", "aaa000", files); image.Cached {
+		t.Error("Expected a removed image not to be reused")
+	}
+	if buildpack.builds != 3 {
+		t.Errorf("Expected the removed image to be rebuilt, got %d builds", buildpack.builds)
+	}
+}
+
+func TestBuildProject_CacheHitFromRegistry(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	s.config.Build.Registry = "registry.example.com/team"
+	buildpack := &countingBuildpack{BaseBuildpack: &builder.BaseBuildpack{}, registry: "registry.example.com/team/"}
+	files := map[string]string{"main.go": "package main"}
+
+	first := buildBundle(t, s, buildpack, "app", "abc123", files)
+
+	// The daemon lost the image, but the registry still has it under its tag
+	fake.mu.Lock()
+	fake.missingImages = map[string]bool{first.ImageID: true, first.ImageTag: true}
+	fake.imageIDs = map[string]string{first.ImageTag: first.ImageID}
+	fake.mu.Unlock()
+
+	second := buildBundle(t, s, buildpack, "app-staging", "def456", files)
+	if buildpack.builds != 1 || !second.Cached || second.ImageID != first.ImageID {
+		t.Errorf("Expected the image pulled from the registry to be reused, got %+v after %d builds", second, buildpack.builds)
+	}
+	if pulled := fake.pulledImages(); len(pulled) != 1 || pulled[0] != first.ImageTag+":latest" {
+		t.Errorf("Expected %s to be pulled, got %v", first.ImageTag, pulled)
+	}
+
+	// A registry tag that was pushed again for other contents is not reused
+	fake.mu.Lock()
+	fake.missingImages = map[string]bool{first.ImageID: true}
+	fake.imageIDs = map[string]string{first.ImageTag: "sha256:other"}
+	fake.mu.Unlock()
+	if image := buildBundle(t, s, buildpack, "app-production", "fed789", files); image.Cached || buildpack.builds != 2 {
+		t.Errorf("Expected a moved registry tag not to be reused, got %+v after %d builds", image, buildpack.builds)
+	}
+}
+
+func TestBuildProject_CacheDisabled(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	s.config.Build.DisableCache = true
+	buildpack := &countingBuildpack{BaseBuildpack: &builder.BaseBuildpack{}}
+	files := map[string]string{"main.go": "package main"}
+
+	buildBundle(t, s, buildpack, "app", "abc123", files)
+	if image := buildBundle(t, s, buildpack, "app", "def456", files); image.Cached || buildpack.builds != 2 {
+		t.Errorf("Expected every build to run with the cache disabled, got %d builds", buildpack.builds)
+	}
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matiasinsaurralde/nina/pkg/store"
+)
+
+const (
+	// defaultBuildLogMaxSize is the maximum size of a stored build log when none is configured
+	defaultBuildLogMaxSize = 1 << 20
+	// defaultBuildLogRetention is how long build logs are kept when no retention is configured
+	defaultBuildLogRetention = 7 * 24 * time.Hour
+	// buildLogTruncatedMarker starts logs whose beginning was dropped to respect the size limit
+	buildLogTruncatedMarker = "[earlier output truncated]\n"
+)
+
+// buildLog collects the output of a build, keeping at most limit bytes from its end
+type buildLog struct {
+	mu        sync.Mutex
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+// newBuildLog returns a build log that keeps at most limit bytes
+func newBuildLog(limit int) *buildLog {
+	return &buildLog{limit: limit}
+}
+
+// Write implements io.Writer, dropping the oldest output once the log grows past twice its limit
+func (l *buildLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = append(l.buf, p...)
+	if len(l.buf) > 2*l.limit {
+		l.buf = append(l.buf[:0], l.buf[len(l.buf)-l.limit:]...)
+		l.truncated = true
+	}
+	return len(p), nil
+}
+
+// String returns the last limit bytes of the output, marking logs whose beginning was dropped
+func (l *buildLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data := l.buf
+	truncated := l.truncated
+	if len(data) > l.limit {
+		data = data[len(data)-l.limit:]
+		truncated = true
+	}
+	if truncated {
+		return buildLogTruncatedMarker + string(data)
+	}
+	return string(data)
+}
+
+// buildLogMaxSize returns the maximum size of a stored build log
+func (s *BaseEngine) buildLogMaxSize() int {
+	if s.config.Engine.BuildLogMaxSize > 0 {
+		return s.config.Engine.BuildLogMaxSize
+	}
+	return defaultBuildLogMaxSize
+}
+
+// buildLogRetention returns how long build logs are kept, zero meaning forever
+func (s *BaseEngine) buildLogRetention() time.Duration {
+	switch retention := s.config.Engine.BuildLogRetention; {
+	case retention < 0:
+		return 0
+	case retention == 0:
+		return defaultBuildLogRetention
+	default:
+		return time.Duration(retention) * time.Second
+	}
+}
+
+// saveBuildLog stores the output of a build, logging instead of failing the build when it cannot
+func (s *BaseEngine) saveBuildLog(ctx context.Context, commitHash string, log *buildLog) {
+	if err := s.store.SaveBuildLog(ctx, commitHash, log.String(), s.buildLogRetention()); err != nil {
+		s.logger.Error("Failed to store build log", "commit_hash", commitHash, "error", err)
+	}
+}
+
+// getBuildLogsHandler handles requests for the output of a build, identified by commit hash or a prefix of it
+func (s *BaseEngine) getBuildLogsHandler(c *gin.Context) {
+	commitHash, err := s.store.ResolveCommitHash(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, store.ErrAmbiguous) {
+		respondBadRequest(c, err)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to resolve commit hash", "commit_hash", c.Param("id"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get build logs",
+		})
+		return
+	}
+
+	log, err := s.store.GetBuildLog(c.Request.Context(), commitHash)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "build logs not found",
+			})
+			return
+		}
+		s.logger.Error("Failed to get build logs", "commit_hash", commitHash, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get build logs",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"commit_hash": commitHash,
+		"logs":        log,
+	})
+}
@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/config"
+)
+
+func TestBuildLog_KeepsTail(t *testing.T) {
+	log := newBuildLog(10)
+	if _, err := log.Write([]byte("short\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if got := log.String(); got != "short\n" {
+		t.Errorf("Expected untruncated log, got %q", got)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := log.Write([]byte("0123456789")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if _, err := log.Write([]byte("end\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	got := log.String()
+	if !strings.HasPrefix(got, buildLogTruncatedMarker) {
+		t.Errorf("Expected truncation marker, got %q", got)
+	}
+	if tail := strings.TrimPrefix(got, buildLogTruncatedMarker); tail != "456789end\n" {
+		t.Errorf("Expected the last 10 bytes, got %q", tail)
+	}
+	if len(log.buf) > 20 {
+		t.Errorf("Expected buffer to stay within twice the limit, got %d bytes", len(log.buf))
+	}
+}
+
+func TestBuildLogRetention(t *testing.T) {
+	s := &BaseEngine{config: &config.Config{}}
+	if got := s.buildLogRetention(); got != defaultBuildLogRetention {
+		t.Errorf("Expected default retention %v, got %v", defaultBuildLogRetention, got)
+	}
+
+	s.config.Engine.BuildLogRetention = 3600
+	if got := s.buildLogRetention(); got != time.Hour {
+		t.Errorf("Expected retention of one hour, got %v", got)
+	}
+
+	s.config.Engine.BuildLogRetention = -1
+	if got := s.buildLogRetention(); got != 0 {
+		t.Errorf("Expected logs to be kept forever, got %v", got)
+	}
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+const (
+	// CallbackSignatureHeader carries the HMAC-SHA256 of a build callback body, as sha256=<hex>
+	CallbackSignatureHeader = "X-Nina-Signature"
+	// defaultCallbackTimeout bounds a build callback request when no timeout is configured
+	defaultCallbackTimeout = 10 * time.Second
+	// callbackRetryBackoff is the wait before the first callback retry, doubled after every attempt
+	callbackRetryBackoff = time.Second
+)
+
+// validCallbackURL reports whether raw is an absolute http or https URL
+func validCallbackURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// buildLogsURL returns the URL of the logs of a build on the Engine, as reached by the request
+func buildLogsURL(r *http.Request, commitHash string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/api/v1/builds/%s/logs", scheme, r.Host, url.PathEscape(commitHash))
+}
+
+// callbackTimeout returns the time a single build callback request may take
+func (s *BaseEngine) callbackTimeout() time.Duration {
+	return configuredTimeout(s.config.Engine.CallbackTimeout, defaultCallbackTimeout)
+}
+
+// notifyBuildCallback posts the recorded outcome of a build to the callback URL of its request.
+// The callback is sent in the background, failures are only logged.
+func (s *BaseEngine) notifyBuildCallback(ctx context.Context, req *types.BuildRequest, logsURL string) {
+	build, err := s.store.GetBuild(ctx, req.CommitHash)
+	if err != nil {
+		s.logger.Error("Failed to get build for callback", "commit_hash", req.CommitHash, "error", err)
+		return
+	}
+	body, err := json.Marshal(&types.BuildCallback{
+		AppName:    build.AppName,
+		CommitHash: build.CommitHash,
+		Status:     build.Status,
+		ImageTag:   build.ImageTag,
+		Size:       build.Size,
+		LogsURL:    logsURL,
+		FinishedAt: build.FinishedAt,
+	})
+	if err != nil {
+		s.logger.Error("Failed to encode build callback", "commit_hash", req.CommitHash, "error", err)
+		return
+	}
+
+	timeout, retries, secret := s.callbackTimeout(), s.config.Engine.CallbackRetries, s.config.Engine.CallbackSecret
+	go func() {
+		err := sendCallback(context.Background(), req.CallbackURL, body, secret, timeout, retries, callbackRetryBackoff)
+		if err != nil {
+			s.logger.Error("Build callback failed", "commit_hash", build.CommitHash, "callback_url", req.CallbackURL,
+				"attempts", retries+1, "error", err)
+			return
+		}
+		s.logger.Info("Build callback sent", "commit_hash", build.CommitHash, "callback_url", req.CallbackURL,
+			"status", build.Status)
+	}()
+}
+
+// sendCallback posts body to callbackURL until it answers with a 2xx status, retrying up to retries times
+// with an exponential backoff. The body is signed with secret when one is given.
+func sendCallback(ctx context.Context, callbackURL string, body []byte, secret string, timeout time.Duration,
+	retries int, backoff time.Duration,
+) error {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err() //nolint:wrapcheck
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		if err = postCallback(ctx, callbackURL, body, secret, timeout); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// postCallback makes a single callback request
+func postCallback(ctx context.Context, callbackURL string, body []byte, secret string, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create callback request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "nina-engine")
+	if secret != "" {
+		req.Header.Set(CallbackSignatureHeader, "sha256="+signCallback(body, secret))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("callback request failed: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback answered with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signCallback returns the hex encoded HMAC-SHA256 of body keyed with secret
+func signCallback(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
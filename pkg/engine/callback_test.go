@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/internal/pkg/builder"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestSendCallback_RetriesAndSigns(t *testing.T) {
+	var attempts atomic.Int32
+	body := []byte(`{"status":"built"}`)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ := io.ReadAll(r.Body)
+		if !bytes.Equal(received, body) {
+			t.Errorf("Expected body %s, got %s", body, received)
+		}
+		if got := r.Header.Get(CallbackSignatureHeader); got != "sha256="+signCallback(body, "s3cret") {
+			t.Errorf("Unexpected signature %q", got)
+		}
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+
+	if err := sendCallback(context.Background(), receiver.URL, body, "s3cret", time.Second, 2, time.Millisecond); err != nil {
+		t.Fatalf("sendCallback() unexpected error: %v", err)
+	}
+	if attempts.Load() != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts.Load())
+	}
+}
+
+func TestSendCallback_GivesUp(t *testing.T) {
+	var attempts atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if r.Header.Get(CallbackSignatureHeader) != "" {
+			t.Error("Expected no signature without a secret")
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(receiver.Close)
+
+	err := sendCallback(context.Background(), receiver.URL, []byte(`{}`), "", time.Second, 2, time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "status 500") {
+		t.Errorf("Expected a status 500 error, got %v", err)
+	}
+	if attempts.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts.Load())
+	}
+}
+
+func TestBuildHandler_Callback(t *testing.T) {
+	callbacks := make(chan *http.Request, 1)
+	payloads := make(chan types.BuildCallback, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload types.BuildCallback
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		callbacks <- r
+		payloads <- payload
+	}))
+	t.Cleanup(receiver.Close)
+
+	s, _ := newTestEngineWithBackends(t)
+	s.config.Engine.CallbackSecret = "s3cret"
+	s.builder = &builder.BaseBuilder{}
+	if err := initBuilder(context.Background(), s.builder, s.dockerClient, s.config, s.logger); err != nil {
+		t.Fatalf("Failed to initialize builder: %v", err)
+	}
+
+	// A corrupted bundle fails the build after its record was created
+	body, _ := json.Marshal(map[string]string{
+		"app_name":        "app",
+		"commit_hash":     "abc123",
+		"bundle_content":  gzippedTar(t, map[string]string{"main.go": "package main"}),
+		"bundle_checksum": strings.Repeat("0", 64),
+		"callback_url":    receiver.URL,
+	})
+	req := httptest.NewRequest("POST", "/api/v1/build", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+
+	select {
+	case r := <-callbacks:
+		payload := <-payloads
+		if payload.AppName != "app" || payload.CommitHash != "abc123" || payload.Status != types.BuildStatusFailed {
+			t.Errorf("Expected the failed build in the callback, got %+v", payload)
+		}
+		if payload.LogsURL != "http://example.com/api/v1/builds/abc123/logs" {
+			t.Errorf("Unexpected logs URL %q", payload.LogsURL)
+		}
+		if !strings.HasPrefix(r.Header.Get(CallbackSignatureHeader), "sha256=") {
+			t.Errorf("Expected a signed callback, got headers %v", r.Header)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a build callback")
+	}
+}
+
+func TestValidateBuildRequest_CallbackURL(t *testing.T) {
+	s := newTestEngine(t)
+	for callbackURL, valid := range map[string]bool{
+		"https://ci.example.com/hooks/nina": true,
+		"http://localhost:8080/done":        true,
+		"ftp://ci.example.com/hook":         false,
+		"/relative/hook":                    false,
+	} {
+		req := &types.BuildRequest{AppName: "app", CommitHash: "abc123", BundleContents: "bundle", CallbackURL: callbackURL}
+		if err := s.validateBuildRequest(req); (err == nil) != valid {
+			t.Errorf("validateBuildRequest(callback_url=%q) error = %v, valid %v", callbackURL, err, valid)
+		}
+	}
+}
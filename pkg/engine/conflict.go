@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+// createContainer creates a container. When the name is held by a leftover container of the same app,
+// e.g. from a deployment that failed half way, the leftover is removed and creation is retried once.
+// Containers of other apps or not created by nina are never removed.
+func (s *BaseEngine) createContainer(
+	ctx context.Context,
+	dockerClient *client.Client,
+	containerConfig *container.Config,
+	hostConfig *container.HostConfig,
+	containerName, appName string,
+) (container.CreateResponse, error) {
+	resp, err := dockerClient.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, containerName)
+	if err == nil || !errdefs.IsConflict(err) {
+		return resp, err
+	}
+
+	stale, inspectErr := dockerClient.ContainerInspect(ctx, containerName)
+	if inspectErr != nil {
+		return resp, errors.Join(err, fmt.Errorf("failed to inspect conflicting container %s: %w", containerName, inspectErr))
+	}
+	if stale.Config == nil || stale.Config.Labels[AppLabel] != appName {
+		return resp, fmt.Errorf("container name %s is taken by a container not owned by %s: %w", containerName, appName, err)
+	}
+
+	s.logger.Warn("Removing stale container holding the container name",
+		"container_id", stale.ID, "container_name", containerName, "app_name", appName)
+	if removeErr := dockerClient.ContainerRemove(ctx, stale.ID, container.RemoveOptions{Force: true}); removeErr != nil {
+		return resp, errors.Join(err, fmt.Errorf("failed to remove stale container %s: %w", stale.ID, removeErr))
+	}
+
+	return dockerClient.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, containerName)
+}
@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestDeployHandler_ReplacesStaleContainerOnNameConflict(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+
+	// The first creation finds its name held by a leftover container of the app
+	fake.mu.Lock()
+	fake.conflicts = 1
+	fake.conflictLabels = map[string]string{AppLabel: "app"}
+	fake.mu.Unlock()
+
+	postDeploy(t, s, "app", "abc123")
+	deployment := waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady)
+
+	fake.mu.Lock()
+	removed := append([]string{}, fake.removed...)
+	fake.mu.Unlock()
+	if len(removed) != 1 || removed[0] != "stale1" {
+		t.Errorf("Expected the stale container to be removed, got %v", removed)
+	}
+	if live := fake.liveContainers(); len(live) != 1 || live[0] != deployment.Containers[0].ContainerID {
+		t.Errorf("Expected the retried container to be deployed, got %v", live)
+	}
+}
+
+func TestDeployHandler_KeepsForeignContainerOnNameConflict(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+
+	// The name is held by a container nina did not create for this app
+	fake.mu.Lock()
+	fake.conflicts = 1
+	fake.conflictLabels = map[string]string{AppLabel: "other"}
+	fake.mu.Unlock()
+
+	postDeploy(t, s, "app", "abc123")
+	waitForDeploymentStatus(t, s, "app", types.DeploymentStatusFailed)
+
+	fake.mu.Lock()
+	removed := len(fake.removed)
+	fake.mu.Unlock()
+	if removed != 0 {
+		t.Errorf("Expected the foreign container to be kept, got %d removals", removed)
+	}
+	if n := fake.createdCount(); n != 0 {
+		t.Errorf("Expected no container to be created, got %d", n)
+	}
+}
+
+func TestCreateContainerConfig_AppLabel(t *testing.T) {
+	s := newTestEngine(t)
+	labels := map[string]string{"team": "payments"}
+
+	config := s.createContainerConfig("app", "nina-app-abc123", 8080, nil, labels)
+	if config.Labels[AppLabel] != "app" || config.Labels["team"] != "payments" {
+		t.Errorf("Expected the deployment labels and the app label, got %v", config.Labels)
+	}
+	if _, ok := labels[AppLabel]; ok {
+		t.Error("Expected the deployment labels to be left untouched")
+	}
+
+	err := s.validateDeploymentRequest(&types.DeploymentRequest{
+		AppName: "app", CommitHash: "abc123", Replicas: 1, Labels: map[string]string{AppLabel: "other"},
+	})
+	if err == nil {
+		t.Error("Expected the app label to be reserved")
+	}
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/gin-gonic/gin"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// containerNamePrefix is the prefix of the names of the containers created for deployments
+const containerNamePrefix = "nina-"
+
+// listContainersHandler lists the containers created by Nina on the default daemon and the nodes,
+// flagging the ones no deployment references as orphaned
+func (s *BaseEngine) listContainersHandler(c *gin.Context) {
+	containers, err := s.managedContainers(c.Request.Context())
+	if err != nil {
+		s.logger.Error("Failed to list containers", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"containers": containers,
+		"count":      len(containers),
+	})
+}
+
+// managedContainers lists the containers that carry the app label or a Nina container name, on the default
+// daemon first and then on the nodes in name order
+func (s *BaseEngine) managedContainers(ctx context.Context) ([]types.ManagedContainer, error) {
+	if s.dockerClient == nil {
+		return nil, fmt.Errorf("docker client is not initialized")
+	}
+
+	deployments, err := s.store.ListNewDeployments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	referenced := map[string]bool{}
+	for _, deployment := range deployments {
+		for _, cont := range deployment.Containers {
+			referenced[cont.ContainerID] = true
+		}
+	}
+
+	nodes := make([]string, 0, len(s.nodeClients))
+	for node := range s.nodeClients {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	managed, err := listManagedContainers(ctx, s.dockerClient, "", referenced)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		nodeContainers, err := listManagedContainers(ctx, s.nodeClients[node], node, referenced)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", node, err)
+		}
+		managed = append(managed, nodeContainers...)
+	}
+	return managed, nil
+}
+
+// listManagedContainers lists the containers of a daemon, stopped ones included, that carry the app label
+// or a Nina container name. Containers created before labeling are only recognized by their name.
+func listManagedContainers(ctx context.Context, dockerClient *client.Client, node string,
+	referenced map[string]bool,
+) ([]types.ManagedContainer, error) {
+	containers, err := dockerClient.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	managed := []types.ManagedContainer{}
+	for _, summary := range containers {
+		name := ""
+		if len(summary.Names) > 0 {
+			name = strings.TrimPrefix(summary.Names[0], "/")
+		}
+		appName, labeled := summary.Labels[AppLabel]
+		if !labeled && !strings.HasPrefix(name, containerNamePrefix) {
+			continue
+		}
+		managed = append(managed, types.ManagedContainer{
+			ContainerID: summary.ID,
+			Name:        name,
+			Image:       summary.Image,
+			Node:        node,
+			AppName:     appName,
+			State:       summary.State,
+			Status:      summary.Status,
+			CreatedAt:   time.Unix(summary.Created, 0).UTC(),
+			Orphaned:    !referenced[summary.ID],
+		})
+	}
+	sort.Slice(managed, func(i, j int) bool {
+		if managed[i].AppName != managed[j].AppName {
+			return managed[i].AppName < managed[j].AppName
+		}
+		return managed[i].Name < managed[j].Name
+	})
+	return managed, nil
+}
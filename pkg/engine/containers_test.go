@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestListContainersHandler(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app-a", "aaa111")
+	createBuiltBuild(t, s, "app-b", "bbb222")
+	postDeploy(t, s, "app-a", "aaa111")
+	postDeploy(t, s, "app-b", "bbb222")
+	waitForDeploymentStatus(t, s, "app-a", types.DeploymentStatusReady)
+	waitForDeploymentStatus(t, s, "app-b", types.DeploymentStatusReady)
+
+	// Dropping the record of app-b leaves its container behind
+	if err := s.store.DeleteNewDeployment(context.Background(), "app-b"); err != nil {
+		t.Fatalf("Failed to delete deployment: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/containers", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Containers []types.ManagedContainer `json:"containers"`
+		Count      int                      `json:"count"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Count != 2 || len(resp.Containers) != 2 {
+		t.Fatalf("Expected 2 containers, got %+v", resp)
+	}
+	a, b := resp.Containers[0], resp.Containers[1]
+	if a.AppName != "app-a" || a.Orphaned || !strings.HasPrefix(a.Name, "nina-app-a-1-") || a.State != "running" {
+		t.Errorf("Expected the referenced container of app-a, got %+v", a)
+	}
+	if b.AppName != "app-b" || !b.Orphaned {
+		t.Errorf("Expected the orphaned container of app-b, got %+v", b)
+	}
+}
+
+func TestListContainersHandler_NoDocker(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	s.dockerClient = nil
+
+	req := httptest.NewRequest("GET", "/api/v1/containers", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, got %d: %s", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+}
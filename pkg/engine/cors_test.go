@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+)
+
+func newCORSTestEngine(t *testing.T, cors config.CORSConfig) *BaseEngine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	s := &BaseEngine{
+		config: &config.Config{Server: config.ServerConfig{CORS: cors}},
+		logger: logger.New(logger.LevelDebug, "text"),
+		router: gin.New(),
+	}
+	s.setupRoutes()
+	return s
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	s := newCORSTestEngine(t, config.CORSConfig{
+		Enabled:        true,
+		AllowedOrigins: []string{"https://dashboard.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         600,
+	})
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/deployments", http.NoBody)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d", http.StatusNoContent, w.Code)
+	}
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":  "https://dashboard.example.com",
+		"Access-Control-Allow-Methods": "GET, POST",
+		"Access-Control-Allow-Headers": "Content-Type",
+		"Access-Control-Max-Age":       "600",
+	}
+	for header, value := range expected {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("Expected %s %q, got %q", header, value, got)
+		}
+	}
+}
+
+func TestCORS_DisallowedOrigin(t *testing.T) {
+	s := newCORSTestEngine(t, config.CORSConfig{
+		Enabled:        true,
+		AllowedOrigins: []string{"https://dashboard.example.com"},
+	})
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/deployments", http.NoBody)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no Access-Control-Allow-Origin header, got %q", got)
+	}
+}
+
+func TestCORS_SimpleRequest(t *testing.T) {
+	s := newCORSTestEngine(t, config.CORSConfig{
+		Enabled:          true,
+		AllowedOrigins:   []string{"*"},
+		AllowCredentials: true,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", http.NoBody)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	// With credentials the wildcard is replaced by the request origin
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://dashboard.example.com" {
+		t.Errorf("Expected origin to be echoed, got %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Expected credentials to be allowed, got %q", got)
+	}
+}
+
+func TestCORS_DisabledByDefault(t *testing.T) {
+	s := newCORSTestEngine(t, config.CORSConfig{})
+
+	req := httptest.NewRequest(http.MethodGet, "/health", http.NoBody)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no CORS headers when disabled, got %q", got)
+	}
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/store"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// getDeploymentStatusWrapper reports the status of a deployment. Legacy deployments are converted to the
+// deployment model first, so the route always answers with a status report.
+func (s *BaseEngine) getDeploymentStatusWrapper(ctx context.Context, id string) (interface{}, error) {
+	deployment, err := s.getDeploymentWrapper(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if legacy, ok := deployment.(*types.LegacyDeployment); ok {
+		converted := store.LegacyToDeployment(legacy)
+		converted.URLs = appURLs(&s.config.Ingress, converted)
+		deployment = converted
+	}
+	return deploymentStatusReport(deployment.(*types.Deployment), time.Now()), nil
+}
+
+// deploymentStatusReport describes the status of a deployment. A deployment whose containers are still
+// being started in the background has no containers yet, which must not read as a failed deployment.
+func deploymentStatusReport(deployment *types.Deployment, now time.Time) *types.DeploymentStatusReport {
+	report := &types.DeploymentStatusReport{Deployment: *deployment}
+	replicas := deployment.Replicas
+	if replicas == 0 {
+		replicas = len(deployment.Containers)
+	}
+
+	switch deployment.Status {
+	case types.DeploymentStatusUnavailable, types.DeploymentStatusDeploying:
+		report.InProgress = true
+		report.ElapsedSeconds = int64(now.Sub(deployment.CreatedAt).Seconds())
+		report.Message = fmt.Sprintf("Starting %d replicas, deploying for %s", replicas,
+			now.Sub(deployment.CreatedAt).Truncate(time.Second))
+	case types.DeploymentStatusReady:
+		report.Message = fmt.Sprintf("All %d replicas are up", len(deployment.Containers))
+	case types.DeploymentStatusPartiallyReady:
+		report.Message = fmt.Sprintf("%d of %d replicas are up", len(deployment.Containers), replicas)
+	case types.DeploymentStatusFailed:
+		report.Message = "No replica came up"
+		if len(deployment.FailedReplicas) > 0 {
+			reasons := make([]string, 0, len(deployment.FailedReplicas))
+			for _, failure := range deployment.FailedReplicas {
+				reasons = append(reasons, fmt.Sprintf("replica %d: %s", failure.Replica, failure.Error))
+			}
+			report.Message += ": " + strings.Join(reasons, "; ")
+		}
+	}
+	return report
+}
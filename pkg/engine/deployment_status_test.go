@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// getDeploymentStatus requests the status of the deployment of an app
+func getDeploymentStatus(t *testing.T, s *BaseEngine, appName string) *types.DeploymentStatusReport {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/deployments/"+appName+"/status", http.NoBody))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var report types.DeploymentStatusReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return &report
+}
+
+func TestGetDeploymentStatus_Deploying(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	ctx := context.Background()
+
+	// What the deploy handler records before the containers are started in the background
+	req := &types.DeploymentRequest{AppName: "app", CommitHash: "abc123", Replicas: 2}
+	deployment, err := s.store.CreateNewDeployment(ctx, req)
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	if err := s.store.UpdateNewDeploymentStatus(ctx, "app", deployment.ID, types.DeploymentStatusDeploying); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+
+	report := getDeploymentStatus(t, s, "app")
+	if report.Status != types.DeploymentStatusDeploying || !report.InProgress {
+		t.Errorf("Expected an in progress deployment, got %+v", report)
+	}
+	if !strings.HasPrefix(report.Message, "Starting 2 replicas") {
+		t.Errorf("Unexpected message %q", report.Message)
+	}
+	if report.AppName != "app" || report.CommitHash != "abc123" {
+		t.Errorf("Expected the deployment fields in the report, got %+v", report)
+	}
+}
+
+func TestGetDeploymentStatus_Failed(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	ctx := context.Background()
+
+	deployment, err := s.store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: "app", CommitHash: "abc123", Replicas: 1})
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	failures := []types.ReplicaFailure{{Replica: 1, Error: "port already allocated"}}
+	if err := s.store.UpdateNewDeploymentWithFailures(ctx, "app", deployment.ID, nil, failures, types.DeploymentStatusFailed); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+
+	report := getDeploymentStatus(t, s, "app")
+	if report.Status != types.DeploymentStatusFailed || report.InProgress || report.ElapsedSeconds != 0 {
+		t.Errorf("Expected a failed deployment that is not in progress, got %+v", report)
+	}
+	if report.Message != "No replica came up: replica 1: port already allocated" {
+		t.Errorf("Unexpected message %q", report.Message)
+	}
+}
+
+func TestGetDeploymentStatus_Legacy(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	legacy, err := s.store.CreateDeployment(context.Background(),
+		&types.ProvisionRequest{Name: "legacy-app", Image: "nginx:latest", Ports: []int{80}})
+	if err != nil {
+		t.Fatalf("Failed to create legacy deployment: %v", err)
+	}
+
+	report := getDeploymentStatus(t, s, legacy.ID)
+	if report.ID != legacy.ID || report.AppName != "legacy-app" || report.Message == "" {
+		t.Errorf("Expected a report of the legacy deployment, got %+v", report)
+	}
+	if len(report.Containers) != 1 || report.Containers[0].ImageTag != "nginx:latest" {
+		t.Errorf("Expected the converted containers, got %+v", report.Containers)
+	}
+}
+
+func TestDeploymentStatusReport(t *testing.T) {
+	created := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	containers := []types.Container{{ContainerID: "c1"}, {ContainerID: "c2"}}
+	tests := []struct {
+		status     types.DeploymentStatus
+		containers []types.Container
+		inProgress bool
+		message    string
+	}{
+		{types.DeploymentStatusUnavailable, nil, true, "Starting 3 replicas, deploying for 1m30s"},
+		{types.DeploymentStatusDeploying, nil, true, "Starting 3 replicas, deploying for 1m30s"},
+		{types.DeploymentStatusPartiallyReady, containers, false, "2 of 3 replicas are up"},
+		{types.DeploymentStatusReady, containers, false, "All 2 replicas are up"},
+		{types.DeploymentStatusFailed, nil, false, "No replica came up"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			deployment := &types.Deployment{Status: tt.status, Replicas: 3, Containers: tt.containers, CreatedAt: created}
+			report := deploymentStatusReport(deployment, created.Add(90*time.Second))
+			if report.InProgress != tt.inProgress || report.Message != tt.message {
+				t.Errorf("Expected in progress %v and message %q, got %v and %q",
+					tt.inProgress, tt.message, report.InProgress, report.Message)
+			}
+			if tt.inProgress && report.ElapsedSeconds != 90 {
+				t.Errorf("Expected 90 elapsed seconds, got %d", report.ElapsedSeconds)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// defaultMinFreeDiskSpace is the free space a build needs when none is configured
+const defaultMinFreeDiskSpace = 1 << 30
+
+// insufficientStorageError reports a filesystem without enough free space for a build
+type insufficientStorageError struct {
+	Name      string
+	Path      string
+	Available uint64
+	Required  uint64
+}
+
+// Error implements the error interface
+func (e *insufficientStorageError) Error() string {
+	return fmt.Sprintf("insufficient disk space in %s (%s): %d bytes available, %d bytes required",
+		e.Name, e.Path, e.Available, e.Required)
+}
+
+// minFreeDiskSpace returns the free space a build needs, zero meaning the check is disabled
+func (s *BaseEngine) minFreeDiskSpace() uint64 {
+	switch required := s.config.Engine.MinFreeDiskSpace; {
+	case required < 0:
+		return 0
+	case required == 0:
+		return defaultMinFreeDiskSpace
+	default:
+		return uint64(required)
+	}
+}
+
+// checkDiskSpace verifies that the temp directory, where bundles are extracted, and the Docker data root,
+// where images are stored, have enough free space for a build. The data root is only checked for a local daemon.
+func (s *BaseEngine) checkDiskSpace(ctx context.Context) error {
+	required := s.minFreeDiskSpace()
+	if required == 0 {
+		return nil
+	}
+
+	paths := []struct{ name, path string }{{"temp directory", s.tempDir()}}
+	if root := s.dockerDataRoot(ctx); root != "" {
+		paths = append(paths, struct{ name, path string }{"Docker data root", root})
+	}
+	for _, p := range paths {
+		available, err := availableDiskSpace(p.path)
+		if err != nil {
+			s.logger.Warn("Skipping disk space check", "name", p.name, "path", p.path, "error", err)
+			continue
+		}
+		s.logger.Debug("Disk space checked", "name", p.name, "path", p.path,
+			"available_bytes", available, "required_bytes", required)
+		if available < required {
+			return &insufficientStorageError{Name: p.name, Path: p.path, Available: available, Required: required}
+		}
+	}
+	return nil
+}
+
+// dockerDataRoot returns the data root of the builder's Docker daemon when it runs on this host
+func (s *BaseEngine) dockerDataRoot(ctx context.Context) string {
+	dockerClient := s.builder.GetDockerClient()
+	if !strings.HasPrefix(dockerClient.DaemonHost(), "unix://") {
+		return ""
+	}
+	info, err := dockerClient.Info(ctx)
+	if err != nil {
+		s.logger.Warn("Failed to get Docker data root", "error", err)
+		return ""
+	}
+	return info.DockerRootDir
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package engine
+
+import "errors"
+
+// availableDiskSpace is not implemented on this platform, the disk space check is skipped
+func availableDiskSpace(_ string) (uint64, error) {
+	return 0, errors.New("disk space check not supported on this platform")
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/internal/pkg/builder"
+)
+
+func TestMinFreeDiskSpace(t *testing.T) {
+	s := newTestEngine(t)
+	if got := s.minFreeDiskSpace(); got != defaultMinFreeDiskSpace {
+		t.Errorf("Expected default %d, got %d", defaultMinFreeDiskSpace, got)
+	}
+	s.config.Engine.MinFreeDiskSpace = 4096
+	if got := s.minFreeDiskSpace(); got != 4096 {
+		t.Errorf("Expected 4096, got %d", got)
+	}
+	s.config.Engine.MinFreeDiskSpace = -1
+	if got := s.minFreeDiskSpace(); got != 0 {
+		t.Errorf("Expected the check to be disabled, got %d", got)
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	s.builder = &builder.BaseBuilder{}
+	s.builder.SetDockerClient(s.dockerClient)
+
+	s.config.Engine.MinFreeDiskSpace = 1
+	if err := s.checkDiskSpace(context.Background()); err != nil {
+		t.Fatalf("Expected enough disk space, got %v", err)
+	}
+
+	s.config.Engine.MinFreeDiskSpace = math.MaxInt64
+	err := s.checkDiskSpace(context.Background())
+	var storageErr *insufficientStorageError
+	if !errors.As(err, &storageErr) {
+		t.Fatalf("Expected insufficient storage error, got %v", err)
+	}
+	if storageErr.Name != "temp directory" || storageErr.Required != math.MaxInt64 {
+		t.Errorf("Unexpected error details: %+v", storageErr)
+	}
+}
+
+func TestBuildHandler_InsufficientStorage(t *testing.T) {
+	s := newTestEngineWithBuilder(t)
+	s.config.Engine.MinFreeDiskSpace = math.MaxInt64
+
+	// The check runs before the body is read, so a body that would be rejected is never looked at
+	req := httptest.NewRequest("POST", "/api/v1/build", strings.NewReader("not a build request"))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInsufficientStorage {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusInsufficientStorage, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "insufficient disk space in temp directory") {
+		t.Errorf("Expected insufficient disk space error, got %s", w.Body.String())
+	}
+}
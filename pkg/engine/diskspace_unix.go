@@ -0,0 +1,17 @@
+//go:build linux || darwin
+
+package engine
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// availableDiskSpace returns the free space in bytes available to unprivileged users on the filesystem of path
+func availableDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, fmt.Errorf("failed to stat filesystem of %s: %w", path, err)
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil //nolint:gosec,unconvert
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+)
+
+const (
+	// dockerConnectAttempts is the number of times the Docker daemon is pinged before giving up
+	dockerConnectAttempts = 5
+	// dockerConnectBackoff is the wait before the first retry, doubled after every failed attempt
+	dockerConnectBackoff = 500 * time.Millisecond
+	// dockerPingTimeout bounds a single ping to the Docker daemon
+	dockerPingTimeout = 5 * time.Second
+)
+
+// dockerClientOpts returns the Docker client options for the configured daemon.
+// Settings that are not configured fall back to the DOCKER_* environment variables.
+func dockerClientOpts(cfg *config.DockerNodeConfig) ([]client.Opt, error) {
+	opts := []client.Opt{client.FromEnv}
+	if cfg.Host != "" {
+		opts = append(opts, client.WithHost(cfg.Host))
+	}
+	if cfg.TLS.Enabled() {
+		if (cfg.TLS.Cert == "") != (cfg.TLS.Key == "") {
+			return nil, fmt.Errorf("docker TLS cert and key must be set together")
+		}
+		opts = append(opts, client.WithTLSClientConfig(cfg.TLS.CA, cfg.TLS.Cert, cfg.TLS.Key))
+	}
+	if cfg.APIVersion != "" {
+		opts = append(opts, client.WithVersion(cfg.APIVersion))
+	} else {
+		opts = append(opts, client.WithAPIVersionNegotiation())
+	}
+	return opts, nil
+}
+
+// checkDockerSocket fails early when a configured Unix socket does not exist,
+// instead of retrying to connect to a path nothing will ever listen on
+func checkDockerSocket(host string) error {
+	path, ok := strings.CutPrefix(host, "unix://")
+	if !ok {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("docker socket %s is not reachable: %w", path, err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("docker socket %s is not a socket", path)
+	}
+	return nil
+}
+
+// connectDocker creates a Docker client and waits until the daemon answers, retrying with exponential backoff
+func connectDocker(
+	ctx context.Context,
+	log *logger.Logger,
+	attempts int,
+	backoff time.Duration,
+	opts ...client.Opt,
+) (*client.Client, error) {
+	dockerClient, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Docker client: %w", err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, dockerPingTimeout)
+		_, err = dockerClient.Ping(pingCtx)
+		cancel()
+		if err == nil {
+			return dockerClient, nil
+		}
+		if attempt >= attempts {
+			break
+		}
+
+		log.Warn("Docker daemon not reachable, retrying", "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			_ = dockerClient.Close()
+			return nil, fmt.Errorf("gave up connecting to Docker daemon: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	_ = dockerClient.Close()
+	return nil, fmt.Errorf("docker daemon unreachable at %s after %d attempts: %w", dockerClient.DaemonHost(), attempts, err)
+}
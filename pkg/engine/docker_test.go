@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+)
+
+func TestConnectDocker(t *testing.T) {
+	log := logger.New(logger.LevelDebug, "text")
+	server := httptest.NewServer(&fakeDocker{})
+	defer server.Close()
+
+	dockerClient, err := connectDocker(context.Background(), log, 1, time.Millisecond,
+		client.WithHost("tcp://"+strings.TrimPrefix(server.URL, "http://")))
+	if err != nil {
+		t.Fatalf("Expected to connect to fake Docker daemon, got %v", err)
+	}
+	if closeErr := dockerClient.Close(); closeErr != nil {
+		t.Logf("Failed to close Docker client: %v", closeErr)
+	}
+}
+
+func TestConnectDocker_Unreachable(t *testing.T) {
+	log := logger.New(logger.LevelDebug, "text")
+
+	// Reserve a port and close it so nothing is listening there
+	server := httptest.NewServer(&fakeDocker{})
+	host := "tcp://" + strings.TrimPrefix(server.URL, "http://")
+	server.Close()
+
+	start := time.Now()
+	_, err := connectDocker(context.Background(), log, 3, 10*time.Millisecond, client.WithHost(host))
+	if err == nil {
+		t.Fatal("Expected error for unreachable Docker daemon, got nil")
+	}
+	if !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("Expected error to mention the number of attempts, got %v", err)
+	}
+	// Two retries with 10ms and 20ms backoff
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected backoff between attempts, finished in %s", elapsed)
+	}
+}
+
+func TestDockerClientOpts(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "tcp://from-env:2375")
+
+	// Without configuration the environment is used and the API version negotiated
+	opts, err := dockerClientOpts(&config.DockerNodeConfig{})
+	if err != nil {
+		t.Fatalf("Failed to build Docker client options: %v", err)
+	}
+	dockerClient, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		t.Fatalf("Failed to create Docker client: %v", err)
+	}
+	if host := dockerClient.DaemonHost(); host != "tcp://from-env:2375" {
+		t.Errorf("Expected host from DOCKER_HOST, got %s", host)
+	}
+
+	// Configured values win over the environment
+	cfg := &config.DockerNodeConfig{Host: "unix:///custom/docker.sock", APIVersion: "1.45"}
+	opts, err = dockerClientOpts(cfg)
+	if err != nil {
+		t.Fatalf("Failed to build Docker client options: %v", err)
+	}
+	dockerClient, err = client.NewClientWithOpts(opts...)
+	if err != nil {
+		t.Fatalf("Failed to create Docker client: %v", err)
+	}
+	if host := dockerClient.DaemonHost(); host != cfg.Host {
+		t.Errorf("Expected configured host %s, got %s", cfg.Host, host)
+	}
+	if version := dockerClient.ClientVersion(); version != "1.45" {
+		t.Errorf("Expected API version 1.45, got %s", version)
+	}
+}
+
+func TestCheckDockerSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	socketPath := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen on Unix socket: %v", err)
+	}
+	defer listener.Close() //nolint:errcheck
+
+	if err := checkDockerSocket("unix://" + socketPath); err != nil {
+		t.Errorf("Expected socket to be reachable, got %v", err)
+	}
+	if err := checkDockerSocket("unix://" + filepath.Join(dir, "missing.sock")); err == nil {
+		t.Error("Expected error for a missing socket, got nil")
+	}
+
+	regularFile := filepath.Join(dir, "file")
+	if err := os.WriteFile(regularFile, nil, 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := checkDockerSocket("unix://" + regularFile); err == nil {
+		t.Error("Expected error for a path that is not a socket, got nil")
+	}
+
+	// Remote and unset hosts are checked by pinging the daemon instead
+	for _, host := range []string{"", "tcp://127.0.0.1:2375"} {
+		if err := checkDockerSocket(host); err != nil {
+			t.Errorf("Expected no error for host %q, got %v", host, err)
+		}
+	}
+}
+
+// writeClientCert writes a self-signed client certificate and its key as PEM files,
+// returning their paths and a pool that trusts the certificate
+func writeClientCert(t *testing.T, dir string) (certPath, keyPath string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "nina-engine"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+	writePEM(t, certPath, "CERTIFICATE", der)
+	writePEM(t, keyPath, "EC PRIVATE KEY", keyDER)
+
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certPath, keyPath, pool
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestConnectDocker_TLS(t *testing.T) {
+	log := logger.New(logger.LevelDebug, "text")
+	dir := t.TempDir()
+	certPath, keyPath, clientCAs := writeClientCert(t, dir)
+
+	// A daemon that only accepts clients presenting the certificate above
+	server := httptest.NewUnstartedServer(&fakeDocker{})
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	caPath := filepath.Join(dir, "ca.pem")
+	writePEM(t, caPath, "CERTIFICATE", server.Certificate().Raw)
+	host := "tcp://" + strings.TrimPrefix(server.URL, "https://")
+
+	cfg := &config.DockerNodeConfig{Host: host, TLS: config.DockerTLSConfig{CA: caPath, Cert: certPath, Key: keyPath}}
+	opts, err := dockerClientOpts(cfg)
+	if err != nil {
+		t.Fatalf("Failed to build Docker client options: %v", err)
+	}
+	dockerClient, err := connectDocker(context.Background(), log, 1, time.Millisecond, opts...)
+	if err != nil {
+		t.Fatalf("Expected to connect to the TLS daemon, got %v", err)
+	}
+	if closeErr := dockerClient.Close(); closeErr != nil {
+		t.Logf("Failed to close Docker client: %v", closeErr)
+	}
+
+	// Without a client certificate the daemon rejects the connection
+	opts, err = dockerClientOpts(&config.DockerNodeConfig{Host: host, TLS: config.DockerTLSConfig{CA: caPath}})
+	if err != nil {
+		t.Fatalf("Failed to build Docker client options: %v", err)
+	}
+	if _, err := connectDocker(context.Background(), log, 1, time.Millisecond, opts...); err == nil {
+		t.Error("Expected connection without a client certificate to fail, got nil")
+	}
+}
+
+func TestDockerClientOpts_TLSRequiresCertAndKey(t *testing.T) {
+	_, err := dockerClientOpts(&config.DockerNodeConfig{Host: "tcp://127.0.0.1:2376", TLS: config.DockerTLSConfig{Cert: "cert.pem"}})
+	if err == nil {
+		t.Error("Expected error for a TLS cert without key, got nil")
+	}
+}
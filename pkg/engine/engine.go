@@ -4,13 +4,19 @@ package engine
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"math/big"
 	"net/http"
+	"os"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/distribution/reference"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
@@ -22,6 +28,13 @@ import (
 	"github.com/matiasinsaurralde/nina/pkg/types"
 )
 
+const (
+	// defaultContainerPort is the container port used when neither the request nor the image sets one
+	defaultContainerPort = 8080
+	// defaultMaxReplicas is the replica limit used when engine.max_replicas is not configured
+	defaultMaxReplicas = 10
+)
+
 // Engine defines the interface for the Engine server
 type Engine interface {
 	Start(ctx context.Context) error
@@ -41,10 +54,18 @@ type BaseEngine struct {
 	router       *gin.Engine
 	server       *http.Server
 	dockerClient *client.Client
+	// nodeClients holds the Docker clients of the named nodes deployments can be placed on
+	nodeClients map[string]*client.Client
+	// appLocks serializes the deployments of an app without blocking the deployments of other apps
+	appLocks keyedMutex
+	// metrics tracks builds and deployments for the /metrics endpoint
+	metrics engineMetrics
+	// activity keeps the recent build and deployment status transitions
+	activity activityLog
 }
 
 // NewEngine creates a new Engine server instance
-func NewEngine(cfg *config.Config, log *logger.Logger, st *store.Store) Engine {
+func NewEngine(cfg *config.Config, log *logger.Logger, st *store.Store) (Engine, error) {
 	// Set Gin mode based on log level
 	if log.GetLevel() == logger.LevelDebug {
 		gin.SetMode(gin.DebugMode)
@@ -58,20 +79,39 @@ func NewEngine(cfg *config.Config, log *logger.Logger, st *store.Store) Engine {
 	router.Use(gin.Recovery())
 	router.Use(loggerMiddleware(log))
 
-	// Initialize Docker client with default options
-	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if cfg.Engine.TempDir != "" {
+		if err := os.MkdirAll(cfg.Engine.TempDir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+	}
+
+	// Initialize Docker client for the configured daemon and make sure it is reachable
+	if err := checkDockerSocket(cfg.Docker.Host); err != nil {
+		return nil, err
+	}
+	dockerOpts, err := dockerClientOpts(&cfg.Docker.DockerNodeConfig)
 	if err != nil {
-		log.Error("Failed to initialize Docker client", "error", err)
-		return nil
+		return nil, err
 	}
-	log.Info("Docker client initialized successfully")
+	dockerClient, err := connectDocker(context.Background(), log, dockerConnectAttempts, dockerConnectBackoff, dockerOpts...)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Docker client initialized successfully", "host", dockerClient.DaemonHost(), "api_version", dockerClient.ClientVersion())
 
-	// Initialize builder
+	// Connect to the named nodes deployments can be placed on
+	nodeClients, err := connectNodes(context.Background(), log, cfg.Docker.Nodes)
+	if err != nil {
+		_ = dockerClient.Close()
+		return nil, err
+	}
+
+	// Initialize builder, sharing the client so builds and containers target the same daemon
 	b := &builder.BaseBuilder{}
-	b.SetDockerClient(dockerClient)
-	if err := b.Init(context.Background(), cfg, log); err != nil {
-		log.Error("Failed to initialize builder", "error", err)
-		// Continue without builder for now
+	if err := initBuilder(context.Background(), b, dockerClient, cfg, log); err != nil {
+		_ = dockerClient.Close()
+		closeNodes(nodeClients)
+		return nil, err
 	}
 
 	server := &BaseEngine{
@@ -81,12 +121,22 @@ func NewEngine(cfg *config.Config, log *logger.Logger, st *store.Store) Engine {
 		builder:      b,
 		router:       router,
 		dockerClient: dockerClient,
+		nodeClients:  nodeClients,
 	}
 
 	// Setup routes
 	server.setupRoutes()
 
-	return server
+	return server, nil
+}
+
+// initBuilder wires the Docker client into the builder and initializes it
+func initBuilder(ctx context.Context, b builder.Builder, dockerClient *client.Client, cfg *config.Config, log *logger.Logger) error {
+	b.SetDockerClient(dockerClient)
+	if err := b.Init(ctx, cfg, log); err != nil {
+		return fmt.Errorf("failed to initialize builder: %w", err)
+	}
+	return nil
 }
 
 // Start starts the Engine server
@@ -99,6 +149,11 @@ func (s *BaseEngine) Start(ctx context.Context) error {
 		IdleTimeout:       5 * time.Minute,
 	}
 
+	// Index deployments and builds stored before the indexes existed
+	if err := s.store.RebuildIndexes(ctx); err != nil {
+		s.logger.Warn("Failed to rebuild store indexes", "error", err)
+	}
+
 	s.logger.Info("Starting Engine server", "addr", s.config.GetServerAddr())
 
 	go func() {
@@ -133,20 +188,50 @@ func (s *BaseEngine) GetConfig() *config.Config {
 
 // setupRoutes sets up the API routes
 func (s *BaseEngine) setupRoutes() {
+	// CORS must run before routing so preflight requests are answered for every path
+	if s.config.Server.CORS.Enabled {
+		s.router.Use(corsMiddleware(&s.config.Server.CORS))
+	}
+
 	// Health check
 	s.router.GET("/health", s.healthHandler)
 
+	// Machine readable API description
+	s.router.GET("/openapi.json", s.openAPIHandler)
+
+	// Prometheus metrics
+	s.router.GET("/metrics", s.metricsHandler)
+
+	// Administrative routes
+	admin := s.router.Group("/admin", timeoutMiddleware(s.requestTimeout()))
+	admin.PUT("/log-level", s.setLogLevelHandler)
+
 	// API v1 routes
 	v1 := s.router.Group("/api/v1")
-	v1.POST("/provision", s.provisionHandler)
-	v1.POST("/deploy", s.deployHandler)
-	v1.POST("/build", s.buildHandler)
-	v1.GET("/builds", s.listBuildsHandler)
-	v1.DELETE("/builds/:id", s.deleteBuildsHandler)
-	v1.GET("/deployments", s.listDeploymentsHandler)
-	v1.GET("/deployments/:id", s.getDeploymentHandler)
-	v1.DELETE("/deployments/:id", s.deleteDeploymentHandler)
-	v1.GET("/deployments/:id/status", s.getDeploymentStatusHandler)
+
+	// Build, deploy and delete requests can take minutes and get a longer timeout,
+	// deletes wait for containers to stop gracefully
+	long := v1.Group("", timeoutMiddleware(s.longRequestTimeout()))
+	long.POST("/deploy", s.deployHandler)
+	long.POST("/build", s.buildHandler)
+	long.DELETE("/deployments", s.deleteDeploymentsByPrefixHandler)
+	long.DELETE("/deployments/:id", s.deleteDeploymentHandler)
+
+	api := v1.Group("", timeoutMiddleware(s.requestTimeout()))
+	api.POST("/provision", s.provisionHandler)
+	api.GET("/builds", s.listBuildsHandler)
+	api.GET("/builds/:id", s.getBuildHandler)
+	api.GET("/builds/:id/logs", s.getBuildLogsHandler)
+	api.DELETE("/builds/:id", s.deleteBuildsHandler)
+	api.GET("/deployments", s.listDeploymentsHandler)
+	api.GET("/deployments/:id", s.getDeploymentHandler)
+	api.GET("/deployments/:id/status", s.getDeploymentStatusHandler)
+	api.GET("/deployments/:id/containers/:containerID", s.inspectContainerHandler)
+	api.GET("/apps", s.listAppsHandler)
+	api.GET("/apps/:name", s.getAppHandler)
+	api.GET("/stats", s.statsHandler)
+	api.GET("/containers", s.listContainersHandler)
+	api.GET("/activity", s.activityHandler)
 }
 
 // healthHandler handles health check requests
@@ -160,7 +245,14 @@ func (s *BaseEngine) healthHandler(c *gin.Context) {
 
 // provisionHandler handles container provisioning requests
 func (s *BaseEngine) provisionHandler(c *gin.Context) {
-	var req store.ProvisionRequest
+	if s.legacyDeploymentsDisabled() {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "Legacy provisioning is disabled, use /api/v1/deploy instead",
+		})
+		return
+	}
+
+	var req types.ProvisionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid request body",
@@ -197,12 +289,50 @@ func (s *BaseEngine) provisionHandler(c *gin.Context) {
 	c.JSON(http.StatusCreated, deployment)
 }
 
-// validateDeploymentRequest validates the deployment request
+// validateDeploymentRequest validates the deployment request and normalizes its app name
 func (s *BaseEngine) validateDeploymentRequest(req *types.DeploymentRequest) error {
-	if req.AppName == "" || req.CommitHash == "" {
-		return fmt.Errorf("app name and commit hash are required")
+	errs := ValidationErrors{}
+	errs.normalizeAppName(&req.AppName)
+	if req.Image != "" {
+		if _, err := reference.ParseNormalizedNamed(req.Image); err != nil {
+			errs.Add("image", fmt.Sprintf("image %q is not a valid image reference: %v", req.Image, err))
+		}
+	} else if req.CommitHash == "" {
+		errs.Add("commit_hash", "commit hash is required")
 	}
-	return nil
+	if maxReplicas := s.maxReplicas(); req.Replicas < 1 || req.Replicas > maxReplicas {
+		errs.Add("replicas", fmt.Sprintf("replicas must be between 1 and %d, got %d", maxReplicas, req.Replicas))
+	}
+	if req.Node != "" && !s.hasNode(req.Node) {
+		errs.Add("node", fmt.Sprintf("unknown node %q", req.Node))
+	}
+	for _, webhookURL := range req.WebhookURLs {
+		if !validCallbackURL(webhookURL) {
+			errs.Add("webhook_urls", fmt.Sprintf("webhook URL %q must be an absolute http or https URL", webhookURL))
+		}
+	}
+	for key := range req.Labels {
+		if strings.TrimSpace(key) == "" {
+			errs.Add("labels", "label keys must not be empty")
+		}
+		if key == AppLabel {
+			errs.Add("labels", fmt.Sprintf("label %s is reserved", AppLabel))
+		}
+	}
+	errs.normalizeDomains(req.Domains)
+	if !req.PullPolicy.Valid() {
+		errs.Add("pull_policy", fmt.Sprintf("pull policy must be %s, %s or %s, got %q",
+			types.PullPolicyAlways, types.PullPolicyIfNotPresent, types.PullPolicyNever, req.PullPolicy))
+	}
+	return errs.Err()
+}
+
+// maxReplicas returns the maximum number of replicas allowed for a deployment
+func (s *BaseEngine) maxReplicas() int {
+	if s.config.Engine.MaxReplicas > 0 {
+		return s.config.Engine.MaxReplicas
+	}
+	return defaultMaxReplicas
 }
 
 // validateBuildForDeployment validates that the build exists and is ready for deployment
@@ -227,7 +357,7 @@ func (s *BaseEngine) createDeploymentRecord(ctx context.Context, req *types.Depl
 	}
 
 	// Update deployment status to deploying
-	if err := s.store.UpdateNewDeploymentStatus(ctx, req.AppName, types.DeploymentStatusDeploying); err != nil {
+	if err := s.store.UpdateNewDeploymentStatus(ctx, req.AppName, deployment.ID, types.DeploymentStatusDeploying); err != nil {
 		s.logger.Error("Failed to update deployment status to deploying", "error", err)
 	}
 
@@ -236,8 +366,8 @@ func (s *BaseEngine) createDeploymentRecord(ctx context.Context, req *types.Depl
 
 // deployHandler handles deployment requests
 func (s *BaseEngine) deployHandler(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
-	defer cancel()
+	// The request is bounded by the long request timeout of the route
+	ctx := c.Request.Context()
 
 	var req types.DeploymentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -248,25 +378,35 @@ func (s *BaseEngine) deployHandler(c *gin.Context) {
 		return
 	}
 
+	// Fall back to the default replica count when none was requested
+	if req.Replicas == 0 {
+		req.Replicas = types.DefaultReplicas
+	}
+
 	// Validate request
 	if err := s.validateDeploymentRequest(&req); err != nil {
 		s.logger.Error("Invalid deployment request", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		respondBadRequest(c, err)
 		return
 	}
 
-	s.logger.Info("Processing deployment request", "app_name", req.AppName, "commit_hash", req.CommitHash, "replicas", req.Replicas)
+	s.logger.Info("Processing deployment request", "app_name", req.AppName, "commit_hash", req.CommitHash,
+		"image", req.Image, "replicas", req.Replicas)
 
-	// Validate build
-	build, err := s.validateBuildForDeployment(ctx, req.CommitHash)
-	if err != nil {
-		s.logger.Error("Build validation failed", "commit_hash", req.CommitHash, "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
+	// A pre-built image is deployed as is, otherwise the image comes from the build of the commit
+	imageTag, exposedPorts := req.Image, []int(nil)
+	if req.Image == "" {
+		build, err := s.validateBuildForDeployment(ctx, req.CommitHash)
+		if err != nil {
+			s.logger.Error("Build validation failed", "commit_hash", req.CommitHash, "error", err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		// A short commit hash is recorded as the full hash of the build it resolved to
+		req.CommitHash = build.CommitHash
+		imageTag, exposedPorts = build.ImageTag, build.ExposedPorts
 	}
 
 	// Create deployment record
@@ -278,37 +418,84 @@ func (s *BaseEngine) deployHandler(c *gin.Context) {
 		})
 		return
 	}
+	deployment.URLs = appURLs(&s.config.Ingress, deployment)
+	s.recordDeployment(deployment)
 
 	// Deploy containers in background
 	go func() {
+		// Deployments of the same app run one at a time, other apps are not held up
+		unlock := s.appLocks.Lock(req.AppName)
+		defer unlock()
+
+		// A delete or a newer deploy of the app that got the lock first wins over this deployment
+		current, err := s.store.GetNewDeployment(context.Background(), req.AppName)
+		if err != nil || current.ID != deployment.ID {
+			s.logger.Warn("Deployment was deleted or replaced before it started, skipping",
+				"app_name", req.AppName, "id", deployment.ID, "error", err)
+			return
+		}
+
 		s.logger.Info("Starting container deployment in background", "app_name", req.AppName, "replicas", req.Replicas)
-		if err := s.deployContainers(context.Background(), req.AppName, build.ImageTag, req.Replicas); err != nil {
+		err = s.deployContainers(context.Background(), deployment.ID, &req, imageTag, exposedPorts)
+		switch {
+		case errors.Is(err, store.ErrReplaced):
+			s.logger.Warn("Deployment was replaced while it was deploying", "app_name", req.AppName, "id", deployment.ID)
+		case err != nil:
 			s.logger.Error("Failed to deploy containers", "app_name", req.AppName, "error", err)
-			if updateErr := s.store.UpdateNewDeploymentStatus(context.Background(), req.AppName, types.DeploymentStatusFailed); updateErr != nil {
+			updateErr := s.store.UpdateNewDeploymentStatus(context.Background(), req.AppName, deployment.ID, types.DeploymentStatusFailed)
+			if updateErr != nil {
 				s.logger.Error("Failed to update deployment status to failed", "error", updateErr)
 			}
 		}
+		s.deploymentFinished(context.Background(), deployment.ID, req.AppName)
 	}()
 
 	c.JSON(http.StatusCreated, deployment)
 }
 
-// createContainerConfig creates the container configuration
-func (s *BaseEngine) createContainerConfig(imageTag string, containerPort int) *container.Config {
+// AppLabel is the container label holding the app name, marking the containers created by nina
+const AppLabel = "nina.app"
+
+// createContainerConfig creates the container configuration, applying the deployment labels as container labels
+// along with the app label
+func (s *BaseEngine) createContainerConfig(appName, imageTag string, containerPort int, env, labels map[string]string) *container.Config {
+	// Sort the keys so that the container environment is deterministic
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	containerEnv := make([]string, 0, len(env)+1)
+	for _, key := range keys {
+		containerEnv = append(containerEnv, fmt.Sprintf("%s=%s", key, env[key]))
+	}
+	// PORT goes last so that it always matches the exposed port
+	containerEnv = append(containerEnv, fmt.Sprintf("PORT=%d", containerPort))
+
+	containerLabels := make(map[string]string, len(labels)+1)
+	for key, value := range labels {
+		containerLabels[key] = value
+	}
+	containerLabels[AppLabel] = appName
+
 	return &container.Config{
-		Image: imageTag,
-		Env: []string{
-			fmt.Sprintf("PORT=%d", containerPort),
-		},
+		Image:  imageTag,
+		Env:    containerEnv,
+		Labels: containerLabels,
 		ExposedPorts: nat.PortSet{
 			nat.Port(fmt.Sprintf("%d/tcp", containerPort)): struct{}{},
 		},
 	}
 }
 
-// createHostConfig creates the host configuration for port binding
-func (s *BaseEngine) createHostConfig(containerPort int) *container.HostConfig {
+// createHostConfig creates the host configuration for port binding and resource limits
+func (s *BaseEngine) createHostConfig(containerPort int, cpu float64, memory int64) *container.HostConfig {
 	return &container.HostConfig{
+		Resources: container.Resources{
+			NanoCPUs: int64(cpu * 1e9),
+			Memory:   memory,
+		},
 		PortBindings: nat.PortMap{
 			nat.Port(fmt.Sprintf("%d/tcp", containerPort)): []nat.PortBinding{
 				{
@@ -323,17 +510,24 @@ func (s *BaseEngine) createHostConfig(containerPort int) *container.HostConfig {
 // createAndStartContainer creates and starts a single container
 func (s *BaseEngine) createAndStartContainer(
 	ctx context.Context,
-	appName, imageTag string,
+	req *types.DeploymentRequest,
+	imageTag string,
 	containerPort, replica int,
-) (*types.Container, error) {
-	s.logger.Info("Creating container", "replica", replica, "app_name", appName)
+) (containerData *types.Container, err error) {
+	appName := req.AppName
+	s.logger.Info("Creating container", "replica", replica, "app_name", appName, "node", req.Node)
 
-	containerConfig := s.createContainerConfig(imageTag, containerPort)
-	hostConfig := s.createHostConfig(containerPort)
+	dockerClient, err := s.dockerClientFor(req.Node)
+	if err != nil {
+		return nil, err
+	}
+
+	containerConfig := s.createContainerConfig(appName, imageTag, containerPort, req.Env, req.Labels)
+	hostConfig := s.createHostConfig(containerPort, req.CPU, req.Memory)
 
 	// Create container with unique name
 	containerName := s.generateUniqueContainerName(appName, replica)
-	resp, err := s.dockerClient.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, containerName)
+	resp, err := s.createContainer(ctx, dockerClient, containerConfig, hostConfig, containerName, appName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create container %d: %w", replica, err)
 	}
@@ -341,13 +535,20 @@ func (s *BaseEngine) createAndStartContainer(
 	containerID := resp.ID
 	s.logger.Info("Container created", "container_id", containerID, "app_name", appName, "replica", replica)
 
+	// A replica that does not come up must not leave its container behind
+	defer func() {
+		if err != nil {
+			s.removeFailedContainer(ctx, dockerClient, containerID, appName, replica)
+		}
+	}()
+
 	// Start container
-	if startErr := s.dockerClient.ContainerStart(ctx, containerID, container.StartOptions{}); startErr != nil {
+	if startErr := dockerClient.ContainerStart(ctx, containerID, container.StartOptions{}); startErr != nil {
 		return nil, fmt.Errorf("failed to start container %d: %w", replica, startErr)
 	}
 
 	// Get the actual assigned host port by inspecting the container
-	containerInfo, err := s.dockerClient.ContainerInspect(ctx, containerID)
+	containerInfo, err := dockerClient.ContainerInspect(ctx, containerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to inspect container %d: %w", replica, err)
 	}
@@ -364,51 +565,238 @@ func (s *BaseEngine) createAndStartContainer(
 
 	s.logger.Info("Container started", "container_id", containerID, "app_name", appName, "host_port", hostPort, "replica", replica)
 
+	// Don't hand out the container until it accepts connections
+	address := s.containerAddress(req.Node)
+	if err := s.waitForReady(ctx, dockerClient, containerID, address, hostPort); err != nil {
+		return nil, fmt.Errorf("container %d did not become ready: %w", replica, err)
+	}
+
 	// Create container info with the actual assigned port
-	containerData := &types.Container{
+	containerData = &types.Container{
 		ContainerID: containerID,
 		ImageTag:    imageTag,
-		Address:     "localhost",
+		Address:     address,
 		Port:        hostPort, // Use the actual assigned host port
+		Node:        req.Node,
 	}
 
 	return containerData, nil
 }
 
-// deployContainers deploys containers for the given app
-func (s *BaseEngine) deployContainers(ctx context.Context, appName, imageTag string, replicas int) error {
+// removeFailedContainer force removes the container of a replica that failed to come up.
+// Removal outlives the deploy context, so that cancelled deployments are cleaned up too.
+func (s *BaseEngine) removeFailedContainer(ctx context.Context, dockerClient *client.Client, containerID, appName string, replica int) {
+	s.logger.Info("Removing failed container", "container_id", containerID, "app_name", appName, "replica", replica)
+	if err := dockerClient.ContainerRemove(context.WithoutCancel(ctx), containerID, container.RemoveOptions{Force: true}); err != nil {
+		s.logger.Error("Failed to remove failed container", "container_id", containerID, "app_name", appName, "error", err)
+	}
+}
+
+// rollbackPartialDeployments reports whether the healthy replicas of a deployment are removed when another replica fails
+func (s *BaseEngine) rollbackPartialDeployments() bool {
+	return s.config != nil && s.config.Engine.RollbackPartialDeployments
+}
+
+// containerPortFor picks the port the application listens on inside the container.
+// An explicit request port wins, then the first port exposed by the image, then the default.
+func containerPortFor(req *types.DeploymentRequest, exposedPorts []int) int {
+	if req.Port > 0 {
+		return req.Port
+	}
+	if len(exposedPorts) > 0 {
+		return exposedPorts[0]
+	}
+	return defaultContainerPort
+}
+
+// deployContainers deploys the containers of the deployment with the given ID. When a newer deployment of the app
+// replaced it in the meantime, the containers are removed and an error wrapping store.ErrReplaced is returned.
+func (s *BaseEngine) deployContainers(ctx context.Context, deploymentID string, req *types.DeploymentRequest, imageTag string,
+	exposedPorts []int,
+) error {
+	appName := req.AppName
+	replicas := req.Replicas
 	s.logger.Info("Starting container deployment", "app_name", appName, "image_tag", imageTag, "replicas", replicas)
+	defer s.metrics.deployStarted()()
+
+	// Replicas that are not ready by the deadline fail the deployment, e.g. crash-looping containers.
+	// The store is still updated with ctx once the deadline has passed.
+	deployCtx := ctx
+	timeout := s.deployTimeout()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		deployCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Make the image available to the Docker daemon once, before any replica is created
+	imageInspect, err := s.ensureImage(deployCtx, req, imageTag)
+	if err != nil {
+		return err
+	}
+	// A pre-built image has no build recording the ports it exposes
+	if req.Image != "" {
+		exposedPorts = builder.ExposedTCPPorts(imageInspect.Config)
+	}
 
 	// Use Docker's automatic port assignment to avoid conflicts
-	containerPort := 8080 // Default container port (from Dockerfile)
+	containerPort := containerPortFor(req, exposedPorts)
 
 	var containers []types.Container
+	var failures []error
+	var failedReplicas []types.ReplicaFailure
+	start := time.Now()
 
-	// Create multiple containers based on replicas count
+	// Create multiple containers based on replicas count, keeping the ones that come up.
+	// When partial deployments are rolled back there is no point in starting more replicas after a failure.
 	for i := 0; i < replicas; i++ {
-		containerData, err := s.createAndStartContainer(ctx, appName, imageTag, containerPort, i+1)
+		if deployCtx.Err() != nil {
+			err := fmt.Errorf("deployment did not become ready within %s", timeout)
+			failures = append(failures, err)
+			failedReplicas = append(failedReplicas, types.ReplicaFailure{Replica: i + 1, Error: err.Error()})
+			continue
+		}
+
+		containerData, err := s.createAndStartContainer(deployCtx, req, imageTag, containerPort, i+1)
+		if err != nil && errors.Is(deployCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("deployment did not become ready within %s: %w", timeout, err)
+		}
 		if err != nil {
-			return err
+			s.logger.Warn("Replica failed", "app_name", appName, "replica", i+1, "error", err)
+			failures = append(failures, err)
+			failedReplicas = append(failedReplicas, types.ReplicaFailure{Replica: i + 1, Error: err.Error()})
+			if s.rollbackPartialDeployments() {
+				break
+			}
+			continue
 		}
 
 		containers = append(containers, *containerData)
 		s.logger.Info("Container added to list", "replica", i+1, "total_containers", len(containers))
 	}
 
-	// Update deployment with all container information and set status to ready
-	if err := s.store.UpdateNewDeploymentWithContainers(ctx, appName, containers, types.DeploymentStatusReady); err != nil {
+	// A deployment that ran out of time is failed as a whole, and so is a partial one when rollbacks are enabled
+	timedOut := errors.Is(deployCtx.Err(), context.DeadlineExceeded)
+	if len(failures) > 0 && len(containers) > 0 && (timedOut || s.rollbackPartialDeployments()) {
+		s.logger.Warn("Rolling back partial deployment", "app_name", appName, "containers", len(containers),
+			"timed_out", timedOut)
+		s.removeDeploymentContainers(context.WithoutCancel(ctx), &types.Deployment{
+			AppName:    appName,
+			Node:       req.Node,
+			Containers: containers,
+		})
+		containers = nil
+	}
+
+	status := deploymentStatusFor(len(containers), replicas)
+	if status == types.DeploymentStatusFailed {
+		err := errors.Join(failures...)
+		s.logger.Error("Deployment failed",
+			"app_name", appName,
+			"commit_hash", req.CommitHash,
+			"replicas", replicas,
+			"containers", len(containers),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"status", status,
+			"error", err,
+		)
+		// Keep the reasons on the record, the caller marks the deployment failed
+		if updateErr := s.store.UpdateNewDeploymentWithFailures(ctx, appName, deploymentID, nil, failedReplicas, status); updateErr != nil {
+			s.logger.Error("Failed to record replica failures", "app_name", appName, "error", updateErr)
+		}
+		return err
+	}
+
+	// Update deployment with the healthy containers, the failed replicas and the resulting status
+	if err := s.store.UpdateNewDeploymentWithFailures(ctx, appName, deploymentID, containers, failedReplicas, status); err != nil {
+		// No record points at the containers of a replaced deployment, they would be left running
+		if errors.Is(err, store.ErrReplaced) {
+			s.removeDeploymentContainers(context.WithoutCancel(ctx), &types.Deployment{
+				AppName:    appName,
+				Node:       req.Node,
+				Containers: containers,
+			})
+		}
 		return fmt.Errorf("failed to update deployment with containers: %w", err)
 	}
+	s.notifyDeploymentsChanged(ctx, appName)
+
+	if status == types.DeploymentStatusPartiallyReady {
+		s.logger.Warn("Deployment partially ready",
+			"app_name", appName,
+			"commit_hash", req.CommitHash,
+			"replicas", replicas,
+			"containers", len(containers),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"status", status,
+			"error", errors.Join(failures...),
+		)
+		return nil
+	}
 
-	s.logger.Info("Deployment completed successfully", "app_name", appName, "replicas", replicas, "containers", len(containers))
+	s.logger.Info("Deployment completed successfully",
+		"app_name", appName,
+		"commit_hash", req.CommitHash,
+		"replicas", replicas,
+		"containers", len(containers),
+		"duration_ms", time.Since(start).Milliseconds(),
+		"status", status,
+	)
 	return nil
 }
 
+// notifyDeploymentsChanged tells the ingress to refresh its routes right away.
+// Failures are only logged, the ingress still picks up the change on its next refresh.
+func (s *BaseEngine) notifyDeploymentsChanged(ctx context.Context, appName string) {
+	if err := s.store.PublishDeploymentsChanged(ctx, appName); err != nil {
+		s.logger.Warn("Failed to notify deployment change", "app_name", appName, "error", err)
+	}
+}
+
+// deploymentStatusFor returns the status of a deployment with the given number of healthy replicas
+func deploymentStatusFor(healthy, replicas int) types.DeploymentStatus {
+	switch {
+	case healthy == 0:
+		return types.DeploymentStatusFailed
+	case healthy < replicas:
+		return types.DeploymentStatusPartiallyReady
+	default:
+		return types.DeploymentStatusReady
+	}
+}
+
 // generateUniqueContainerName generates a unique container name
 func (s *BaseEngine) generateUniqueContainerName(appName string, replica int) string {
 	// Generate a random number for uniqueness
 	n, _ := rand.Int(rand.Reader, big.NewInt(999999))
-	return fmt.Sprintf("nina-%s-%d-%d", appName, replica, n.Int64())
+	return fmt.Sprintf("nina-%s-%d-%d", types.SanitizeAppName(appName), replica, n.Int64())
+}
+
+// respondDeleteDeploymentError answers a failed deployment deletion with 404 for missing
+// deployments, or 200 when ignoreMissing is set, and 500 for any other store failure
+func respondDeleteDeploymentError(c *gin.Context, log *logger.Logger, id string, ignoreMissing bool, err error) {
+	if errors.Is(err, store.ErrNotFound) && ignoreMissing {
+		log.Info("Deployment already absent", "id", id)
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Deployment not found, nothing to delete",
+			"id":      id,
+			"missing": true,
+		})
+		return
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		log.Warn("Deployment not found", "id", id)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Deployment not found",
+			"id":    id,
+		})
+		return
+	}
+	log.Error("Failed to delete deployment", "id", id, "error", err)
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": "Failed to delete deployment",
+		"id":    id,
+	})
 }
 
 // deleteDeploymentHandler handles deployment deletion requests
@@ -421,24 +809,33 @@ func (s *BaseEngine) deleteDeploymentHandler(c *gin.Context) {
 		return
 	}
 
+	// Deployments are stored and locked under the sanitized app name the deploy request normalized
+	if sanitized := types.SanitizeAppName(id); sanitized != "" {
+		id = sanitized
+	}
+
+	// With ignore_missing=true deleting an absent deployment succeeds
+	ignoreMissing, _ := strconv.ParseBool(c.Query("ignore_missing"))
+
+	// Wait for a running deployment of the app, so that no container it creates is left behind
+	unlock := s.appLocks.Lock(id)
+	defer unlock()
+
 	// Try to get deployment using the new types structure first
 	deployment, err := s.store.GetNewDeployment(c.Request.Context(), id)
 	if err != nil {
-		// If not found, try the old structure
-		_, oldErr := s.store.GetDeployment(c.Request.Context(), id)
-		if oldErr != nil {
-			s.logger.Error("Failed to get deployment", "id", id, "error", err)
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "Deployment not found",
-			})
+		if !errors.Is(err, store.ErrNotFound) {
+			respondDeleteDeploymentError(c, s.logger, id, ignoreMissing, err)
 			return
 		}
-		// For old deployments, just delete from store (no containers to clean up)
+		if s.legacyDeploymentsDisabled() {
+			respondDeleteDeploymentError(c, s.logger, id, ignoreMissing, err)
+			return
+		}
+		// If not found, try the old structure. For old deployments, just delete
+		// from store (no containers to clean up)
 		if err := s.store.DeleteDeployment(c.Request.Context(), id); err != nil {
-			s.logger.Error("Failed to delete deployment", "id", id, "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to delete deployment",
-			})
+			respondDeleteDeploymentError(c, s.logger, id, ignoreMissing, err)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
@@ -449,11 +846,38 @@ func (s *BaseEngine) deleteDeploymentHandler(c *gin.Context) {
 	}
 
 	// Clean up containers for new deployment type
+	containersRemoved := s.removeDeploymentContainers(c.Request.Context(), deployment)
+
+	// Delete deployment from store
+	if err := s.store.DeleteNewDeployment(c.Request.Context(), id); err != nil {
+		respondDeleteDeploymentError(c, s.logger, id, ignoreMissing, err)
+		return
+	}
+	s.notifyDeploymentsChanged(c.Request.Context(), deployment.AppName)
+
+	s.logger.Info("Deployment deleted successfully", "id", id, "app_name", deployment.AppName, "containers_removed", containersRemoved)
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Deployment deleted successfully",
+		"id":                 id,
+		"containers_removed": containersRemoved,
+	})
+}
+
+// removeDeploymentContainers force removes the containers of a deployment and returns how many were removed
+func (s *BaseEngine) removeDeploymentContainers(ctx context.Context, deployment *types.Deployment) int {
+	dockerClient, err := s.dockerClientFor(deployment.Node)
+	if err != nil {
+		s.logger.Error("Failed to remove containers", "app_name", deployment.AppName, "error", err)
+		return 0
+	}
+
+	s.stopContainers(ctx, dockerClient, deployment)
+
 	containersRemoved := 0
 	for _, cont := range deployment.Containers {
 		if cont.ContainerID != "" {
 			s.logger.Info("Removing container", "container_id", cont.ContainerID, "app_name", deployment.AppName, "port", cont.Port)
-			if err := s.dockerClient.ContainerRemove(c.Request.Context(), cont.ContainerID, container.RemoveOptions{Force: true}); err != nil {
+			if err := dockerClient.ContainerRemove(ctx, cont.ContainerID, container.RemoveOptions{Force: true}); err != nil {
 				s.logger.Error("Failed to remove container", "container_id", cont.ContainerID, "error", err)
 				// Continue with other containers even if one fails
 			} else {
@@ -461,31 +885,116 @@ func (s *BaseEngine) deleteDeploymentHandler(c *gin.Context) {
 			}
 		}
 	}
+	return containersRemoved
+}
 
-	// Delete deployment from store
-	if err := s.store.DeleteNewDeployment(c.Request.Context(), id); err != nil {
-		s.logger.Error("Failed to delete deployment", "id", id, "error", err)
+// deleteDeploymentLocked deletes the deployment of an app and its containers once no deployment of the app
+// is running, reading the deployment again under the app lock to remove the containers it ended up with
+func (s *BaseEngine) deleteDeploymentLocked(ctx context.Context, appName string) (int, error) {
+	unlock := s.appLocks.Lock(appName)
+	defer unlock()
+
+	deployment, err := s.store.GetNewDeployment(ctx, appName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get deployment: %w", err)
+	}
+	containersRemoved := s.removeDeploymentContainers(ctx, deployment)
+	if err := s.store.DeleteNewDeployment(ctx, appName); err != nil {
+		return containersRemoved, fmt.Errorf("failed to delete deployment: %w", err)
+	}
+	s.notifyDeploymentsChanged(ctx, appName)
+	return containersRemoved, nil
+}
+
+// deleteDeploymentsByPrefixHandler deletes all deployments whose app name starts with the prefix query parameter
+func (s *BaseEngine) deleteDeploymentsByPrefixHandler(c *gin.Context) {
+	prefix := strings.TrimSpace(c.Query("prefix"))
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "A non-empty prefix is required",
+		})
+		return
+	}
+
+	deployments, err := s.store.ListNewDeployments(c.Request.Context())
+	if err != nil {
+		s.logger.Error("Failed to list deployments", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to delete deployment",
+			"error": "Failed to list deployments",
 		})
 		return
 	}
 
-	s.logger.Info("Deployment deleted successfully", "id", id, "app_name", deployment.AppName, "containers_removed", containersRemoved)
+	// Apps are deleted concurrently, so that a request waits for about one stop timeout rather than one per app
+	var (
+		mu                sync.Mutex
+		wg                sync.WaitGroup
+		deleted           = make([]string, 0)
+		failed            []string
+		containersRemoved int
+	)
+	for _, deployment := range deployments {
+		if !strings.HasPrefix(deployment.AppName, prefix) {
+			continue
+		}
+		wg.Add(1)
+		go func(appName string) {
+			defer wg.Done()
+			removed, err := s.deleteDeploymentLocked(c.Request.Context(), appName)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				// Deleted while waiting for a running deployment of the app
+			case err != nil:
+				s.logger.Error("Failed to delete deployment", "app_name", appName, "error", err)
+				failed = append(failed, appName)
+			default:
+				containersRemoved += removed
+				deleted = append(deleted, appName)
+			}
+		}(deployment.AppName)
+	}
+	wg.Wait()
+	sort.Strings(deleted)
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   fmt.Sprintf("Failed to delete deployment %s", strings.Join(failed, ", ")),
+			"deleted": deleted,
+			"count":   len(deleted),
+		})
+		return
+	}
+
+	s.logger.Info("Deleted deployments by prefix", "prefix", prefix, "count", len(deleted), "containers_removed", containersRemoved)
 	c.JSON(http.StatusOK, gin.H{
-		"message":            "Deployment deleted successfully",
-		"id":                 id,
+		"deleted":            deleted,
+		"count":              len(deleted),
 		"containers_removed": containersRemoved,
 	})
 }
 
-// getDeploymentWrapper wraps the store.GetDeployment function to match the interface
+// getDeploymentWrapper returns the deployment of the app named id. When there is none and legacy
+// deployments are enabled, the legacy deployment with the ID id is returned instead.
 func (s *BaseEngine) getDeploymentWrapper(ctx context.Context, id string) (interface{}, error) {
-	deployment, err := s.store.GetDeployment(ctx, id)
+	// A legacy record left over from the migration must not hide the current deployment of the app
+	deployment, err := s.store.GetNewDeployment(ctx, id)
+	if err == nil {
+		deployment.URLs = appURLs(&s.config.Ingress, deployment)
+		return deployment, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) || s.legacyDeploymentsDisabled() {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	// Not the app name of a deployment, look the id up as a legacy deployment ID
+	legacy, err := s.store.GetDeployment(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get deployment: %w", err)
 	}
-	return deployment, nil
+	return legacy, nil
 }
 
 // getDeploymentHandler handles deployment retrieval requests
@@ -495,38 +1004,137 @@ func (s *BaseEngine) getDeploymentHandler(c *gin.Context) {
 
 // getDeploymentStatusHandler handles deployment status requests
 func (s *BaseEngine) getDeploymentStatusHandler(c *gin.Context) {
-	s.handleGetByID(c, s.getDeploymentWrapper, "deployment")
+	s.handleGetByID(c, s.getDeploymentStatusWrapper, "deployment")
+}
+
+// legacyDeploymentsDisabled reports whether the legacy provision path is turned off
+func (s *BaseEngine) legacyDeploymentsDisabled() bool {
+	return s.config != nil && s.config.Engine.DisableLegacyDeployments
 }
 
-// listDeploymentsWrapper wraps the store.ListNewDeployments function
-func (s *BaseEngine) listDeploymentsWrapper(ctx context.Context) (interface{}, error) {
-	deployments, err := s.store.ListNewDeployments(ctx)
+// listDeploymentsWrapper wraps the store.ListAllDeployments function.
+// Only new deployments are listed when legacy deployments are disabled.
+func (s *BaseEngine) listDeploymentsWrapper(ctx context.Context, opts *types.ListOptions) (interface{}, error) {
+	listFunc := s.store.ListAllDeployments
+	if s.legacyDeploymentsDisabled() {
+		listFunc = s.store.ListNewDeployments
+	}
+	deployments, err := listFunc(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list deployments: %w", err)
 	}
-	return deployments, nil
+	return store.FilterAndSortDeployments(deployments, opts), nil
 }
 
-// listDeploymentsByAppNameWrapper wraps the store.ListNewDeploymentsByAppName function
-func (s *BaseEngine) listDeploymentsByAppNameWrapper(ctx context.Context, appName string) (interface{}, error) {
-	deployments, err := s.store.ListNewDeploymentsByAppName(ctx, appName)
+// listDeploymentsByAppNameWrapper wraps the store.ListAllDeploymentsByAppName function
+func (s *BaseEngine) listDeploymentsByAppNameWrapper(ctx context.Context, appName string, opts *types.ListOptions) (interface{}, error) {
+	listFunc := s.store.ListAllDeploymentsByAppName
+	if s.legacyDeploymentsDisabled() {
+		listFunc = s.store.ListNewDeploymentsByAppName
+	}
+	deployments, err := listFunc(ctx, appName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list deployments by app name: %w", err)
 	}
-	return deployments, nil
+	return store.FilterAndSortDeployments(deployments, opts), nil
 }
 
 // listDeploymentsHandler handles deployment listing requests
 func (s *BaseEngine) listDeploymentsHandler(c *gin.Context) {
-	s.handleList(c, s.listDeploymentsWrapper, s.listDeploymentsByAppNameWrapper, "app_name", "deployments")
+	opts, err := listOptionsFromQuery(c)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+	s.handleList(c,
+		func(ctx context.Context) (interface{}, error) { return s.listDeploymentsWrapper(ctx, opts) },
+		func(ctx context.Context, appName string) (interface{}, error) {
+			return s.listDeploymentsByAppNameWrapper(ctx, appName, opts)
+		},
+		"app_name", "deployments")
 }
 
-// validateBuildRequest validates the build request
+// listAppsHandler lists the apps collated from builds and deployments
+func (s *BaseEngine) listAppsHandler(c *gin.Context) {
+	apps, err := s.store.ListApps(c.Request.Context(), !s.legacyDeploymentsDisabled())
+	if err != nil {
+		s.logger.Error("Failed to list apps", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list apps",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"apps":  apps,
+		"count": len(apps),
+	})
+}
+
+// getAppHandler handles requests for a single app, identified by name
+func (s *BaseEngine) getAppHandler(c *gin.Context) {
+	name := c.Param("name")
+	app, err := s.store.GetApp(c.Request.Context(), name, !s.legacyDeploymentsDisabled())
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "app not found",
+		})
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to get app", "name", name, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get app",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, app)
+}
+
+// listOptionsFromQuery reads the sort, order, status, since and label query parameters
+func listOptionsFromQuery(c *gin.Context) (*types.ListOptions, error) {
+	labels, err := types.ParseLabels(c.QueryArray("label"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid list options: %w", err)
+	}
+	opts := &types.ListOptions{
+		SortBy: c.Query("sort"),
+		Order:  c.Query("order"),
+		Status: c.Query("status"),
+		Since:  c.Query("since"),
+		Labels: labels,
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid list options: %w", err)
+	}
+	return opts, nil
+}
+
+// validateBuildRequest validates the build request and normalizes its app name
 func (s *BaseEngine) validateBuildRequest(req *types.BuildRequest) error {
-	if req.AppName == "" || req.BundleContents == "" {
-		return fmt.Errorf("app name and bundle contents are required")
+	errs := ValidationErrors{}
+	errs.normalizeAppName(&req.AppName)
+	if req.CommitHash == "" {
+		errs.Add("commit_hash", "commit hash is required")
 	}
-	return nil
+	if req.BundleContents == "" && req.BundlePath == "" {
+		errs.Add("bundle_content", "bundle contents are required")
+	}
+	if req.Buildpack != "" {
+		if err := builder.ValidateBuildpack(req.Buildpack); err != nil {
+			errs.Add("buildpack", err.Error())
+		}
+	}
+	for _, name := range req.Secrets {
+		if err := builder.ValidateBuildSecret(s.config, name); err != nil {
+			errs.Add("secrets", err.Error())
+		}
+	}
+	if req.CallbackURL != "" && !validCallbackURL(req.CallbackURL) {
+		errs.Add("callback_url", fmt.Sprintf("callback URL %q must be an absolute http or https URL", req.CallbackURL))
+	}
+	return errs.Err()
 }
 
 // createBuildRecord creates a build record in the store
@@ -539,8 +1147,9 @@ func (s *BaseEngine) createBuildRecord(ctx context.Context, req *types.BuildRequ
 	return nil
 }
 
-// extractAndMatchBundle extracts the bundle and matches it with a buildpack
-func (s *BaseEngine) extractAndMatchBundle(ctx context.Context, req *types.BuildRequest) (*builder.Bundle, builder.Buildpack, error) {
+// extractAndMatchBundle extracts the bundle and matches it with a buildpack.
+// The bundle is only kept when a buildpack matched; the caller then has to clean it up.
+func (s *BaseEngine) extractAndMatchBundle(ctx context.Context, req *types.BuildRequest) (_ *builder.Bundle, _ builder.Buildpack, err error) {
 	// Extract bundle
 	bundle, err := s.builder.ExtractBundle(ctx, req)
 	if err != nil {
@@ -551,6 +1160,14 @@ func (s *BaseEngine) extractAndMatchBundle(ctx context.Context, req *types.Build
 		}
 		return nil, nil, fmt.Errorf("failed to extract bundle: %w", err)
 	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if cleanupErr := bundle.Cleanup(); cleanupErr != nil {
+			s.logger.Warn("Failed to cleanup bundle", "app_name", req.AppName, "error", cleanupErr)
+		}
+	}()
 
 	// Match buildpack
 	buildpack, err := s.builder.MatchBuildpack(ctx, req)
@@ -588,12 +1205,54 @@ func (s *BaseEngine) buildProject(
 		s.logger.Error("Failed to update build status to building", "error", updateErr)
 	}
 
-	// Build the project
+	// The extracted bundle is not needed once the build is over, whatever its outcome
+	defer func() {
+		if err := bundle.Cleanup(); err != nil {
+			s.logger.Warn("Failed to cleanup bundle", "app_name", req.AppName, "error", err)
+		}
+	}()
+
+	// Build the project, keeping its output for later retrieval
+	output := newBuildLog(s.buildLogMaxSize())
+	bundle.SetBuildOutput(output)
+	start := time.Now()
+	// A cancelled or timed out build must still be recorded
+	recordCtx := context.WithoutCancel(ctx)
+
+	// Reuse the image of an earlier build of identical contents instead of building again
+	contentHash := s.bundleContentHash(req, bundle, buildpack)
+	if contentHash != "" {
+		if image := s.cachedImage(ctx, req, contentHash); image != nil {
+			fmt.Fprintf(output, "Reusing image %s built from identical contents (content hash %s)\n", image.ImageTag, contentHash)
+			s.saveBuildLog(recordCtx, req.CommitHash, output)
+			if err := s.store.UpdateBuildWithImage(ctx, req.CommitHash, types.BuildStatusBuilt, image.ImageTag,
+				image.ImageID, image.Size, image.ExposedPorts); err != nil {
+				s.logger.Error("Failed to update build status to built", "error", err)
+			}
+			s.logger.Info("Build cache hit",
+				"app_name", req.AppName,
+				"commit_hash", req.CommitHash,
+				"content_hash", contentHash,
+				"image_tag", image.ImageTag,
+				"status", types.BuildStatusBuilt,
+			)
+			return image, nil
+		}
+	}
+
 	deployment, err := buildpack.Build(ctx, bundle)
+	s.saveBuildLog(recordCtx, req.CommitHash, output)
 	if err != nil {
-		s.logger.Error("Failed to build project", "app_name", req.AppName, "error", err)
-		// Update build status to failed
-		if updateErr := s.store.UpdateBuildStatus(ctx, req.CommitHash, types.BuildStatusFailed); updateErr != nil {
+		s.logger.Error("Failed to build project",
+			"app_name", req.AppName,
+			"commit_hash", req.CommitHash,
+			"buildpack", buildpack.Name(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"status", types.BuildStatusFailed,
+			"error", err,
+		)
+		// Keep the failure, including the captured build output, on the build record
+		if updateErr := s.store.UpdateBuildFailure(recordCtx, req.CommitHash, err.Error()); updateErr != nil {
 			s.logger.Error("Failed to update build status to failed", "error", updateErr)
 		}
 		return nil, fmt.Errorf("failed to build project: %w", err)
@@ -601,27 +1260,79 @@ func (s *BaseEngine) buildProject(
 
 	// Update build with image information and status to built
 	if err := s.store.UpdateBuildWithImage(ctx, req.CommitHash, types.BuildStatusBuilt, deployment.ImageTag,
-		deployment.ImageID, deployment.Size); err != nil {
+		deployment.ImageID, deployment.Size, deployment.ExposedPorts); err != nil {
 		s.logger.Error("Failed to update build status to built", "error", err)
 	}
+	if contentHash != "" {
+		if err := s.store.SaveCachedImage(recordCtx, contentHash, deployment); err != nil {
+			s.logger.Warn("Failed to cache build image", "app_name", req.AppName, "error", err)
+		}
+	}
+
+	s.logger.Info("Build completed successfully",
+		"app_name", req.AppName,
+		"commit_hash", req.CommitHash,
+		"buildpack", buildpack.Name(),
+		"image_size_bytes", deployment.Size,
+		"duration_ms", time.Since(start).Milliseconds(),
+		"status", types.BuildStatusBuilt,
+		"temp_dir", bundle.GetTempDir(),
+	)
 
-	s.logger.Info("Build completed successfully", "app_name", req.AppName, "temp_dir", bundle.GetTempDir())
+	return deployment, nil
+}
 
-	// Clean up the bundle
-	if err := bundle.Cleanup(); err != nil {
-		s.logger.Warn("Failed to cleanup bundle", "app_name", req.AppName, "error", err)
+// tempDir returns the directory bundles are uploaded and extracted to
+func (s *BaseEngine) tempDir() string {
+	if s.config.Engine.TempDir != "" {
+		return s.config.Engine.TempDir
 	}
+	return os.TempDir()
+}
 
-	return deployment, nil
+// builderReady reports why the builder cannot accept builds, or nil when it is ready
+func (s *BaseEngine) builderReady() error {
+	if s.builder == nil {
+		return errors.New("builder is not initialized")
+	}
+	if s.builder.GetDockerClient() == nil {
+		return errors.New("builder has no Docker client")
+	}
+	return nil
 }
 
 // buildHandler handles build requests
 func (s *BaseEngine) buildHandler(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
-	defer cancel()
+	// The request is bounded by the long request timeout of the route
+	ctx := c.Request.Context()
+
+	// Check the builder and the free disk space before the bundle is streamed to disk
+	if err := s.builderReady(); err != nil {
+		s.logger.Error("Build subsystem unavailable", "reason", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "build subsystem unavailable",
+		})
+		return
+	}
 
-	var req types.BuildRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := s.checkDiskSpace(ctx); err != nil {
+		s.logger.Error("Not enough disk space to build", "error", err)
+		c.JSON(http.StatusInsufficientStorage, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	req, cleanup, err := bindBuildRequest(c, s.tempDir(), s.maxBundleSize())
+	defer cleanup()
+	if isBundleTooLarge(err) {
+		s.logger.Error("Build request rejected", "error", err)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
 		s.logger.Error("Invalid build request body", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid request body",
@@ -630,26 +1341,48 @@ func (s *BaseEngine) buildHandler(c *gin.Context) {
 	}
 
 	// Validate request
-	if err := s.validateBuildRequest(&req); err != nil {
+	if err := s.validateBuildRequest(req); err != nil {
 		s.logger.Error("Invalid build request", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
+		respondBadRequest(c, err)
 		return
 	}
 
 	s.logger.Info("Processing build request", "app_name", req.AppName, "commit_hash", req.CommitHash)
 
 	// Create build record
-	if err := s.createBuildRecord(ctx, &req); err != nil {
+	if err := s.createBuildRecord(ctx, req); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
+	// Report the recorded outcome to the callback URL however the build ends
+	if req.CallbackURL != "" {
+		defer s.notifyBuildCallback(context.WithoutCancel(ctx), req, buildLogsURL(c.Request, req.CommitHash))
+	}
+
+	// Every recorded build is counted by its outcome
+	finishBuild, status := s.metrics.buildStarted(), types.BuildStatusFailed
+	s.recordBuild(req, types.BuildStatusBuilding)
+	defer func() {
+		finishBuild(status)
+		s.recordBuild(req, status)
+	}()
+
 	// Extract bundle and match buildpack
-	bundle, buildpack, err := s.extractAndMatchBundle(ctx, &req)
+	bundle, buildpack, err := s.extractAndMatchBundle(ctx, req)
+	if errors.Is(err, builder.ErrBundleChecksumMismatch) {
+		// The bundle was corrupted on its way to the engine
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, builder.ErrBuildpackMismatch) {
+		respondBadRequest(c, err)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -658,38 +1391,63 @@ func (s *BaseEngine) buildHandler(c *gin.Context) {
 	}
 
 	// Build the project
-	deployment, err := s.buildProject(ctx, &req, bundle, buildpack)
+	deployment, err := s.buildProject(ctx, req, bundle, buildpack)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	status = types.BuildStatusBuilt
 
 	c.JSON(http.StatusCreated, deployment)
 }
 
 // listBuildsWrapper wraps the store.ListBuilds function
-func (s *BaseEngine) listBuildsWrapper(ctx context.Context) (interface{}, error) {
+func (s *BaseEngine) listBuildsWrapper(ctx context.Context, opts *types.ListOptions) (interface{}, error) {
 	builds, err := s.store.ListBuilds(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list builds: %w", err)
 	}
-	return builds, nil
+	return store.FilterAndSortBuilds(builds, opts), nil
 }
 
 // listBuildsByCommitHashWrapper wraps the store.ListBuildsByCommitHash function
-func (s *BaseEngine) listBuildsByCommitHashWrapper(ctx context.Context, commitHash string) (interface{}, error) {
+func (s *BaseEngine) listBuildsByCommitHashWrapper(ctx context.Context, commitHash string, opts *types.ListOptions) (interface{}, error) {
 	builds, err := s.store.ListBuildsByCommitHash(ctx, commitHash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list builds by commit hash: %w", err)
 	}
-	return builds, nil
+	return store.FilterAndSortBuilds(builds, opts), nil
 }
 
 // listBuildsHandler handles build listing requests
 func (s *BaseEngine) listBuildsHandler(c *gin.Context) {
-	s.handleList(c, s.listBuildsWrapper, s.listBuildsByCommitHashWrapper, "commit_hash", "builds")
+	opts, err := listOptionsFromQuery(c)
+	if err != nil {
+		respondBadRequest(c, err)
+		return
+	}
+	s.handleList(c,
+		func(ctx context.Context) (interface{}, error) { return s.listBuildsWrapper(ctx, opts) },
+		func(ctx context.Context, commitHash string) (interface{}, error) {
+			return s.listBuildsByCommitHashWrapper(ctx, commitHash, opts)
+		},
+		"commit_hash", "builds")
+}
+
+// getBuildWrapper wraps the store.GetBuild function
+func (s *BaseEngine) getBuildWrapper(ctx context.Context, commitHash string) (interface{}, error) {
+	build, err := s.store.GetBuild(ctx, commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get build: %w", err)
+	}
+	return build, nil
+}
+
+// getBuildHandler handles requests for a single build, identified by commit hash or a prefix of it
+func (s *BaseEngine) getBuildHandler(c *gin.Context) {
+	s.handleGetByID(c, s.getBuildWrapper, "build")
 }
 
 // deleteBuildsHandler handles build deletion requests
@@ -703,6 +1461,10 @@ func (s *BaseEngine) deleteBuildsHandler(c *gin.Context) {
 	}
 
 	deletedKeys, count, err := s.store.DeleteBuilds(c.Request.Context(), id)
+	if errors.Is(err, store.ErrAmbiguous) {
+		respondBadRequest(c, err)
+		return
+	}
 	if err != nil {
 		s.logger.Error("Failed to delete builds", "id", id, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -730,14 +1492,14 @@ func (s *BaseEngine) GetDockerClient() *client.Client {
 // loggerMiddleware adds logging middleware to Gin
 func loggerMiddleware(log *logger.Logger) gin.HandlerFunc {
 	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		log.Info("HTTP Request",
-			"method", param.Method,
-			"path", param.Path,
-			"status", param.StatusCode,
-			"latency", param.Latency,
-			"client_ip", param.ClientIP,
-			"user_agent", param.Request.UserAgent(),
-		)
+		log.WithRequest(&logger.RequestFields{
+			Method:    param.Method,
+			Path:      param.Path,
+			Status:    param.StatusCode,
+			Latency:   param.Latency,
+			ClientIP:  param.ClientIP,
+			UserAgent: param.Request.UserAgent(),
+		}).Info("HTTP Request")
 		return ""
 	})
 }
@@ -753,6 +1515,10 @@ func (s *BaseEngine) handleGetByID(c *gin.Context, getFunc func(context.Context,
 	}
 
 	item, err := getFunc(c.Request.Context(), id)
+	if errors.Is(err, store.ErrAmbiguous) {
+		respondBadRequest(c, err)
+		return
+	}
 	if err != nil {
 		s.logger.Error(fmt.Sprintf("Failed to get %s", idType), "id", id, "error", err)
 		c.JSON(http.StatusNotFound, gin.H{
@@ -0,0 +1,1375 @@
+package engine
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/matiasinsaurralde/nina/internal/pkg/builder"
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+	"github.com/matiasinsaurralde/nina/pkg/store"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// newTestEngine creates an engine with routes but without store, builder or Docker client
+func newTestEngine(t *testing.T) *BaseEngine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	s := &BaseEngine{
+		// Containers are ready once started, instead of after the startup grace
+		config: &config.Config{Engine: config.EngineConfig{StartupGrace: -1}},
+		logger: logger.New(logger.LevelDebug, "text"),
+		router: gin.New(),
+	}
+	s.setupRoutes()
+	return s
+}
+
+// newTestEngineWithBackends creates an engine backed by Miniredis and a fake Docker API
+func newTestEngineWithBackends(t *testing.T) (*BaseEngine, *fakeDocker) {
+	t.Helper()
+	s := newTestEngine(t)
+	s.store = newTestStore(t, s.logger)
+	dockerClient, fake := newFakeDockerClient(t)
+	s.dockerClient = dockerClient
+	return s, fake
+}
+
+// newTestEngineWithBuilder creates an engine backed by Miniredis and a fake Docker API, with a builder
+// using the fake Docker API
+func newTestEngineWithBuilder(t *testing.T) *BaseEngine {
+	t.Helper()
+	s, _ := newTestEngineWithBackends(t)
+	s.builder = &builder.BaseBuilder{}
+	if err := initBuilder(context.Background(), s.builder, s.dockerClient, s.config, s.logger); err != nil {
+		t.Fatalf("Failed to initialize builder: %v", err)
+	}
+	return s
+}
+
+// createBuiltBuild stores a build that is ready to be deployed
+func createBuiltBuild(t *testing.T, s *BaseEngine, appName, commitHash string) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := s.store.CreateBuild(ctx, &types.BuildRequest{AppName: appName, CommitHash: commitHash}); err != nil {
+		t.Fatalf("Failed to create build: %v", err)
+	}
+	imageTag := fmt.Sprintf("nina-%s-%s", appName, commitHash)
+	if err := s.store.UpdateBuildWithImage(ctx, commitHash, types.BuildStatusBuilt, imageTag, "sha256:test", 1024, nil); err != nil {
+		t.Fatalf("Failed to update build: %v", err)
+	}
+}
+
+// waitForDeploymentStatus polls the store until the deployment reaches the given status
+func waitForDeploymentStatus(t *testing.T, s *BaseEngine, appName string, status types.DeploymentStatus) *types.Deployment {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		deployment, err := s.store.GetNewDeployment(context.Background(), appName)
+		if err == nil && deployment.Status == status {
+			return deployment
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Deployment %s did not reach status %s", appName, status)
+	return nil
+}
+
+func TestValidateDeploymentRequest(t *testing.T) {
+	s := newTestEngine(t)
+
+	if err := s.validateDeploymentRequest(&types.DeploymentRequest{AppName: "app", CommitHash: "abc123", Replicas: 1}); err != nil {
+		t.Errorf("Expected valid request, got %v", err)
+	}
+
+	err := s.validateDeploymentRequest(&types.DeploymentRequest{Replicas: -1})
+	validationErrs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, got %T", err)
+	}
+	for _, field := range []string{"app_name", "commit_hash", "replicas"} {
+		if _, exists := validationErrs[field]; !exists {
+			t.Errorf("Expected validation error for %s, got %v", field, validationErrs)
+		}
+	}
+}
+
+func TestValidateDeploymentRequest_ReplicaBounds(t *testing.T) {
+	s := newTestEngine(t)
+	s.config.Engine.MaxReplicas = 5
+
+	tests := []struct {
+		name     string
+		replicas int
+		wantErr  bool
+	}{
+		{"single replica", 1, false},
+		{"at the limit", 5, false},
+		{"above the limit", 6, true},
+		{"negative", -1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.validateDeploymentRequest(&types.DeploymentRequest{AppName: "app", CommitHash: "abc123", Replicas: tt.replicas})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateDeploymentRequest(replicas=%d) error = %v, wantErr %v", tt.replicas, err, tt.wantErr)
+			}
+		})
+	}
+
+	// Without a configured limit the default one applies
+	s.config.Engine.MaxReplicas = 0
+	err := s.validateDeploymentRequest(&types.DeploymentRequest{AppName: "app", CommitHash: "abc123", Replicas: defaultMaxReplicas + 1})
+	if err == nil {
+		t.Errorf("Expected error above the default limit of %d replicas, got nil", defaultMaxReplicas)
+	}
+}
+
+func TestDeployHandler_RejectsTooManyReplicas(t *testing.T) {
+	s := newTestEngine(t)
+	s.config.Engine.MaxReplicas = 2
+
+	body := `{"app_name":"app","commit_hash":"abc123","replicas":3}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "replicas must be between 1 and 2") {
+		t.Errorf("Expected replica bounds message, got %s", w.Body.String())
+	}
+}
+
+func TestValidateBuildRequest(t *testing.T) {
+	s := newTestEngine(t)
+
+	err := s.validateBuildRequest(&types.BuildRequest{})
+	validationErrs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, got %T", err)
+	}
+	for _, field := range []string{"app_name", "commit_hash", "bundle_content"} {
+		if _, exists := validationErrs[field]; !exists {
+			t.Errorf("Expected validation error for %s, got %v", field, validationErrs)
+		}
+	}
+
+	err = s.validateBuildRequest(&types.BuildRequest{AppName: "app", CommitHash: "abc123", BundleContents: "data"})
+	if err != nil {
+		t.Errorf("Expected valid request, got %v", err)
+	}
+
+	// Build secrets must be configured in the Engine's secret store
+	s.config.Build.Secrets = map[string]string{"netrc": "/etc/nina/netrc"}
+	err = s.validateBuildRequest(&types.BuildRequest{AppName: "app", CommitHash: "abc123", BundleContents: "data", Secrets: []string{"netrc"}})
+	if err != nil {
+		t.Errorf("Expected a configured secret to be accepted, got %v", err)
+	}
+	err = s.validateBuildRequest(&types.BuildRequest{AppName: "app", CommitHash: "abc123", BundleContents: "data", Secrets: []string{"token"}})
+	if validationErrs, ok := err.(ValidationErrors); !ok || !strings.Contains(validationErrs["secrets"], "unknown build secret") {
+		t.Errorf("Expected an unknown secret to be rejected, got %v", err)
+	}
+}
+
+func TestValidateRequests_NormalizeAppName(t *testing.T) {
+	s := newTestEngine(t)
+
+	deployReq := &types.DeploymentRequest{AppName: "My_App.v2", CommitHash: "abc123", Replicas: 1}
+	if err := s.validateDeploymentRequest(deployReq); err != nil {
+		t.Fatalf("Expected valid request, got %v", err)
+	}
+	if deployReq.AppName != "my-app-v2" {
+		t.Errorf("Expected normalized app name 'my-app-v2', got '%s'", deployReq.AppName)
+	}
+
+	buildReq := &types.BuildRequest{AppName: "  Web Frontend ", CommitHash: "abc123", BundleContents: "data"}
+	if err := s.validateBuildRequest(buildReq); err != nil {
+		t.Fatalf("Expected valid request, got %v", err)
+	}
+	if buildReq.AppName != "web-frontend" {
+		t.Errorf("Expected normalized app name 'web-frontend', got '%s'", buildReq.AppName)
+	}
+
+	err := s.validateDeploymentRequest(&types.DeploymentRequest{AppName: "!!!", CommitHash: "abc123", Replicas: 1})
+	validationErrs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, got %T", err)
+	}
+	if !strings.Contains(validationErrs["app_name"], "no valid characters") {
+		t.Errorf("Expected app_name error about invalid characters, got %v", validationErrs)
+	}
+}
+
+func TestValidateDeploymentRequest_Domains(t *testing.T) {
+	s := newTestEngine(t)
+
+	req := &types.DeploymentRequest{AppName: "app", CommitHash: "abc123", Replicas: 1,
+		Domains: []string{"WWW.Example.com.", "*.preview.example.com", "example.com"}}
+	if err := s.validateDeploymentRequest(req); err != nil {
+		t.Fatalf("Expected valid request, got %v", err)
+	}
+	if strings.Join(req.Domains, ",") != "www.example.com,*.preview.example.com,example.com" {
+		t.Errorf("Expected normalized domains, got %v", req.Domains)
+	}
+
+	for _, domain := range []string{"", "exa mple.com", "-example.com", "example..com", "*", "a.*.example.com", "example_app.com"} {
+		err := s.validateDeploymentRequest(&types.DeploymentRequest{AppName: "app", CommitHash: "abc123", Replicas: 1,
+			Domains: []string{domain}})
+		validationErrs, ok := err.(ValidationErrors)
+		if !ok || validationErrs["domains"] == "" {
+			t.Errorf("Expected a domains error for %q, got %v", domain, err)
+		}
+	}
+}
+
+func TestGenerateUniqueContainerName_SanitizesAppName(t *testing.T) {
+	s := newTestEngine(t)
+
+	name := s.generateUniqueContainerName("My App/../x", 0)
+	if !strings.HasPrefix(name, "nina-my-app-x-0-") {
+		t.Errorf("Expected sanitized container name, got '%s'", name)
+	}
+}
+
+func TestBuildHandler_ReportsAllInvalidFields(t *testing.T) {
+	s := newTestEngineWithBuilder(t)
+
+	req := httptest.NewRequest("POST", "/api/v1/build", strings.NewReader(`{"commit_hash":"abc123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	var resp struct {
+		Error  string            `json:"error"`
+		Fields map[string]string `json:"fields"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Fields) != 2 || resp.Fields["app_name"] == "" || resp.Fields["bundle_content"] == "" {
+		t.Errorf("Expected app_name and bundle_content errors, got %v", resp.Fields)
+	}
+	if !strings.Contains(resp.Error, "app_name") || !strings.Contains(resp.Error, "bundle_content") {
+		t.Errorf("Expected error message to mention both fields, got %q", resp.Error)
+	}
+}
+
+func TestDeployHandler_CreatesRequestedReplicas(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+
+	body, err := json.Marshal(&types.DeploymentRequest{AppName: "app", CommitHash: "abc123", Replicas: 3})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	deployment := waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady)
+	if len(deployment.Containers) != 3 {
+		t.Errorf("Expected 3 containers in deployment, got %d", len(deployment.Containers))
+	}
+	if fake.createdCount() != 3 {
+		t.Errorf("Expected 3 containers to be created, got %d", fake.createdCount())
+	}
+}
+
+func TestDeployHandler_NotifiesDeploymentsChanged(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+
+	changes, unsubscribe, err := s.store.SubscribeDeploymentsChanged(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	defer unsubscribe() //nolint:errcheck
+
+	postDeploy(t, s, "app", "abc123")
+	select {
+	case appName := <-changes:
+		if appName != "app" {
+			t.Errorf("Expected a change of app, got %q", appName)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Deployment change was not published")
+	}
+	if deployment := waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady); len(deployment.Containers) != 1 {
+		t.Errorf("Expected the containers to be stored before the change is published, got %+v", deployment)
+	}
+}
+
+func TestDeployHandler_PartiallyReady(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	fake.failStart = map[string]bool{"container2": true}
+	createBuiltBuild(t, s, "app", "abc123")
+
+	body := `{"app_name":"app","commit_hash":"abc123","replicas":3}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	deployment := waitForDeploymentStatus(t, s, "app", types.DeploymentStatusPartiallyReady)
+	if len(deployment.Containers) != 2 {
+		t.Errorf("Expected 2 healthy containers, got %d", len(deployment.Containers))
+	}
+	if deployment.Replicas != 3 {
+		t.Errorf("Expected 3 requested replicas, got %d", deployment.Replicas)
+	}
+	for _, cont := range deployment.Containers {
+		if cont.ContainerID == "container2" {
+			t.Errorf("Expected failed replica to be left out of the deployment")
+		}
+	}
+	if live := fake.liveContainers(); strings.Join(live, ",") != "container1,container3" {
+		t.Errorf("Expected the failed container to be removed, got live containers %v", live)
+	}
+	if len(deployment.FailedReplicas) != 1 || deployment.FailedReplicas[0].Replica != 2 ||
+		!strings.Contains(deployment.FailedReplicas[0].Error, "cannot start container2") {
+		t.Errorf("Expected replica 2 to be reported as failed, got %+v", deployment.FailedReplicas)
+	}
+}
+
+func TestDeployHandler_RollbackPartialDeployment(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	s.config.Engine.RollbackPartialDeployments = true
+	fake.failStart = map[string]bool{"container2": true}
+	createBuiltBuild(t, s, "app", "abc123")
+
+	body := `{"app_name":"app","commit_hash":"abc123","replicas":3}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	deployment := waitForDeploymentStatus(t, s, "app", types.DeploymentStatusFailed)
+	if len(deployment.Containers) != 0 {
+		t.Errorf("Expected no containers, got %d", len(deployment.Containers))
+	}
+	// The third replica is not attempted after the second one fails
+	if fake.createdCount() != 2 {
+		t.Errorf("Expected 2 containers to be created, got %d", fake.createdCount())
+	}
+	if len(deployment.FailedReplicas) != 1 || deployment.FailedReplicas[0].Replica != 2 {
+		t.Errorf("Expected replica 2 to be reported as failed, got %+v", deployment.FailedReplicas)
+	}
+	if live := fake.liveContainers(); len(live) != 0 {
+		t.Errorf("Expected no orphaned containers, got %v", live)
+	}
+}
+
+func TestDeployHandler_AllReplicasFail(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	fake.failStart = map[string]bool{"container1": true, "container2": true}
+	createBuiltBuild(t, s, "app", "abc123")
+
+	body := `{"app_name":"app","commit_hash":"abc123","replicas":2}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	deployment := waitForDeploymentStatus(t, s, "app", types.DeploymentStatusFailed)
+	if len(deployment.Containers) != 0 {
+		t.Errorf("Expected no containers, got %d", len(deployment.Containers))
+	}
+	if live := fake.liveContainers(); len(live) != 0 {
+		t.Errorf("Expected the failed containers to be removed, got %v", live)
+	}
+	if len(deployment.FailedReplicas) != 2 {
+		t.Errorf("Expected both replicas to be reported as failed, got %+v", deployment.FailedReplicas)
+	}
+}
+
+func TestDeploymentStatusFor(t *testing.T) {
+	tests := []struct {
+		healthy, replicas int
+		expected          types.DeploymentStatus
+	}{
+		{0, 3, types.DeploymentStatusFailed},
+		{1, 3, types.DeploymentStatusPartiallyReady},
+		{3, 3, types.DeploymentStatusReady},
+	}
+	for _, tt := range tests {
+		if got := deploymentStatusFor(tt.healthy, tt.replicas); got != tt.expected {
+			t.Errorf("deploymentStatusFor(%d, %d) = %s, want %s", tt.healthy, tt.replicas, got, tt.expected)
+		}
+	}
+}
+
+func TestDeleteDeploymentHandler_NotFound(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+
+	req := httptest.NewRequest("DELETE", "/api/v1/deployments/missing-app", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusNotFound, w.Code, w.Body.String())
+	}
+	var resp map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp["error"] != "Deployment not found" || resp["id"] != "missing-app" {
+		t.Errorf("Unexpected response body: %v", resp)
+	}
+}
+
+func TestDeleteHandlers_IgnoreMissing(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+
+	serve := func(path string) (int, map[string]interface{}) {
+		req := httptest.NewRequest("DELETE", path, http.NoBody)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, resp
+	}
+
+	// Absent deployments are reported as missing instead of failing
+	code, resp := serve("/api/v1/deployments/missing-app?ignore_missing=true")
+	if code != http.StatusOK || resp["missing"] != true {
+		t.Errorf("Expected 200 with missing=true, got %d %v", code, resp)
+	}
+	if code, _ := serve("/api/v1/deployments/missing-app?ignore_missing=false"); code != http.StatusNotFound {
+		t.Errorf("Expected status code %d without ignore_missing, got %d", http.StatusNotFound, code)
+	}
+
+	// Build deletion is always idempotent and reports how many builds matched
+	code, resp = serve("/api/v1/builds/missing-app")
+	if code != http.StatusOK || resp["count"] != float64(0) {
+		t.Errorf("Expected 200 with count 0 for missing builds, got %d %v", code, resp)
+	}
+}
+
+func TestDeleteDeploymentHandler_StoreFailure(t *testing.T) {
+	s := newTestEngine(t)
+	mockRedis := miniredis.RunT(t)
+	st, err := store.NewStore(&config.Config{
+		Redis: config.RedisConfig{Host: mockRedis.Host(), Port: mockRedis.Server().Addr().Port},
+	}, s.logger)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	s.store = st
+	mockRedis.SetError("connection reset")
+
+	req := httptest.NewRequest("DELETE", "/api/v1/deployments/app", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "Failed to delete deployment") {
+		t.Errorf("Expected failure message, got %s", w.Body.String())
+	}
+}
+
+func TestDeployHandler_DefaultReplicas(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(`{"app_name":"app","commit_hash":"abc123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	deployment := waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady)
+	if len(deployment.Containers) != types.DefaultReplicas || fake.createdCount() != types.DefaultReplicas {
+		t.Errorf("Expected %d container, got %d in deployment and %d created",
+			types.DefaultReplicas, len(deployment.Containers), fake.createdCount())
+	}
+}
+
+func TestDeployHandler_UsesExposedPort(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	ctx := context.Background()
+	if _, err := s.store.CreateBuild(ctx, &types.BuildRequest{AppName: "app", CommitHash: "abc123"}); err != nil {
+		t.Fatalf("Failed to create build: %v", err)
+	}
+	if err := s.store.UpdateBuildWithImage(ctx, "abc123", types.BuildStatusBuilt, "nina-app-abc123", "sha256:test", 1024,
+		[]int{9090}); err != nil {
+		t.Fatalf("Failed to update build: %v", err)
+	}
+
+	body, err := json.Marshal(&types.DeploymentRequest{AppName: "app", CommitHash: "abc123", Replicas: 2})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady)
+	for _, port := range fake.containerPorts() {
+		if port != "9090/tcp" {
+			t.Errorf("Expected containers to expose 9090/tcp, got %s", port)
+		}
+	}
+}
+
+func TestContainerPortFor(t *testing.T) {
+	tests := []struct {
+		name         string
+		requestPort  int
+		exposedPorts []int
+		expected     int
+	}{
+		{"default", 0, nil, defaultContainerPort},
+		{"exposed by image", 0, []int{3000, 9090}, 3000},
+		{"request wins", 5000, []int{3000}, 5000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := containerPortFor(&types.DeploymentRequest{Port: tt.requestPort}, tt.exposedPorts)
+			if port != tt.expected {
+				t.Errorf("Expected port %d, got %d", tt.expected, port)
+			}
+		})
+	}
+}
+
+// failingBuilder is a builder whose initialization always fails
+type failingBuilder struct {
+	*builder.BaseBuilder
+}
+
+func (f *failingBuilder) Init(_ context.Context, _ *config.Config, _ *logger.Logger) error {
+	return errors.New("buildpack config rejected")
+}
+
+func TestInitBuilder_PropagatesInitFailure(t *testing.T) {
+	dockerClient, _ := newFakeDockerClient(t)
+	b := &failingBuilder{BaseBuilder: &builder.BaseBuilder{}}
+
+	err := initBuilder(context.Background(), b, dockerClient, &config.Config{}, logger.New(logger.LevelDebug, "text"))
+	if err == nil {
+		t.Fatal("Expected builder init failure to be returned, got nil")
+	}
+	if !strings.Contains(err.Error(), "buildpack config rejected") {
+		t.Errorf("Expected error to wrap the init failure, got %v", err)
+	}
+	if b.GetDockerClient() != dockerClient {
+		t.Error("Expected Docker client to be set on the builder before init")
+	}
+}
+
+func TestInitBuilder(t *testing.T) {
+	dockerClient, _ := newFakeDockerClient(t)
+	b := &builder.BaseBuilder{}
+
+	if err := initBuilder(context.Background(), b, dockerClient, &config.Config{}, logger.New(logger.LevelDebug, "text")); err != nil {
+		t.Fatalf("Expected builder to initialize, got %v", err)
+	}
+}
+
+func TestBuildHandler_BuilderUnavailable(t *testing.T) {
+	s := newTestEngine(t)
+
+	body := `{"app_name":"app","commit_hash":"abc123","bundle_content":"Zm9v"}`
+	req := httptest.NewRequest("POST", "/api/v1/build", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status code %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "build subsystem unavailable") {
+		t.Errorf("Expected build subsystem unavailable error, got %s", w.Body.String())
+	}
+}
+
+// gzippedTar returns a base64 encoded gzipped tarball holding the given files
+func gzippedTar(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(content))}); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write tar entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Failed to close gzip writer: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestBuildHandler_InvalidBuildpack(t *testing.T) {
+	s := newTestEngineWithBuilder(t)
+
+	// An unknown buildpack is rejected with the other invalid fields
+	body, _ := json.Marshal(map[string]string{
+		"app_name":       "app",
+		"commit_hash":    "abc123",
+		"buildpack":      "rust",
+		"bundle_content": gzippedTar(t, map[string]string{"README.md": "hello"}),
+	})
+	req := httptest.NewRequest("POST", "/api/v1/build", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	var resp struct {
+		Fields map[string]string `json:"fields"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !strings.Contains(resp.Fields["buildpack"], `buildpack "rust" does not exist`) {
+		t.Errorf("Expected a buildpack field error, got %v", resp.Fields)
+	}
+
+	// A buildpack that does not match the project is rejected too
+	body, _ = json.Marshal(map[string]string{
+		"app_name":       "app",
+		"commit_hash":    "abc456",
+		"buildpack":      "golang",
+		"bundle_content": gzippedTar(t, map[string]string{"README.md": "hello"}),
+	})
+	req = httptest.NewRequest("POST", "/api/v1/build", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `buildpack \"golang\" does not match this project`) {
+		t.Errorf("Expected a buildpack mismatch error, got %s", w.Body.String())
+	}
+}
+
+func TestBuildHandler_FailedBuildsLeaveNoTempDirs(t *testing.T) {
+	testApp, err := os.ReadFile(filepath.Join("..", "..", "testdata", "nina-test-app.tar.gz"))
+	if err != nil {
+		t.Fatalf("Failed to read test bundle: %v", err)
+	}
+	var corrupt bytes.Buffer
+	gz := gzip.NewWriter(&corrupt)
+	_, _ = gz.Write([]byte("not a tarball"))
+	_ = gz.Close()
+
+	tests := []struct {
+		name   string
+		bundle string
+	}{
+		{name: "extraction fails", bundle: base64.StdEncoding.EncodeToString(corrupt.Bytes())},
+		{name: "no buildpack matches", bundle: gzippedTar(t, map[string]string{"README.md": "hello"})},
+		// The fake Docker API does not implement image builds
+		{name: "image build fails", bundle: base64.StdEncoding.EncodeToString(testApp)},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			t.Setenv("TMPDIR", tempDir)
+
+			s, _ := newTestEngineWithBackends(t)
+			s.builder = &builder.BaseBuilder{}
+			if err := initBuilder(context.Background(), s.builder, s.dockerClient, s.config, s.logger); err != nil {
+				t.Fatalf("Failed to initialize builder: %v", err)
+			}
+
+			body, _ := json.Marshal(map[string]string{
+				"app_name":       "app",
+				"commit_hash":    fmt.Sprintf("abc12%d", i),
+				"bundle_content": tt.bundle,
+			})
+			req := httptest.NewRequest("POST", "/api/v1/build", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("Expected status code %d, got %d: %s", http.StatusInternalServerError, w.Code, w.Body.String())
+			}
+
+			entries, err := os.ReadDir(tempDir)
+			if err != nil {
+				t.Fatalf("Failed to read temp directory: %v", err)
+			}
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), "nina-bundle") || strings.HasPrefix(entry.Name(), "nina-build-") {
+					t.Errorf("Expected no leftover temp directories, found %s", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestBuildHandler_BundleChecksumMismatch(t *testing.T) {
+	s := newTestEngineWithBuilder(t)
+
+	body, _ := json.Marshal(map[string]string{
+		"app_name":        "app",
+		"commit_hash":     "abc123",
+		"bundle_content":  gzippedTar(t, map[string]string{"main.go": "package main"}),
+		"bundle_checksum": strings.Repeat("0", 64),
+	})
+	req := httptest.NewRequest("POST", "/api/v1/build", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "bundle checksum mismatch") {
+		t.Errorf("Expected checksum mismatch error, got %s", w.Body.String())
+	}
+}
+
+func TestLegacyDeploymentsDisabled(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	s.config.Engine.DisableLegacyDeployments = true
+	ctx := context.Background()
+
+	legacy, err := s.store.CreateDeployment(ctx, &types.ProvisionRequest{Name: "legacy-app", Image: "nginx"})
+	if err != nil {
+		t.Fatalf("Failed to create legacy deployment: %v", err)
+	}
+	if _, err := s.store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: "new-app", CommitHash: "abc123"}); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve("POST", "/api/v1/provision", `{"name":"other","image":"nginx"}`); w.Code != http.StatusGone {
+		t.Errorf("Expected provision status %d, got %d", http.StatusGone, w.Code)
+	}
+
+	// Legacy records are no longer reachable through the API
+	if w := serve("GET", "/api/v1/deployments/"+legacy.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected legacy get status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if w := serve("DELETE", "/api/v1/deployments/"+legacy.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected legacy delete status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if _, err := s.store.GetDeployment(ctx, legacy.ID); err != nil {
+		t.Errorf("Expected legacy deployment to be left in the store, got %v", err)
+	}
+
+	// New deployments are served from the new model
+	w := serve("GET", "/api/v1/deployments/new-app", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected get status %d, got %d", http.StatusOK, w.Code)
+	}
+	var deployment types.Deployment
+	if err := json.NewDecoder(w.Body).Decode(&deployment); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if deployment.AppName != "new-app" {
+		t.Errorf("Expected app name 'new-app', got '%s'", deployment.AppName)
+	}
+}
+
+func TestGetDeploymentStatus_LegacyEnabled(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	ctx := context.Background()
+
+	if _, err := s.store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: "new-app", CommitHash: "abc123"}); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	// With legacy deployments enabled, app names still resolve to the new model
+	req := httptest.NewRequest("GET", "/api/v1/deployments/new-app/status", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var deployment types.Deployment
+	if err := json.NewDecoder(w.Body).Decode(&deployment); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if deployment.AppName != "new-app" || deployment.CommitHash != "abc123" {
+		t.Errorf("Expected the new-app deployment at abc123, got %+v", deployment)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/deployments/missing/status", http.NoBody)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for a missing deployment, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGetDeployment_PrefersNewDeployment(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	ctx := context.Background()
+
+	// A legacy record left over from the migration shares its ID with the app name of a deployment
+	legacy, err := s.store.CreateDeployment(ctx, &types.ProvisionRequest{Name: "legacy-app", Image: "nginx"})
+	if err != nil {
+		t.Fatalf("Failed to create legacy deployment: %v", err)
+	}
+	if _, err := s.store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: legacy.ID, CommitHash: "abc123"}); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/deployments/"+legacy.ID, http.NoBody))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var deployment types.Deployment
+	if err := json.NewDecoder(w.Body).Decode(&deployment); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if deployment.AppName != legacy.ID || deployment.CommitHash != "abc123" {
+		t.Errorf("Expected the current deployment, got %+v", deployment)
+	}
+}
+
+func TestGetDeployment_AfterDeploy(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+	postDeploy(t, s, "app", "abc123")
+	waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady)
+
+	// Deployments created through the deploy endpoint are fetched by app name from both routes
+	for _, path := range []string{"/api/v1/deployments/app", "/api/v1/deployments/app/status"} {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest("GET", path, http.NoBody))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d for %s, got %d: %s", http.StatusOK, path, w.Code, w.Body.String())
+		}
+		var deployment types.Deployment
+		if err := json.NewDecoder(w.Body).Decode(&deployment); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if deployment.AppName != "app" || deployment.CommitHash != "abc123" ||
+			deployment.Status != types.DeploymentStatusReady || len(deployment.Containers) != 1 {
+			t.Errorf("Expected the ready app deployment at abc123 from %s, got %+v", path, deployment)
+		}
+	}
+}
+
+func TestDeleteDeploymentsByPrefix(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	ctx := context.Background()
+
+	for i, appName := range []string{"preview-1", "preview-2", "production"} {
+		deployment, err := s.store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: appName, CommitHash: "abc123"})
+		if err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+		containers := []types.Container{{ContainerID: fmt.Sprintf("%s-container-%d", appName, i), Port: 8080}}
+		if err := s.store.UpdateNewDeploymentWithContainers(ctx, appName, deployment.ID, containers, types.DeploymentStatusReady); err != nil {
+			t.Fatalf("Failed to update deployment: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("DELETE", "/api/v1/deployments?prefix=preview-", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Deleted           []string `json:"deleted"`
+		Count             int      `json:"count"`
+		ContainersRemoved int      `json:"containers_removed"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Count != 2 || len(resp.Deleted) != 2 || resp.Deleted[0] != "preview-1" || resp.Deleted[1] != "preview-2" {
+		t.Errorf("Expected preview-1 and preview-2 to be deleted, got %+v", resp)
+	}
+	if resp.ContainersRemoved != 2 {
+		t.Errorf("Expected 2 containers removed, got %d", resp.ContainersRemoved)
+	}
+
+	fake.mu.Lock()
+	removed := len(fake.removed)
+	fake.mu.Unlock()
+	if removed != 2 {
+		t.Errorf("Expected 2 containers removed from Docker, got %d", removed)
+	}
+
+	if _, err := s.store.GetNewDeployment(ctx, "production"); err != nil {
+		t.Errorf("Expected deployment without the prefix to be kept, got %v", err)
+	}
+	if _, err := s.store.GetNewDeployment(ctx, "preview-1"); err == nil {
+		t.Error("Expected preview-1 to be deleted")
+	}
+}
+
+func TestDeleteDeploymentsByPrefix_Concurrent(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	fake.stopGate, fake.heldStops = make(chan struct{}), make(chan struct{}, 3)
+	ctx := context.Background()
+	for i, appName := range []string{"preview-1", "preview-2", "preview-3"} {
+		deployment, err := s.store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: appName, CommitHash: "abc123"})
+		if err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+		containers := []types.Container{{ContainerID: fmt.Sprintf("%s-container-%d", appName, i), Port: 8080}}
+		if err := s.store.UpdateNewDeploymentWithContainers(ctx, appName, deployment.ID, containers, types.DeploymentStatusReady); err != nil {
+			t.Fatalf("Failed to update deployment: %v", err)
+		}
+	}
+
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/v1/deployments?prefix=preview-", http.NoBody))
+		done <- w
+	}()
+
+	// The containers of every app are stopped at the same time, not one app after the other
+	for i := 0; i < 3; i++ {
+		select {
+		case <-fake.heldStops:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the 3 apps to be stopped concurrently, %d stops started", i)
+		}
+	}
+	close(fake.stopGate)
+
+	w := <-done
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"count":3`) {
+		t.Errorf("Expected the 3 apps to be deleted, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDeleteDeploymentsByPrefix_RequiresPrefix(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+
+	for _, path := range []string{"/api/v1/deployments", "/api/v1/deployments?prefix=", "/api/v1/deployments?prefix=%20"} {
+		req := httptest.NewRequest("DELETE", path, http.NoBody)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for %s, got %d", http.StatusBadRequest, path, w.Code)
+		}
+	}
+}
+
+func TestListBuildsHandler_SortAndFilter(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+
+	createBuiltBuild(t, s, "bravo", "commit1")
+	time.Sleep(time.Millisecond)
+	createBuiltBuild(t, s, "alpha", "commit2")
+	time.Sleep(time.Millisecond)
+	if _, err := s.store.CreateBuild(context.Background(), &types.BuildRequest{AppName: "charlie", CommitHash: "commit3"}); err != nil {
+		t.Fatalf("Failed to create build: %v", err)
+	}
+
+	list := func(query string) []string {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/v1/builds"+query, http.NoBody)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d for %q, got %d", http.StatusOK, query, w.Code)
+		}
+		var resp struct {
+			Builds []*types.Build `json:"builds"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		appNames := make([]string, 0, len(resp.Builds))
+		for _, build := range resp.Builds {
+			appNames = append(appNames, build.AppName)
+		}
+		return appNames
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"", "bravo,alpha,charlie"},
+		{"?order=desc", "charlie,alpha,bravo"},
+		{"?sort=app_name", "alpha,bravo,charlie"},
+		{"?sort=app_name&order=desc&status=built", "bravo,alpha"},
+		{"?status=pending", "charlie"},
+		{"?since=1h&status=built", "bravo,alpha"},
+		{"?since=2000-01-01T00:00:00Z&order=desc", "charlie,alpha,bravo"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(list(tt.query), ","); got != tt.expected {
+			t.Errorf("Expected %q for %q, got %q", tt.expected, tt.query, got)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/builds?sort=size", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for unsupported sort, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/builds?since=soon", http.NoBody)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for invalid since, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// failingBuildpack is a buildpack whose builds always fail with the given error
+type failingBuildpack struct {
+	*builder.BaseBuildpack
+	err error
+}
+
+func (f *failingBuildpack) Build(_ context.Context, bundle *builder.Bundle) (*types.DeploymentImage, error) {
+	_, _ = io.WriteString(bundle.GetBuildOutput(), "Step 2/3 : RUN go build -o myapp\n./main.go:5:2: undefined: foo\n")
+	return nil, f.err
+}
+
+func (f *failingBuildpack) Match(_ context.Context, _ *builder.Bundle) (bool, error) {
+	return true, nil
+}
+
+func (f *failingBuildpack) Name() string { return "failing" }
+
+func (f *failingBuildpack) Priority() int { return 0 }
+
+func TestBuildProject_RecordsFailureLog(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	ctx := context.Background()
+	req := &types.BuildRequest{AppName: "app", CommitHash: "abc123"}
+	if _, err := s.store.CreateBuild(ctx, req); err != nil {
+		t.Fatalf("Failed to create build: %v", err)
+	}
+
+	buildErr := &builder.BuildError{
+		Err: errors.New("returned a non-zero code: 1"),
+		Log: []string{"Step 2/3 : RUN go build -o myapp", "./main.go:5:2: undefined: foo"},
+	}
+	_, err := s.buildProject(ctx, req, &builder.Bundle{}, &failingBuildpack{BaseBuildpack: &builder.BaseBuildpack{}, err: buildErr})
+	if err == nil {
+		t.Fatal("Expected build error, got nil")
+	}
+	if !strings.Contains(err.Error(), "undefined: foo") {
+		t.Errorf("Expected error to include the build output, got %v", err)
+	}
+
+	// The failure is available through the build endpoint
+	httpReq := httptest.NewRequest("GET", "/api/v1/builds/abc123", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httpReq)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var build types.Build
+	if err := json.Unmarshal(w.Body.Bytes(), &build); err != nil {
+		t.Fatalf("Failed to decode build: %v", err)
+	}
+	if build.Status != types.BuildStatusFailed {
+		t.Errorf("Expected status %s, got %s", types.BuildStatusFailed, build.Status)
+	}
+	if build.FailureLog != buildErr.Error() {
+		t.Errorf("Expected failure log %q, got %q", buildErr.Error(), build.FailureLog)
+	}
+	if build.FinishedAt.IsZero() {
+		t.Error("Expected finished time to be set")
+	}
+}
+
+func TestBuildProject_RecordsCancelledBuild(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	req := &types.BuildRequest{AppName: "app", CommitHash: "abc123"}
+	if _, err := s.store.CreateBuild(context.Background(), req); err != nil {
+		t.Fatalf("Failed to create build: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	buildpack := &failingBuildpack{BaseBuildpack: &builder.BaseBuildpack{}, err: ctx.Err()}
+	if _, err := s.buildProject(ctx, req, &builder.Bundle{}, buildpack); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected cancellation error, got %v", err)
+	}
+
+	build, err := s.store.GetBuild(context.Background(), "abc123")
+	if err != nil {
+		t.Fatalf("Failed to get build: %v", err)
+	}
+	if build.Status != types.BuildStatusFailed {
+		t.Errorf("Expected status %s, got %s", types.BuildStatusFailed, build.Status)
+	}
+}
+
+func TestGetBuildHandler_NotFound(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+
+	req := httptest.NewRequest("GET", "/api/v1/builds/missing", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGetBuildLogsHandler(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	ctx := context.Background()
+	req := &types.BuildRequest{AppName: "app", CommitHash: "abc123"}
+	if _, err := s.store.CreateBuild(ctx, req); err != nil {
+		t.Fatalf("Failed to create build: %v", err)
+	}
+
+	// Logs of failed builds are kept too
+	buildpack := &failingBuildpack{BaseBuildpack: &builder.BaseBuildpack{}, err: errors.New("build failed")}
+	if _, err := s.buildProject(ctx, req, &builder.Bundle{}, buildpack); err == nil {
+		t.Fatal("Expected build error, got nil")
+	}
+
+	httpReq := httptest.NewRequest("GET", "/api/v1/builds/abc123/logs", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httpReq)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		CommitHash string `json:"commit_hash"`
+		Logs       string `json:"logs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.CommitHash != "abc123" || !strings.Contains(resp.Logs, "undefined: foo") {
+		t.Errorf("Expected build output for abc123, got %+v", resp)
+	}
+
+	httpReq = httptest.NewRequest("GET", "/api/v1/builds/missing/logs", http.NoBody)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httpReq)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for missing logs, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestAppHandlers(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "web", "abc123")
+
+	req := httptest.NewRequest("GET", "/api/v1/apps", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var list struct {
+		Apps  []*types.App `json:"apps"`
+		Count int          `json:"count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if list.Count != 1 || list.Apps[0].Name != "web" || list.Apps[0].LatestBuild.CommitHash != "abc123" {
+		t.Errorf("Expected the web app with its build, got %+v", list)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/apps/web", http.NoBody)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/apps/missing", http.NoBody)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestBuildHandlers_CommitPrefix(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abcd123def456")
+	createBuiltBuild(t, s, "app", "abcd999aaa000")
+
+	tests := []struct {
+		method, path string
+		expected     int
+	}{
+		{"GET", "/api/v1/builds/abcd1", http.StatusOK},
+		{"GET", "/api/v1/builds/abcd", http.StatusBadRequest},
+		{"GET", "/api/v1/builds/abcd/logs", http.StatusBadRequest},
+		{"DELETE", "/api/v1/builds/abcd", http.StatusBadRequest},
+		{"DELETE", "/api/v1/builds/abcd9", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, http.NoBody)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		if w.Code != tt.expected {
+			t.Errorf("%s %s: expected status code %d, got %d: %s", tt.method, tt.path, tt.expected, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestDeployHandler_Labels(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "checkout", "abc123")
+	createBuiltBuild(t, s, "search", "def456")
+
+	for _, req := range []*types.DeploymentRequest{
+		{AppName: "checkout", CommitHash: "abc123", Labels: map[string]string{"team": "payments", "ticket": "NINA-1"}},
+		{AppName: "search", CommitHash: "def456", Labels: map[string]string{"team": "discovery"}},
+	} {
+		body, err := json.Marshal(req)
+		if err != nil {
+			t.Fatalf("Failed to marshal request: %v", err)
+		}
+		httpReq := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(string(body)))
+		httpReq.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httpReq)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		waitForDeploymentStatus(t, s, req.AppName, types.DeploymentStatusReady)
+	}
+
+	// The labels are applied to the containers
+	labels := fake.containerLabels()
+	if len(labels) != 2 || labels[0]["team"] != "payments" || labels[0]["ticket"] != "NINA-1" {
+		t.Errorf("Expected the deployment labels on the containers, got %v", labels)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/deployments?label=team=payments", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp struct {
+		Deployments []*types.Deployment `json:"deployments"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Deployments) != 1 || resp.Deployments[0].AppName != "checkout" {
+		t.Errorf("Expected only the checkout deployment, got %+v", resp.Deployments)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/deployments?label=team", http.NoBody)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for a malformed label, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestLoggerMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	router := gin.New()
+	log := logger.NewWithWriter(logger.LevelInfo, "json", &buf)
+	log.DisableColor()
+	router.Use(loggerMiddleware(log))
+	router.GET("/api/v1/apps/:name", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "app not found"})
+	})
+
+	req := httptest.NewRequest("GET", "/api/v1/apps/missing?verbose=1", http.NoBody)
+	req.Header.Set("User-Agent", "nina-test")
+	req.RemoteAddr = "192.0.2.1:1234"
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a single JSON log line, got %q: %v", buf.String(), err)
+	}
+	expected := map[string]interface{}{
+		"msg":        "HTTP Request",
+		"method":     "GET",
+		"path":       "/api/v1/apps/missing?verbose=1",
+		"status":     float64(http.StatusNotFound),
+		"client_ip":  "192.0.2.1",
+		"user_agent": "nina-test",
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, entry[key])
+		}
+	}
+	if _, ok := entry["latency"]; !ok {
+		t.Errorf("Expected the latency to be logged, got %v", entry)
+	}
+}
@@ -0,0 +1,367 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/docker/docker/client"
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+	"github.com/matiasinsaurralde/nina/pkg/store"
+)
+
+// fakeDocker is a minimal Docker Engine API used to exercise the container lifecycle in tests
+type fakeDocker struct {
+	mu         sync.Mutex
+	created    []string
+	started    []string
+	removed    []string
+	ports      map[string]string
+	labels     map[string]map[string]string
+	failStart  map[string]bool
+	nextHostID int
+	// missingImages are the images the daemon does not have until they are pulled
+	missingImages map[string]bool
+	pulled        []string
+	// imageIDs are the IDs of images by name, an image's ID is its name prefixed with sha256: by default
+	imageIDs map[string]string
+	// exposedPorts are the ports images declare with EXPOSE by name, e.g. "3000/tcp"
+	exposedPorts map[string][]string
+	// tags maps the image tags created with the tag endpoint to their source image
+	tags map[string]string
+	// names maps container names to the containers holding them
+	names map[string]string
+	// conflicts is the number of container creations that find their name held by a stale container
+	// labeled with conflictLabels
+	conflicts      int
+	conflictLabels map[string]string
+	// stopsAndRemovals records the container stop and removal calls in order, e.g. "stop container1 t=10"
+	stopsAndRemovals []string
+	// exited are the exit codes of the containers that exit right after they start, by ID
+	exited map[string]int
+	// startGate holds container starts until it is closed, when set before the first request
+	startGate chan struct{}
+	// stopGate holds container stops until it is closed, when set before the first request.
+	// Every held stop is announced on heldStops.
+	stopGate  chan struct{}
+	heldStops chan struct{}
+}
+
+// ServeHTTP implements the subset of the Docker API used by the engine
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.startGate != nil && r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/start") {
+		<-f.startGate
+	}
+	if f.stopGate != nil && r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/stop") {
+		f.heldStops <- struct{}{}
+		<-f.stopGate
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := r.URL.Path
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case strings.HasSuffix(path, "/_ping"):
+		w.Header().Set("Api-Version", "1.48")
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet && strings.Contains(path, "/images/"):
+		name := imageNameFromPath(path)
+		if f.missingImages[name] {
+			writeFakeJSON(w, http.StatusNotFound, map[string]string{"message": "No such image: " + name})
+			return
+		}
+		id := "sha256:" + name
+		if imageID, ok := f.imageIDs[name]; ok {
+			id = imageID
+		}
+		exposed := map[string]struct{}{}
+		for _, port := range f.exposedPorts[name] {
+			exposed[port] = struct{}{}
+		}
+		writeFakeJSON(w, http.StatusOK, map[string]interface{}{"Id": id, "RepoTags": []string{name},
+			"Config": map[string]interface{}{"ExposedPorts": exposed}})
+	case r.Method == http.MethodPost && strings.Contains(path, "/images/") && strings.HasSuffix(path, "/tag"):
+		source := strings.TrimSuffix(imageNameFromPath(path), "/tag")
+		f.tags[r.URL.Query().Get("repo")+":"+r.URL.Query().Get("tag")] = source
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/images/create"):
+		name := r.URL.Query().Get("fromImage") + ":" + r.URL.Query().Get("tag")
+		f.pulled = append(f.pulled, name)
+		delete(f.missingImages, strings.TrimSuffix(name, ":latest"))
+		writeFakeJSON(w, http.StatusOK, map[string]string{"status": "Downloaded newer image for " + name})
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/containers/create"):
+		name := r.URL.Query().Get("name")
+		if f.conflicts > 0 {
+			f.conflicts--
+			f.nextHostID++
+			staleID := fmt.Sprintf("stale%d", f.nextHostID)
+			f.names[name], f.ports[staleID], f.labels[staleID] = staleID, "8080/tcp", f.conflictLabels
+		}
+		if holder, ok := f.names[name]; ok {
+			writeFakeJSON(w, http.StatusConflict, map[string]string{
+				"message": fmt.Sprintf("Conflict. The container name %q is already in use by container %q", "/"+name, holder),
+			})
+			return
+		}
+		f.nextHostID++
+		id := fmt.Sprintf("container%d", f.nextHostID)
+		f.created = append(f.created, id)
+		f.names[name] = id
+		f.ports[id], f.labels[id] = decodeCreateBody(r)
+		writeFakeJSON(w, http.StatusCreated, map[string]interface{}{"Id": id, "Warnings": []string{}})
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/start"):
+		if id := containerIDFromPath(path); f.failStart[id] {
+			writeFakeJSON(w, http.StatusInternalServerError, map[string]string{"message": "cannot start " + id})
+			return
+		}
+		f.started = append(f.started, containerIDFromPath(path))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/stop"):
+		id := containerIDFromPath(path)
+		f.stopsAndRemovals = append(f.stopsAndRemovals, fmt.Sprintf("stop %s t=%s", id, r.URL.Query().Get("t")))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/containers/json"):
+		writeFakeJSON(w, http.StatusOK, f.runningContainerList(r))
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/json"):
+		id := containerIDFromPath(path)
+		if byName, ok := f.names[id]; ok {
+			id = byName
+		}
+		if _, ok := f.ports[id]; !ok {
+			writeFakeJSON(w, http.StatusNotFound, map[string]string{"message": "No such container: " + id})
+			return
+		}
+		state := map[string]interface{}{
+			"Status":     "running",
+			"Running":    true,
+			"StartedAt":  "2025-01-02T03:04:05.000000006Z",
+			"FinishedAt": "0001-01-01T00:00:00Z",
+		}
+		if exitCode, ok := f.exited[id]; ok {
+			state["Status"], state["Running"], state["ExitCode"] = "exited", false, exitCode
+		}
+		writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+			"Id":           id,
+			"Name":         "/" + id,
+			"RestartCount": 0,
+			"State":        state,
+			"Config":       map[string]interface{}{"Image": "nina-" + id, "Labels": f.labels[id]},
+			"NetworkSettings": map[string]interface{}{
+				"Ports": map[string]interface{}{
+					f.ports[id]: []map[string]string{{"HostIp": "0.0.0.0", "HostPort": fmt.Sprintf("%d", 32000+len(f.started))}},
+				},
+			},
+		})
+	case r.Method == http.MethodDelete && strings.Contains(path, "/containers/"):
+		id := containerIDFromPath(path)
+		f.removed = append(f.removed, id)
+		f.stopsAndRemovals = append(f.stopsAndRemovals, "remove "+id)
+		for name, holder := range f.names {
+			if holder == id {
+				delete(f.names, name)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeFakeJSON(w, http.StatusNotFound, map[string]string{"message": "not implemented: " + path})
+	}
+}
+
+// runningContainerList lists the started containers that were not removed with their names, keeping those
+// that have the label keys of the label filter of the request
+func (f *fakeDocker) runningContainerList(r *http.Request) []map[string]interface{} {
+	var listFilters map[string]map[string]bool
+	if raw := r.URL.Query().Get("filters"); raw != "" {
+		_ = json.Unmarshal([]byte(raw), &listFilters)
+	}
+	removed := make(map[string]bool, len(f.removed))
+	for _, id := range f.removed {
+		removed[id] = true
+	}
+
+	containers := []map[string]interface{}{}
+	for _, id := range f.started {
+		if removed[id] {
+			continue
+		}
+		matches := true
+		for label := range listFilters["label"] {
+			if _, ok := f.labels[id][label]; !ok {
+				matches = false
+			}
+		}
+		if matches {
+			var names []string
+			for name, holder := range f.names {
+				if holder == id {
+					names = append(names, "/"+name)
+				}
+			}
+			containers = append(containers, map[string]interface{}{
+				"Id": id, "Names": names, "Image": "nina-" + id, "Labels": f.labels[id], "State": "running", "Status": "Up 1 second",
+			})
+		}
+	}
+	return containers
+}
+
+// stopAndRemoveCalls returns the container stop and removal calls made so far, in order
+func (f *fakeDocker) stopAndRemoveCalls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.stopsAndRemovals...)
+}
+
+// createdCount returns the number of containers created so far
+func (f *fakeDocker) createdCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.created)
+}
+
+// liveContainers returns the created containers that were not removed, in creation order
+func (f *fakeDocker) liveContainers() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	removed := make(map[string]bool, len(f.removed))
+	for _, id := range f.removed {
+		removed[id] = true
+	}
+	live := []string{}
+	for _, id := range f.created {
+		if !removed[id] {
+			live = append(live, id)
+		}
+	}
+	return live
+}
+
+// containerLabels returns the labels of every created container, in creation order
+func (f *fakeDocker) containerLabels() []map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	labels := make([]map[string]string, 0, len(f.created))
+	for _, id := range f.created {
+		labels = append(labels, f.labels[id])
+	}
+	return labels
+}
+
+// containerPorts returns the exposed container port of every created container, in creation order
+func (f *fakeDocker) containerPorts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ports := make([]string, 0, len(f.created))
+	for _, id := range f.created {
+		ports = append(ports, f.ports[id])
+	}
+	return ports
+}
+
+// decodeCreateBody returns the first exposed port and the labels of a container create request
+func decodeCreateBody(r *http.Request) (port string, labels map[string]string) {
+	var body struct {
+		ExposedPorts map[string]struct{}
+		Labels       map[string]string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return "", nil
+	}
+	for port := range body.ExposedPorts {
+		return port, body.Labels
+	}
+	return "", body.Labels
+}
+
+// imageNameFromPath extracts the image name from paths such as /v1.48/images/{name}/json
+func imageNameFromPath(path string) string {
+	_, name, _ := strings.Cut(path, "/images/")
+	return strings.TrimSuffix(name, "/json")
+}
+
+// imageTags returns the tags created so far with their source images
+func (f *fakeDocker) imageTags() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tags := make(map[string]string, len(f.tags))
+	for tag, source := range f.tags {
+		tags[tag] = source
+	}
+	return tags
+}
+
+// pulledImages returns the images pulled so far
+func (f *fakeDocker) pulledImages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.pulled...)
+}
+
+// containerIDFromPath extracts the container ID from paths such as /v1.48/containers/{id}/start
+func containerIDFromPath(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range parts {
+		if part == "containers" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+func writeFakeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// newFakeDockerClient starts a fake Docker API server and returns a client connected to it
+func newFakeDockerClient(t *testing.T) (*client.Client, *fakeDocker) {
+	t.Helper()
+	fake := &fakeDocker{
+		ports: map[string]string{}, labels: map[string]map[string]string{}, names: map[string]string{},
+		tags: map[string]string{},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+strings.TrimPrefix(server.URL, "http://")),
+		client.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatalf("Failed to create Docker client: %v", err)
+	}
+	return cli, fake
+}
+
+// newTestStore creates a store backed by Miniredis
+func newTestStore(t *testing.T, log *logger.Logger) *store.Store {
+	t.Helper()
+	mockRedis, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start Miniredis: %v", err)
+	}
+	t.Cleanup(mockRedis.Close)
+
+	cfg := &config.Config{
+		Redis: config.RedisConfig{
+			Host: mockRedis.Host(),
+			Port: mockRedis.Server().Addr().Port,
+		},
+	}
+	st, err := store.NewStore(cfg, log)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() {
+		if closeErr := st.Close(); closeErr != nil {
+			t.Logf("Failed to close store: %v", closeErr)
+		}
+	})
+	return st
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/gin-gonic/gin"
+	"github.com/matiasinsaurralde/nina/pkg/store"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// inspectContainerHandler returns the Docker state of a single replica container of a deployment.
+// The container ID may be abbreviated, as long as it matches a single container of the deployment.
+func (s *BaseEngine) inspectContainerHandler(c *gin.Context) {
+	id, containerID := c.Param("id"), c.Param("containerID")
+
+	deployment, err := s.store.GetNewDeployment(c.Request.Context(), id)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			s.logger.Error("Failed to get deployment", "id", id, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get deployment"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "deployment not found"})
+		return
+	}
+
+	replica, err := findDeploymentContainer(deployment, containerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	dockerClient, err := s.dockerClientFor(deployment.Node)
+	if err != nil {
+		s.logger.Error("Failed to get Docker client", "id", id, "node", deployment.Node, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	info, err := dockerClient.ContainerInspect(c.Request.Context(), replica.ContainerID)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("container %s no longer exists", replica.ContainerID)})
+			return
+		}
+		s.logger.Error("Failed to inspect container", "id", id, "container_id", replica.ContainerID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to inspect container"})
+		return
+	}
+
+	details := containerDetails(&info)
+	details.Node = deployment.Node
+	c.JSON(http.StatusOK, details)
+}
+
+// findDeploymentContainer returns the container of the deployment whose ID is or starts with containerID
+func findDeploymentContainer(deployment *types.Deployment, containerID string) (*types.Container, error) {
+	if containerID == "" {
+		return nil, fmt.Errorf("container not found in deployment %s", deployment.ID)
+	}
+	var match *types.Container
+	for i := range deployment.Containers {
+		replica := &deployment.Containers[i]
+		if replica.ContainerID == containerID {
+			return replica, nil
+		}
+		if strings.HasPrefix(replica.ContainerID, containerID) {
+			if match != nil {
+				return nil, fmt.Errorf("container %s matches more than one container of deployment %s", containerID, deployment.ID)
+			}
+			match = replica
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("container %s not found in deployment %s", containerID, deployment.ID)
+	}
+	return match, nil
+}
+
+// containerDetails summarizes a Docker inspect response
+func containerDetails(info *container.InspectResponse) *types.ContainerDetails {
+	details := &types.ContainerDetails{Ports: []types.PortBinding{}}
+	if info.ContainerJSONBase != nil {
+		details.ContainerID = info.ID
+		details.Name = strings.TrimPrefix(info.Name, "/")
+		details.RestartCount = info.RestartCount
+		if state := info.State; state != nil {
+			details.Status = state.Status
+			details.Running = state.Running
+			details.ExitCode = state.ExitCode
+			details.Error = state.Error
+			details.StartedAt = parseDockerTime(state.StartedAt)
+			details.FinishedAt = parseDockerTime(state.FinishedAt)
+			if state.Health != nil {
+				details.Health = state.Health.Status
+			}
+		}
+	}
+	if info.Config != nil {
+		details.Image = info.Config.Image
+	}
+	if info.NetworkSettings != nil {
+		for port, bindings := range info.NetworkSettings.Ports {
+			for _, binding := range bindings {
+				details.Ports = append(details.Ports, types.PortBinding{
+					ContainerPort: string(port),
+					HostIP:        binding.HostIP,
+					HostPort:      binding.HostPort,
+				})
+			}
+		}
+		sort.Slice(details.Ports, func(i, j int) bool {
+			if details.Ports[i].ContainerPort != details.Ports[j].ContainerPort {
+				return details.Ports[i].ContainerPort < details.Ports[j].ContainerPort
+			}
+			return details.Ports[i].HostIP < details.Ports[j].HostIP
+		})
+	}
+	return details
+}
+
+// parseDockerTime parses a Docker timestamp, returning the zero time for unset values such as
+// the 0001-01-01T00:00:00Z finish time of a running container
+func parseDockerTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestInspectContainerHandler(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+	postDeploy(t, s, "app", "abc123")
+	deployment := waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady)
+	containerID := deployment.Containers[0].ContainerID
+
+	// Full and abbreviated IDs of a replica of the deployment
+	for _, id := range []string{containerID, containerID[:len(containerID)-1]} {
+		req := httptest.NewRequest("GET", "/api/v1/deployments/app/containers/"+id, nil)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		var details types.ContainerDetails
+		if err := json.Unmarshal(w.Body.Bytes(), &details); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if details.ContainerID != containerID || details.Name != containerID {
+			t.Errorf("Expected container %s, got %+v", containerID, details)
+		}
+		if details.Status != "running" || !details.Running || details.StartedAt.IsZero() || !details.FinishedAt.IsZero() {
+			t.Errorf("Expected a running container, got %+v", details)
+		}
+		if len(details.Ports) != 1 || details.Ports[0].ContainerPort != "8080/tcp" || details.Ports[0].HostPort == "" {
+			t.Errorf("Expected the published port, got %+v", details.Ports)
+		}
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing deployment", "/api/v1/deployments/missing/containers/" + containerID},
+		{"container of another deployment", "/api/v1/deployments/app/containers/other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("Expected status code %d, got %d: %s", http.StatusNotFound, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	// The container is gone from Docker
+	fake.mu.Lock()
+	delete(fake.ports, containerID)
+	fake.mu.Unlock()
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/deployments/app/containers/"+containerID, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d: %s", http.StatusNotFound, w.Code, w.Body.String())
+	}
+}
+
+func TestFindDeploymentContainer(t *testing.T) {
+	deployment := &types.Deployment{ID: "app", Containers: []types.Container{
+		{ContainerID: "abc111"}, {ContainerID: "abc222"},
+	}}
+
+	replica, err := findDeploymentContainer(deployment, "abc2")
+	if err != nil || replica.ContainerID != "abc222" {
+		t.Errorf("Expected abc222, got %+v (err %v)", replica, err)
+	}
+	for _, id := range []string{"", "abc", "def"} {
+		if _, err := findDeploymentContainer(deployment, id); err == nil {
+			t.Errorf("Expected an error for %q", id)
+		}
+	}
+}
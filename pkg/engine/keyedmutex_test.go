@@ -0,0 +1,231 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/store"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestKeyedMutex(t *testing.T) {
+	var locks keyedMutex
+	unlockA := locks.Lock("app-a")
+
+	// A different key is not held up
+	done := make(chan struct{})
+	go func() {
+		unlock := locks.Lock("app-b")
+		unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Lock for app-b blocked while app-a was held")
+	}
+
+	// The same key waits for the holder
+	acquired := make(chan struct{})
+	go func() {
+		unlock := locks.Lock("app-a")
+		close(acquired)
+		unlock()
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Lock for app-a was acquired twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlockA()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Lock for app-a was not acquired after unlock")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for locks.len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := locks.len(); n != 0 {
+		t.Errorf("Expected released keys to be removed, got %d", n)
+	}
+}
+
+// postDeploy sends a deploy request for the given app and commit
+func postDeploy(t *testing.T, s *BaseEngine, appName, commitHash string) {
+	t.Helper()
+	body, err := json.Marshal(&types.DeploymentRequest{AppName: appName, CommitHash: commitHash})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+}
+
+func TestDeployHandler_LocksPerApp(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app-a", "aaa111")
+	createBuiltBuild(t, s, "app-b", "bbb222")
+
+	// Simulate a deployment of app-a that is still running
+	unlock := s.appLocks.Lock("app-a")
+	postDeploy(t, s, "app-a", "aaa111")
+	postDeploy(t, s, "app-b", "bbb222")
+
+	// app-b proceeds while app-a waits for its running deployment
+	waitForDeploymentStatus(t, s, "app-b", types.DeploymentStatusReady)
+	if n := fake.createdCount(); n != 1 {
+		t.Errorf("Expected only the app-b container to be created, got %d", n)
+	}
+
+	unlock()
+	waitForDeploymentStatus(t, s, "app-a", types.DeploymentStatusReady)
+	if n := fake.createdCount(); n != 2 {
+		t.Errorf("Expected both containers to be created, got %d", n)
+	}
+}
+
+// waitForLockRefs polls until n goroutines hold or wait for the lock of key
+func waitForLockRefs(t *testing.T, locks *keyedMutex, key string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		locks.mu.Lock()
+		entry := locks.locks[key]
+		refs := 0
+		if entry != nil {
+			refs = entry.refs
+		}
+		locks.mu.Unlock()
+		if refs == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Lock %s did not reach %d holders and waiters", key, n)
+}
+
+func TestDeployHandler_SkipsDeploymentDeletedWhileWaiting(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+
+	// The deployment waits for the lock while a delete removes its record
+	unlock := s.appLocks.Lock("app")
+	postDeploy(t, s, "app", "abc123")
+	waitForLockRefs(t, &s.appLocks, "app", 2)
+	if err := s.store.DeleteNewDeployment(context.Background(), "app"); err != nil {
+		t.Fatalf("Failed to delete deployment: %v", err)
+	}
+	unlock()
+	waitForLockRefs(t, &s.appLocks, "app", 0)
+
+	if n := fake.createdCount(); n != 0 {
+		t.Errorf("Expected no container for a deleted deployment, got %d", n)
+	}
+	if _, err := s.store.GetNewDeployment(context.Background(), "app"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected the deployment to stay deleted, got %v", err)
+	}
+}
+
+func TestDeployHandler_RedeployWhileDeploying(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+	createBuiltBuild(t, s, "app", "def456")
+
+	// The first deployment is starting its container when the redeploy replaces its record
+	fake.startGate = make(chan struct{})
+	postDeploy(t, s, "app", "abc123")
+	deadline := time.Now().Add(5 * time.Second)
+	for fake.createdCount() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("The first deployment did not create its container")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	postDeploy(t, s, "app", "def456")
+	close(fake.startGate)
+
+	waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady)
+	waitForLockRefs(t, &s.appLocks, "app", 0)
+	deployment, err := s.store.GetNewDeployment(context.Background(), "app")
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if deployment.CommitHash != "def456" || len(deployment.Containers) != 1 || deployment.Containers[0].ContainerID != "container2" {
+		t.Errorf("Expected the redeploy to own the record with its own container, got %+v", deployment)
+	}
+	// The container of the replaced deployment is not left running without a record
+	if live := fake.liveContainers(); len(live) != 1 || live[0] != "container2" {
+		t.Errorf("Expected only the container of the redeploy to be left, got %v", live)
+	}
+}
+
+func TestDeployAndDelete_Concurrent(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+
+	// Queue a deployment and a delete of the same app, then let them race for the lock
+	unlock := s.appLocks.Lock("app")
+	postDeploy(t, s, "app", "abc123")
+	waitForLockRefs(t, &s.appLocks, "app", 2)
+	deleted := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/v1/deployments/app", http.NoBody))
+		deleted <- w.Code
+	}()
+	waitForLockRefs(t, &s.appLocks, "app", 3)
+	unlock()
+
+	if code := <-deleted; code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, code)
+	}
+	waitForLockRefs(t, &s.appLocks, "app", 0)
+
+	// Whichever ran first, the app ends up fully deleted
+	if _, err := s.store.GetNewDeployment(context.Background(), "app"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected the deployment to be deleted, got %v", err)
+	}
+	if live := fake.liveContainers(); len(live) != 0 {
+		t.Errorf("Expected no container to be left behind, got %v", live)
+	}
+}
+
+func TestDeleteDeployment_LocksNormalizedAppName(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "my-app", "abc123")
+	postDeploy(t, s, "My_App", "abc123")
+	waitForDeploymentStatus(t, s, "my-app", types.DeploymentStatusReady)
+
+	// The delete waits on the lock the deploy of the same app takes
+	unlock := s.appLocks.Lock("my-app")
+	deleted := make(chan int, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/v1/deployments/My_App", http.NoBody))
+		deleted <- w.Code
+	}()
+	waitForLockRefs(t, &s.appLocks, "my-app", 2)
+	unlock()
+
+	if code := <-deleted; code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, code)
+	}
+	if live := fake.liveContainers(); len(live) != 0 {
+		t.Errorf("Expected the containers to be removed, got %v", live)
+	}
+}
@@ -0,0 +1,40 @@
+package engine
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+)
+
+// logLevelRequest is the body of a log level change
+type logLevelRequest struct {
+	Level string `json:"level" binding:"required"`
+}
+
+// setLogLevelHandler changes the log level of the Engine without restarting it
+func (s *BaseEngine) setLogLevelHandler(c *gin.Context) {
+	var req logLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	level, err := logger.ParseLevel(req.Level)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	previous := s.logger.GetLevel()
+	s.logger.SetLevel(level)
+	s.logger.Info("Log level changed", "previous", previous, "level", level)
+
+	c.JSON(http.StatusOK, gin.H{
+		"level": level,
+	})
+}
@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+)
+
+// putLogLevel requests a log level change and returns the response status code
+func putLogLevel(t *testing.T, s *BaseEngine, body string) int {
+	t.Helper()
+	req := httptest.NewRequest("PUT", "/admin/log-level", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestSetLogLevelHandler(t *testing.T) {
+	s := newTestEngine(t)
+	var buf bytes.Buffer
+	s.logger = logger.NewWithWriter(logger.LevelInfo, "text", &buf)
+	derived := s.logger.WithContext("component", "builder")
+
+	derived.Debug("before")
+	if code := putLogLevel(t, s, `{"level":"debug"}`); code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, code)
+	}
+	derived.Debug("while debugging")
+	if code := putLogLevel(t, s, `{"level":"info"}`); code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, code)
+	}
+	derived.Debug("after")
+
+	output := buf.String()
+	if !strings.Contains(output, "while debugging") {
+		t.Errorf("Expected debug lines after switching to debug, got %q", output)
+	}
+	if strings.Contains(output, "before") || strings.Contains(output, "after") {
+		t.Errorf("Expected no debug lines at the info level, got %q", output)
+	}
+
+	for _, body := range []string{`{"level":"verbose"}`, `{}`, `not json`} {
+		if code := putLogLevel(t, s, body); code != http.StatusBadRequest {
+			t.Errorf("Expected status code %d for %s, got %d", http.StatusBadRequest, body, code)
+		}
+	}
+	if s.logger.GetLevel() != logger.LevelInfo {
+		t.Errorf("Expected rejected requests to keep the info level, got %s", s.logger.GetLevel())
+	}
+}
@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// metricsContentType is the content type of the Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// durationBuckets are the upper bounds in seconds of the build and deploy duration histograms
+var durationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600}
+
+// engineMetrics holds the build and deployment metrics exposed on /metrics.
+// The zero value is ready to use.
+type engineMetrics struct {
+	mu                sync.Mutex
+	builds            map[types.BuildStatus]uint64
+	buildDuration     histogram
+	deployDuration    histogram
+	activeBuilds      int
+	activeDeployments int
+}
+
+// histogram counts observations into cumulative buckets, as Prometheus histograms do
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// observe records a value in seconds
+func (h *histogram) observe(seconds float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(durationBuckets))
+	}
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// write writes the histogram samples of the named metric
+func (h *histogram) write(w io.Writer, name string) {
+	for i, bound := range durationBuckets {
+		var count uint64
+		if h.counts != nil {
+			count = h.counts[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound, count)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %g\n", name, h.sum)
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// buildStarted counts a running build and returns the function recording its outcome once it is over
+func (m *engineMetrics) buildStarted() (finished func(status types.BuildStatus)) {
+	start := time.Now()
+	m.mu.Lock()
+	m.activeBuilds++
+	m.mu.Unlock()
+
+	return func(status types.BuildStatus) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.activeBuilds--
+		if m.builds == nil {
+			m.builds = make(map[types.BuildStatus]uint64)
+		}
+		m.builds[status]++
+		m.buildDuration.observe(time.Since(start).Seconds())
+	}
+}
+
+// deployStarted counts a running deployment and returns the function recording its duration once it is over
+func (m *engineMetrics) deployStarted() (finished func()) {
+	start := time.Now()
+	m.mu.Lock()
+	m.activeDeployments++
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.activeDeployments--
+		m.deployDuration.observe(time.Since(start).Seconds())
+	}
+}
+
+// write writes all metrics in the Prometheus text exposition format
+func (m *engineMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP nina_builds_total Finished builds by status.")
+	fmt.Fprintln(w, "# TYPE nina_builds_total counter")
+	statuses := make([]string, 0, len(m.builds))
+	for status := range m.builds {
+		statuses = append(statuses, string(status))
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(w, "nina_builds_total{status=%q} %d\n", status, m.builds[types.BuildStatus(status)])
+	}
+
+	fmt.Fprintln(w, "# HELP nina_build_duration_seconds Time taken by finished builds.")
+	fmt.Fprintln(w, "# TYPE nina_build_duration_seconds histogram")
+	m.buildDuration.write(w, "nina_build_duration_seconds")
+
+	fmt.Fprintln(w, "# HELP nina_deploy_duration_seconds Time taken to start the containers of deployments.")
+	fmt.Fprintln(w, "# TYPE nina_deploy_duration_seconds histogram")
+	m.deployDuration.write(w, "nina_deploy_duration_seconds")
+
+	fmt.Fprintln(w, "# HELP nina_active_builds Builds in progress.")
+	fmt.Fprintln(w, "# TYPE nina_active_builds gauge")
+	fmt.Fprintf(w, "nina_active_builds %d\n", m.activeBuilds)
+
+	fmt.Fprintln(w, "# HELP nina_active_deployments Deployments starting their containers.")
+	fmt.Fprintln(w, "# TYPE nina_active_deployments gauge")
+	fmt.Fprintf(w, "nina_active_deployments %d\n", m.activeDeployments)
+}
+
+// metricsHandler exposes the Engine metrics to Prometheus
+func (s *BaseEngine) metricsHandler(c *gin.Context) {
+	if s.config.Engine.DisableMetrics {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Metrics are disabled",
+		})
+		return
+	}
+	var buf bytes.Buffer
+	s.metrics.write(&buf)
+	c.Data(http.StatusOK, metricsContentType, buf.Bytes())
+}
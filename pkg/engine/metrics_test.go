@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// getMetrics returns the body of the metrics endpoint
+func getMetrics(t *testing.T, s *BaseEngine) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", http.NoBody))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != metricsContentType {
+		t.Errorf("Expected content type %q, got %q", metricsContentType, contentType)
+	}
+	return w.Body.String()
+}
+
+func TestMetricsHandler_Builds(t *testing.T) {
+	s := newTestEngine(t)
+	s.metrics.buildStarted()(types.BuildStatusBuilt)
+	s.metrics.buildStarted()(types.BuildStatusFailed)
+	s.metrics.buildStarted()(types.BuildStatusFailed)
+	s.metrics.buildStarted()
+
+	body := getMetrics(t, s)
+	for _, expected := range []string{
+		`nina_builds_total{status="built"} 1`,
+		`nina_builds_total{status="failed"} 2`,
+		`nina_build_duration_seconds_bucket{le="1"} 3`,
+		`nina_build_duration_seconds_bucket{le="+Inf"} 3`,
+		`nina_build_duration_seconds_count 3`,
+		"nina_active_builds 1",
+		"nina_active_deployments 0",
+	} {
+		if !strings.Contains(body, expected+"\n") {
+			t.Errorf("Expected %q in the metrics, got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestMetricsHandler_FailedBuild(t *testing.T) {
+	s := newTestEngineWithBuilder(t)
+
+	// A bundle that does not match its checksum fails the build after its record was created
+	body, _ := json.Marshal(map[string]string{
+		"app_name":        "app",
+		"commit_hash":     "abc123",
+		"bundle_content":  gzippedTar(t, map[string]string{"main.go": "package main"}),
+		"bundle_checksum": strings.Repeat("0", 64),
+	})
+	req := httptest.NewRequest("POST", "/api/v1/build", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+
+	metrics := getMetrics(t, s)
+	if !strings.Contains(metrics, `nina_builds_total{status="failed"} 1`+"\n") {
+		t.Errorf("Expected the failed build to be counted, got:\n%s", metrics)
+	}
+}
+
+func TestMetricsHandler_Deploy(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+	postDeploy(t, s, "app", "abc123")
+	waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady)
+
+	// The duration is recorded once deployContainers returned, right after the status update
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		body := getMetrics(t, s)
+		if strings.Contains(body, "nina_deploy_duration_seconds_count 1\n") {
+			if !strings.Contains(body, "nina_active_deployments 0\n") {
+				t.Errorf("Expected no active deployment, got:\n%s", body)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the deployment to be recorded, got:\n%s", body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMetricsHandler_Disabled(t *testing.T) {
+	s := newTestEngine(t)
+	s.config.Engine.DisableMetrics = true
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", http.NoBody))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
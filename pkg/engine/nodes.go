@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/docker/docker/client"
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+)
+
+// defaultContainerAddress is the address of containers running on the default Docker daemon
+const defaultContainerAddress = "localhost"
+
+// connectNodes creates a Docker client for every configured node, closing them all if one is unreachable
+func connectNodes(ctx context.Context, log *logger.Logger, nodes map[string]config.DockerNodeConfig) (map[string]*client.Client, error) {
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	clients := make(map[string]*client.Client, len(nodes))
+	for _, name := range names {
+		node := nodes[name]
+		dockerClient, err := connectNode(ctx, log, &node)
+		if err != nil {
+			closeNodes(clients)
+			return nil, fmt.Errorf("failed to connect to Docker node %s: %w", name, err)
+		}
+		log.Info("Docker node connected", "node", name, "host", dockerClient.DaemonHost())
+		clients[name] = dockerClient
+	}
+	return clients, nil
+}
+
+// connectNode creates a Docker client for a single daemon and waits until it answers
+func connectNode(ctx context.Context, log *logger.Logger, node *config.DockerNodeConfig) (*client.Client, error) {
+	if err := checkDockerSocket(node.Host); err != nil {
+		return nil, err
+	}
+	opts, err := dockerClientOpts(node)
+	if err != nil {
+		return nil, err
+	}
+	return connectDocker(ctx, log, dockerConnectAttempts, dockerConnectBackoff, opts...)
+}
+
+// closeNodes closes the given node clients
+func closeNodes(clients map[string]*client.Client) {
+	for _, dockerClient := range clients {
+		_ = dockerClient.Close()
+	}
+}
+
+// hasNode reports whether a node with the given name is configured
+func (s *BaseEngine) hasNode(name string) bool {
+	_, ok := s.config.Docker.Nodes[name]
+	return ok
+}
+
+// dockerClientFor returns the Docker client of a node, or the default client for an empty node name
+func (s *BaseEngine) dockerClientFor(node string) (*client.Client, error) {
+	if node == "" {
+		return s.dockerClient, nil
+	}
+	dockerClient, ok := s.nodeClients[node]
+	if !ok {
+		return nil, fmt.Errorf("unknown node: %s", node)
+	}
+	return dockerClient, nil
+}
+
+// containerAddress returns the address the published ports of containers on a node are reachable at:
+// the host of a TCP daemon, or localhost for the default daemon and local sockets
+func (s *BaseEngine) containerAddress(node string) string {
+	if node == "" {
+		return defaultContainerAddress
+	}
+	u, err := url.Parse(s.config.Docker.Nodes[node].Host)
+	if err != nil || u.Scheme != "tcp" || u.Hostname() == "" {
+		return defaultContainerAddress
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/client"
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// addTestNode registers a named node backed by its own fake Docker API
+func addTestNode(t *testing.T, s *BaseEngine, name string) *fakeDocker {
+	t.Helper()
+	dockerClient, fake := newFakeDockerClient(t)
+	if s.config.Docker.Nodes == nil {
+		s.config.Docker.Nodes = map[string]config.DockerNodeConfig{}
+	}
+	s.config.Docker.Nodes[name] = config.DockerNodeConfig{Host: dockerClient.DaemonHost()}
+	if s.nodeClients == nil {
+		s.nodeClients = map[string]*client.Client{}
+	}
+	s.nodeClients[name] = dockerClient
+	return fake
+}
+
+func TestDeployHandler_PlacesContainersOnNode(t *testing.T) {
+	s, defaultFake := newTestEngineWithBackends(t)
+	nodeFake := addTestNode(t, s, "worker")
+	createBuiltBuild(t, s, "app", "abc123")
+
+	body := `{"app_name":"app","commit_hash":"abc123","replicas":2,"node":"worker"}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	deployment := waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady)
+	if deployment.Node != "worker" {
+		t.Errorf("Expected deployment on node worker, got %q", deployment.Node)
+	}
+	for _, cont := range deployment.Containers {
+		if cont.Node != "worker" {
+			t.Errorf("Expected container %s to record node worker, got %q", cont.ContainerID, cont.Node)
+		}
+	}
+	if nodeFake.createdCount() != 2 {
+		t.Errorf("Expected 2 containers created on the node, got %d", nodeFake.createdCount())
+	}
+	if defaultFake.createdCount() != 0 {
+		t.Errorf("Expected no containers created on the default daemon, got %d", defaultFake.createdCount())
+	}
+	for _, cont := range deployment.Containers {
+		if cont.Address != "127.0.0.1" {
+			t.Errorf("Expected container address 127.0.0.1, got %q", cont.Address)
+		}
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/v1/deployments/app", http.NoBody)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	nodeFake.mu.Lock()
+	nodeRemoved := len(nodeFake.removed)
+	nodeFake.mu.Unlock()
+	defaultFake.mu.Lock()
+	defaultRemoved := len(defaultFake.removed)
+	defaultFake.mu.Unlock()
+	if nodeRemoved != 2 || defaultRemoved != 0 {
+		t.Errorf("Expected 2 containers removed from the node and none from the default daemon, got %d and %d",
+			nodeRemoved, defaultRemoved)
+	}
+}
+
+func TestDeployHandler_RejectsUnknownNode(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+
+	body := `{"app_name":"app","commit_hash":"abc123","replicas":1,"node":"missing"}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "missing") {
+		t.Errorf("Expected error to name the unknown node, got %s", w.Body.String())
+	}
+	if fake.createdCount() != 0 {
+		t.Errorf("Expected no containers to be created, got %d", fake.createdCount())
+	}
+}
+
+func TestContainerAddress(t *testing.T) {
+	s := newTestEngine(t)
+	s.config.Docker.Nodes = map[string]config.DockerNodeConfig{
+		"remote": {Host: "tcp://10.0.0.2:2376"},
+		"socket": {Host: "unix:///var/run/other.sock"},
+	}
+
+	tests := []struct {
+		node string
+		want string
+	}{
+		{"", "localhost"},
+		{"remote", "10.0.0.2"},
+		{"socket", "localhost"},
+	}
+	for _, tt := range tests {
+		if got := s.containerAddress(tt.node); got != tt.want {
+			t.Errorf("containerAddress(%q) = %q, want %q", tt.node, got, tt.want)
+		}
+	}
+}
+
+func TestDockerClientFor(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	addTestNode(t, s, "worker")
+
+	if c, err := s.dockerClientFor(""); err != nil || c != s.dockerClient {
+		t.Errorf("Expected the default client for an empty node, got %v, %v", c, err)
+	}
+	if c, err := s.dockerClientFor("worker"); err != nil || c != s.nodeClients["worker"] {
+		t.Errorf("Expected the worker client, got %v, %v", c, err)
+	}
+	if _, err := s.dockerClientFor("missing"); err == nil {
+		t.Error("Expected an error for an unknown node")
+	}
+}
@@ -0,0 +1,19 @@
+package engine
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec is the OpenAPI 3 description of the Engine API.
+// It is maintained by hand, openapi_test.go checks it against the routes and pkg/types.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// openAPIHandler serves the OpenAPI description of the API
+func (s *BaseEngine) openAPIHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", openAPISpec)
+}
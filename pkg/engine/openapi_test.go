@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// openAPIDocument is the subset of an OpenAPI document checked by the tests
+type openAPIDocument struct {
+	OpenAPI    string                                `json:"openapi"`
+	Info       struct{ Title, Version string }       `json:"info"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+		Responses map[string]json.RawMessage `json:"responses"`
+	} `json:"components"`
+}
+
+func loadOpenAPIDocument(t *testing.T) *openAPIDocument {
+	t.Helper()
+	var doc openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("Failed to parse OpenAPI document: %v", err)
+	}
+	return &doc
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	s := newTestEngine(t)
+
+	req := httptest.NewRequest("GET", "/openapi.json", http.NoBody)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json content type, got %q", ct)
+	}
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to parse OpenAPI document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got version %q", doc.OpenAPI)
+	}
+	if doc.Info.Title == "" || doc.Info.Version == "" {
+		t.Errorf("Expected info title and version, got %+v", doc.Info)
+	}
+}
+
+func TestOpenAPI_DescribesAllRoutes(t *testing.T) {
+	s := newTestEngine(t)
+	doc := loadOpenAPIDocument(t)
+	param := regexp.MustCompile(`:([^/]+)`)
+
+	routes := map[string]bool{}
+	for _, route := range s.router.Routes() {
+		path := param.ReplaceAllString(route.Path, "{$1}")
+		method := strings.ToLower(route.Method)
+		routes[method+" "+path] = true
+		if _, ok := doc.Paths[path][method]; !ok {
+			t.Errorf("Route %s %s is missing from the OpenAPI document", route.Method, path)
+		}
+	}
+
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			if !routes[method+" "+path] {
+				t.Errorf("OpenAPI document describes %s %s, which is not a route", method, path)
+			}
+		}
+	}
+}
+
+func TestOpenAPI_ReferencesResolve(t *testing.T) {
+	doc := loadOpenAPIDocument(t)
+	refs := regexp.MustCompile(`"\$ref":\s*"#/components/(schemas|responses)/([^"]+)"`)
+
+	for _, match := range refs.FindAllStringSubmatch(string(openAPISpec), -1) {
+		var found bool
+		if match[1] == "schemas" {
+			_, found = doc.Components.Schemas[match[2]]
+		} else {
+			_, found = doc.Components.Responses[match[2]]
+		}
+		if !found {
+			t.Errorf("Unresolved reference to %s %s", match[1], match[2])
+		}
+	}
+}
+
+func TestOpenAPI_SchemasMatchTypes(t *testing.T) {
+	doc := loadOpenAPIDocument(t)
+
+	schemas := map[string]interface{}{
+		"DeploymentRequest":      types.DeploymentRequest{},
+		"Deployment":             types.Deployment{},
+		"DeploymentStatusReport": types.DeploymentStatusReport{},
+		"ReplicaFailure":         types.ReplicaFailure{},
+		"Container":              types.Container{},
+		"ContainerDetails":       types.ContainerDetails{},
+		"PortBinding":            types.PortBinding{},
+		"ManagedContainer":       types.ManagedContainer{},
+		"ActivityEvent":          types.ActivityEvent{},
+		"DeploymentImage":        types.DeploymentImage{},
+		"BuildRequest":           types.BuildRequest{},
+		"BuildCallback":          types.BuildCallback{},
+		"DeploymentWebhook":      types.DeploymentWebhook{},
+		"Build":                  types.Build{},
+		"App":                    types.App{},
+		"Stats":                  types.Stats{},
+		"ProvisionRequest":       types.ProvisionRequest{},
+		"LegacyDeployment":       types.LegacyDeployment{},
+	}
+	for name, value := range schemas {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("Schema %s is missing", name)
+			continue
+		}
+
+		want := jsonFieldNames(reflect.TypeOf(value))
+		got := make([]string, 0, len(schema.Properties))
+		for property := range schema.Properties {
+			got = append(got, property)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Schema %s has properties %v, want %v", name, got, want)
+		}
+	}
+}
+
+// jsonFieldNames returns the sorted JSON names of the fields of a struct type
+func jsonFieldNames(typ reflect.Type) []string {
+	names := []string{}
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); field.Anonymous && field.Tag.Get("json") == "" {
+			// The fields of embedded structs are promoted to the embedding struct
+			names = append(names, jsonFieldNames(field.Type)...)
+			continue
+		}
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// ensureImage makes the image of a deployment available to the Docker daemon of its node
+// according to the pull policy of the request, returning the inspected image
+func (s *BaseEngine) ensureImage(ctx context.Context, req *types.DeploymentRequest, imageTag string,
+) (image.InspectResponse, error) {
+	dockerClient, err := s.dockerClientFor(req.Node)
+	if err != nil {
+		return image.InspectResponse{}, err
+	}
+
+	policy := req.PullPolicy
+	if policy == "" {
+		policy = types.PullPolicyIfNotPresent
+	}
+
+	if policy != types.PullPolicyAlways {
+		inspect, err := dockerClient.ImageInspect(ctx, imageTag)
+		switch {
+		case err == nil:
+			return inspect, nil
+		case !client.IsErrNotFound(err):
+			return image.InspectResponse{}, fmt.Errorf("failed to inspect image %s: %w", imageTag, err)
+		case policy == types.PullPolicyNever:
+			return image.InspectResponse{}, fmt.Errorf("image %s is not present and the pull policy is %s", imageTag, policy)
+		}
+	}
+
+	s.logger.Info("Pulling image", "app_name", req.AppName, "image_tag", imageTag, "node", req.Node, "pull_policy", policy)
+	if err := pullImage(ctx, dockerClient, imageTag); err != nil {
+		return image.InspectResponse{}, fmt.Errorf("failed to pull image %s: %w", imageTag, err)
+	}
+	s.logger.Info("Image pulled", "app_name", req.AppName, "image_tag", imageTag)
+
+	inspect, err := dockerClient.ImageInspect(ctx, imageTag)
+	if err != nil {
+		return image.InspectResponse{}, fmt.Errorf("failed to inspect image %s: %w", imageTag, err)
+	}
+	return inspect, nil
+}
+
+// pullImage pulls an image and waits for the pull to finish, returning the errors reported in the pull stream
+func pullImage(ctx context.Context, dockerClient *client.Client, imageTag string) error {
+	stream, err := dockerClient.ImagePull(ctx, imageTag, image.PullOptions{})
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer stream.Close() //nolint:errcheck
+
+	return jsonmessage.DisplayJSONMessagesStream(stream, io.Discard, 0, false, nil) //nolint:wrapcheck
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestDeployHandler_PullPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       types.PullPolicy
+		missing      bool
+		expectPulled []string
+		expectStatus types.DeploymentStatus
+	}{
+		{"default present", "", false, nil, types.DeploymentStatusReady},
+		{"default missing", "", true, []string{"nina-app-abc123:latest"}, types.DeploymentStatusReady},
+		{"if-not-present present", types.PullPolicyIfNotPresent, false, nil, types.DeploymentStatusReady},
+		{"always present", types.PullPolicyAlways, false, []string{"nina-app-abc123:latest"}, types.DeploymentStatusReady},
+		{"never present", types.PullPolicyNever, false, nil, types.DeploymentStatusReady},
+		{"never missing", types.PullPolicyNever, true, nil, types.DeploymentStatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fake := newTestEngineWithBackends(t)
+			if tt.missing {
+				fake.missingImages = map[string]bool{"nina-app-abc123": true}
+			}
+			createBuiltBuild(t, s, "app", "abc123")
+
+			body := `{"app_name":"app","commit_hash":"abc123","replicas":1,"pull_policy":"` + string(tt.policy) + `"}`
+			req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+			}
+
+			waitForDeploymentStatus(t, s, "app", tt.expectStatus)
+			if pulled := fake.pulledImages(); strings.Join(pulled, ",") != strings.Join(tt.expectPulled, ",") {
+				t.Errorf("Expected pulled images %v, got %v", tt.expectPulled, pulled)
+			}
+			if tt.expectStatus == types.DeploymentStatusFailed && fake.createdCount() != 0 {
+				t.Errorf("Expected no containers to be created, got %d", fake.createdCount())
+			}
+		})
+	}
+}
+
+func TestDeployHandler_InvalidPullPolicy(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+
+	body := `{"app_name":"app","commit_hash":"abc123","replicas":1,"pull_policy":"sometimes"}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
+
+func TestDeployHandler_Image(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	fake.missingImages = map[string]bool{"registry.example.com/team/app:1.2": true}
+
+	// No build exists, the image is pulled and run as is
+	body := `{"app_name":"app","image":"registry.example.com/team/app:1.2","replicas":2,"port":9000}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	deployment := waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady)
+	if deployment.Image != "registry.example.com/team/app:1.2" || deployment.CommitHash != "" {
+		t.Errorf("Expected a deployment of the image without a commit, got %+v", deployment)
+	}
+	if pulled := fake.pulledImages(); len(pulled) != 1 || pulled[0] != "registry.example.com/team/app:1.2" {
+		t.Errorf("Expected the image to be pulled, got %v", pulled)
+	}
+	for _, container := range deployment.Containers {
+		if container.ImageTag != "registry.example.com/team/app:1.2" {
+			t.Errorf("Expected containers of the image, got %s", container.ImageTag)
+		}
+	}
+	if ports := fake.containerPorts(); len(ports) != 2 || ports[0] != "9000/tcp" {
+		t.Errorf("Expected 2 containers on port 9000, got %v", ports)
+	}
+}
+
+func TestDeployHandler_ImageExposedPorts(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	fake.exposedPorts = map[string][]string{"registry.example.com/team/app:1.2": {"3000/tcp"}}
+
+	// Without a port in the request, the containers listen on the port the image exposes
+	body := `{"app_name":"app","image":"registry.example.com/team/app:1.2","replicas":1}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	waitForDeploymentStatus(t, s, "app", types.DeploymentStatusReady)
+	if ports := fake.containerPorts(); len(ports) != 1 || ports[0] != "3000/tcp" {
+		t.Errorf("Expected a container on port 3000, got %v", ports)
+	}
+}
+
+func TestDeployHandler_InvalidImage(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+
+	body := `{"app_name":"app","image":"Registry/App:latest","replicas":1}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "not a valid image reference") {
+		t.Errorf("Expected status code %d with an image error, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
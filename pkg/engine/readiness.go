@@ -0,0 +1,176 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// ReadinessProbeNone disables the readiness probe
+	ReadinessProbeNone = "none"
+	// ReadinessProbeTCP waits until a TCP connection to the container succeeds
+	ReadinessProbeTCP = "tcp"
+	// ReadinessProbeHTTP waits until an HTTP GET to the container returns a non 5xx response
+	ReadinessProbeHTTP = "http"
+
+	// defaultReadinessTimeout is used when no readiness timeout is configured
+	defaultReadinessTimeout = 30 * time.Second
+	// defaultDeployTimeout is used when no deploy timeout is configured
+	defaultDeployTimeout = 5 * time.Minute
+	// defaultStartupGrace is used when no startup grace is configured
+	defaultStartupGrace = 3 * time.Second
+	// readinessPollInterval is the time between two probe attempts
+	readinessPollInterval = 500 * time.Millisecond
+	// readinessAttemptTimeout bounds a single probe attempt
+	readinessAttemptTimeout = 2 * time.Second
+)
+
+// waitForReady blocks until the container listening on host:port passes the configured readiness probe.
+// Without a probe it waits until the container has kept running for the startup grace.
+func (s *BaseEngine) waitForReady(ctx context.Context, dockerClient *client.Client, containerID, host string, port int) error {
+	probe := strings.ToLower(s.config.Engine.ReadinessProbe)
+	if probe == "" || probe == ReadinessProbeNone {
+		return s.waitForRunning(ctx, dockerClient, containerID)
+	}
+
+	timeout := defaultReadinessTimeout
+	if s.config.Engine.ReadinessTimeout > 0 {
+		timeout = time.Duration(s.config.Engine.ReadinessTimeout) * time.Second
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	s.logger.Info("Waiting for container to become ready", "addr", addr, "probe", probe, "timeout", timeout)
+
+	return waitForProbe(ctx, probe, addr, s.config.Engine.ReadinessPath, timeout)
+}
+
+// waitForRunning blocks until the container has been running for the startup grace, failing as soon as it
+// exits or restarts
+func (s *BaseEngine) waitForRunning(ctx context.Context, dockerClient *client.Client, containerID string) error {
+	grace := configuredTimeout(s.config.Engine.StartupGrace, defaultStartupGrace)
+	if grace == 0 {
+		return nil
+	}
+	deadline := time.Now().Add(grace)
+
+	ticker := time.NewTicker(readinessPollInterval)
+	defer ticker.Stop()
+
+	for {
+		info, err := dockerClient.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return fmt.Errorf("failed to inspect container: %w", err)
+		}
+		if err := containerStateError(info); err != nil {
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("container did not keep running for %s: %w", grace, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// containerStateError returns why a container is not healthily running, nil when it is
+func containerStateError(info container.InspectResponse) error {
+	state := info.State
+	switch {
+	case state == nil:
+		return nil
+	case state.Restarting || info.RestartCount > 0:
+		return fmt.Errorf("container is restarting, %d restarts", info.RestartCount)
+	case !state.Running:
+		return fmt.Errorf("container exited with code %d", state.ExitCode)
+	}
+	return nil
+}
+
+// deployTimeout returns the time all the replicas of a deployment have to become ready, zero when
+// deployments have no deadline
+func (s *BaseEngine) deployTimeout() time.Duration {
+	return configuredTimeout(s.config.Engine.DeployTimeout, defaultDeployTimeout)
+}
+
+// waitForProbe runs the probe until it succeeds or the timeout expires
+func waitForProbe(ctx context.Context, probe, addr, path string, timeout time.Duration) error {
+	var probeFunc func(context.Context) error
+	switch probe {
+	case ReadinessProbeTCP:
+		probeFunc = func(ctx context.Context) error { return probeTCP(ctx, addr) }
+	case ReadinessProbeHTTP:
+		probeFunc = func(ctx context.Context) error { return probeHTTP(ctx, addr, path) }
+	default:
+		return fmt.Errorf("unknown readiness probe: %s", probe)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(readinessPollInterval)
+	defer ticker.Stop()
+
+	for {
+		attemptCtx, attemptCancel := context.WithTimeout(ctx, readinessAttemptTimeout)
+		err := probeFunc(attemptCtx)
+		attemptCancel()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("readiness probe did not succeed within %s: %w", timeout, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeTCP checks that a TCP connection can be established
+func probeTCP(ctx context.Context, addr string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if err := conn.Close(); err != nil {
+		return fmt.Errorf("failed to close connection to %s: %w", addr, err)
+	}
+	return nil
+}
+
+// probeHTTP checks that an HTTP GET request returns a response that is not a server error
+func probeHTTP(ctx context.Context, addr, path string) error {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	url := fmt.Sprintf("http://%s%s", addr, path)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to %s: %w", url, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status from %s: %d", url, resp.StatusCode)
+	}
+	return nil
+}
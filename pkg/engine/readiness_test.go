@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestWaitForProbeTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+
+	if err := waitForProbe(context.Background(), ReadinessProbeTCP, addr, "", time.Second); err != nil {
+		t.Errorf("Expected TCP probe to succeed, got %v", err)
+	}
+
+	// Once the listener is closed the probe must time out
+	if err := listener.Close(); err != nil {
+		t.Fatalf("Failed to close listener: %v", err)
+	}
+	if err := waitForProbe(context.Background(), ReadinessProbeTCP, addr, "", time.Second); err == nil {
+		t.Error("Expected TCP probe to fail on a closed port, got nil")
+	}
+}
+
+func TestWaitForProbeHTTP(t *testing.T) {
+	var ready atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	if err := waitForProbe(context.Background(), ReadinessProbeHTTP, addr, "healthz", time.Second); err == nil {
+		t.Error("Expected HTTP probe to fail while the server is not ready, got nil")
+	}
+
+	ready.Store(true)
+	if err := waitForProbe(context.Background(), ReadinessProbeHTTP, addr, "/healthz", time.Second); err != nil {
+		t.Errorf("Expected HTTP probe to succeed, got %v", err)
+	}
+}
+
+func TestWaitForProbeUnknown(t *testing.T) {
+	if err := waitForProbe(context.Background(), "grpc", "127.0.0.1:1", "", time.Second); err == nil {
+		t.Error("Expected error for unknown probe, got nil")
+	}
+}
+
+func TestDeployTimeout_ContainersNeverReady(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	// Nothing listens on the ports of the fake containers, so the probe never succeeds
+	s.config.Engine.ReadinessProbe = ReadinessProbeTCP
+	s.config.Engine.ReadinessTimeout = 60
+	s.config.Engine.DeployTimeout = 1
+
+	createBuiltBuild(t, s, "app", "abc123")
+	body := `{"app_name":"app","commit_hash":"abc123","replicas":2}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	deployment := waitForDeploymentStatus(t, s, "app", types.DeploymentStatusFailed)
+	if len(deployment.FailedReplicas) != 2 {
+		t.Fatalf("Expected both replicas to be recorded as failed, got %+v", deployment.FailedReplicas)
+	}
+	for _, failure := range deployment.FailedReplicas {
+		if !strings.Contains(failure.Error, "deployment did not become ready within 1s") {
+			t.Errorf("Expected replica %d to fail on the deploy timeout, got %q", failure.Replica, failure.Error)
+		}
+	}
+	if live := fake.liveContainers(); len(live) != 0 {
+		t.Errorf("Expected the containers to be removed, got %v", live)
+	}
+}
+
+func TestDeployTimeout_ContainersExitWithoutProbe(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	// No readiness probe is configured, the containers exit right after they start
+	s.config.Engine.StartupGrace = 1
+	fake.exited = map[string]int{"container1": 1, "container2": 1}
+
+	createBuiltBuild(t, s, "app", "abc123")
+	body := `{"app_name":"app","commit_hash":"abc123","replicas":2}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	deployment := waitForDeploymentStatus(t, s, "app", types.DeploymentStatusFailed)
+	if len(deployment.FailedReplicas) != 2 {
+		t.Fatalf("Expected both replicas to be recorded as failed, got %+v", deployment.FailedReplicas)
+	}
+	for _, failure := range deployment.FailedReplicas {
+		if !strings.Contains(failure.Error, "container exited with code 1") {
+			t.Errorf("Expected replica %d to fail on the container exit, got %q", failure.Replica, failure.Error)
+		}
+	}
+	if live := fake.liveContainers(); len(live) != 0 {
+		t.Errorf("Expected the containers to be removed, got %v", live)
+	}
+}
+
+func TestWaitForRunning(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	s.config.Engine.StartupGrace = 1
+	fake.ports["container1"], fake.ports["container2"] = "8080/tcp", "8080/tcp"
+	fake.exited = map[string]int{"container2": 137}
+
+	start := time.Now()
+	if err := s.waitForRunning(context.Background(), s.dockerClient, "container1"); err != nil {
+		t.Errorf("Expected a running container to pass, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected the container to be watched for the startup grace, returned after %s", elapsed)
+	}
+
+	err := s.waitForRunning(context.Background(), s.dockerClient, "container2")
+	if err == nil || !strings.Contains(err.Error(), "exited with code 137") {
+		t.Errorf("Expected the exit to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/gin-gonic/gin"
+)
+
+// statsHandler returns aggregate counts of the apps, deployments and builds, along with the number
+// of running app containers. The container count is omitted when a Docker daemon cannot be queried.
+func (s *BaseEngine) statsHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	stats, err := s.store.Stats(ctx, !s.legacyDeploymentsDisabled())
+	if err != nil {
+		s.logger.Error("Failed to compute stats", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to compute stats",
+		})
+		return
+	}
+
+	running, err := s.runningContainers(ctx)
+	if err != nil {
+		s.logger.Warn("Failed to count running containers", "error", err)
+	} else {
+		stats.RunningContainers = &running
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// runningContainers counts the running containers labeled with an app name on the default daemon and the nodes
+func (s *BaseEngine) runningContainers(ctx context.Context) (int, error) {
+	if s.dockerClient == nil {
+		return 0, fmt.Errorf("docker client is not initialized")
+	}
+	opts := container.ListOptions{Filters: filters.NewArgs(filters.Arg("label", AppLabel))}
+
+	containers, err := s.dockerClient.ContainerList(ctx, opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list containers: %w", err)
+	}
+	running := len(containers)
+	for node, dockerClient := range s.nodeClients {
+		containers, err := dockerClient.ContainerList(ctx, opts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list containers on node %s: %w", node, err)
+		}
+		running += len(containers)
+	}
+	return running, nil
+}
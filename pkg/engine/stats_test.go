@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// getStats requests the engine stats
+func getStats(t *testing.T, s *BaseEngine) *types.Stats {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/stats", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var stats types.Stats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to unmarshal stats: %v", err)
+	}
+	return &stats
+}
+
+func TestStatsHandler(t *testing.T) {
+	s, fake := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app-a", "aaa111")
+	createBuiltBuild(t, s, "app-b", "bbb222")
+	createBuiltBuild(t, s, "app-b", "ccc333")
+	postDeploy(t, s, "app-a", "aaa111")
+	waitForDeploymentStatus(t, s, "app-a", types.DeploymentStatusReady)
+
+	// Containers without the app label are not counted
+	fake.mu.Lock()
+	fake.started = append(fake.started, "unmanaged")
+	fake.labels["unmanaged"] = map[string]string{}
+	fake.mu.Unlock()
+
+	stats := getStats(t, s)
+	if stats.Apps != 2 || stats.Builds != 3 || stats.Deployments != 1 {
+		t.Errorf("Expected 2 apps, 3 builds and 1 deployment, got %+v", stats)
+	}
+	if stats.BuildsByStatus[types.BuildStatusBuilt] != 3 {
+		t.Errorf("Expected 3 built builds, got %v", stats.BuildsByStatus)
+	}
+	if stats.DeploymentsByStatus[types.DeploymentStatusReady] != 1 {
+		t.Errorf("Expected 1 ready deployment, got %v", stats.DeploymentsByStatus)
+	}
+	// The seeded builds share their image
+	if stats.ImageBytes != 1024 {
+		t.Errorf("Expected 1024 image bytes, got %d", stats.ImageBytes)
+	}
+	if stats.RunningContainers == nil || *stats.RunningContainers != 1 {
+		t.Errorf("Expected 1 running container, got %v", stats.RunningContainers)
+	}
+}
+
+func TestStatsHandler_DockerUnavailable(t *testing.T) {
+	s := newTestEngine(t)
+	s.store = newTestStore(t, s.logger)
+	createBuiltBuild(t, s, "app", "aaa111")
+
+	stats := getStats(t, s)
+	if stats.Apps != 1 || stats.Builds != 1 {
+		t.Errorf("Expected 1 app and 1 build, got %+v", stats)
+	}
+	if stats.RunningContainers != nil {
+		t.Errorf("Expected running containers to be omitted, got %d", *stats.RunningContainers)
+	}
+}
@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// defaultStopTimeout is the time a container has to exit after SIGTERM when engine.stop_timeout is not configured
+const defaultStopTimeout = 10 * time.Second
+
+// stopTimeout returns the time a container has to exit after SIGTERM before it is killed, zero when
+// containers are removed without a graceful stop
+func (s *BaseEngine) stopTimeout() time.Duration {
+	if s.config == nil {
+		return defaultStopTimeout
+	}
+	return configuredTimeout(s.config.Engine.StopTimeout, defaultStopTimeout)
+}
+
+// stopContainers gracefully stops the containers of a deployment before they are removed, giving each the
+// stop timeout to finish in-flight work. The containers are stopped concurrently so that removing a deployment
+// takes at most one stop timeout. Failures are only logged, the containers are force removed afterwards anyway.
+func (s *BaseEngine) stopContainers(ctx context.Context, dockerClient *client.Client, deployment *types.Deployment) {
+	timeout := s.stopTimeout()
+	if timeout == 0 {
+		return
+	}
+	seconds := int(timeout / time.Second)
+
+	var wg sync.WaitGroup
+	for _, cont := range deployment.Containers {
+		if cont.ContainerID == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(containerID string) {
+			defer wg.Done()
+			s.logger.Info("Stopping container", "container_id", containerID, "app_name", deployment.AppName, "timeout", timeout)
+			if err := dockerClient.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &seconds}); err != nil {
+				s.logger.Warn("Failed to stop container, removing it anyway", "container_id", containerID, "error", err)
+			}
+		}(cont.ContainerID)
+	}
+	wg.Wait()
+}
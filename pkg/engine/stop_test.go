@@ -0,0 +1,69 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestDeleteDeploymentHandler_StopsBeforeRemoving(t *testing.T) {
+	tests := []struct {
+		name        string
+		stopTimeout int
+		expected    []string
+	}{
+		{"configured timeout", 3, []string{"stop c1 t=3", "stop c2 t=3", "remove c1", "remove c2"}},
+		{"default timeout", 0, []string{"stop c1 t=10", "stop c2 t=10", "remove c1", "remove c2"}},
+		{"disabled", -1, []string{"remove c1", "remove c2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fake := newTestEngineWithBackends(t)
+			s.config.Engine.StopTimeout = tt.stopTimeout
+			ctx := context.Background()
+
+			deployment, err := s.store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: "app", CommitHash: "abc123"})
+			if err != nil {
+				t.Fatalf("Failed to create deployment: %v", err)
+			}
+			containers := []types.Container{{ContainerID: "c1", Port: 8080}, {ContainerID: "c2", Port: 8080}}
+			if err := s.store.UpdateNewDeploymentWithContainers(ctx, "app", deployment.ID, containers, types.DeploymentStatusReady); err != nil {
+				t.Fatalf("Failed to update deployment: %v", err)
+			}
+
+			req := httptest.NewRequest("DELETE", "/api/v1/deployments/app", http.NoBody)
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+
+			// Containers are stopped concurrently, so only the stops as a whole are ordered before the removals
+			calls := fake.stopAndRemoveCalls()
+			stops := 0
+			for stops < len(calls) && strings.HasPrefix(calls[stops], "stop ") {
+				stops++
+			}
+			sort.Strings(calls[:stops])
+			if strings.Join(calls, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected calls %v, got %v", tt.expected, calls)
+			}
+		})
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	s := newTestEngine(t)
+	for seconds, expected := range map[int]time.Duration{0: defaultStopTimeout, 5: 5 * time.Second, -1: 0} {
+		s.config.Engine.StopTimeout = seconds
+		if got := s.stopTimeout(); got != expected {
+			t.Errorf("stopTimeout() with stop_timeout %d = %s, want %s", seconds, got, expected)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultRequestTimeout bounds regular API requests when no timeout is configured
+	defaultRequestTimeout = 30 * time.Second
+	// defaultLongRequestTimeout bounds build and deploy requests when no timeout is configured
+	defaultLongRequestTimeout = 10 * time.Minute
+)
+
+// timeoutWriter buffers the response so it can be replaced by a 504 when the deadline is exceeded
+type timeoutWriter struct {
+	gin.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+// WriteHeader records the status code without sending it
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+// WriteHeaderNow is a no-op, the header is sent when the buffered response is flushed
+func (w *timeoutWriter) WriteHeaderNow() {}
+
+// Write buffers the response body
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data) //nolint:wrapcheck
+}
+
+// WriteString buffers the response body
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s) //nolint:wrapcheck
+}
+
+// Status returns the buffered status code
+func (w *timeoutWriter) Status() int {
+	return w.status
+}
+
+// Size returns the size of the buffered body
+func (w *timeoutWriter) Size() int {
+	return w.body.Len()
+}
+
+// Written reports whether the handler produced a response
+func (w *timeoutWriter) Written() bool {
+	return w.body.Len() > 0 || w.status != 0
+}
+
+// timeoutMiddleware sets a deadline on the request context and answers 504 when a handler runs past it.
+// Handlers are expected to honor the request context; their response is discarded once the deadline passes.
+func timeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		original := c.Writer
+		tw := &timeoutWriter{ResponseWriter: original}
+		c.Writer = tw
+
+		c.Next()
+
+		c.Writer = original
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{
+				"error": "Request timed out",
+			})
+			return
+		}
+
+		status := tw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		original.WriteHeader(status)
+		if tw.body.Len() > 0 {
+			_, _ = original.Write(tw.body.Bytes())
+		} else {
+			original.WriteHeaderNow()
+		}
+	}
+}
+
+// requestTimeout returns the deadline applied to regular API requests
+func (s *BaseEngine) requestTimeout() time.Duration {
+	return configuredTimeout(s.config.Engine.RequestTimeout, defaultRequestTimeout)
+}
+
+// longRequestTimeout returns the deadline applied to build and deploy requests
+func (s *BaseEngine) longRequestTimeout() time.Duration {
+	return configuredTimeout(s.config.Engine.LongRequestTimeout, defaultLongRequestTimeout)
+}
+
+// configuredTimeout converts a timeout in seconds from the configuration.
+// Zero selects the default and a negative value disables the timeout.
+func configuredTimeout(seconds int, fallback time.Duration) time.Duration {
+	switch {
+	case seconds < 0:
+		return 0
+	case seconds == 0:
+		return fallback
+	default:
+		return time.Duration(seconds) * time.Second
+	}
+}
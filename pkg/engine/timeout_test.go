@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTimeoutRouter(timeout time.Duration, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", timeoutMiddleware(timeout), handler)
+	return router
+}
+
+func TestTimeoutMiddleware_SlowHandler(t *testing.T) {
+	router := newTimeoutRouter(20*time.Millisecond, func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			// A slow backend call returns an error once the deadline passes
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "backend failed"})
+		case <-time.After(5 * time.Second):
+			c.JSON(http.StatusOK, gin.H{"status": "done"})
+		}
+	})
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", http.NoBody))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status code %d, got %d", http.StatusGatewayTimeout, w.Code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected request to be cut off, took %s", elapsed)
+	}
+}
+
+func TestTimeoutMiddleware_FastHandler(t *testing.T) {
+	router := newTimeoutRouter(time.Second, func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"status": "created"})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", http.NoBody))
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected status code %d, got %d", http.StatusCreated, w.Code)
+	}
+	if w.Body.String() != `{"status":"created"}` {
+		t.Errorf("Unexpected body: %s", w.Body.String())
+	}
+	if w.Header().Get("Content-Type") == "" {
+		t.Error("Expected Content-Type header to be kept")
+	}
+}
+
+func TestConfiguredTimeout(t *testing.T) {
+	if got := configuredTimeout(0, time.Minute); got != time.Minute {
+		t.Errorf("Expected default timeout, got %s", got)
+	}
+	if got := configuredTimeout(5, time.Minute); got != 5*time.Second {
+		t.Errorf("Expected 5s timeout, got %s", got)
+	}
+	if got := configuredTimeout(-1, time.Minute); got != 0 {
+		t.Errorf("Expected disabled timeout, got %s", got)
+	}
+}
@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// maxBuildMetadataSize limits the size of the JSON metadata part of a multipart build request
+const maxBuildMetadataSize = 1 << 20
+
+// errBundleTooLarge is returned when an uploaded bundle exceeds the configured maximum size
+var errBundleTooLarge = errors.New("bundle too large")
+
+// maxBuildRequestSize returns the largest build request body that can hold a bundle of maxBundleSize bytes,
+// leaving room for the base64 encoding of JSON requests and for the metadata
+func maxBuildRequestSize(maxBundleSize int64) int64 {
+	return maxBundleSize/3*4 + 4 + maxBuildMetadataSize
+}
+
+// isBundleTooLarge reports whether err was caused by a bundle or request body over the size limit
+func isBundleTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.Is(err, errBundleTooLarge) || errors.As(err, &maxBytesErr)
+}
+
+// maxBundleSize returns the largest bundle accepted by the build endpoint, zero meaning no limit
+func (s *BaseEngine) maxBundleSize() int64 {
+	switch limit := s.config.Engine.MaxBundleSize; {
+	case limit < 0:
+		return 0
+	case limit == 0:
+		return types.DefaultMaxBundleSize
+	default:
+		return limit
+	}
+}
+
+// bindBuildRequest reads a build request from either a JSON or a multipart/form-data body.
+// The returned cleanup function removes any bundle file streamed to disk and must always be called.
+// A streamed bundle is saved inside tempDir. Bundles larger than maxBundleSize bytes are rejected,
+// from the Content-Length when possible, before the body is read; zero disables the limit.
+func bindBuildRequest(c *gin.Context, tempDir string, maxBundleSize int64) (*types.BuildRequest, func(), error) {
+	if maxBundleSize > 0 {
+		maxRequestSize := maxBuildRequestSize(maxBundleSize)
+		if c.Request.ContentLength > maxRequestSize {
+			return nil, func() {}, fmt.Errorf("%w: request body of %d bytes exceeds the limit of %d bytes",
+				errBundleTooLarge, c.Request.ContentLength, maxRequestSize)
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestSize)
+	}
+
+	var req types.BuildRequest
+	mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, func() {}, fmt.Errorf("failed to decode JSON body: %w", err)
+		}
+		if size := int64(base64.StdEncoding.DecodedLen(len(req.BundleContents))); maxBundleSize > 0 && size > maxBundleSize {
+			return nil, func() {}, fmt.Errorf("%w: bundle of %d bytes exceeds the limit of %d bytes",
+				errBundleTooLarge, size, maxBundleSize)
+		}
+		return &req, func() {}, nil
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("failed to read multipart body: %w", err)
+	}
+	cleanup := func() {
+		if req.BundlePath != "" {
+			_ = os.Remove(req.BundlePath)
+		}
+	}
+	if err := readBuildParts(reader, &req, tempDir, maxBundleSize); err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+	return &req, cleanup, nil
+}
+
+// readBuildParts decodes the metadata part and streams the bundle part of a multipart build request to disk
+func readBuildParts(reader *multipart.Reader, req *types.BuildRequest, tempDir string, maxBundleSize int64) error {
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read multipart part: %w", err)
+		}
+
+		switch part.FormName() {
+		case types.BuildFormMetadata:
+			bundlePath := req.BundlePath
+			if err := json.NewDecoder(io.LimitReader(part, maxBuildMetadataSize)).Decode(req); err != nil {
+				return fmt.Errorf("failed to decode build metadata: %w", err)
+			}
+			req.BundlePath = bundlePath
+		case types.BuildFormBundle:
+			if req.BundlePath != "" {
+				return errors.New("multiple bundle parts in request")
+			}
+			if req.BundlePath, err = saveBundlePart(part, tempDir, maxBundleSize); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// saveBundlePart streams a bundle part into a temporary file inside tempDir and returns its path.
+// Bundles larger than maxBundleSize bytes are rejected, zero disables the limit.
+func saveBundlePart(part io.Reader, tempDir string, maxBundleSize int64) (string, error) {
+	file, err := os.CreateTemp(tempDir, "nina-upload-*.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("failed to create bundle file: %w", err)
+	}
+	if maxBundleSize > 0 {
+		part = io.LimitReader(part, maxBundleSize+1)
+	}
+	written, err := io.Copy(file, part)
+	if err == nil && maxBundleSize > 0 && written > maxBundleSize {
+		err = fmt.Errorf("%w: bundle exceeds the limit of %d bytes", errBundleTooLarge, maxBundleSize)
+	}
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return "", fmt.Errorf("failed to save bundle: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return "", fmt.Errorf("failed to save bundle: %w", err)
+	}
+	return file.Name(), nil
+}
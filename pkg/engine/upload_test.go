@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// newMultipartBuildRequest builds a multipart build request with the given metadata and bundle contents
+func newMultipartBuildRequest(t *testing.T, metadata *types.BuildRequest, bundle []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	metadataPart, err := writer.CreateFormField(types.BuildFormMetadata)
+	if err != nil {
+		t.Fatalf("Failed to create metadata part: %v", err)
+	}
+	if err := json.NewEncoder(metadataPart).Encode(metadata); err != nil {
+		t.Fatalf("Failed to encode metadata: %v", err)
+	}
+	if bundle != nil {
+		bundlePart, err := writer.CreateFormFile(types.BuildFormBundle, "bundle.tar.gz")
+		if err != nil {
+			t.Fatalf("Failed to create bundle part: %v", err)
+		}
+		if _, err := bundlePart.Write(bundle); err != nil {
+			t.Fatalf("Failed to write bundle: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close multipart writer: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/build", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestBindBuildRequest_Multipart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = newMultipartBuildRequest(t, &types.BuildRequest{AppName: "app", CommitHash: "abc123"}, []byte("bundle-data"))
+
+	req, cleanup, err := bindBuildRequest(c, "", 0)
+	if err != nil {
+		t.Fatalf("Failed to bind multipart request: %v", err)
+	}
+	if req.AppName != "app" || req.CommitHash != "abc123" {
+		t.Errorf("Expected metadata to be decoded, got %+v", req)
+	}
+	contents, err := os.ReadFile(req.BundlePath)
+	if err != nil {
+		t.Fatalf("Expected bundle to be saved to disk: %v", err)
+	}
+	if string(contents) != "bundle-data" {
+		t.Errorf("Expected bundle contents %q, got %q", "bundle-data", contents)
+	}
+
+	cleanup()
+	if _, err := os.Stat(req.BundlePath); !os.IsNotExist(err) {
+		t.Errorf("Expected bundle file to be removed by cleanup, got %v", err)
+	}
+}
+
+func TestBindBuildRequest_JSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/api/v1/build",
+		strings.NewReader(`{"app_name":"app","commit_hash":"abc123","bundle_content":"Zm9v"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	req, cleanup, err := bindBuildRequest(c, "", 0)
+	defer cleanup()
+	if err != nil {
+		t.Fatalf("Failed to bind JSON request: %v", err)
+	}
+	if req.BundleContents != "Zm9v" || req.BundlePath != "" {
+		t.Errorf("Expected inline bundle contents, got %+v", req)
+	}
+}
+
+func TestBindBuildRequest_MaxBundleSize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	metadata := &types.BuildRequest{AppName: "app", CommitHash: "abc123"}
+
+	// A streamed bundle over the limit is rejected and not kept on disk
+	tempDir := t.TempDir()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = newMultipartBuildRequest(t, metadata, bytes.Repeat([]byte("x"), 2048))
+	c.Request.ContentLength = -1
+	_, cleanup, err := bindBuildRequest(c, tempDir, 1024)
+	cleanup()
+	if !isBundleTooLarge(err) {
+		t.Fatalf("Expected bundle too large error, got %v", err)
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("Expected the partial upload to be removed, found %d files", len(entries))
+	}
+
+	// A bundle within the limit is accepted
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = newMultipartBuildRequest(t, metadata, bytes.Repeat([]byte("x"), 512))
+	_, cleanup, err = bindBuildRequest(c, tempDir, 1024)
+	cleanup()
+	if err != nil {
+		t.Fatalf("Expected bundle within the limit to be accepted, got %v", err)
+	}
+
+	// An inline bundle over the limit is rejected once decoded
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	body, _ := json.Marshal(map[string]string{
+		"app_name":       "app",
+		"commit_hash":    "abc123",
+		"bundle_content": base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("x"), 2048)),
+	})
+	c.Request = httptest.NewRequest("POST", "/api/v1/build", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	_, cleanup, err = bindBuildRequest(c, tempDir, 1024)
+	cleanup()
+	if !isBundleTooLarge(err) {
+		t.Fatalf("Expected bundle too large error, got %v", err)
+	}
+}
+
+func TestBuildHandler_BundleTooLarge(t *testing.T) {
+	s := newTestEngineWithBuilder(t)
+	s.config.Engine.MaxBundleSize = 1024
+
+	// The Content-Length alone is enough to reject the request
+	req := newMultipartBuildRequest(t, &types.BuildRequest{AppName: "app", CommitHash: "abc123"},
+		bytes.Repeat([]byte("x"), int(maxBuildRequestSize(1024))))
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "bundle too large") {
+		t.Errorf("Expected bundle too large error, got %s", w.Body.String())
+	}
+}
+
+func TestBuildHandler_MultipartValidation(t *testing.T) {
+	s := newTestEngineWithBuilder(t)
+
+	// Without a bundle part the request is rejected
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, newMultipartBuildRequest(t, &types.BuildRequest{AppName: "app", CommitHash: "abc123"}, nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "bundle_content") {
+		t.Errorf("Expected bundle_content validation error, got %s", w.Body.String())
+	}
+
+	// With a bundle part the request passes validation and reaches the build, which fails to extract it
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, newMultipartBuildRequest(t, &types.BuildRequest{AppName: "app", CommitHash: "abc123"}, []byte("data")))
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "failed to extract bundle") {
+		t.Errorf("Expected the bundle extraction to fail, got %d: %s", w.Code, w.Body.String())
+	}
+}
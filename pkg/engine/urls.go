@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// appURLs returns the URLs the ingress serves an app at: its name under the domain suffix, or the bare
+// name without one, its subdomain of every wildcard domain and the domains registered by the deployment.
+// Wildcard domains registered by the deployment match many hosts and are left out.
+func appURLs(cfg *config.IngressConfig, deployment *types.Deployment) []string {
+	hosts := []string{deployment.AppName}
+	if suffix := strings.Trim(cfg.DomainSuffix, "."); suffix != "" {
+		hosts[0] = deployment.AppName + "." + suffix
+	}
+	for _, pattern := range cfg.WildcardDomains {
+		if parent := strings.TrimPrefix(strings.TrimSuffix(pattern, "."), "*."); parent != "" {
+			hosts = append(hosts, deployment.AppName+"."+parent)
+		}
+	}
+	for _, domain := range deployment.Domains {
+		if !strings.HasPrefix(domain, "*.") {
+			hosts = append(hosts, domain)
+		}
+	}
+
+	seen := make(map[string]bool, len(hosts))
+	urls := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		host = strings.ToLower(host)
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+		if cfg.Port != 0 && cfg.Port != 80 {
+			host = net.JoinHostPort(host, strconv.Itoa(cfg.Port))
+		}
+		urls = append(urls, (&url.URL{Scheme: "http", Host: host}).String())
+	}
+	return urls
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestAppURLs(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.IngressConfig
+		domains  []string
+		expected []string
+	}{
+		{"bare app name", config.IngressConfig{Port: 8081}, nil, []string{"http://myapp:8081"}},
+		{"default HTTP port", config.IngressConfig{Port: 80, DomainSuffix: ".nina.local."}, nil, []string{"http://myapp.nina.local"}},
+		{
+			"wildcard and custom domains",
+			config.IngressConfig{Port: 8081, DomainSuffix: "nina.local", WildcardDomains: []string{"*.preview.nina.local"}},
+			[]string{"www.example.com", "*.example.com", "MYAPP.nina.local"},
+			[]string{"http://myapp.nina.local:8081", "http://myapp.preview.nina.local:8081", "http://www.example.com:8081"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls := appURLs(&tt.cfg, &types.Deployment{AppName: "myapp", Domains: tt.domains})
+			if !reflect.DeepEqual(urls, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, urls)
+			}
+		})
+	}
+}
+
+func TestDeployHandler_ReturnsURLs(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	s.config.Ingress = config.IngressConfig{Port: 8081, DomainSuffix: "nina.local"}
+	createBuiltBuild(t, s, "myapp", "abc123")
+
+	body := `{"app_name":"myapp","commit_hash":"abc123","domains":["www.example.com"]}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	var deployment types.Deployment
+	if err := json.Unmarshal(w.Body.Bytes(), &deployment); err != nil {
+		t.Fatalf("Failed to unmarshal deployment: %v", err)
+	}
+	expected := []string{"http://myapp.nina.local:8081", "http://www.example.com:8081"}
+	if !reflect.DeepEqual(deployment.URLs, expected) {
+		t.Errorf("Expected URLs %v, got %v", expected, deployment.URLs)
+	}
+
+	// The URLs are derived from the configuration rather than stored with the deployment
+	waitForDeploymentStatus(t, s, "myapp", types.DeploymentStatusReady)
+	stored, err := s.store.GetNewDeployment(req.Context(), "myapp")
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if stored.URLs != nil {
+		t.Errorf("Expected no stored URLs, got %v", stored.URLs)
+	}
+}
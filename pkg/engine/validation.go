@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// ValidationErrors maps request fields to the reason they were rejected
+type ValidationErrors map[string]string
+
+// Add records a validation error for the given field
+func (v ValidationErrors) Add(field, message string) {
+	v[field] = message
+}
+
+// Err returns the validation errors as an error, or nil when there are none
+func (v ValidationErrors) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// Error implements the error interface, listing the fields in a stable order
+func (v ValidationErrors) Error() string {
+	fields := make([]string, 0, len(v))
+	for field := range v {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, 0, len(fields))
+	for _, field := range fields {
+		messages = append(messages, fmt.Sprintf("%s: %s", field, v[field]))
+	}
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+// normalizeAppName replaces the app name with its sanitized form, recording an error
+// when it is missing or has no characters that can be used in container names and hosts
+func (v ValidationErrors) normalizeAppName(appName *string) {
+	if *appName == "" {
+		v.Add("app_name", "app name is required")
+		return
+	}
+	sanitized := types.SanitizeAppName(*appName)
+	if sanitized == "" {
+		v.Add("app_name", fmt.Sprintf("app name %q has no valid characters", *appName))
+		return
+	}
+	*appName = sanitized
+}
+
+// normalizeDomains lowercases the domains registered by a deployment, recording an error for domains
+// that are not valid hostnames or wildcard patterns such as *.example.com
+func (v ValidationErrors) normalizeDomains(domains []string) {
+	for i, domain := range domains {
+		normalized := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if !validDomain(strings.TrimPrefix(normalized, "*.")) {
+			v.Add("domains", fmt.Sprintf("domain %q is not a valid hostname or *.hostname pattern", domain))
+			return
+		}
+		domains[i] = normalized
+	}
+}
+
+// validDomain reports whether domain is a lowercase hostname made of dot separated labels
+// of letters, digits and inner hyphens
+func validDomain(domain string) bool {
+	if domain == "" || len(domain) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// respondBadRequest writes a 400 response, including field level details for validation errors
+func respondBadRequest(c *gin.Context, err error) {
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  validationErrs.Error(),
+			"fields": validationErrs,
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": err.Error(),
+	})
+}
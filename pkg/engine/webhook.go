@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// deploymentFinished records the outcome of a deployment once it left the deploying status and notifies
+// its webhooks
+func (s *BaseEngine) deploymentFinished(ctx context.Context, deploymentID, appName string) {
+	deployment, err := s.store.GetNewDeployment(ctx, appName)
+	if err != nil {
+		s.logger.Error("Failed to get finished deployment", "app_name", appName, "error", err)
+		return
+	}
+	// The deployment may have been deleted or replaced in the meantime
+	if deployment.ID != deploymentID {
+		return
+	}
+	s.recordDeployment(deployment)
+	s.notifyDeploymentWebhooks(deployment)
+}
+
+// notifyDeploymentWebhooks posts the outcome of a deployment to its webhook URLs. Webhooks share the delivery
+// settings of build callbacks and are sent in the background, failures are only logged.
+func (s *BaseEngine) notifyDeploymentWebhooks(deployment *types.Deployment) {
+	if len(deployment.WebhookURLs) == 0 {
+		return
+	}
+	appName := deployment.AppName
+	body, err := json.Marshal(&types.DeploymentWebhook{
+		DeploymentID:  deployment.ID,
+		AppName:       deployment.AppName,
+		CommitHash:    deployment.CommitHash,
+		Image:         deployment.Image,
+		Status:        deployment.Status,
+		Replicas:      deployment.Replicas,
+		ReadyReplicas: len(deployment.Containers),
+		UpdatedAt:     deployment.UpdatedAt,
+	})
+	if err != nil {
+		s.logger.Error("Failed to encode deployment webhook", "app_name", appName, "error", err)
+		return
+	}
+
+	timeout, retries, secret := s.callbackTimeout(), s.config.Engine.CallbackRetries, s.config.Engine.CallbackSecret
+	for _, webhookURL := range deployment.WebhookURLs {
+		go func() {
+			err := sendCallback(context.Background(), webhookURL, body, secret, timeout, retries, callbackRetryBackoff)
+			if err != nil {
+				s.logger.Error("Deployment webhook failed", "app_name", appName, "webhook_url", webhookURL,
+					"attempts", retries+1, "error", err)
+				return
+			}
+			s.logger.Info("Deployment webhook sent", "app_name", appName, "webhook_url", webhookURL,
+				"status", deployment.Status)
+		}()
+	}
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// newWebhookReceiver starts a server recording the deployment webhooks it receives along with their signature
+func newWebhookReceiver(t *testing.T) (url string, payloads chan types.DeploymentWebhook, signatures chan string) {
+	t.Helper()
+	payloads, signatures = make(chan types.DeploymentWebhook, 1), make(chan string, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload types.DeploymentWebhook
+		_ = json.Unmarshal(body, &payload)
+		signatures <- strings.TrimPrefix(r.Header.Get(CallbackSignatureHeader), "sha256=") + " " + signCallback(body, "s3cret")
+		payloads <- payload
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver.URL, payloads, signatures
+}
+
+// receiveWebhook waits for a deployment webhook and checks its signature
+func receiveWebhook(t *testing.T, payloads chan types.DeploymentWebhook, signatures chan string) types.DeploymentWebhook {
+	t.Helper()
+	select {
+	case signature := <-signatures:
+		if got, expected, _ := strings.Cut(signature, " "); got != expected {
+			t.Errorf("Expected signature %q, got %q", expected, got)
+		}
+		return <-payloads
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the deployment webhook")
+		return types.DeploymentWebhook{}
+	}
+}
+
+func TestDeployHandler_WebhookReady(t *testing.T) {
+	webhookURL, payloads, signatures := newWebhookReceiver(t)
+	s, _ := newTestEngineWithBackends(t)
+	s.config.Engine.CallbackSecret = "s3cret"
+	createBuiltBuild(t, s, "app", "abc123")
+
+	body := `{"app_name":"app","commit_hash":"abc123","replicas":2,"webhook_urls":["` + webhookURL + `"]}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	payload := receiveWebhook(t, payloads, signatures)
+	if payload.AppName != "app" || payload.CommitHash != "abc123" || payload.Status != types.DeploymentStatusReady {
+		t.Errorf("Expected the ready deployment in the webhook, got %+v", payload)
+	}
+	if payload.Replicas != 2 || payload.ReadyReplicas != 2 {
+		t.Errorf("Expected 2 of 2 replicas, got %d of %d", payload.ReadyReplicas, payload.Replicas)
+	}
+}
+
+func TestDeployHandler_WebhookFailed(t *testing.T) {
+	webhookURL, payloads, signatures := newWebhookReceiver(t)
+	s, fake := newTestEngineWithBackends(t)
+	s.config.Engine.CallbackSecret = "s3cret"
+	fake.failStart = map[string]bool{"container1": true, "container2": true}
+	createBuiltBuild(t, s, "app", "abc123")
+
+	body := `{"app_name":"app","commit_hash":"abc123","replicas":2,"webhook_urls":["` + webhookURL + `"]}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	payload := receiveWebhook(t, payloads, signatures)
+	if payload.AppName != "app" || payload.Status != types.DeploymentStatusFailed {
+		t.Errorf("Expected the failed deployment in the webhook, got %+v", payload)
+	}
+	if payload.Replicas != 2 || payload.ReadyReplicas != 0 {
+		t.Errorf("Expected 0 of 2 replicas, got %d of %d", payload.ReadyReplicas, payload.Replicas)
+	}
+}
+
+func TestDeployHandler_InvalidWebhookURL(t *testing.T) {
+	s, _ := newTestEngineWithBackends(t)
+	createBuiltBuild(t, s, "app", "abc123")
+
+	body := `{"app_name":"app","commit_hash":"abc123","replicas":1,"webhook_urls":["ftp://hooks.example.com"]}`
+	req := httptest.NewRequest("POST", "/api/v1/deploy", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "webhook_urls") {
+		t.Errorf("Expected a webhook_urls validation error, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,58 @@
+package ingress
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+const (
+	// LoadBalancingRandom spreads requests randomly over all replicas of an app
+	LoadBalancingRandom = "random"
+	// LoadBalancingPreferLocal spreads requests randomly over the replicas on the node of the ingress,
+	// falling back to all replicas when none of them is local
+	LoadBalancingPreferLocal = "prefer-local"
+)
+
+// loadBalancing returns the configured load balancing strategy, random when none is configured
+func (i *Ingress) loadBalancing() string {
+	strategy := strings.ToLower(i.config.Ingress.LoadBalancing)
+	if strategy == "" {
+		return LoadBalancingRandom
+	}
+	return strategy
+}
+
+// validateLoadBalancing checks that the configured load balancing strategy is known
+func (i *Ingress) validateLoadBalancing() error {
+	switch strategy := i.loadBalancing(); strategy {
+	case LoadBalancingRandom, LoadBalancingPreferLocal:
+		return nil
+	default:
+		return fmt.Errorf("unknown load balancing strategy: %s", strategy)
+	}
+}
+
+// selectReplica selects the replica a request is proxied to using the configured load balancing strategy
+func (i *Ingress) selectReplica(deployment *types.Deployment) *types.Container {
+	if i.loadBalancing() == LoadBalancingPreferLocal {
+		return i.selectLocalReplica(deployment)
+	}
+	return i.selectRandomReplica(deployment)
+}
+
+// selectLocalReplica selects a random replica on the node of the ingress, or a random replica
+// on any node when none of them is local
+func (i *Ingress) selectLocalReplica(deployment *types.Deployment) *types.Container {
+	local := make([]int, 0, len(deployment.Containers))
+	for index := range deployment.Containers {
+		if deployment.Containers[index].Node == i.config.Ingress.Node {
+			local = append(local, index)
+		}
+	}
+	if len(local) == 0 {
+		return i.selectRandomReplica(deployment)
+	}
+	return &deployment.Containers[local[randomIndex(len(local))]]
+}
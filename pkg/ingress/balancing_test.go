@@ -0,0 +1,86 @@
+package ingress
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+	"github.com/matiasinsaurralde/nina/pkg/store"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func newBalancingTestIngress(strategy, node string) *Ingress {
+	cfg := &config.Config{Ingress: config.IngressConfig{LoadBalancing: strategy, Node: node}}
+	return NewIngress(cfg, logger.New(logger.LevelDebug, "text"), &store.Store{})
+}
+
+func TestIngress_SelectReplica_PreferLocal(t *testing.T) {
+	deployment := &types.Deployment{Containers: []types.Container{
+		{ContainerID: "remote1", Node: "worker-2"},
+		{ContainerID: "local1", Node: "worker-1"},
+		{ContainerID: "default", Node: ""},
+		{ContainerID: "local2", Node: "worker-1"},
+	}}
+
+	ingress := newBalancingTestIngress(LoadBalancingPreferLocal, "worker-1")
+	seen := map[string]bool{}
+	for n := 0; n < 200; n++ {
+		seen[ingress.selectReplica(deployment).ContainerID] = true
+	}
+	if len(seen) != 2 || !seen["local1"] || !seen["local2"] {
+		t.Errorf("Expected only the local replicas to be selected, got %v", seen)
+	}
+
+	// Without a configured node, the replicas of the default Docker daemon are local
+	ingress = newBalancingTestIngress(LoadBalancingPreferLocal, "")
+	for n := 0; n < 20; n++ {
+		if id := ingress.selectReplica(deployment).ContainerID; id != "default" {
+			t.Fatalf("Expected the default daemon replica, got %s", id)
+		}
+	}
+}
+
+func TestIngress_SelectReplica_PreferLocalFallsBack(t *testing.T) {
+	deployment := &types.Deployment{Containers: []types.Container{
+		{ContainerID: "remote1", Node: "worker-2"},
+		{ContainerID: "remote2", Node: "worker-3"},
+	}}
+
+	ingress := newBalancingTestIngress(LoadBalancingPreferLocal, "worker-1")
+	seen := map[string]bool{}
+	for n := 0; n < 200; n++ {
+		seen[ingress.selectReplica(deployment).ContainerID] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected all remote replicas to be selected without local ones, got %v", seen)
+	}
+
+	if replica := ingress.selectReplica(&types.Deployment{}); replica != nil {
+		t.Errorf("Expected no replica for a deployment without containers, got %+v", replica)
+	}
+}
+
+func TestIngress_SelectReplica_RandomIgnoresNodes(t *testing.T) {
+	deployment := &types.Deployment{Containers: []types.Container{
+		{ContainerID: "local", Node: "worker-1"},
+		{ContainerID: "remote", Node: "worker-2"},
+	}}
+
+	// The default strategy keeps single node setups unaffected
+	ingress := newBalancingTestIngress("", "worker-1")
+	seen := map[string]bool{}
+	for n := 0; n < 200; n++ {
+		seen[ingress.selectReplica(deployment).ContainerID] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected both replicas to be selected, got %v", seen)
+	}
+}
+
+func TestIngress_Start_UnknownLoadBalancing(t *testing.T) {
+	ingress := newBalancingTestIngress("round-robin", "")
+	if err := ingress.Start(context.Background()); err == nil {
+		t.Error("Expected an error for an unknown load balancing strategy")
+	}
+}
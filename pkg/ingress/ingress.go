@@ -24,6 +24,13 @@ import (
 const (
 	// DefaultDeploymentRefreshInterval is the default interval for refreshing deployments
 	DefaultDeploymentRefreshInterval = 5 * time.Second
+	// DefaultStartupTimeout is how long Start waits for the first successful deployments fetch by default
+	DefaultStartupTimeout = 10 * time.Second
+
+	// ReservedPathPrefix is handled by the ingress itself and never proxied to an application
+	ReservedPathPrefix = "/_nina/"
+	// HealthPath reports the health of the ingress
+	HealthPath = ReservedPathPrefix + "health"
 )
 
 // Ingress represents the reverse proxy ingress
@@ -36,11 +43,19 @@ type Ingress struct {
 	// Global deployments state
 	deployments     []*types.Deployment
 	deploymentsMux  sync.RWMutex
+	lastRefresh     time.Time
+	lastError       string
+	lastErrorAt     time.Time
+	createdAt       time.Time
 	refreshInterval time.Duration
-
-	// Background goroutine control
-	stopChan chan struct{}
-	wg       sync.WaitGroup
+	staleThreshold  time.Duration
+	startupTimeout  time.Duration
+
+	// Background goroutine control. stopChan is created by Start and closed by Stop,
+	// lifecycleMux serializes them so the ingress can be started again after a stop.
+	lifecycleMux sync.Mutex
+	stopChan     chan struct{}
+	wg           sync.WaitGroup
 }
 
 // Route represents a routing rule
@@ -49,6 +64,16 @@ type Route struct {
 	Target string `json:"target"`
 }
 
+// HealthResponse represents the response of the ingress health endpoint
+type HealthResponse struct {
+	Status      string     `json:"status"`
+	Deployments int        `json:"deployments"`
+	LastRefresh *time.Time `json:"last_refresh,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	Stale       bool       `json:"stale"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error   string `json:"error"`
@@ -62,94 +87,229 @@ func NewIngress(cfg *config.Config, log *logger.Logger, st *store.Store) *Ingres
 		refreshInterval = time.Duration(cfg.Ingress.DeploymentRefreshInterval) * time.Second
 	}
 
+	startupTimeout := DefaultStartupTimeout
+	if cfg.Ingress.StartupTimeout != 0 {
+		startupTimeout = time.Duration(cfg.Ingress.StartupTimeout) * time.Second
+	}
+
 	return &Ingress{
 		config:          cfg,
 		logger:          log,
 		store:           st,
+		createdAt:       time.Now().UTC(),
 		refreshInterval: refreshInterval,
-		stopChan:        make(chan struct{}),
+		staleThreshold:  time.Duration(cfg.Ingress.StaleThreshold) * time.Second,
+		startupTimeout:  startupTimeout,
 	}
 }
 
-// Start starts the ingress server
+// Start starts the ingress server and blocks until ctx is cancelled or Stop is called
 func (i *Ingress) Start(ctx context.Context) error {
+	if err := i.validateLoadBalancing(); err != nil {
+		return err
+	}
+
+	i.lifecycleMux.Lock()
+	if i.stopChan != nil {
+		i.lifecycleMux.Unlock()
+		return fmt.Errorf("ingress is already running")
+	}
+	stopChan := make(chan struct{})
+	i.stopChan = stopChan
+
 	// Start the background goroutine for fetching deployments
+	fetched := make(chan struct{})
 	i.wg.Add(1)
-	go i.deploymentFetcher()
+	go i.deploymentFetcher(stopChan, fetched)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", i.handleRequest)
 
-	i.server = &http.Server{
+	server := &http.Server{
 		Addr:              i.config.GetIngressAddr(),
 		Handler:           mux,
 		ReadHeaderTimeout: 5 * time.Second,
 	}
+	i.server = server
+	i.lifecycleMux.Unlock()
+
+	// Requests served before the first fetch would not find any app
+	if !i.waitForFirstFetch(ctx, stopChan, fetched) {
+		return i.Stop(context.Background())
+	}
 
 	i.logger.Info("Starting ingress server", "addr", i.config.GetIngressAddr(), "refresh_interval", i.refreshInterval)
 
 	go func() {
-		if err := i.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			i.logger.Error("Failed to start ingress server", "error", err)
 		}
 	}()
 
-	// Wait for context cancellation
-	<-ctx.Done()
+	// Wait for context cancellation or an explicit Stop
+	select {
+	case <-ctx.Done():
+	case <-stopChan:
+	}
 	return i.Stop(context.Background())
 }
 
-// Stop stops the ingress server
+// Stop stops the ingress server. Stopping an ingress that is not running returns nil,
+// so Stop may be called more than once, before Start, or after Start returned.
 func (i *Ingress) Stop(ctx context.Context) error {
+	i.lifecycleMux.Lock()
+	defer i.lifecycleMux.Unlock()
+
+	if i.stopChan == nil {
+		return nil
+	}
 	i.logger.Info("Stopping ingress server")
 
 	// Stop the background goroutine
 	close(i.stopChan)
+	i.stopChan = nil
 	i.wg.Wait()
 
-	if i.server != nil {
-		return fmt.Errorf("failed to shutdown ingress: %w", i.server.Shutdown(ctx))
+	server := i.server
+	i.server = nil
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to shutdown ingress: %w", err)
 	}
 	return nil
 }
 
-// deploymentFetcher runs in a background goroutine and fetches deployments periodically
-func (i *Ingress) deploymentFetcher() {
+// waitForFirstFetch waits until fetched is closed or the startup timeout expires, in which case the ingress
+// starts with an empty cache. It returns false when ctx is cancelled or stop is closed in the meantime.
+func (i *Ingress) waitForFirstFetch(ctx context.Context, stop, fetched <-chan struct{}) bool {
+	if i.startupTimeout < 0 {
+		return true
+	}
+	timer := time.NewTimer(i.startupTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-fetched:
+	case <-timer.C:
+		i.logger.Warn("Deployments were not fetched before the startup timeout, starting with an empty cache",
+			"timeout", i.startupTimeout)
+	case <-ctx.Done():
+		return false
+	case <-stop:
+		return false
+	}
+	return true
+}
+
+// deploymentFetcher runs in a background goroutine and fetches deployments periodically until stop is closed.
+// fetched is closed after the first successful fetch.
+func (i *Ingress) deploymentFetcher(stop <-chan struct{}, fetched chan<- struct{}) {
 	defer i.wg.Done()
 
 	ticker := time.NewTicker(i.refreshInterval)
 	defer ticker.Stop()
 
+	// Subscribe before the first fetch so that no change is missed in between
+	changes, unsubscribe := i.subscribeDeploymentsChanged()
+	defer unsubscribe()
+
+	fetch := func() {
+		if i.fetchDeployments() && fetched != nil {
+			close(fetched)
+			fetched = nil
+		}
+	}
+
 	// Fetch deployments immediately on startup
-	i.fetchDeployments()
+	fetch()
 
 	for {
 		select {
 		case <-ticker.C:
-			i.fetchDeployments()
-		case <-i.stopChan:
+			fetch()
+		case appName, ok := <-changes:
+			if !ok {
+				// Keep refreshing on the interval only
+				changes = nil
+				continue
+			}
+			i.logger.Debug("Deployment changed, refreshing deployments", "app_name", appName)
+			fetch()
+		case <-stop:
 			i.logger.Info("Stopping deployment fetcher")
 			return
 		}
 	}
 }
 
-// fetchDeployments fetches deployments from the store and updates the global state
-func (i *Ingress) fetchDeployments() {
+// subscribeDeploymentsChanged subscribes to the deployment changes announced by the Engine.
+// Without a subscription the returned channel is nil and deployments are only refreshed on the interval.
+func (i *Ingress) subscribeDeploymentsChanged() (changes <-chan string, unsubscribe func()) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	changes, closeSubscription, err := i.store.SubscribeDeploymentsChanged(ctx)
+	if err != nil {
+		i.logger.Warn("Failed to subscribe to deployment changes, refreshing on the interval only", "error", err)
+		return nil, func() {}
+	}
+	return changes, func() {
+		if err := closeSubscription(); err != nil {
+			i.logger.Warn("Failed to unsubscribe from deployment changes", "error", err)
+		}
+	}
+}
+
+// fetchDeployments fetches deployments from the store and updates the global state, reporting whether it succeeded
+func (i *Ingress) fetchDeployments() bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	deployments, err := i.store.ListNewDeployments(ctx)
+	// Only deployments serving traffic are routable, so the rest are never fetched
+	deployments, err := i.store.ListNewDeploymentsByStatus(ctx, types.DeploymentStatusReady, types.DeploymentStatusPartiallyReady)
 	if err != nil {
 		i.logger.Error("Failed to fetch deployments", "error", err)
-		return
+
+		i.deploymentsMux.Lock()
+		i.lastError = err.Error()
+		i.lastErrorAt = time.Now().UTC()
+		stale, age := i.isStaleLocked()
+		i.deploymentsMux.Unlock()
+
+		if stale {
+			i.logger.Warn("Deployments cache is stale", "age", age, "threshold", i.staleThreshold)
+		}
+		return false
 	}
 
 	i.deploymentsMux.Lock()
 	i.deployments = deployments
+	i.lastRefresh = time.Now().UTC()
+	i.lastError = ""
+	i.lastErrorAt = time.Time{}
 	i.deploymentsMux.Unlock()
 
 	i.logger.Debug("Updated deployments cache", "count", len(deployments))
+	return true
+}
+
+// isStaleLocked reports whether the cache is older than the stale threshold, along with its age.
+// The age is measured from the ingress creation until the first successful refresh.
+// The caller must hold deploymentsMux.
+func (i *Ingress) isStaleLocked() (stale bool, age time.Duration) {
+	since := i.lastRefresh
+	if since.IsZero() {
+		since = i.createdAt
+	}
+	age = time.Since(since)
+	return i.staleThreshold > 0 && age > i.staleThreshold, age
+}
+
+// isStale reports whether the deployments cache is too old to trust
+func (i *Ingress) isStale() bool {
+	i.deploymentsMux.RLock()
+	defer i.deploymentsMux.RUnlock()
+	stale, _ := i.isStaleLocked()
+	return stale
 }
 
 // getDeployments returns a copy of the current deployments
@@ -165,18 +325,29 @@ func (i *Ingress) getDeployments() []*types.Deployment {
 
 // handleRequest handles incoming HTTP requests
 func (i *Ingress) handleRequest(w http.ResponseWriter, r *http.Request) {
+	// Paths under the reserved prefix belong to the ingress, whatever the host
+	if strings.HasPrefix(r.URL.Path, ReservedPathPrefix) {
+		i.handleReserved(w, r)
+		return
+	}
+
+	if i.config.Ingress.RejectWhenStale && i.isStale() {
+		i.handleStaleCache(w)
+		return
+	}
+
 	host := i.extractHost(r)
 	i.logger.Debug("Received request", "host", host, "path", r.URL.Path, "method", r.Method)
 
-	// Find deployment by appName (host)
-	deployment := i.findDeploymentByAppName(host)
+	// Find the deployment the host routes to
+	deployment := i.matchDeployment(host)
 	if deployment == nil {
 		i.handleUnknownApplication(w, host)
 		return
 	}
 
-	// Select a random replica
-	container := i.selectRandomReplica(deployment)
+	// Select a replica according to the load balancing strategy
+	container := i.selectReplica(deployment)
 	if container == nil {
 		i.handleNoReplicasAvailable(w, deployment.AppName)
 		return
@@ -193,6 +364,47 @@ func (i *Ingress) handleRequest(w http.ResponseWriter, r *http.Request) {
 	proxy.ServeHTTP(w, r)
 }
 
+// handleReserved handles requests under the reserved path prefix
+func (i *Ingress) handleReserved(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.URL.Path != HealthPath {
+		w.WriteHeader(http.StatusNotFound)
+		if err := json.NewEncoder(w).Encode(ErrorResponse{Error: "not_found", Message: "not found"}); err != nil {
+			i.logger.Error("Failed to encode error response", "error", err)
+		}
+		return
+	}
+
+	i.deploymentsMux.RLock()
+	stale, _ := i.isStaleLocked()
+	resp := HealthResponse{
+		Status:      "healthy",
+		Deployments: len(i.deployments),
+		LastError:   i.lastError,
+		Stale:       stale,
+	}
+	if !i.lastRefresh.IsZero() {
+		lastRefresh := i.lastRefresh
+		resp.LastRefresh = &lastRefresh
+	}
+	if !i.lastErrorAt.IsZero() {
+		lastErrorAt := i.lastErrorAt
+		resp.LastErrorAt = &lastErrorAt
+	}
+	i.deploymentsMux.RUnlock()
+
+	status := http.StatusOK
+	if stale {
+		resp.Status = "stale"
+		status = http.StatusServiceUnavailable
+	}
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		i.logger.Error("Failed to encode health response", "error", err)
+	}
+}
+
 // extractHost extracts the host from the request
 func (i *Ingress) extractHost(r *http.Request) string {
 	host := r.Host
@@ -207,6 +419,19 @@ func (i *Ingress) extractHost(r *http.Request) string {
 	return host
 }
 
+// appNameFromHost returns the app name a host refers to: the host without the configured domain suffix.
+// Hosts outside the suffix, such as bare app names, are returned unchanged.
+func (i *Ingress) appNameFromHost(host string) string {
+	suffix := strings.ToLower(strings.Trim(i.config.Ingress.DomainSuffix, "."))
+	if suffix == "" {
+		return host
+	}
+	if name, ok := strings.CutSuffix(strings.ToLower(strings.TrimSuffix(host, ".")), "."+suffix); ok {
+		return name
+	}
+	return host
+}
+
 // handleUnknownApplication handles requests for unknown applications
 func (i *Ingress) handleUnknownApplication(w http.ResponseWriter, host string) {
 	i.logger.Warn("Unknown application", "host", host)
@@ -239,6 +464,22 @@ func (i *Ingress) handleNoReplicasAvailable(w http.ResponseWriter, appName strin
 	}
 }
 
+// handleStaleCache handles requests when the deployments cache is too stale to route with
+func (i *Ingress) handleStaleCache(w http.ResponseWriter) {
+	i.logger.Error("Refusing to route with a stale deployments cache")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	errorResp := ErrorResponse{
+		Error:   "stale_routing_cache",
+		Message: "routing cache is stale",
+	}
+
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		i.logger.Error("Failed to encode error response", "error", err)
+	}
+}
+
 // createProxy creates and configures a reverse proxy for the given container
 func (i *Ingress) createProxy(container *types.Container, host string) *httputil.ReverseProxy {
 	// Build target URL
@@ -288,16 +529,30 @@ func (i *Ingress) createProxy(container *types.Container, host string) *httputil
 	return proxy
 }
 
-// findDeploymentByAppName finds a deployment by appName
+// findDeploymentByAppName finds a deployment by appName, comparing sanitized names so
+// that hosts match apps whose names were stored before normalization
 func (i *Ingress) findDeploymentByAppName(appName string) *types.Deployment {
-	deployments := i.getDeployments()
+	return findDeploymentByName(i.getDeployments(), appName)
+}
 
+// findDeploymentByName finds the deployment of an app among deployments, see findDeploymentByAppName
+func findDeploymentByName(deployments []*types.Deployment, appName string) *types.Deployment {
 	for _, deployment := range deployments {
 		if deployment.AppName == appName {
 			return deployment
 		}
 	}
 
+	target := types.SanitizeAppName(appName)
+	if target == "" {
+		return nil
+	}
+	for _, deployment := range deployments {
+		if types.SanitizeAppName(deployment.AppName) == target {
+			return deployment
+		}
+	}
+
 	return nil
 }
 
@@ -307,13 +562,18 @@ func (i *Ingress) selectRandomReplica(deployment *types.Deployment) *types.Conta
 		return nil
 	}
 
+	return &deployment.Containers[randomIndex(len(deployment.Containers))]
+}
+
+// randomIndex returns a random index below n, which must be positive
+func randomIndex(n int) int {
 	// Use crypto/rand for secure random selection
-	randomIndex, err := rand.Int(rand.Reader, big.NewInt(int64(len(deployment.Containers))))
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
 	if err != nil {
-		// Fallback to first container if random generation fails
-		return &deployment.Containers[0]
+		// Fallback to the first index if random generation fails
+		return 0
 	}
-	return &deployment.Containers[randomIndex.Int64()]
+	return int(index.Int64())
 }
 
 // AddRoute adds a new routing rule
@@ -3,15 +3,19 @@ package ingress
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/matiasinsaurralde/nina/pkg/config"
 	"github.com/matiasinsaurralde/nina/pkg/logger"
 	"github.com/matiasinsaurralde/nina/pkg/store"
@@ -137,6 +141,29 @@ func TestIngress_FindDeploymentByAppName(t *testing.T) {
 	}
 }
 
+func TestIngress_FindDeploymentByAppName_Sanitized(t *testing.T) {
+	cfg := &config.Config{Ingress: config.IngressConfig{Host: "localhost", Port: 8081}}
+	log := logger.New(logger.LevelDebug, "text")
+	ingress := NewIngress(cfg, log, &store.Store{})
+
+	// A deployment stored before app names were normalized
+	ingress.deploymentsMux.Lock()
+	ingress.deployments = []*types.Deployment{{ID: "1", AppName: "My_App"}}
+	ingress.deploymentsMux.Unlock()
+
+	for _, host := range []string{"my-app", "MY-APP", "My_App"} {
+		if deployment := ingress.findDeploymentByAppName(host); deployment == nil || deployment.ID != "1" {
+			t.Errorf("Expected host '%s' to match deployment 'My_App', got %v", host, deployment)
+		}
+	}
+
+	for _, host := range []string{"myapp", "", "---"} {
+		if deployment := ingress.findDeploymentByAppName(host); deployment != nil {
+			t.Errorf("Expected no match for host '%s', got %v", host, deployment)
+		}
+	}
+}
+
 func TestIngress_SelectRandomReplica(t *testing.T) {
 	// Create test config
 	cfg := &config.Config{
@@ -400,6 +427,77 @@ func TestIngress_HandleRequest_ValidRouting(t *testing.T) { //nolint: funlen
 	}
 }
 
+func TestIngress_AppNameFromHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		suffix   string
+		host     string
+		expected string
+	}{
+		{"no suffix", "", "myapp", "myapp"},
+		{"no suffix keeps dotted hosts", "", "myapp.nina.local", "myapp.nina.local"},
+		{"suffixed host", "nina.local", "myapp.nina.local", "myapp"},
+		{"bare host", "nina.local", "myapp", "myapp"},
+		{"leading dot in suffix", ".nina.local", "myapp.nina.local", "myapp"},
+		{"case insensitive", "nina.local", "MyApp.Nina.Local", "myapp"},
+		{"fully qualified host", "nina.local", "myapp.nina.local.", "myapp"},
+		{"other domain", "nina.local", "myapp.example.com", "myapp.example.com"},
+		{"suffix without the dot", "nina.local", "myappnina.local", "myappnina.local"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Ingress: config.IngressConfig{DomainSuffix: tt.suffix}}
+			ingress := NewIngress(cfg, logger.New(logger.LevelDebug, "text"), &store.Store{})
+			if got := ingress.appNameFromHost(tt.host); got != tt.expected {
+				t.Errorf("appNameFromHost(%q) = %q, want %q", tt.host, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIngress_HandleRequest_DomainSuffix(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("hello from backend"))
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatalf("invalid backend URL: %v", err)
+	}
+	backendPort, err := strconv.Atoi(backendURL.Port())
+	if err != nil {
+		t.Fatalf("invalid backend port: %v", err)
+	}
+
+	cfg := &config.Config{Ingress: config.IngressConfig{DomainSuffix: "nina.local"}}
+	ingress := NewIngress(cfg, logger.New(logger.LevelDebug, "text"), &store.Store{})
+	ingress.deployments = []*types.Deployment{{
+		ID:         "1",
+		AppName:    testAppName,
+		Containers: []types.Container{{ContainerID: "container1", Address: backendURL.Hostname(), Port: backendPort}},
+	}}
+
+	tests := []struct {
+		host           string
+		expectedStatus int
+	}{
+		{testAppName + ".nina.local", http.StatusOK},
+		{testAppName + ".nina.local:8081", http.StatusOK},
+		{testAppName, http.StatusOK},
+		{"other.nina.local", http.StatusNotFound},
+		{"nina.local", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", http.NoBody)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		ingress.handleRequest(w, req)
+		if w.Code != tt.expectedStatus {
+			t.Errorf("Host %s: expected status %d, got %d: %s", tt.host, tt.expectedStatus, w.Code, w.Body.String())
+		}
+	}
+}
+
 func TestIngress_DeploymentFetcher(t *testing.T) {
 	t.Skip("Skipping deployment fetcher test - requires proper store setup")
 
@@ -426,13 +524,15 @@ func TestIngress_DeploymentFetcher(t *testing.T) {
 	// In a real scenario, the store would be properly initialized with Redis
 
 	// Start the fetcher in a goroutine
-	go ingress.deploymentFetcher()
+	stop := make(chan struct{})
+	ingress.wg.Add(1)
+	go ingress.deploymentFetcher(stop, make(chan struct{}))
 
 	// Wait a bit for the initial fetch
 	time.Sleep(100 * time.Millisecond)
 
 	// Stop the fetcher
-	close(ingress.stopChan)
+	close(stop)
 
 	// Wait for the goroutine to finish
 	ingress.wg.Wait()
@@ -465,4 +565,417 @@ func TestIngress_Stop(t *testing.T) {
 	if err != nil {
 		t.Errorf("Expected no error when stopping without starting, got %v", err)
 	}
+
+	// Stopping again is a no-op (should not panic)
+	if err := ingress.Stop(ctx); err != nil {
+		t.Errorf("Expected no error when stopping twice, got %v", err)
+	}
+}
+
+// newLifecycleTestIngress creates an ingress listening on a random port, backed by an unreachable store
+func newLifecycleTestIngress(t *testing.T) *Ingress {
+	t.Helper()
+	cfg := &config.Config{
+		Ingress: config.IngressConfig{
+			Host:                      "127.0.0.1",
+			Port:                      0,
+			DeploymentRefreshInterval: 1,
+		},
+	}
+	log := logger.New(logger.LevelDebug, "text")
+	return NewIngress(cfg, log, newUnreachableStore(t, log))
+}
+
+// startIngress runs Start in the background until it fetched deployments once and returns its result channel
+func startIngress(ctx context.Context, t *testing.T, ingress *Ingress) <-chan error {
+	t.Helper()
+	ingress.deploymentsMux.Lock()
+	ingress.lastError = ""
+	ingress.deploymentsMux.Unlock()
+
+	done := make(chan error, 1)
+	go func() { done <- ingress.Start(ctx) }()
+
+	// The fetcher of a running ingress records the failure of the unreachable store
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		ingress.deploymentsMux.RLock()
+		fetched := ingress.lastError != ""
+		ingress.deploymentsMux.RUnlock()
+		if fetched {
+			return done
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Deployment fetcher did not run after Start")
+	return nil
+}
+
+// waitForStart waits for Start to return and checks that it shut down cleanly
+func waitForStart(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return")
+	}
+}
+
+func TestIngress_StopAfterStart(t *testing.T) {
+	ingress := newLifecycleTestIngress(t)
+
+	// Start stops the ingress once its context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	done := startIngress(ctx, t, ingress)
+	cancel()
+	waitForStart(t, done)
+
+	// An explicit Stop after shutdown is a no-op (should not panic)
+	if err := ingress.Stop(context.Background()); err != nil {
+		t.Errorf("Expected no error when stopping a stopped ingress, got %v", err)
+	}
+}
+
+func TestIngress_Lifecycle(t *testing.T) {
+	ingress := newLifecycleTestIngress(t)
+
+	// Stopping before starting does not keep a later Start from running
+	if err := ingress.Stop(context.Background()); err != nil {
+		t.Fatalf("Expected no error when stopping without starting, got %v", err)
+	}
+	done := startIngress(context.Background(), t, ingress)
+
+	// A running ingress cannot be started twice
+	if err := ingress.Start(context.Background()); err == nil {
+		t.Error("Expected an error when starting a running ingress")
+	}
+
+	// An explicit Stop makes Start return
+	if err := ingress.Stop(context.Background()); err != nil {
+		t.Errorf("Expected no error when stopping, got %v", err)
+	}
+	waitForStart(t, done)
+
+	// The ingress can be started again after it was stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	done = startIngress(ctx, t, ingress)
+	cancel()
+	waitForStart(t, done)
+}
+
+func TestIngress_HandleRequest_Health(t *testing.T) {
+	cfg := &config.Config{
+		Ingress: config.IngressConfig{
+			Host:                      "localhost",
+			Port:                      8081,
+			DeploymentRefreshInterval: 1,
+		},
+	}
+	log := logger.New(logger.LevelDebug, "text")
+	ingress := NewIngress(cfg, log, &store.Store{})
+
+	// Populate the cache with a deployment that would match any host named _nina
+	refreshed := time.Now().UTC()
+	ingress.deploymentsMux.Lock()
+	ingress.deployments = []*types.Deployment{
+		{AppName: "_nina", Containers: []types.Container{{Address: "localhost", Port: 1}}},
+		{AppName: testAppName},
+	}
+	ingress.lastRefresh = refreshed
+	ingress.deploymentsMux.Unlock()
+
+	req := httptest.NewRequest("GET", HealthPath, http.NoBody)
+	req.Host = "_nina"
+	w := httptest.NewRecorder()
+	ingress.handleRequest(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var health HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if health.Status != "healthy" {
+		t.Errorf("Expected status 'healthy', got '%s'", health.Status)
+	}
+	if health.Deployments != 2 {
+		t.Errorf("Expected 2 cached deployments, got %d", health.Deployments)
+	}
+	if health.LastRefresh == nil || !health.LastRefresh.Equal(refreshed) {
+		t.Errorf("Expected last refresh %v, got %v", refreshed, health.LastRefresh)
+	}
+
+	// Other reserved paths are not proxied either
+	req = httptest.NewRequest("GET", ReservedPathPrefix+"other", http.NoBody)
+	req.Host = testAppName
+	w = httptest.NewRecorder()
+	ingress.handleRequest(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for reserved path, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+// newMiniredisStore returns a store backed by a Miniredis server
+func newMiniredisStore(t *testing.T, log *logger.Logger) (*store.Store, *miniredis.Miniredis) {
+	t.Helper()
+	mockRedis, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start Miniredis: %v", err)
+	}
+	t.Cleanup(mockRedis.Close)
+	cfg := &config.Config{
+		Redis: config.RedisConfig{
+			Host: mockRedis.Host(),
+			Port: mockRedis.Server().Addr().Port,
+		},
+	}
+	st, err := store.NewStore(cfg, log)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := st.Close(); err != nil {
+			t.Logf("Failed to close store: %v", err)
+		}
+	})
+	return st, mockRedis
+}
+
+// newUnreachableStore returns a store whose Redis server has been shut down
+func newUnreachableStore(t *testing.T, log *logger.Logger) *store.Store {
+	t.Helper()
+	st, mockRedis := newMiniredisStore(t, log)
+	mockRedis.Close()
+	return st
+}
+
+func TestIngress_FetchFailureMarksCacheStale(t *testing.T) {
+	cfg := &config.Config{
+		Ingress: config.IngressConfig{
+			Host:                      "localhost",
+			Port:                      8081,
+			DeploymentRefreshInterval: 1,
+			StaleThreshold:            60,
+			RejectWhenStale:           true,
+		},
+	}
+	log := logger.New(logger.LevelDebug, "text")
+	ingress := NewIngress(cfg, log, newUnreachableStore(t, log))
+
+	// The cache was refreshed a while ago and Redis is now down
+	ingress.deploymentsMux.Lock()
+	ingress.deployments = []*types.Deployment{{AppName: testAppName}}
+	ingress.lastRefresh = time.Now().Add(-2 * time.Minute)
+	ingress.deploymentsMux.Unlock()
+
+	ingress.fetchDeployments()
+
+	// The previous cache is kept
+	if deployments := ingress.getDeployments(); len(deployments) != 1 {
+		t.Errorf("Expected cached deployment to be kept, got %d", len(deployments))
+	}
+
+	req := httptest.NewRequest("GET", HealthPath, http.NoBody)
+	w := httptest.NewRecorder()
+	ingress.handleRequest(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	var health HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if !health.Stale || health.Status != "stale" {
+		t.Errorf("Expected stale health, got %+v", health)
+	}
+	if health.LastError == "" || health.LastErrorAt == nil {
+		t.Errorf("Expected last error to be reported, got %+v", health)
+	}
+
+	// Routing is refused while the cache is stale
+	req = httptest.NewRequest("GET", "/", http.NoBody)
+	req.Host = testAppName
+	w = httptest.NewRecorder()
+	ingress.handleRequest(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "stale_routing_cache") {
+		t.Errorf("Expected stale_routing_cache error, got %s", w.Body.String())
+	}
+}
+
+func TestIngress_FetchFailureWithinThreshold(t *testing.T) {
+	cfg := &config.Config{
+		Ingress: config.IngressConfig{
+			Host:                      "localhost",
+			Port:                      8081,
+			DeploymentRefreshInterval: 1,
+			StaleThreshold:            60,
+		},
+	}
+	log := logger.New(logger.LevelDebug, "text")
+	ingress := NewIngress(cfg, log, newUnreachableStore(t, log))
+
+	ingress.fetchDeployments()
+
+	if ingress.isStale() {
+		t.Error("Expected cache within the threshold not to be stale")
+	}
+
+	req := httptest.NewRequest("GET", HealthPath, http.NoBody)
+	w := httptest.NewRecorder()
+	ingress.handleRequest(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, w.Code)
+	}
+	var health HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if health.LastError == "" {
+		t.Error("Expected the fetch error to be reported")
+	}
+}
+
+func TestIngress_RefreshesOnDeploymentChange(t *testing.T) {
+	// The refresh interval is far longer than the test, only the change notification can refresh the cache
+	cfg := &config.Config{
+		Ingress: config.IngressConfig{
+			Host:                      "127.0.0.1",
+			Port:                      0,
+			DeploymentRefreshInterval: 3600,
+		},
+	}
+	log := logger.New(logger.LevelDebug, "text")
+	st, _ := newMiniredisStore(t, log)
+	ingress := NewIngress(cfg, log, st)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- ingress.Start(ctx) }()
+	defer func() {
+		cancel()
+		waitForStart(t, done)
+	}()
+
+	// Wait for the initial fetch, which subscribes first
+	refreshed := func() bool {
+		ingress.deploymentsMux.RLock()
+		defer ingress.deploymentsMux.RUnlock()
+		return !ingress.lastRefresh.IsZero()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !refreshed() {
+		if time.Now().After(deadline) {
+			t.Fatal("Ingress did not fetch deployments on startup")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// What the Engine does once the containers of a deployment are up
+	bg := context.Background()
+	deployment, err := st.CreateNewDeployment(bg, &types.DeploymentRequest{AppName: "app", CommitHash: "abc123"})
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	containers := []types.Container{{ContainerID: "c1", Address: "127.0.0.1", Port: 32768}}
+	if err := st.UpdateNewDeploymentWithContainers(bg, "app", deployment.ID, containers, types.DeploymentStatusReady); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+	published := time.Now()
+	if err := st.PublishDeploymentsChanged(bg, "app"); err != nil {
+		t.Fatalf("Failed to publish deployment change: %v", err)
+	}
+
+	for ingress.findDeploymentByAppName("app") == nil {
+		if time.Since(published) > time.Second {
+			t.Fatal("Ingress did not refresh after the deployment change")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Logf("Ingress refreshed %s after the deployment change", time.Since(published))
+}
+
+func TestIngress_StartWaitsForFirstFetch(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatalf("Invalid backend URL: %v", err)
+	}
+	backendPort, err := strconv.Atoi(backendURL.Port())
+	if err != nil {
+		t.Fatalf("Invalid backend port: %v", err)
+	}
+
+	// Reserve a port for the ingress, it has to be known before Start listens on it
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve a port: %v", err)
+	}
+	ingressPort := listener.Addr().(*net.TCPAddr).Port
+	if err := listener.Close(); err != nil {
+		t.Fatalf("Failed to release the port: %v", err)
+	}
+
+	cfg := &config.Config{
+		Ingress: config.IngressConfig{
+			Host:                      "127.0.0.1",
+			Port:                      ingressPort,
+			DeploymentRefreshInterval: 1,
+		},
+	}
+	log := logger.New(logger.LevelDebug, "text")
+	st, mockRedis := newMiniredisStore(t, log)
+	bg := context.Background()
+	deployment, err := st.CreateNewDeployment(bg, &types.DeploymentRequest{AppName: testAppName, CommitHash: "abc123"})
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	containers := []types.Container{{ContainerID: "c1", Address: backendURL.Hostname(), Port: backendPort}}
+	if err := st.UpdateNewDeploymentWithContainers(bg, testAppName, deployment.ID, containers, types.DeploymentStatusReady); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+
+	// Redis fails until shortly after Start, so the cache cannot be filled right away
+	mockRedis.SetError("LOADING Redis is loading the dataset in memory")
+	time.AfterFunc(200*time.Millisecond, func() { mockRedis.SetError("") })
+
+	ingress := NewIngress(cfg, log, st)
+	ctx, cancel := context.WithCancel(bg)
+	done := make(chan error, 1)
+	go func() { done <- ingress.Start(ctx) }()
+	defer func() {
+		cancel()
+		waitForStart(t, done)
+	}()
+
+	// The very first request the ingress accepts is routed
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("http://127.0.0.1:%d/", ingressPort), http.NoBody)
+		req.Host = testAppName
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Expected the first request to be routed, got status %d", resp.StatusCode)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Ingress did not start listening: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
 }
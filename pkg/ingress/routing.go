@@ -0,0 +1,76 @@
+package ingress
+
+import (
+	"strings"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// matchDeployment finds the deployment a request host routes to. The host is matched, in order, against:
+//
+//  1. app names, e.g. myapp
+//  2. the domains registered by deployments, e.g. www.example.com
+//  3. the wildcard domains registered by deployments, e.g. *.example.com
+//  4. the subdomain of the configured wildcard domains, e.g. myapp-feature for myapp-feature.preview.nina.local
+//  5. the host without the configured domain suffix, e.g. myapp for myapp.nina.local
+//
+// The first step that finds a deployment wins. Wildcards match a single subdomain, so a.b.example.com
+// does not match *.example.com. A domain registered by more than one deployment routes to the app
+// whose name sorts first.
+func (i *Ingress) matchDeployment(host string) *types.Deployment {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return nil
+	}
+	deployments := i.getDeployments()
+
+	if deployment := findDeploymentByName(deployments, host); deployment != nil {
+		return deployment
+	}
+	if deployment := findDeploymentByDomain(deployments, host); deployment != nil {
+		return deployment
+	}
+	subdomain, parent, hasParent := strings.Cut(host, ".")
+	if hasParent {
+		if deployment := findDeploymentByDomain(deployments, "*."+parent); deployment != nil {
+			return deployment
+		}
+		if i.isWildcardDomain(parent) {
+			if deployment := findDeploymentByName(deployments, subdomain); deployment != nil {
+				return deployment
+			}
+		}
+	}
+	if name := i.appNameFromHost(host); name != host {
+		return findDeploymentByName(deployments, name)
+	}
+	return nil
+}
+
+// findDeploymentByDomain returns the deployment that registered domain, preferring the app name that sorts first
+func findDeploymentByDomain(deployments []*types.Deployment, domain string) *types.Deployment {
+	var match *types.Deployment
+	for _, deployment := range deployments {
+		if match != nil && deployment.AppName >= match.AppName {
+			continue
+		}
+		for _, registered := range deployment.Domains {
+			if registered == domain {
+				match = deployment
+				break
+			}
+		}
+	}
+	return match
+}
+
+// isWildcardDomain reports whether *.parent is one of the configured wildcard domains;
+// the leading "*." of the configured patterns is optional
+func (i *Ingress) isWildcardDomain(parent string) bool {
+	for _, pattern := range i.config.Ingress.WildcardDomains {
+		if strings.TrimPrefix(strings.ToLower(strings.TrimSuffix(pattern, ".")), "*.") == parent {
+			return true
+		}
+	}
+	return false
+}
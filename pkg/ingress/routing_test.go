@@ -0,0 +1,63 @@
+package ingress
+
+import (
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/config"
+	"github.com/matiasinsaurralde/nina/pkg/logger"
+	"github.com/matiasinsaurralde/nina/pkg/store"
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestIngress_MatchDeployment(t *testing.T) {
+	cfg := &config.Config{Ingress: config.IngressConfig{
+		DomainSuffix:    "nina.local",
+		WildcardDomains: []string{"*.preview.nina.local"},
+	}}
+	ingress := NewIngress(cfg, logger.New(logger.LevelDebug, "text"), &store.Store{})
+	ingress.deployments = []*types.Deployment{
+		{AppName: "web", Domains: []string{"www.example.com", "example.com"}},
+		{AppName: "api", Domains: []string{"api.example.com", "web"}},
+		{AppName: "catchall", Domains: []string{"*.example.com"}},
+		{AppName: "docs", Domains: []string{"*.example.com"}},
+		{AppName: "myapp"},
+		{AppName: "myapp-feature"},
+		{AppName: "pinned", Domains: []string{"myapp-feature.preview.nina.local"}},
+		{AppName: "preview"},
+	}
+
+	tests := []struct {
+		name     string
+		host     string
+		expected string
+	}{
+		{"app name", "myapp", "myapp"},
+		{"app name is case insensitive", "MyApp", "myapp"},
+		{"app name wins over a registered domain", "web", "web"},
+		{"registered domain", "api.example.com", "api"},
+		{"registered domain is case insensitive", "WWW.Example.com.", "web"},
+		{"registered apex domain", "example.com", "web"},
+		{"exact domain wins over a wildcard", "www.example.com", "web"},
+		{"registered wildcard, first app name wins", "blog.example.com", "catchall"},
+		{"wildcard matches a single subdomain", "a.blog.example.com", ""},
+		{"configured wildcard", "myapp.preview.nina.local", "myapp"},
+		{"registered domain wins over the configured wildcard", "myapp-feature.preview.nina.local", "pinned"},
+		{"configured wildcard without a deployment", "missing.preview.nina.local", ""},
+		{"domain suffix", "myapp.nina.local", "myapp"},
+		{"domain suffix of the wildcard domain itself", "preview.nina.local", "preview"},
+		{"unknown host", "unknown.example.org", ""},
+		{"empty host", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := ingress.matchDeployment(tt.host)
+			got := ""
+			if deployment != nil {
+				got = deployment.AppName
+			}
+			if got != tt.expected {
+				t.Errorf("matchDeployment(%q) = %q, want %q", tt.host, got, tt.expected)
+			}
+		})
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // Level represents the logging level
@@ -28,8 +30,10 @@ const (
 // Logger wraps slog.Logger with additional functionality
 type Logger struct {
 	*slog.Logger
-	level      Level
+	// level is shared with the loggers derived with WithContext and WithFields, so SetLevel affects them all
+	level      *slog.LevelVar
 	forceColor bool
+	noColor    bool
 }
 
 // New creates a new logger with the specified level and format
@@ -39,24 +43,7 @@ func New(level Level, format string) *Logger {
 
 // NewWithOptions creates a new logger with the specified level, format, and options
 func NewWithOptions(level Level, format string, forceColor bool) *Logger {
-	var handler slog.Handler
-
-	switch strings.ToLower(format) {
-	case "json":
-		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level: getSlogLevel(level),
-		})
-	default:
-		// Use custom handler that preserves ANSI color codes
-		handler = newColoredTextHandler(os.Stdout, getSlogLevel(level))
-	}
-
-	logger := slog.New(handler)
-	return &Logger{
-		Logger:     logger,
-		level:      level,
-		forceColor: forceColor,
-	}
+	return newLogger(level, format, os.Stdout, forceColor)
 }
 
 // NewWithWriter creates a new logger with a custom writer
@@ -66,26 +53,42 @@ func NewWithWriter(level Level, format string, w io.Writer) *Logger {
 
 // NewWithWriterAndOptions creates a new logger with a custom writer and options
 func NewWithWriterAndOptions(level Level, format string, w io.Writer, forceColor bool) *Logger {
-	var handler slog.Handler
+	return newLogger(level, format, w, forceColor)
+}
 
+// newLogger creates a logger writing to w whose level can be changed with SetLevel
+func newLogger(level Level, format string, w io.Writer, forceColor bool) *Logger {
+	levelVar := &slog.LevelVar{}
+	levelVar.Set(getSlogLevel(level))
+
+	var handler slog.Handler
 	switch strings.ToLower(format) {
 	case "json":
 		handler = slog.NewJSONHandler(w, &slog.HandlerOptions{
-			Level: getSlogLevel(level),
+			Level: levelVar,
 		})
 	default:
 		// Use custom handler that preserves ANSI color codes
-		handler = newColoredTextHandler(w, getSlogLevel(level))
+		handler = newColoredTextHandler(w, levelVar)
 	}
 
-	logger := slog.New(handler)
 	return &Logger{
-		Logger:     logger,
-		level:      level,
+		Logger:     slog.New(handler),
+		level:      levelVar,
 		forceColor: forceColor,
 	}
 }
 
+// ParseLevel parses a log level name, case insensitively
+func ParseLevel(name string) (Level, error) {
+	switch level := Level(strings.ToLower(strings.TrimSpace(name))); level {
+	case LevelDebug, LevelInfo, LevelWarn, LevelError:
+		return level, nil
+	default:
+		return "", fmt.Errorf("invalid log level %q, expected debug, info, warn or error", name)
+	}
+}
+
 // getSlogLevel converts our Level to slog.Level
 func getSlogLevel(level Level) slog.Level {
 	switch level {
@@ -134,6 +137,7 @@ func (l *Logger) WithContext(key string, value any) *Logger {
 		Logger:     l.With(key, value),
 		level:      l.level,
 		forceColor: l.forceColor,
+		noColor:    l.noColor,
 	}
 }
 
@@ -148,11 +152,45 @@ func (l *Logger) WithFields(fields map[string]any) *Logger {
 		Logger:     l.With(args...),
 		level:      l.level,
 		forceColor: l.forceColor,
+		noColor:    l.noColor,
+	}
+}
+
+// RequestFields are the fields logged for a handled HTTP request
+type RequestFields struct {
+	Method    string
+	Path      string
+	Status    int
+	Latency   time.Duration
+	ClientIP  string
+	UserAgent string
+}
+
+// WithRequest creates a new logger with the fields of a handled HTTP request, so that every server
+// logs requests with the same field names and order
+func (l *Logger) WithRequest(req *RequestFields) *Logger {
+	return &Logger{
+		Logger: l.With(
+			"method", req.Method,
+			"path", req.Path,
+			"status", req.Status,
+			"latency", req.Latency,
+			"client_ip", req.ClientIP,
+			"user_agent", req.UserAgent,
+		),
+		level:      l.level,
+		forceColor: l.forceColor,
+		noColor:    l.noColor,
 	}
 }
 
 // colorize adds ANSI color codes to the message
 func (l *Logger) colorize(msg, color string) string {
+	// Disabled colors and NO_COLOR win over everything else
+	if l.noColor || NoColor() {
+		return msg
+	}
+
 	// If forceColor is enabled, always add colors
 	if l.forceColor {
 		return l.addColorCodes(msg, color)
@@ -169,6 +207,11 @@ func (l *Logger) colorize(msg, color string) string {
 
 // addColorCodes adds the actual ANSI color codes to the message
 func (l *Logger) addColorCodes(msg, color string) string {
+	return Colorize(msg, color)
+}
+
+// Colorize wraps msg in the ANSI codes of the named color, leaving it unchanged for unknown colors
+func Colorize(msg, color string) string {
 	var colorCode string
 	switch color {
 	case "red":
@@ -193,16 +236,31 @@ func (l *Logger) addColorCodes(msg, color string) string {
 	return fmt.Sprintf("%s%s%s", colorCode, msg, reset)
 }
 
+// IsTerminal reports whether the output appears to be a terminal
+func IsTerminal() bool {
+	return isTerminal()
+}
+
+// NoColor reports whether the NO_COLOR environment variable asks for plain output, see https://no-color.org
+func NoColor() bool {
+	return os.Getenv("NO_COLOR") != ""
+}
+
+// IsStdoutTerminal reports whether stdout itself is a terminal, so output piped elsewhere stays plain
+func IsStdoutTerminal() bool {
+	fileInfo, err := os.Stdout.Stat()
+	return err == nil && (fileInfo.Mode()&os.ModeCharDevice) != 0
+}
+
 // isTerminal checks if the output is a terminal
 func isTerminal() bool {
 	// Check if stdout is a character device
-	fileInfo, err := os.Stdout.Stat()
-	if err == nil && (fileInfo.Mode()&os.ModeCharDevice) != 0 {
+	if IsStdoutTerminal() {
 		return true
 	}
 
 	// Check if stderr is a character device (fallback)
-	fileInfo, err = os.Stderr.Stat()
+	fileInfo, err := os.Stderr.Stat()
 	if err == nil && (fileInfo.Mode()&os.ModeCharDevice) != 0 {
 		return true
 	}
@@ -259,28 +317,45 @@ func (l *Logger) DebugTerminalInfo() {
 	// Final result
 	fmt.Fprintf(os.Stderr, "isTerminal(): %v\n", isTerminal())
 	fmt.Fprintf(os.Stderr, "forceColor: %v\n", l.forceColor)
+	fmt.Fprintf(os.Stderr, "NO_COLOR: %s\n", os.Getenv("NO_COLOR"))
 	fmt.Fprintf(os.Stderr, "IsColorEnabled(): %v\n", l.IsColorEnabled())
 	fmt.Fprintf(os.Stderr, "========================\n")
 }
 
 // GetLevel returns the current log level
 func (l *Logger) GetLevel() Level {
-	return l.level
+	switch level := l.level.Level(); {
+	case level <= slog.LevelDebug:
+		return LevelDebug
+	case level <= slog.LevelInfo:
+		return LevelInfo
+	case level <= slog.LevelWarn:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
+// SetLevel changes the log level of the logger and of the loggers derived from it
+func (l *Logger) SetLevel(level Level) {
+	l.level.Set(getSlogLevel(level))
 }
 
 // ForceColor enables forced color output
 func (l *Logger) ForceColor() {
 	l.forceColor = true
+	l.noColor = false
 }
 
 // IsColorEnabled returns true if color output is enabled
 func (l *Logger) IsColorEnabled() bool {
-	return l.forceColor || isTerminal()
+	return !l.noColor && !NoColor() && (l.forceColor || isTerminal())
 }
 
-// DisableColor disables color output
+// DisableColor disables color output, even on a terminal
 func (l *Logger) DisableColor() {
 	l.forceColor = false
+	l.noColor = true
 }
 
 // Timestamp returns the current timestamp in a formatted string
@@ -291,11 +366,12 @@ func Timestamp() string {
 // coloredTextHandler is a custom slog handler that preserves ANSI color codes
 type coloredTextHandler struct {
 	writer io.Writer
-	level  slog.Level
+	level  slog.Leveler
+	attrs  []slog.Attr // attributes added with WithAttrs, written before the record attributes
 }
 
 // newColoredTextHandler creates a new colored text handler
-func newColoredTextHandler(w io.Writer, level slog.Level) *coloredTextHandler {
+func newColoredTextHandler(w io.Writer, level slog.Leveler) *coloredTextHandler {
 	return &coloredTextHandler{
 		writer: w,
 		level:  level,
@@ -304,7 +380,7 @@ func newColoredTextHandler(w io.Writer, level slog.Level) *coloredTextHandler {
 
 // Enabled implements slog.Handler.Enabled
 func (h *coloredTextHandler) Enabled(_ context.Context, level slog.Level) bool {
-	return level >= slog.LevelInfo
+	return level >= h.level.Level()
 }
 
 // Handle implements slog.Handler.Handle
@@ -313,7 +389,7 @@ func (h *coloredTextHandler) Handle(_ context.Context, r slog.Record) error { //
 	var buf strings.Builder
 
 	// Add timestamp
-	buf.WriteString(fmt.Sprintf("time=%s ", r.Time.Format("2006-01-02T15:04:05.000-07:00")))
+	buf.WriteString(fmt.Sprintf("time=%s ", r.Time.Format(textTimeFormat)))
 
 	// Add level
 	buf.WriteString(fmt.Sprintf("level=%s ", r.Level.String()))
@@ -322,8 +398,11 @@ func (h *coloredTextHandler) Handle(_ context.Context, r slog.Record) error { //
 	buf.WriteString(fmt.Sprintf("msg=%s ", r.Message))
 
 	// Add attributes
+	for _, a := range h.attrs {
+		writeTextAttr(&buf, "", a)
+	}
 	r.Attrs(func(a slog.Attr) bool {
-		buf.WriteString(fmt.Sprintf("%s=%v ", a.Key, a.Value))
+		writeTextAttr(&buf, "", a)
 		return true
 	})
 
@@ -337,11 +416,84 @@ func (h *coloredTextHandler) Handle(_ context.Context, r slog.Record) error { //
 	return nil
 }
 
+// textTimeFormat is the layout of the record time and of time attributes in text output
+const textTimeFormat = "2006-01-02T15:04:05.000-07:00"
+
+// writeTextAttr writes an attribute as key=value followed by a space, following the slog text
+// conventions: group members are prefixed with the group key and attributes with an empty key are skipped
+func writeTextAttr(buf *strings.Builder, prefix string, a slog.Attr) {
+	value := a.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, member := range value.Group() {
+			writeTextAttr(buf, prefix, member)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	buf.WriteString(prefix + a.Key + "=" + formatTextValue(value) + " ")
+}
+
+// formatTextValue formats an attribute value, quoting it when it would be ambiguous unquoted
+func formatTextValue(value slog.Value) string {
+	var text string
+	switch value.Kind() {
+	case slog.KindString:
+		text = value.String()
+	case slog.KindTime:
+		return value.Time().Format(textTimeFormat)
+	case slog.KindDuration:
+		return value.Duration().String()
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case nil:
+			return "<nil>"
+		case error:
+			text = v.Error()
+		default:
+			text = fmt.Sprintf("%+v", v)
+		}
+	default:
+		return value.String()
+	}
+
+	if needsQuoting(text) {
+		return strconv.Quote(text)
+	}
+	return text
+}
+
+// needsQuoting reports whether a value is empty or contains spaces, quotes, equal signs or
+// unprintable characters, which would make the key=value pairs ambiguous
+func needsQuoting(text string) bool {
+	if text == "" {
+		return true
+	}
+	for _, r := range text {
+		if unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
 // WithAttrs implements slog.Handler.WithAttrs
-func (h *coloredTextHandler) WithAttrs(_ []slog.Attr) slog.Handler {
-	// For simplicity, return the same handler
-	// In a full implementation, you'd want to store the attrs
-	return h
+func (h *coloredTextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	merged := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	merged = append(merged, h.attrs...)
+	merged = append(merged, attrs...)
+	return &coloredTextHandler{
+		writer: h.writer,
+		level:  h.level,
+		attrs:  merged,
+	}
 }
 
 // WithGroup implements slog.Handler.WithGroup
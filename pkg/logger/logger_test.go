@@ -0,0 +1,176 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTextHandlerWithAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewWithWriter(LevelInfo, "text", &buf)
+
+	log.WithContext("app_name", "my-app").Info("Build completed", "duration_ms", 42)
+
+	output := buf.String()
+	for _, want := range []string{"app_name=my-app", "duration_ms=42"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got %q", want, output)
+		}
+	}
+	if strings.Index(output, "app_name=") > strings.Index(output, "duration_ms=") {
+		t.Errorf("Expected logger attributes before record attributes, got %q", output)
+	}
+}
+
+func TestDisableColor(t *testing.T) {
+	t.Setenv("TERM", "xterm-256color")
+	t.Setenv("NO_COLOR", "")
+
+	var buf bytes.Buffer
+	log := NewWithWriter(LevelInfo, "text", &buf)
+	log.ForceColor()
+	log.DisableColor()
+
+	log.WithContext("app_name", "my-app").Info("Build completed")
+
+	if strings.Contains(buf.String(), "\033[") {
+		t.Errorf("Expected no ANSI codes with colors disabled, got %q", buf.String())
+	}
+	if log.IsColorEnabled() {
+		t.Error("Expected IsColorEnabled to be false with colors disabled")
+	}
+
+	buf.Reset()
+	log.ForceColor()
+	log.Info("Build completed")
+	if !strings.Contains(buf.String(), "\033[32mBuild completed") {
+		t.Errorf("Expected ForceColor to re-enable colors, got %q", buf.String())
+	}
+}
+
+func TestNoColorEnv(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	var buf bytes.Buffer
+	log := NewWithWriter(LevelInfo, "text", &buf)
+	log.ForceColor()
+
+	log.Info("Build completed")
+	log.Error("Build failed")
+
+	if strings.Contains(buf.String(), "\033[") {
+		t.Errorf("Expected no ANSI codes with NO_COLOR set, got %q", buf.String())
+	}
+	if log.IsColorEnabled() {
+		t.Error("Expected IsColorEnabled to be false with NO_COLOR set")
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	for _, format := range []string{"text", "json"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := NewWithWriter(LevelInfo, format, &buf)
+			derived := log.WithContext("app_name", "my-app").WithFields(map[string]any{"commit_hash": "abc123"})
+
+			derived.Debug("hidden")
+			if buf.Len() != 0 {
+				t.Fatalf("Expected no debug output at info level, got %q", buf.String())
+			}
+
+			log.SetLevel(LevelDebug)
+			if log.GetLevel() != LevelDebug || derived.GetLevel() != LevelDebug {
+				t.Errorf("Expected the debug level, got %s and %s", log.GetLevel(), derived.GetLevel())
+			}
+			derived.Debug("shown")
+			if !strings.Contains(buf.String(), "shown") {
+				t.Errorf("Expected debug output after raising verbosity, got %q", buf.String())
+			}
+
+			buf.Reset()
+			log.SetLevel(LevelWarn)
+			derived.Debug("hidden")
+			log.Info("hidden")
+			log.Warn("warned")
+			if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "warned") {
+				t.Errorf("Expected only warnings at warn level, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for input, expected := range map[string]Level{"debug": LevelDebug, " WARN ": LevelWarn, "Error": LevelError} {
+		level, err := ParseLevel(input)
+		if err != nil || level != expected {
+			t.Errorf("Expected %q to parse as %s, got %s (err %v)", input, expected, level, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+}
+
+func TestWithRequest(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewWithWriter(LevelInfo, "text", &buf)
+	log.DisableColor()
+
+	log.WithRequest(&RequestFields{
+		Method:    "GET",
+		Path:      "/api/v1/apps",
+		Status:    200,
+		Latency:   1500 * time.Microsecond,
+		ClientIP:  "192.0.2.1",
+		UserAgent: "curl/8.0",
+	}).Info("HTTP Request")
+
+	want := "msg=HTTP Request method=GET path=/api/v1/apps status=200 latency=1.5ms client_ip=192.0.2.1 user_agent=curl/8.0"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Expected output to contain %q, got %q", want, buf.String())
+	}
+}
+
+func TestTextHandlerFormatsValues(t *testing.T) {
+	var buf bytes.Buffer
+	log := NewWithWriter(LevelInfo, "text", &buf)
+	log.DisableColor()
+
+	started := time.Date(2025, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	log.Info("Build failed",
+		"app_name", "my-app",
+		"commit_message", "fix the build",
+		"empty", "",
+		"quoted", `say "hi"`,
+		"pair", "a=b",
+		"error", errors.New("exit status 1: no space left"),
+		"missing", nil,
+		"duration", 1500*time.Millisecond,
+		"started_at", started,
+		"ports", []int{8080, 9090},
+		slog.Group("request", "method", "GET", "path", "/api/v1/apps"),
+	)
+
+	output := buf.String()
+	for _, want := range []string{
+		"app_name=my-app ",
+		`commit_message="fix the build" `,
+		`empty="" `,
+		`quoted="say \"hi\"" `,
+		`pair="a=b" `,
+		`error="exit status 1: no space left" `,
+		"missing=<nil> ",
+		"duration=1.5s ",
+		"started_at=2025-01-02T03:04:05.006+00:00 ",
+		`ports="[8080 9090]" `,
+		"request.method=GET request.path=/api/v1/apps\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got %q", want, output)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package manifest provides parsing of the nina.yaml application manifest.
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/go-units"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the manifest file expected at the repository root
+const FileName = "nina.yaml"
+
+// Manifest holds the application settings declared in nina.yaml
+type Manifest struct {
+	AppName   string            `yaml:"app_name"`
+	Port      int               `yaml:"port"`
+	Replicas  int               `yaml:"replicas"`
+	Env       map[string]string `yaml:"env"`
+	CPU       float64           `yaml:"cpu"`
+	Memory    string            `yaml:"memory"`
+	Buildpack string            `yaml:"buildpack"`
+	Node      string            `yaml:"node"`
+	// Webhooks are the URLs notified when a deployment of the app becomes ready or fails
+	Webhooks []string `yaml:"webhooks"`
+}
+
+// Load reads the manifest from the given directory.
+// An empty manifest is returned when the directory has no manifest file.
+func Load(dir string) (*Manifest, error) {
+	path := filepath.Join(dir, FileName)
+
+	//nolint: gosec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Manifest{}, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+
+	m, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Parse parses and validates manifest contents
+func Parse(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Validate checks that the manifest values are within their allowed ranges
+func (m *Manifest) Validate() error {
+	if m.Port < 0 || m.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535, got %d", m.Port)
+	}
+	if m.Replicas < 0 {
+		return fmt.Errorf("replicas must not be negative, got %d", m.Replicas)
+	}
+	if m.CPU < 0 {
+		return fmt.Errorf("cpu must not be negative, got %v", m.CPU)
+	}
+	if _, err := m.MemoryBytes(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// MemoryBytes returns the memory limit in bytes, or zero when no limit is set
+func (m *Manifest) MemoryBytes() (int64, error) {
+	if m.Memory == "" {
+		return 0, nil
+	}
+	bytes, err := units.RAMInBytes(m.Memory)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory value %q: %w", m.Memory, err)
+	}
+	return bytes, nil
+}
+
+// Merge returns a new manifest with the values from overrides applied on top of m.
+// Zero values in overrides are ignored, and environment variables are merged key by key.
+func (m *Manifest) Merge(overrides *Manifest) *Manifest {
+	merged := *m
+	merged.Env = make(map[string]string, len(m.Env))
+	for k, v := range m.Env {
+		merged.Env[k] = v
+	}
+
+	if overrides == nil {
+		return &merged
+	}
+
+	if overrides.AppName != "" {
+		merged.AppName = overrides.AppName
+	}
+	if overrides.Port != 0 {
+		merged.Port = overrides.Port
+	}
+	if overrides.Replicas != 0 {
+		merged.Replicas = overrides.Replicas
+	}
+	if overrides.CPU != 0 {
+		merged.CPU = overrides.CPU
+	}
+	if overrides.Memory != "" {
+		merged.Memory = overrides.Memory
+	}
+	if overrides.Buildpack != "" {
+		merged.Buildpack = overrides.Buildpack
+	}
+	if overrides.Node != "" {
+		merged.Node = overrides.Node
+	}
+	if len(overrides.Webhooks) > 0 {
+		merged.Webhooks = overrides.Webhooks
+	}
+	for k, v := range overrides.Env {
+		merged.Env[k] = v
+	}
+
+	return &merged
+}
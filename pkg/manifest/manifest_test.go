@@ -0,0 +1,153 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testManifest = `
+app_name: my-app
+port: 9090
+replicas: 3
+env:
+  LOG_LEVEL: debug
+  REGION: eu
+cpu: 0.5
+memory: 256m
+buildpack: golang
+webhooks:
+  - https://hooks.example.com/nina
+`
+
+func TestParse(t *testing.T) {
+	m, err := Parse([]byte(testManifest))
+	if err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+
+	if m.AppName != "my-app" {
+		t.Errorf("Expected app name 'my-app', got '%s'", m.AppName)
+	}
+	if m.Port != 9090 {
+		t.Errorf("Expected port 9090, got %d", m.Port)
+	}
+	if m.Replicas != 3 {
+		t.Errorf("Expected 3 replicas, got %d", m.Replicas)
+	}
+	if m.Env["LOG_LEVEL"] != "debug" || m.Env["REGION"] != "eu" {
+		t.Errorf("Unexpected env: %v", m.Env)
+	}
+	if m.CPU != 0.5 {
+		t.Errorf("Expected cpu 0.5, got %v", m.CPU)
+	}
+	if m.Buildpack != "golang" {
+		t.Errorf("Expected buildpack 'golang', got '%s'", m.Buildpack)
+	}
+	if len(m.Webhooks) != 1 || m.Webhooks[0] != "https://hooks.example.com/nina" {
+		t.Errorf("Unexpected webhooks: %v", m.Webhooks)
+	}
+
+	memory, err := m.MemoryBytes()
+	if err != nil {
+		t.Fatalf("Failed to get memory bytes: %v", err)
+	}
+	if memory != 256*1024*1024 {
+		t.Errorf("Expected memory %d, got %d", 256*1024*1024, memory)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"malformed yaml", "app_name: [unterminated"},
+		{"negative replicas", "replicas: -1"},
+		{"port out of range", "port: 70000"},
+		{"negative cpu", "cpu: -2"},
+		{"invalid memory", "memory: lots"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.contents)); err == nil {
+				t.Errorf("Expected error for %q, got nil", tt.contents)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	// A missing manifest yields an empty manifest
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Expected no error for missing manifest, got %v", err)
+	}
+	if m.AppName != "" || m.Replicas != 0 || len(m.Env) != 0 {
+		t.Errorf("Expected empty manifest, got %+v", m)
+	}
+
+	if writeErr := os.WriteFile(filepath.Join(dir, FileName), []byte(testManifest), 0o600); writeErr != nil {
+		t.Fatalf("Failed to write manifest: %v", writeErr)
+	}
+
+	m, err = Load(dir)
+	if err != nil {
+		t.Fatalf("Failed to load manifest: %v", err)
+	}
+	if m.AppName != "my-app" {
+		t.Errorf("Expected app name 'my-app', got '%s'", m.AppName)
+	}
+}
+
+func TestMergePrecedence(t *testing.T) {
+	m, err := Parse([]byte(testManifest))
+	if err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+
+	merged := m.Merge(&Manifest{
+		Replicas: 5,
+		Env:      map[string]string{"LOG_LEVEL": "info"},
+		Node:     "worker",
+		Webhooks: []string{"https://hooks.example.com/override"},
+	})
+
+	// Values set by the overrides win
+	if merged.Replicas != 5 {
+		t.Errorf("Expected override replicas 5, got %d", merged.Replicas)
+	}
+	if merged.Env["LOG_LEVEL"] != "info" {
+		t.Errorf("Expected override LOG_LEVEL 'info', got '%s'", merged.Env["LOG_LEVEL"])
+	}
+	if merged.Node != "worker" {
+		t.Errorf("Expected override node 'worker', got '%s'", merged.Node)
+	}
+	if len(merged.Webhooks) != 1 || merged.Webhooks[0] != "https://hooks.example.com/override" {
+		t.Errorf("Expected override webhooks, got %v", merged.Webhooks)
+	}
+
+	// Values not set by the overrides are kept from the manifest
+	if merged.Port != 9090 {
+		t.Errorf("Expected manifest port 9090, got %d", merged.Port)
+	}
+	if merged.Env["REGION"] != "eu" {
+		t.Errorf("Expected manifest REGION 'eu', got '%s'", merged.Env["REGION"])
+	}
+	if merged.AppName != "my-app" {
+		t.Errorf("Expected manifest app name 'my-app', got '%s'", merged.AppName)
+	}
+
+	// The original manifest is left untouched
+	if m.Replicas != 3 || m.Env["LOG_LEVEL"] != "debug" {
+		t.Errorf("Expected original manifest to be unchanged, got %+v", m)
+	}
+
+	// A nil override returns an equivalent copy
+	if copied := m.Merge(nil); copied.Replicas != 3 || copied.Port != 9090 {
+		t.Errorf("Expected copy of manifest, got %+v", copied)
+	}
+}
@@ -0,0 +1,153 @@
+// Package stack provides parsing of nina-stack.yaml files, which declare several apps deployed together.
+package stack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/matiasinsaurralde/nina/pkg/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the conventional name of a stack file
+const FileName = "nina-stack.yaml"
+
+// Stack holds the services declared in a stack file, keyed by name
+type Stack struct {
+	Services map[string]*Service `yaml:"services"`
+	// dir is the directory of the stack file, service paths are relative to it
+	dir string
+}
+
+// Service is an app of a stack. Its values take precedence over the nina.yaml of its source directory.
+type Service struct {
+	// Path is the source directory of the service, relative to the stack file
+	Path     string            `yaml:"path"`
+	Replicas int               `yaml:"replicas"`
+	Port     int               `yaml:"port"`
+	Env      map[string]string `yaml:"env"`
+	// DependsOn names the services deployed before this one
+	DependsOn []string `yaml:"depends_on"`
+}
+
+// Load reads and validates the stack file at path
+func Load(path string) (*Stack, error) {
+	//nolint: gosec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stack file %s: %w", path, err)
+	}
+
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stack file %s: %w", path, err)
+	}
+	s.dir = filepath.Dir(path)
+	return s, nil
+}
+
+// Parse parses and validates stack file contents
+func Parse(data []byte) (*Stack, error) {
+	var s Stack
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse stack file: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks that every service has a source path and valid values, and that dependencies
+// name declared services without forming a cycle
+func (s *Stack) Validate() error {
+	if len(s.Services) == 0 {
+		return fmt.Errorf("no services declared")
+	}
+	for _, name := range s.names() {
+		service := s.Services[name]
+		if service == nil || service.Path == "" {
+			return fmt.Errorf("service %s: path is required", name)
+		}
+		if err := service.Manifest(name).Validate(); err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		for _, dep := range service.DependsOn {
+			if _, ok := s.Services[dep]; !ok {
+				return fmt.Errorf("service %s depends on undeclared service %s", name, dep)
+			}
+		}
+	}
+	_, err := s.Order()
+	return err
+}
+
+// Order returns the service names in deployment order: every service comes after the services it
+// depends on, and services that do not depend on each other are ordered by name
+func (s *Stack) Order() ([]string, error) {
+	remaining := make(map[string]int, len(s.Services))
+	dependents := make(map[string][]string, len(s.Services))
+	for _, name := range s.names() {
+		remaining[name] = len(s.Services[name].DependsOn)
+		for _, dep := range s.Services[name].DependsOn {
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var ready, order []string
+	for _, name := range s.names() {
+		if remaining[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		for _, dependent := range dependents[name] {
+			if remaining[dependent]--; remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+				sort.Strings(ready)
+			}
+		}
+	}
+
+	if len(order) < len(s.Services) {
+		var cyclic []string
+		for _, name := range s.names() {
+			if remaining[name] > 0 {
+				cyclic = append(cyclic, name)
+			}
+		}
+		return nil, fmt.Errorf("dependency cycle between services %s", strings.Join(cyclic, ", "))
+	}
+	return order, nil
+}
+
+// Dir returns the source directory of the named service
+func (s *Stack) Dir(name string) string {
+	return filepath.Join(s.dir, s.Services[name].Path)
+}
+
+// Manifest returns the values of the service as manifest overrides, the service name being the app name
+func (svc *Service) Manifest(name string) *manifest.Manifest {
+	return &manifest.Manifest{
+		AppName:  name,
+		Port:     svc.Port,
+		Replicas: svc.Replicas,
+		Env:      svc.Env,
+	}
+}
+
+// names returns the service names in alphabetical order
+func (s *Stack) names() []string {
+	names := make([]string, 0, len(s.Services))
+	for name := range s.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
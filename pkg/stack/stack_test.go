@@ -0,0 +1,143 @@
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/manifest"
+)
+
+const testStack = `
+services:
+  web:
+    path: ./web
+    replicas: 2
+    port: 3000
+    env:
+      API_URL: http://api.nina.local
+    depends_on: [api]
+  api:
+    path: ./api
+    depends_on: [db, cache]
+  db:
+    path: ./db
+  cache:
+    path: ./cache
+  worker:
+    path: ./worker
+    depends_on: [db]
+`
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(testStack))
+	if err != nil {
+		t.Fatalf("Failed to parse stack: %v", err)
+	}
+
+	if len(s.Services) != 5 {
+		t.Fatalf("Expected 5 services, got %d", len(s.Services))
+	}
+	web := s.Services["web"]
+	if web.Path != "./web" || web.Replicas != 2 || web.Port != 3000 || web.Env["API_URL"] != "http://api.nina.local" {
+		t.Errorf("Unexpected web service: %+v", web)
+	}
+	if !reflect.DeepEqual(web.DependsOn, []string{"api"}) {
+		t.Errorf("Unexpected web dependencies: %v", web.DependsOn)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	s, err := Parse([]byte(testStack))
+	if err != nil {
+		t.Fatalf("Failed to parse stack: %v", err)
+	}
+
+	order, err := s.Order()
+	if err != nil {
+		t.Fatalf("Failed to order services: %v", err)
+	}
+	expected := []string{"cache", "db", "api", "web", "worker"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected order %v, got %v", expected, order)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{"no services", "services: {}", "no services declared"},
+		{"missing path", "services:\n  api:\n    replicas: 2", "service api: path is required"},
+		{"invalid port", "services:\n  api:\n    path: ./api\n    port: 70000", "service api: port must be"},
+		{"unknown dependency", "services:\n  api:\n    path: ./api\n    depends_on: [db]", "undeclared service db"},
+		{
+			"cycle",
+			"services:\n  a:\n    path: ./a\n    depends_on: [b]\n  b:\n    path: ./b\n    depends_on: [a]\n  c:\n    path: ./c",
+			"dependency cycle between services a, b",
+		},
+		{"invalid yaml", "services: [", "failed to parse stack file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestServiceManifestOverrides(t *testing.T) {
+	s, err := Parse([]byte(testStack))
+	if err != nil {
+		t.Fatalf("Failed to parse stack: %v", err)
+	}
+
+	// The nina.yaml of the service source directory
+	base := &manifest.Manifest{
+		AppName:  "web-frontend",
+		Port:     8080,
+		Replicas: 1,
+		Env:      map[string]string{"API_URL": "http://localhost:8080", "LOG_LEVEL": "info"},
+		Memory:   "256m",
+	}
+
+	merged := base.Merge(s.Services["web"].Manifest("web"))
+	if merged.AppName != "web" || merged.Port != 3000 || merged.Replicas != 2 || merged.Memory != "256m" {
+		t.Errorf("Expected the service values to override the manifest, got %+v", merged)
+	}
+	if merged.Env["API_URL"] != "http://api.nina.local" || merged.Env["LOG_LEVEL"] != "info" {
+		t.Errorf("Expected env to be merged key by key, got %v", merged.Env)
+	}
+
+	// Values the service leaves out keep the manifest values
+	merged = base.Merge(s.Services["api"].Manifest("api"))
+	if merged.Port != 8080 || merged.Replicas != 1 {
+		t.Errorf("Expected the manifest values to be kept, got %+v", merged)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte(testStack), 0o600); err != nil {
+		t.Fatalf("Failed to write stack file: %v", err)
+	}
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load stack: %v", err)
+	}
+	if got := s.Dir("web"); got != filepath.Join(dir, "web") {
+		t.Errorf("Expected the service path to be relative to the stack file, got %s", got)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Expected an error for a missing stack file")
+	}
+}
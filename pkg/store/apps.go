@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// ListApps collates the stored builds and deployments by app name, sorted by name.
+// Legacy deployments are only included when includeLegacy is set.
+func (s *Store) ListApps(ctx context.Context, includeLegacy bool) ([]*types.App, error) {
+	builds, err := s.ListBuilds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builds: %w", err)
+	}
+
+	listFunc := s.ListNewDeployments
+	if includeLegacy {
+		listFunc = s.ListAllDeployments
+	}
+	deployments, err := listFunc(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	return collateApps(builds, deployments), nil
+}
+
+// GetApp collates the builds and the deployment of a single app.
+// ErrNotFound is returned when the app has neither builds nor a deployment.
+func (s *Store) GetApp(ctx context.Context, name string, includeLegacy bool) (*types.App, error) {
+	builds, err := s.ListBuildsByAppName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list builds: %w", err)
+	}
+
+	listFunc := s.ListNewDeploymentsByAppName
+	if includeLegacy {
+		listFunc = s.ListAllDeploymentsByAppName
+	}
+	deployments, err := listFunc(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+
+	for _, app := range collateApps(builds, deployments) {
+		if app.Name == name {
+			return app, nil
+		}
+	}
+	return nil, fmt.Errorf("app %w: %s", ErrNotFound, name)
+}
+
+// collateApps groups builds and deployments by app name.
+// The latest build is the most recently created one; the ingress routes the app name as its domain,
+// along with the domains registered by the deployment.
+func collateApps(builds []*types.Build, deployments []*types.Deployment) []*types.App {
+	apps := make(map[string]*types.App)
+	appFor := func(name string) *types.App {
+		app, ok := apps[name]
+		if !ok {
+			app = &types.App{Name: name, Domains: []string{name}}
+			apps[name] = app
+		}
+		return app
+	}
+
+	for _, build := range builds {
+		app := appFor(build.AppName)
+		app.Builds++
+		if app.LatestBuild == nil || build.CreatedAt.After(app.LatestBuild.CreatedAt) {
+			app.LatestBuild = build
+		}
+	}
+	for _, deployment := range deployments {
+		app := appFor(deployment.AppName)
+		app.Deployment = deployment
+		app.Env = deployment.Env
+		app.Domains = append([]string{app.Name}, deployment.Domains...)
+	}
+
+	result := make([]*types.App, 0, len(apps))
+	for _, app := range apps {
+		result = append(result, app)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestApps(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	for _, req := range []*types.BuildRequest{
+		{AppName: "web", CommitHash: "aaa111"},
+		{AppName: "web", CommitHash: "bbb222"},
+		{AppName: "worker", CommitHash: "ccc333"},
+	} {
+		if _, err := store.CreateBuild(ctx, req); err != nil {
+			t.Fatalf("Failed to create build: %v", err)
+		}
+	}
+	if _, err := store.CreateNewDeployment(ctx, &types.DeploymentRequest{
+		AppName: "web", CommitHash: "bbb222", Env: map[string]string{"PORT": "8080"}, Domains: []string{"www.example.com"},
+	}); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	if _, err := store.CreateDeployment(ctx, &types.ProvisionRequest{Name: "legacy", Image: "nginx:latest"}); err != nil {
+		t.Fatalf("Failed to create legacy deployment: %v", err)
+	}
+
+	apps, err := store.ListApps(ctx, false)
+	if err != nil {
+		t.Fatalf("Failed to list apps: %v", err)
+	}
+	if len(apps) != 2 || apps[0].Name != "web" || apps[1].Name != "worker" {
+		t.Fatalf("Expected apps web and worker, got %+v", apps)
+	}
+
+	web := apps[0]
+	if web.Builds != 2 {
+		t.Errorf("Expected 2 builds for web, got %d", web.Builds)
+	}
+	if web.LatestBuild == nil || web.LatestBuild.CommitHash != "bbb222" {
+		t.Errorf("Expected latest build bbb222, got %+v", web.LatestBuild)
+	}
+	if web.Deployment == nil || web.Deployment.CommitHash != "bbb222" {
+		t.Errorf("Expected the web deployment, got %+v", web.Deployment)
+	}
+	if web.Env["PORT"] != "8080" {
+		t.Errorf("Expected the deployment env, got %v", web.Env)
+	}
+	if len(web.Domains) != 2 || web.Domains[0] != "web" || web.Domains[1] != "www.example.com" {
+		t.Errorf("Expected domains web and www.example.com, got %v", web.Domains)
+	}
+	if apps[1].Deployment != nil {
+		t.Errorf("Expected no deployment for worker, got %+v", apps[1].Deployment)
+	}
+
+	// Legacy deployments are apps of their own when included
+	apps, err = store.ListApps(ctx, true)
+	if err != nil {
+		t.Fatalf("Failed to list apps: %v", err)
+	}
+	if len(apps) != 3 || apps[0].Name != "legacy" {
+		t.Errorf("Expected the legacy app to be listed first, got %+v", apps)
+	}
+
+	app, err := store.GetApp(ctx, "worker", false)
+	if err != nil {
+		t.Fatalf("Failed to get app: %v", err)
+	}
+	if app.Builds != 1 || app.LatestBuild.CommitHash != "ccc333" {
+		t.Errorf("Expected the worker build, got %+v", app)
+	}
+
+	if _, err := store.GetApp(ctx, "legacy", false); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a legacy app when legacy is excluded, got %v", err)
+	}
+	if _, err := store.GetApp(ctx, "missing", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing app, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// buildCacheKey returns the key of the image built from the bundle contents with the given content hash
+func buildCacheKey(contentHash string) string {
+	return fmt.Sprintf("nina-buildcache-%s", contentHash)
+}
+
+// GetCachedImage retrieves the image built from bundle contents with the given content hash.
+// ErrNotFound is returned when no build of those contents was cached.
+func (s *Store) GetCachedImage(ctx context.Context, contentHash string) (*types.DeploymentImage, error) {
+	data, err := s.getItemByKey(ctx, buildCacheKey(contentHash), "cached image")
+	if err != nil {
+		return nil, err
+	}
+
+	var image types.DeploymentImage
+	if err := s.unmarshalItem(data, &image, "cached image"); err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// SaveCachedImage records the image built from bundle contents with the given content hash
+func (s *Store) SaveCachedImage(ctx context.Context, contentHash string, image *types.DeploymentImage) error {
+	data, err := json.Marshal(image)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached image: %w", err)
+	}
+	if err := s.client.Set(ctx, buildCacheKey(contentHash), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to store cached image: %w", err)
+	}
+
+	s.logger.Info("Cached build image", "content_hash", contentHash, "image_tag", image.ImageTag)
+	return nil
+}
+
+// DeleteCachedImage forgets the image built from bundle contents with the given content hash
+func (s *Store) DeleteCachedImage(ctx context.Context, contentHash string) error {
+	if err := s.client.Del(ctx, buildCacheKey(contentHash)).Err(); err != nil {
+		return fmt.Errorf("failed to delete cached image: %w", err)
+	}
+	return nil
+}
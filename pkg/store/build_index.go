@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// buildsByAppKey returns the key of the set holding the commit hashes of the builds of an app
+func buildsByAppKey(appName string) string {
+	return fmt.Sprintf("nina-builds-by-app:%s", appName)
+}
+
+// ListBuildsByAppName retrieves the builds of an app using the builds by app index, sorted by creation time
+func (s *Store) ListBuildsByAppName(ctx context.Context, appName string) ([]*types.Build, error) {
+	commitHashes, err := s.client.SMembers(ctx, buildsByAppKey(appName)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get builds by app: %w", err)
+	}
+	if len(commitHashes) == 0 {
+		return []*types.Build{}, nil
+	}
+
+	keys := make([]string, 0, len(commitHashes))
+	for _, commitHash := range commitHashes {
+		keys = append(keys, fmt.Sprintf("nina-build-%s", commitHash))
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get builds: %w", err)
+	}
+
+	builds := make([]*types.Build, 0, len(values))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			// Deleted since the index was read
+			continue
+		}
+		var build types.Build
+		if err := json.Unmarshal([]byte(data), &build); err != nil {
+			s.logger.Warn("Failed to unmarshal build", "key", keys[i], "error", err)
+			continue
+		}
+		// Skip commits rebuilt for another app since the index was read
+		if build.AppName != appName {
+			continue
+		}
+		builds = append(builds, &build)
+	}
+
+	sort.Slice(builds, func(i, j int) bool {
+		return builds[i].CreatedAt.Before(builds[j].CreatedAt)
+	})
+	return builds, nil
+}
+
+// RebuildBuildsByAppIndex rebuilds the builds by app index from the stored builds,
+// indexing builds that were stored before the index existed
+func (s *Store) RebuildBuildsByAppIndex(ctx context.Context) error {
+	builds, err := s.ListBuilds(ctx)
+	if err != nil {
+		return err
+	}
+	indexKeys, err := s.client.Keys(ctx, buildsByAppKey("*")).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get builds by app index: %w", err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if len(indexKeys) > 0 {
+			pipe.Del(ctx, indexKeys...)
+		}
+		for _, build := range builds {
+			pipe.SAdd(ctx, buildsByAppKey(build.AppName), build.CommitHash)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rebuild builds by app index: %w", err)
+	}
+
+	s.logger.Info("Rebuilt builds by app index", "builds", len(builds))
+	return nil
+}
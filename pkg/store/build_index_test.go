@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// buildCommitHashes returns the commit hashes of builds
+func buildCommitHashes(builds []*types.Build) []string {
+	hashes := make([]string, 0, len(builds))
+	for _, build := range builds {
+		hashes = append(hashes, build.CommitHash)
+	}
+	return hashes
+}
+
+func TestBuildsByAppIndex(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	for _, req := range []*types.BuildRequest{
+		{AppName: "app", CommitHash: "aaa111"},
+		{AppName: "app", CommitHash: "bbb222"},
+		{AppName: "app", CommitHash: "ccc333"},
+		{AppName: "other", CommitHash: "ddd444"},
+	} {
+		if _, err := store.CreateBuild(ctx, req); err != nil {
+			t.Fatalf("Failed to create build: %v", err)
+		}
+	}
+
+	builds, err := store.ListBuildsByAppName(ctx, "app")
+	if err != nil {
+		t.Fatalf("Failed to list builds: %v", err)
+	}
+	assertNames(t, buildCommitHashes(builds), "aaa111", "bbb222", "ccc333")
+
+	// A commit rebuilt for another app moves to the index of that app
+	if _, err := store.CreateBuild(ctx, &types.BuildRequest{AppName: "other", CommitHash: "ccc333"}); err != nil {
+		t.Fatalf("Failed to create build: %v", err)
+	}
+	if members := store.client.SMembers(ctx, buildsByAppKey("app")).Val(); len(members) != 2 {
+		t.Errorf("Expected 2 indexed builds for app, got %v", members)
+	}
+
+	deleted, count, err := store.DeleteBuilds(ctx, "app")
+	if err != nil {
+		t.Fatalf("Failed to delete builds: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 deleted builds, got %v", deleted)
+	}
+	if exists := store.client.Exists(ctx, buildsByAppKey("app")).Val(); exists != 0 {
+		t.Error("Expected the index of app to be removed with its last build")
+	}
+
+	// Deleting by commit hash keeps the index of the app consistent
+	if _, count, err := store.DeleteBuilds(ctx, "ddd444"); err != nil || count != 1 {
+		t.Fatalf("Expected the ddd444 build to be deleted, got %d (err %v)", count, err)
+	}
+	builds, err = store.ListBuildsByAppName(ctx, "other")
+	if err != nil {
+		t.Fatalf("Failed to list builds: %v", err)
+	}
+	assertNames(t, buildCommitHashes(builds), "ccc333")
+	if members := store.client.SMembers(ctx, buildsByAppKey("other")).Val(); len(members) != 1 {
+		t.Errorf("Expected 1 indexed build for other, got %v", members)
+	}
+}
+
+func TestRebuildBuildsByAppIndex(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	// A build stored before the index existed and a stale index entry
+	data, err := json.Marshal(&types.Build{AppName: "app", CommitHash: "aaa111"})
+	if err != nil {
+		t.Fatalf("Failed to marshal build: %v", err)
+	}
+	store.client.Set(ctx, "nina-build-aaa111", data, 0)
+	store.client.SAdd(ctx, buildsByAppKey("gone"), "bbb222")
+
+	if err := store.RebuildBuildsByAppIndex(ctx); err != nil {
+		t.Fatalf("Failed to rebuild index: %v", err)
+	}
+	builds, err := store.ListBuildsByAppName(ctx, "app")
+	if err != nil {
+		t.Fatalf("Failed to list builds: %v", err)
+	}
+	assertNames(t, buildCommitHashes(builds), "aaa111")
+	if exists := store.client.Exists(ctx, buildsByAppKey("gone")).Val(); exists != 0 {
+		t.Error("Expected the stale index to be dropped")
+	}
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// FilterAndSortDeployments returns the deployments matching the status, since and label filters in the requested order
+func FilterAndSortDeployments(deployments []*types.Deployment, opts *types.ListOptions) []*types.Deployment {
+	return applyListOptions(deployments, opts, func(d *types.Deployment) listFields {
+		return listFields{appName: d.AppName, status: string(d.Status), createdAt: d.CreatedAt, labels: d.Labels}
+	})
+}
+
+// FilterAndSortBuilds returns the builds matching the status and since filters in the requested order
+func FilterAndSortBuilds(builds []*types.Build, opts *types.ListOptions) []*types.Build {
+	return applyListOptions(builds, opts, func(b *types.Build) listFields {
+		return listFields{appName: b.AppName, status: string(b.Status), createdAt: b.CreatedAt}
+	})
+}
+
+// listFields holds the fields of a record used for filtering and sorting
+type listFields struct {
+	appName   string
+	status    string
+	createdAt time.Time
+	labels    map[string]string
+}
+
+// applyListOptions filters and sorts items, leaving the input slice untouched
+func applyListOptions[T any](items []T, opts *types.ListOptions, fields func(T) listFields) []T {
+	if opts == nil {
+		opts = &types.ListOptions{}
+	}
+	// Invalid values are rejected by Validate, so a parse error leaves the filter off
+	since, _ := types.ParseSince(opts.Since, time.Now())
+
+	result := make([]T, 0, len(items))
+	for _, item := range items {
+		f := fields(item)
+		if opts.Status != "" && !strings.EqualFold(f.status, opts.Status) {
+			continue
+		}
+		if !since.IsZero() && f.createdAt.Before(since) {
+			continue
+		}
+		if !hasLabels(f.labels, opts.Labels) {
+			continue
+		}
+		result = append(result, item)
+	}
+
+	desc := strings.EqualFold(opts.Order, types.OrderDesc)
+	less := func(a, b listFields) bool {
+		if opts.SortBy == types.SortByAppName && a.appName != b.appName {
+			return a.appName < b.appName
+		}
+		if !a.createdAt.Equal(b.createdAt) {
+			return a.createdAt.Before(b.createdAt)
+		}
+		return a.appName < b.appName
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := fields(result[i]), fields(result[j])
+		if desc {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+
+	return result
+}
+
+// hasLabels reports whether labels contains every key and value of want
+func hasLabels(labels, want map[string]string) bool {
+	for key, value := range want {
+		if got, ok := labels[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
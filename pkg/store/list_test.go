@@ -0,0 +1,147 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestFilterAndSortDeployments(t *testing.T) {
+	now := time.Now()
+	deployments := []*types.Deployment{
+		{AppName: "beta", Status: types.DeploymentStatusReady, CreatedAt: now.Add(2 * time.Minute)},
+		{AppName: "alpha", Status: types.DeploymentStatusFailed, CreatedAt: now.Add(3 * time.Minute)},
+		{AppName: "gamma", Status: types.DeploymentStatusReady, CreatedAt: now.Add(time.Minute)},
+	}
+
+	tests := []struct {
+		name     string
+		opts     *types.ListOptions
+		expected []string
+	}{
+		{"default is created_at ascending", nil, []string{"gamma", "beta", "alpha"}},
+		{"created_at descending", &types.ListOptions{SortBy: types.SortByCreatedAt, Order: types.OrderDesc}, []string{"alpha", "beta", "gamma"}},
+		{"app_name ascending", &types.ListOptions{SortBy: types.SortByAppName}, []string{"alpha", "beta", "gamma"}},
+		{"app_name descending", &types.ListOptions{SortBy: types.SortByAppName, Order: types.OrderDesc}, []string{"gamma", "beta", "alpha"}},
+		{"status filter", &types.ListOptions{Status: "ready", SortBy: types.SortByAppName}, []string{"beta", "gamma"}},
+		{"status filter without matches", &types.ListOptions{Status: "deploying"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FilterAndSortDeployments(deployments, tt.opts)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("Expected %d deployments, got %d", len(tt.expected), len(result))
+			}
+			for i, appName := range tt.expected {
+				if result[i].AppName != appName {
+					t.Errorf("Expected %s at position %d, got %s", appName, i, result[i].AppName)
+				}
+			}
+		})
+	}
+
+	// The input slice is not reordered
+	if deployments[0].AppName != "beta" {
+		t.Errorf("Expected input to be left untouched, got %s first", deployments[0].AppName)
+	}
+}
+
+func TestFilterAndSortBuilds(t *testing.T) {
+	now := time.Now()
+	builds := []*types.Build{
+		{AppName: "app", CommitHash: "b", Status: types.BuildStatusBuilt, CreatedAt: now},
+		{AppName: "app", CommitHash: "a", Status: types.BuildStatusFailed, CreatedAt: now.Add(-time.Minute)},
+	}
+
+	result := FilterAndSortBuilds(builds, &types.ListOptions{Status: "built"})
+	if len(result) != 1 || result[0].CommitHash != "b" {
+		t.Errorf("Expected only the built build, got %+v", result)
+	}
+
+	result = FilterAndSortBuilds(builds, &types.ListOptions{})
+	if len(result) != 2 || result[0].CommitHash != "a" {
+		t.Errorf("Expected oldest build first, got %+v", result)
+	}
+}
+
+func TestFilterBySince(t *testing.T) {
+	now := time.Now()
+	deployments := []*types.Deployment{
+		{AppName: "old", Status: types.DeploymentStatusReady, CreatedAt: now.Add(-48 * time.Hour)},
+		{AppName: "recent-failed", Status: types.DeploymentStatusFailed, CreatedAt: now.Add(-30 * time.Minute)},
+		{AppName: "hours-ago", Status: types.DeploymentStatusReady, CreatedAt: now.Add(-3 * time.Hour)},
+		{AppName: "recent", Status: types.DeploymentStatusReady, CreatedAt: now.Add(-5 * time.Minute)},
+	}
+
+	tests := []struct {
+		name     string
+		opts     *types.ListOptions
+		expected []string
+	}{
+		{"duration", &types.ListOptions{Since: "1h"}, []string{"recent-failed", "recent"}},
+		{"wider duration", &types.ListOptions{Since: "24h"}, []string{"hours-ago", "recent-failed", "recent"}},
+		{"timestamp", &types.ListOptions{Since: now.Add(-4 * time.Hour).Format(time.RFC3339)}, []string{"hours-ago", "recent-failed", "recent"}},
+		{"combined with status and order", &types.ListOptions{Since: "24h", Status: "ready", Order: types.OrderDesc}, []string{"recent", "hours-ago"}},
+		{"nothing recent enough", &types.ListOptions{Since: "1m"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FilterAndSortDeployments(deployments, tt.opts)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("Expected %d deployments, got %d", len(tt.expected), len(result))
+			}
+			for i, appName := range tt.expected {
+				if result[i].AppName != appName {
+					t.Errorf("Expected %s at position %d, got %s", appName, i, result[i].AppName)
+				}
+			}
+		})
+	}
+
+	builds := []*types.Build{
+		{CommitHash: "old", CreatedAt: now.Add(-2 * time.Hour)},
+		{CommitHash: "new", CreatedAt: now.Add(-time.Minute)},
+	}
+	result := FilterAndSortBuilds(builds, &types.ListOptions{Since: "1h"})
+	if len(result) != 1 || result[0].CommitHash != "new" {
+		t.Errorf("Expected only the recent build, got %+v", result)
+	}
+}
+
+func TestFilterByLabels(t *testing.T) {
+	deployments := []*types.Deployment{
+		{AppName: "checkout", Labels: map[string]string{"team": "payments", "env": "prod"}},
+		{AppName: "invoices", Labels: map[string]string{"team": "payments", "env": "staging"}},
+		{AppName: "search", Labels: map[string]string{"team": "discovery"}},
+		{AppName: "legacy"},
+	}
+
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		expected []string
+	}{
+		{"no label filter", nil, []string{"checkout", "invoices", "legacy", "search"}},
+		{"single label", map[string]string{"team": "payments"}, []string{"checkout", "invoices"}},
+		{"all labels must match", map[string]string{"team": "payments", "env": "prod"}, []string{"checkout"}},
+		{"empty value matches only empty values", map[string]string{"team": ""}, []string{}},
+		{"unknown label", map[string]string{"ticket": "NINA-1"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FilterAndSortDeployments(deployments, &types.ListOptions{SortBy: types.SortByAppName, Labels: tt.labels})
+			if len(result) != len(tt.expected) {
+				t.Fatalf("Expected %d deployments, got %d", len(tt.expected), len(result))
+			}
+			for i, appName := range tt.expected {
+				if result[i].AppName != appName {
+					t.Errorf("Expected %s at position %d, got %s", appName, i, result[i].AppName)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// DeploymentsChangedChannel is the Redis pub/sub channel the Engine announces deployment changes on,
+// so that the ingress refreshes its routes without waiting for the next refresh interval
+const DeploymentsChangedChannel = "nina-deployments-changed"
+
+// deploymentsChangedBuffer is the number of deployment changes buffered for a slow subscriber
+const deploymentsChangedBuffer = 16
+
+// PublishDeploymentsChanged announces that the deployment of an app changed
+func (s *Store) PublishDeploymentsChanged(ctx context.Context, appName string) error {
+	if err := s.client.Publish(ctx, DeploymentsChangedChannel, appName).Err(); err != nil {
+		return fmt.Errorf("failed to publish deployment change: %w", err)
+	}
+	return nil
+}
+
+// SubscribeDeploymentsChanged subscribes to deployment changes. The returned channel receives the app name
+// of every changed deployment until the returned function ends the subscription and closes it;
+// changes beyond a small buffer are dropped while the receiver is busy.
+// It only returns once the subscription is active, so changes published afterwards are delivered.
+func (s *Store) SubscribeDeploymentsChanged(ctx context.Context) (changes <-chan string, unsubscribe func() error, err error) {
+	pubsub := s.client.Subscribe(ctx, DeploymentsChangedChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, nil, fmt.Errorf("failed to subscribe to deployment changes: %w", err)
+	}
+
+	// Changes are dropped rather than blocking the subscription when the receiver falls behind
+	out := make(chan string, deploymentsChangedBuffer)
+	messages := pubsub.Channel()
+	go func() {
+		defer close(out)
+		for msg := range messages {
+			select {
+			case out <- msg.Payload:
+			default:
+			}
+		}
+	}()
+	return out, pubsub.Close, nil
+}
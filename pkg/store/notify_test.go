@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDeploymentsChanged(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	changes, unsubscribe, err := store.SubscribeDeploymentsChanged(ctx)
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	if err := store.PublishDeploymentsChanged(ctx, "app"); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+	select {
+	case appName := <-changes:
+		if appName != "app" {
+			t.Errorf("Expected a change of app, got %q", appName)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Deployment change was not received")
+	}
+
+	// Ending the subscription closes the channel
+	if err := unsubscribe(); err != nil {
+		t.Errorf("Failed to unsubscribe: %v", err)
+	}
+	select {
+	case _, ok := <-changes:
+		if ok {
+			t.Error("Expected the channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Channel was not closed after unsubscribing")
+	}
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// statsScanCount is the number of keys requested per SCAN call when computing stats
+const statsScanCount = 500
+
+// scanItems iterates the keys matching pattern with SCAN and fetches their values in batches,
+// so that records are visited without loading all of them at once. Keys starting with skipPrefix,
+// when set, are not fetched; keys deleted while scanning are skipped. SCAN may return a key in more
+// than one batch while the keyspace is rehashed, so callers must tolerate an occasional repeat.
+func (s *Store) scanItems(ctx context.Context, pattern, skipPrefix string, visit func(key string, data []byte)) error {
+	var cursor uint64
+	for {
+		keys, next, err := s.client.Scan(ctx, cursor, pattern, statsScanCount).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", pattern, err)
+		}
+
+		batch := make([]string, 0, len(keys))
+		for _, key := range keys {
+			if skipPrefix == "" || !strings.HasPrefix(key, skipPrefix) {
+				batch = append(batch, key)
+			}
+		}
+		if len(batch) > 0 {
+			values, err := s.client.MGet(ctx, batch...).Result()
+			if err != nil {
+				return fmt.Errorf("failed to get %s: %w", pattern, err)
+			}
+			for i, value := range values {
+				if data, ok := value.(string); ok {
+					visit(batch[i], []byte(data))
+				}
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Stats counts the apps, deployments and builds and sums the size of the built images.
+// Legacy deployments are only counted when includeLegacy is set, unless a new deployment of the
+// same app exists. RunningContainers is left unset, it is reported by the Docker daemons.
+func (s *Store) Stats(ctx context.Context, includeLegacy bool) (*types.Stats, error) {
+	stats := &types.Stats{
+		DeploymentsByStatus: make(map[types.DeploymentStatus]int),
+		BuildsByStatus:      make(map[types.BuildStatus]int),
+	}
+	apps := make(map[string]bool)
+	deployed := make(map[string]bool)
+	images := make(map[string]bool)
+
+	err := s.scanItems(ctx, "nina-deployment-*", "", func(key string, data []byte) {
+		var deployment types.Deployment
+		if err := json.Unmarshal(data, &deployment); err != nil {
+			s.logger.Warn("Failed to unmarshal deployment", "key", key, "error", err)
+			return
+		}
+		apps[deployment.AppName] = true
+		deployed[deployment.AppName] = true
+		stats.Deployments++
+		stats.DeploymentsByStatus[deployment.Status]++
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if includeLegacy {
+		err := s.scanItems(ctx, "deployment:*", "deployment:name:", func(key string, data []byte) {
+			var deployment types.LegacyDeployment
+			if err := json.Unmarshal(data, &deployment); err != nil {
+				s.logger.Warn("Failed to unmarshal deployment", "key", key, "error", err)
+				return
+			}
+			if deployed[deployment.Name] {
+				return
+			}
+			apps[deployment.Name] = true
+			stats.Deployments++
+			stats.DeploymentsByStatus[legacyDeploymentStatus(deployment.Status)]++
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.scanItems(ctx, "nina-build-*", "", func(key string, data []byte) {
+		var build types.Build
+		if err := json.Unmarshal(data, &build); err != nil {
+			s.logger.Warn("Failed to unmarshal build", "key", key, "error", err)
+			return
+		}
+		apps[build.AppName] = true
+		stats.Builds++
+		stats.BuildsByStatus[build.Status]++
+		// Builds reusing a cached image share its image ID
+		if build.Status == types.BuildStatusBuilt && build.ImageID != "" && !images[build.ImageID] {
+			images[build.ImageID] = true
+			stats.ImageBytes += build.Size
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Apps = len(apps)
+	return stats, nil
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+func TestStats(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	// Enough builds to span several SCAN batches
+	const failedBuilds = statsScanCount + 20
+	for i := 0; i < failedBuilds; i++ {
+		commitHash := fmt.Sprintf("failed%04d", i)
+		if _, err := store.CreateBuild(ctx, &types.BuildRequest{AppName: "flaky", CommitHash: commitHash}); err != nil {
+			t.Fatalf("Failed to create build: %v", err)
+		}
+		if err := store.UpdateBuildStatus(ctx, commitHash, types.BuildStatusFailed); err != nil {
+			t.Fatalf("Failed to update build: %v", err)
+		}
+	}
+	// Two builds share a cached image, whose size is counted once
+	for _, build := range []struct{ appName, commitHash, imageID string }{
+		{"app", "aaa111", "sha256:one"},
+		{"app-staging", "bbb222", "sha256:one"},
+		{"app", "ccc333", "sha256:two"},
+	} {
+		if _, err := store.CreateBuild(ctx, &types.BuildRequest{AppName: build.appName, CommitHash: build.commitHash}); err != nil {
+			t.Fatalf("Failed to create build: %v", err)
+		}
+		err := store.UpdateBuildWithImage(ctx, build.commitHash, types.BuildStatusBuilt, "nina-"+build.commitHash, build.imageID, 1000, nil)
+		if err != nil {
+			t.Fatalf("Failed to update build: %v", err)
+		}
+	}
+
+	ids := map[string]string{}
+	for _, appName := range []string{"app", "app-staging", "no-builds"} {
+		deployment, err := store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: appName, CommitHash: "aaa111"})
+		if err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+		ids[appName] = deployment.ID
+	}
+	if err := store.UpdateNewDeploymentStatus(ctx, "app", ids["app"], types.DeploymentStatusReady); err != nil {
+		t.Fatalf("Failed to update deployment: %v", err)
+	}
+
+	// A legacy deployment of a new app and one shadowed by the new deployment of app
+	for _, legacy := range []*types.LegacyDeployment{
+		{ID: "legacy1", Name: "legacy", Status: "running", CreatedAt: time.Now()},
+		{ID: "legacy2", Name: "app", Status: "failed", CreatedAt: time.Now()},
+	} {
+		data, err := json.Marshal(legacy)
+		if err != nil {
+			t.Fatalf("Failed to marshal deployment: %v", err)
+		}
+		store.client.Set(ctx, "deployment:"+legacy.ID, data, 0)
+		store.client.Set(ctx, "deployment:name:"+legacy.Name, legacy.ID, 0)
+	}
+
+	stats, err := store.Stats(ctx, false)
+	if err != nil {
+		t.Fatalf("Failed to compute stats: %v", err)
+	}
+	if stats.Apps != 4 {
+		t.Errorf("Expected 4 apps, got %d", stats.Apps)
+	}
+	if stats.Builds != failedBuilds+3 {
+		t.Errorf("Expected %d builds, got %d", failedBuilds+3, stats.Builds)
+	}
+	if stats.BuildsByStatus[types.BuildStatusFailed] != failedBuilds || stats.BuildsByStatus[types.BuildStatusBuilt] != 3 {
+		t.Errorf("Unexpected builds by status: %v", stats.BuildsByStatus)
+	}
+	if stats.ImageBytes != 2000 {
+		t.Errorf("Expected 2000 image bytes, got %d", stats.ImageBytes)
+	}
+	if stats.Deployments != 3 {
+		t.Errorf("Expected 3 deployments, got %d", stats.Deployments)
+	}
+	if stats.DeploymentsByStatus[types.DeploymentStatusReady] != 1 || stats.DeploymentsByStatus[types.DeploymentStatusUnavailable] != 2 {
+		t.Errorf("Unexpected deployments by status: %v", stats.DeploymentsByStatus)
+	}
+	if stats.RunningContainers != nil {
+		t.Errorf("Expected running containers to be left unset, got %d", *stats.RunningContainers)
+	}
+
+	stats, err = store.Stats(ctx, true)
+	if err != nil {
+		t.Fatalf("Failed to compute stats: %v", err)
+	}
+	if stats.Apps != 5 || stats.Deployments != 4 {
+		t.Errorf("Expected 5 apps and 4 deployments with legacy deployments, got %d and %d", stats.Apps, stats.Deployments)
+	}
+	if stats.DeploymentsByStatus[types.DeploymentStatusReady] != 2 || stats.DeploymentsByStatus[types.DeploymentStatusFailed] != 0 {
+		t.Errorf("Unexpected deployments by status: %v", stats.DeploymentsByStatus)
+	}
+}
+
+func TestScanItems_SkipPrefix(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+	store.client.Set(ctx, "deployment:legacy1", `{"id":"legacy1"}`, 0)
+	store.client.Set(ctx, "deployment:name:legacy", "legacy1", 0)
+
+	var visited []string
+	err := store.scanItems(ctx, "deployment:*", "deployment:name:", func(key string, _ []byte) {
+		visited = append(visited, key)
+	})
+	if err != nil {
+		t.Fatalf("Failed to scan: %v", err)
+	}
+	if len(visited) != 1 || visited[0] != "deployment:legacy1" {
+		t.Errorf("Expected the name index to be skipped, visited %v", visited)
+	}
+}
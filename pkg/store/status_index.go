@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// deploymentStatuses are the statuses deployments are indexed by
+var deploymentStatuses = []types.DeploymentStatus{
+	types.DeploymentStatusUnavailable,
+	types.DeploymentStatusDeploying,
+	types.DeploymentStatusReady,
+	types.DeploymentStatusPartiallyReady,
+	types.DeploymentStatusFailed,
+}
+
+// deploymentStatusKey returns the key of the set holding the app names of the deployments with a status
+func deploymentStatusKey(status types.DeploymentStatus) string {
+	return fmt.Sprintf("nina-deployments-by-status:%s", status)
+}
+
+// indexDeploymentStatus moves the app to the status set of its deployment; an empty status only
+// removes it from the index. It is queued on pipe so that it is applied with the deployment write.
+func indexDeploymentStatus(ctx context.Context, pipe redis.Pipeliner, appName string, status types.DeploymentStatus) {
+	for _, indexed := range deploymentStatuses {
+		if indexed != status {
+			pipe.SRem(ctx, deploymentStatusKey(indexed), appName)
+		}
+	}
+	if status != "" {
+		pipe.SAdd(ctx, deploymentStatusKey(status), appName)
+	}
+}
+
+// ListNewDeploymentsByStatus lists the deployments with any of the given statuses using the status index,
+// sorted by creation time
+func (s *Store) ListNewDeploymentsByStatus(ctx context.Context, statuses ...types.DeploymentStatus) ([]*types.Deployment, error) {
+	if len(statuses) == 0 {
+		return []*types.Deployment{}, nil
+	}
+	setKeys := make([]string, 0, len(statuses))
+	wanted := make(map[types.DeploymentStatus]bool, len(statuses))
+	for _, status := range statuses {
+		setKeys = append(setKeys, deploymentStatusKey(status))
+		wanted[status] = true
+	}
+
+	appNames, err := s.client.SUnion(ctx, setKeys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployments by status: %w", err)
+	}
+	if len(appNames) == 0 {
+		return []*types.Deployment{}, nil
+	}
+
+	keys := make([]string, 0, len(appNames))
+	for _, appName := range appNames {
+		keys = append(keys, fmt.Sprintf("nina-deployment-%s", appName))
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployments: %w", err)
+	}
+
+	deployments := make([]*types.Deployment, 0, len(values))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			// Deleted since the index was read
+			continue
+		}
+		var deployment types.Deployment
+		if err := json.Unmarshal([]byte(data), &deployment); err != nil {
+			s.logger.Warn("Failed to unmarshal deployment", "key", keys[i], "error", err)
+			continue
+		}
+		// Skip deployments whose status changed since the index was read
+		if !wanted[deployment.Status] {
+			continue
+		}
+		deployments = append(deployments, &deployment)
+	}
+
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].CreatedAt.Before(deployments[j].CreatedAt)
+	})
+	return deployments, nil
+}
+
+// RebuildDeploymentStatusIndex rebuilds the status index from the stored deployments,
+// indexing deployments that were stored before the index existed
+func (s *Store) RebuildDeploymentStatusIndex(ctx context.Context) error {
+	deployments, err := s.ListNewDeployments(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, status := range deploymentStatuses {
+			pipe.Del(ctx, deploymentStatusKey(status))
+		}
+		for _, deployment := range deployments {
+			if deployment.Status != "" {
+				pipe.SAdd(ctx, deploymentStatusKey(deployment.Status), deployment.AppName)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rebuild deployment status index: %w", err)
+	}
+
+	s.logger.Info("Rebuilt deployment status index", "deployments", len(deployments))
+	return nil
+}
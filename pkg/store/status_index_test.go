@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
+)
+
+// appNamesByStatus lists the app names of the deployments with any of the given statuses
+func appNamesByStatus(t *testing.T, store *Store, statuses ...types.DeploymentStatus) []string {
+	t.Helper()
+	deployments, err := store.ListNewDeploymentsByStatus(context.Background(), statuses...)
+	if err != nil {
+		t.Fatalf("Failed to list deployments by status: %v", err)
+	}
+	names := make([]string, 0, len(deployments))
+	for _, deployment := range deployments {
+		names = append(names, deployment.AppName)
+	}
+	return names
+}
+
+func assertNames(t *testing.T, got []string, expected ...string) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestListNewDeploymentsByStatus_Transitions(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	ids := map[string]string{}
+	for _, appName := range []string{"first", "second"} {
+		deployment, err := store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: appName, CommitHash: "abc123"})
+		if err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+		ids[appName] = deployment.ID
+	}
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusUnavailable), "first", "second")
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusReady))
+
+	if err := store.UpdateNewDeploymentStatus(ctx, "first", ids["first"], types.DeploymentStatusDeploying); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	containers := []types.Container{{ContainerID: "c1", Address: "localhost", Port: 8080}}
+	if err := store.UpdateNewDeploymentWithContainers(ctx, "second", ids["second"], containers, types.DeploymentStatusReady); err != nil {
+		t.Fatalf("Failed to update containers: %v", err)
+	}
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusUnavailable))
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusDeploying), "first")
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusReady), "second")
+
+	if err := store.UpdateNewDeploymentWithContainers(ctx, "first", ids["first"], containers, types.DeploymentStatusPartiallyReady); err != nil {
+		t.Fatalf("Failed to update containers: %v", err)
+	}
+	if err := store.UpdateNewDeploymentStatus(ctx, "second", ids["second"], types.DeploymentStatusFailed); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusDeploying))
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusReady, types.DeploymentStatusPartiallyReady), "first")
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusFailed), "second")
+
+	// Recreating a deployment moves it back to unavailable
+	if _, err := store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: "first", CommitHash: "def456"}); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusPartiallyReady))
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusUnavailable), "first")
+
+	// Deleted deployments leave the index
+	if err := store.DeleteNewDeployment(ctx, "second"); err != nil {
+		t.Fatalf("Failed to delete deployment: %v", err)
+	}
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusFailed))
+	for _, status := range deploymentStatuses {
+		if members := store.client.SMembers(ctx, deploymentStatusKey(status)).Val(); len(members) > 1 {
+			t.Errorf("Expected at most one member in %s, got %v", status, members)
+		}
+	}
+
+	assertNames(t, appNamesByStatus(t, store))
+}
+
+func TestListNewDeploymentsByStatus_SkipsStaleEntries(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	if _, err := store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: "app", CommitHash: "abc123"}); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	// Index entries whose record is missing or has another status are ignored
+	store.client.SAdd(ctx, deploymentStatusKey(types.DeploymentStatusReady), "app", "missing")
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusReady))
+}
+
+func TestRebuildDeploymentStatusIndex(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	// A deployment stored before the index existed and a stale index entry
+	data, err := json.Marshal(&types.Deployment{AppName: "legacy", Status: types.DeploymentStatusReady})
+	if err != nil {
+		t.Fatalf("Failed to marshal deployment: %v", err)
+	}
+	store.client.Set(ctx, "nina-deployment-legacy", data, 0)
+	store.client.SAdd(ctx, deploymentStatusKey(types.DeploymentStatusFailed), "gone")
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusReady))
+
+	if err := store.RebuildDeploymentStatusIndex(ctx); err != nil {
+		t.Fatalf("Failed to rebuild index: %v", err)
+	}
+	assertNames(t, appNamesByStatus(t, store, types.DeploymentStatusReady), "legacy")
+	if members := store.client.SMembers(ctx, deploymentStatusKey(types.DeploymentStatusFailed)).Val(); len(members) != 0 {
+		t.Errorf("Expected the stale entry to be dropped, got %v", members)
+	}
+}
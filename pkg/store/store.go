@@ -4,8 +4,10 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,6 +17,19 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrNotFound is returned, wrapped, when a requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrAmbiguous is returned, wrapped, when a short commit hash matches more than one build
+var ErrAmbiguous = errors.New("is ambiguous")
+
+// ErrReplaced is returned, wrapped, when a deployment update targets a deployment that a newer deployment
+// of the same app replaced
+var ErrReplaced = errors.New("was replaced")
+
+// MinCommitPrefixLength is the shortest commit hash prefix resolved to a full commit hash
+const MinCommitPrefixLength = 4
+
 // Store represents the Redis store
 type Store struct {
 	client *redis.Client
@@ -22,26 +37,6 @@ type Store struct {
 	config *config.Config
 }
 
-// Deployment represents a container deployment
-type Deployment struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Image       string            `json:"image"`
-	Status      string            `json:"status"`
-	Ports       []int             `json:"ports"`
-	Environment map[string]string `json:"environment"`
-	CreatedAt   time.Time         `json:"created_at"`
-	UpdatedAt   time.Time         `json:"updated_at"`
-}
-
-// ProvisionRequest represents a request to provision a container
-type ProvisionRequest struct {
-	Name        string            `json:"name"`
-	Image       string            `json:"image"`
-	Ports       []int             `json:"ports"`
-	Environment map[string]string `json:"environment"`
-}
-
 // NewStore creates a new Redis store instance
 func NewStore(cfg *config.Config, log *logger.Logger) (*Store, error) {
 	client := redis.NewClient(&redis.Options{
@@ -75,9 +70,17 @@ func (s *Store) Close() error {
 	return nil
 }
 
+// RebuildIndexes rebuilds the secondary indexes from the stored deployments and builds
+func (s *Store) RebuildIndexes(ctx context.Context) error {
+	if err := s.RebuildDeploymentStatusIndex(ctx); err != nil {
+		return err
+	}
+	return s.RebuildBuildsByAppIndex(ctx)
+}
+
 // CreateDeployment creates a new deployment
-func (s *Store) CreateDeployment(ctx context.Context, req *ProvisionRequest) (*Deployment, error) {
-	deployment := &Deployment{
+func (s *Store) CreateDeployment(ctx context.Context, req *types.ProvisionRequest) (*types.LegacyDeployment, error) {
+	deployment := &types.LegacyDeployment{
 		ID:          generateID(),
 		Name:        req.Name,
 		Image:       req.Image,
@@ -120,6 +123,13 @@ func (s *Store) CreateNewDeployment(ctx context.Context, req *types.DeploymentRe
 		CommitMessage: req.CommitMessage,
 		Status:        types.DeploymentStatusUnavailable,
 		Containers:    []types.Container{},
+		Replicas:      req.Replicas,
+		Node:          req.Node,
+		Env:           req.Env,
+		Labels:        req.Labels,
+		Domains:       req.Domains,
+		Image:         req.Image,
+		WebhookURLs:   req.WebhookURLs,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
@@ -131,7 +141,12 @@ func (s *Store) CreateNewDeployment(ctx context.Context, req *types.DeploymentRe
 		return nil, fmt.Errorf("failed to marshal deployment: %w", err)
 	}
 
-	if err := s.client.Set(ctx, key, data, 0).Err(); err != nil {
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, data, 0)
+		indexDeploymentStatus(ctx, pipe, req.AppName, deployment.Status)
+		return nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to store deployment: %w", err)
 	}
 
@@ -140,17 +155,17 @@ func (s *Store) CreateNewDeployment(ctx context.Context, req *types.DeploymentRe
 }
 
 // GetDeployment retrieves a deployment by ID
-func (s *Store) GetDeployment(ctx context.Context, id string) (*Deployment, error) {
+func (s *Store) GetDeployment(ctx context.Context, id string) (*types.LegacyDeployment, error) {
 	key := fmt.Sprintf("deployment:%s", id)
 	data, err := s.client.Get(ctx, key).Bytes()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, fmt.Errorf("deployment not found: %s", id)
+			return nil, fmt.Errorf("deployment %w: %s", ErrNotFound, id)
 		}
 		return nil, fmt.Errorf("failed to get deployment: %w", err)
 	}
 
-	var deployment Deployment
+	var deployment types.LegacyDeployment
 	if err := json.Unmarshal(data, &deployment); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal deployment: %w", err)
 	}
@@ -176,12 +191,12 @@ func (s *Store) GetNewDeployment(ctx context.Context, appName string) (*types.De
 }
 
 // GetDeploymentByName retrieves a deployment by name
-func (s *Store) GetDeploymentByName(ctx context.Context, name string) (*Deployment, error) {
+func (s *Store) GetDeploymentByName(ctx context.Context, name string) (*types.LegacyDeployment, error) {
 	nameKey := fmt.Sprintf("deployment:name:%s", name)
 	deploymentID, err := s.client.Get(ctx, nameKey).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, fmt.Errorf("deployment not found: %s", name)
+			return nil, fmt.Errorf("deployment %w: %s", ErrNotFound, name)
 		}
 		return nil, fmt.Errorf("failed to get deployment ID: %w", err)
 	}
@@ -213,55 +228,101 @@ func (s *Store) UpdateDeploymentStatus(ctx context.Context, id, status string) e
 	return nil
 }
 
-// UpdateNewDeploymentStatus updates the status of a new deployment
-func (s *Store) UpdateNewDeploymentStatus(ctx context.Context, appName string, status types.DeploymentStatus) error {
-	deployment, err := s.GetNewDeployment(ctx, appName)
+// UpdateNewDeploymentStatus updates the status of a new deployment. The update fails with ErrReplaced when the
+// deployment of the app is no longer the one with the given ID.
+func (s *Store) UpdateNewDeploymentStatus(ctx context.Context, appName, id string, status types.DeploymentStatus) error {
+	err := s.updateNewDeployment(ctx, appName, id, func(deployment *types.Deployment) {
+		deployment.Status = status
+	})
 	if err != nil {
 		return err
 	}
 
-	deployment.Status = status
-	deployment.UpdatedAt = time.Now()
-
-	key := fmt.Sprintf("nina-deployment-%s", appName)
-	data, err := json.Marshal(deployment)
-	if err != nil {
-		return fmt.Errorf("failed to marshal deployment: %w", err)
-	}
-
-	if err := s.client.Set(ctx, key, data, 0).Err(); err != nil {
-		return fmt.Errorf("failed to update deployment: %w", err)
-	}
-
-	s.logger.Info("Updated new deployment status", "app_name", appName, "status", status)
+	s.logger.Info("Updated new deployment status", "app_name", appName, "id", id, "status", status)
 	return nil
 }
 
-// UpdateNewDeploymentWithContainers updates a deployment with container information
-func (s *Store) UpdateNewDeploymentWithContainers(ctx context.Context, appName string, containers []types.Container,
+// UpdateNewDeploymentWithContainers updates a deployment with container information, clearing its replica failures
+func (s *Store) UpdateNewDeploymentWithContainers(ctx context.Context, appName, id string, containers []types.Container,
 	status types.DeploymentStatus,
 ) error {
-	deployment, err := s.GetNewDeployment(ctx, appName)
+	return s.UpdateNewDeploymentWithFailures(ctx, appName, id, containers, nil, status)
+}
+
+// UpdateNewDeploymentWithFailures updates a deployment with its containers and status, recording the replicas
+// that failed to come up. The failures replace the ones of the previous update. The update fails with
+// ErrReplaced when the deployment of the app is no longer the one with the given ID.
+func (s *Store) UpdateNewDeploymentWithFailures(ctx context.Context, appName, id string, containers []types.Container,
+	failures []types.ReplicaFailure, status types.DeploymentStatus,
+) error {
+	err := s.updateNewDeployment(ctx, appName, id, func(deployment *types.Deployment) {
+		deployment.Containers = containers
+		deployment.FailedReplicas = failures
+		deployment.Status = status
+	})
 	if err != nil {
 		return err
 	}
 
-	deployment.Containers = containers
-	deployment.Status = status
-	deployment.UpdatedAt = time.Now()
+	s.logger.Info("Updated deployment with containers", "app_name", appName, "id", id,
+		"containers_count", len(containers), "failed_replicas", len(failures), "status", status)
+	return nil
+}
+
+// maxUpdateRetries bounds how often a deployment update is retried after losing a race
+const maxUpdateRetries = 50
 
+// updateNewDeployment applies mutate to the stored deployment inside a WATCH/MULTI transaction,
+// so that concurrent updates to the same deployment are retried instead of overwriting each other.
+// The stored deployment must still have the given ID, a deployment that replaced it is left alone.
+func (s *Store) updateNewDeployment(ctx context.Context, appName, id string, mutate func(*types.Deployment)) error {
 	key := fmt.Sprintf("nina-deployment-%s", appName)
-	data, err := json.Marshal(deployment)
-	if err != nil {
-		return fmt.Errorf("failed to marshal deployment: %w", err)
+
+	update := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil {
+			if err == redis.Nil {
+				return fmt.Errorf("deployment %w: %s", ErrNotFound, appName)
+			}
+			return fmt.Errorf("failed to get deployment: %w", err)
+		}
+
+		var deployment types.Deployment
+		if err := s.unmarshalItem(data, &deployment, "deployment"); err != nil {
+			return err
+		}
+		if deployment.ID != id {
+			return fmt.Errorf("deployment %s of %s %w by %s", id, appName, ErrReplaced, deployment.ID)
+		}
+
+		mutate(&deployment)
+		deployment.UpdatedAt = time.Now()
+
+		data, err = json.Marshal(&deployment)
+		if err != nil {
+			return fmt.Errorf("failed to marshal deployment: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, 0)
+			indexDeploymentStatus(ctx, pipe, appName, deployment.Status)
+			return nil
+		})
+		return err
 	}
 
-	if err := s.client.Set(ctx, key, data, 0).Err(); err != nil {
-		return fmt.Errorf("failed to update deployment: %w", err)
+	for attempt := 0; attempt < maxUpdateRetries; attempt++ {
+		err := s.client.Watch(ctx, update, key)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+		s.logger.Debug("Deployment changed during update, retrying", "app_name", appName, "attempt", attempt+1)
 	}
 
-	s.logger.Info("Updated deployment with containers", "app_name", appName, "containers_count", len(containers), "status", status)
-	return nil
+	return fmt.Errorf("failed to update deployment %s: too many concurrent updates", appName)
 }
 
 // DeleteDeployment deletes a deployment
@@ -290,7 +351,12 @@ func (s *Store) DeleteDeployment(ctx context.Context, id string) error {
 // DeleteNewDeployment deletes a new deployment by app name
 func (s *Store) DeleteNewDeployment(ctx context.Context, appName string) error {
 	key := fmt.Sprintf("nina-deployment-%s", appName)
-	if err := s.client.Del(ctx, key).Err(); err != nil {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		indexDeploymentStatus(ctx, pipe, appName, "")
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to delete deployment: %w", err)
 	}
 
@@ -299,14 +365,14 @@ func (s *Store) DeleteNewDeployment(ctx context.Context, appName string) error {
 }
 
 // ListDeployments lists all deployments
-func (s *Store) ListDeployments(ctx context.Context) ([]*Deployment, error) {
+func (s *Store) ListDeployments(ctx context.Context) ([]*types.LegacyDeployment, error) {
 	pattern := "deployment:*"
 	keys, err := s.client.Keys(ctx, pattern).Result()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get deployment keys: %w", err)
 	}
 
-	deployments := make([]*Deployment, 0, len(keys))
+	deployments := make([]*types.LegacyDeployment, 0, len(keys))
 	for _, key := range keys {
 		// Skip name mappings
 		if len(key) > 14 && key[:14] == "deployment:name" {
@@ -324,7 +390,7 @@ func (s *Store) ListDeployments(ctx context.Context) ([]*Deployment, error) {
 			continue
 		}
 
-		var deployment Deployment
+		var deployment types.LegacyDeployment
 		if err := json.Unmarshal(data, &deployment); err != nil {
 			s.logger.Warn("Failed to unmarshal deployment", "key", key, "error", err)
 			continue
@@ -345,6 +411,140 @@ func (s *Store) ListNewDeployments(ctx context.Context) ([]*types.Deployment, er
 	return items.([]*types.Deployment), nil
 }
 
+// ListAllDeployments lists new and legacy deployments, converting legacy records to types.Deployment.
+// A legacy record is skipped when a new deployment with the same app name exists.
+func (s *Store) ListAllDeployments(ctx context.Context) ([]*types.Deployment, error) {
+	deployments, err := s.ListNewDeployments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	legacy, err := s.ListDeployments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	appNames := make(map[string]bool, len(deployments))
+	for _, deployment := range deployments {
+		appNames[deployment.AppName] = true
+	}
+	for _, deployment := range legacy {
+		if appNames[deployment.Name] {
+			continue
+		}
+		deployments = append(deployments, LegacyToDeployment(deployment))
+	}
+
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].CreatedAt.Before(deployments[j].CreatedAt)
+	})
+	return deployments, nil
+}
+
+// ListAllDeploymentsByAppName lists the new or legacy deployment for the given app name
+func (s *Store) ListAllDeploymentsByAppName(ctx context.Context, appName string) ([]*types.Deployment, error) {
+	deployments, err := s.ListNewDeploymentsByAppName(ctx, appName)
+	if err != nil || len(deployments) > 0 {
+		return deployments, err
+	}
+
+	legacy, err := s.GetDeploymentByName(ctx, appName)
+	if errors.Is(err, ErrNotFound) {
+		// A missing legacy deployment is not an error, there is simply nothing to list
+		return []*types.Deployment{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []*types.Deployment{LegacyToDeployment(legacy)}, nil
+}
+
+// LegacyToDeployment converts a legacy deployment into the types.Deployment shape.
+// Each legacy port becomes a container entry; commit information is not available and left empty.
+func LegacyToDeployment(d *types.LegacyDeployment) *types.Deployment {
+	containers := make([]types.Container, 0, len(d.Ports))
+	for _, port := range d.Ports {
+		containers = append(containers, types.Container{ImageTag: d.Image, Port: port})
+	}
+
+	return &types.Deployment{
+		ID:         d.ID,
+		AppName:    d.Name,
+		Containers: containers,
+		Env:        d.Environment,
+		Status:     legacyDeploymentStatus(d.Status),
+		CreatedAt:  d.CreatedAt,
+		UpdatedAt:  d.UpdatedAt,
+	}
+}
+
+// MigrationResult summarizes a migration of legacy deployment records
+type MigrationResult struct {
+	// Migrated is the number of legacy deployments written under the new key
+	Migrated int
+	// Skipped is the number of legacy deployments that already had a new record
+	Skipped int
+	// Deleted is the number of legacy deployments removed after migration
+	Deleted int
+}
+
+// MigrateLegacyDeployments copies every legacy deployment to a nina-deployment-* record.
+// Existing new records are never overwritten, so running the migration again is safe.
+// When deleteOld is set the legacy keys are removed once a new record exists.
+func (s *Store) MigrateLegacyDeployments(ctx context.Context, deleteOld bool) (*MigrationResult, error) {
+	legacy, err := s.ListDeployments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MigrationResult{}
+	for _, old := range legacy {
+		data, err := json.Marshal(LegacyToDeployment(old))
+		if err != nil {
+			return result, fmt.Errorf("failed to marshal deployment %s: %w", old.ID, err)
+		}
+
+		key := fmt.Sprintf("nina-deployment-%s", old.Name)
+		created, err := s.client.SetNX(ctx, key, data, 0).Result()
+		if err != nil {
+			return result, fmt.Errorf("failed to store migrated deployment %s: %w", old.ID, err)
+		}
+		if created {
+			if err := s.client.SAdd(ctx, deploymentStatusKey(legacyDeploymentStatus(old.Status)), old.Name).Err(); err != nil {
+				return result, fmt.Errorf("failed to index migrated deployment %s: %w", old.ID, err)
+			}
+			result.Migrated++
+			s.logger.Info("Migrated legacy deployment", "id", old.ID, "app_name", old.Name)
+		} else {
+			result.Skipped++
+			s.logger.Debug("Deployment already migrated", "id", old.ID, "app_name", old.Name)
+		}
+
+		if deleteOld {
+			if err := s.DeleteDeployment(ctx, old.ID); err != nil {
+				return result, fmt.Errorf("failed to delete legacy deployment %s: %w", old.ID, err)
+			}
+			result.Deleted++
+		}
+	}
+
+	return result, nil
+}
+
+// legacyDeploymentStatus maps a legacy status string to a types.DeploymentStatus
+func legacyDeploymentStatus(status string) types.DeploymentStatus {
+	switch status {
+	case "creating":
+		return types.DeploymentStatusDeploying
+	case "running":
+		return types.DeploymentStatusReady
+	case "failed":
+		return types.DeploymentStatusFailed
+	default:
+		return types.DeploymentStatusUnavailable
+	}
+}
+
 // getItemByKeyAndUnmarshal is a helper function to get and unmarshal a single item by key
 func (s *Store) getItemByKeyAndUnmarshal(ctx context.Context, key string, item interface{}, itemType string) error {
 	data, err := s.client.Get(ctx, key).Bytes()
@@ -402,7 +602,25 @@ func (s *Store) CreateBuild(ctx context.Context, req *types.BuildRequest) (*type
 		return nil, fmt.Errorf("failed to marshal build: %w", err)
 	}
 
-	if err := s.client.Set(ctx, key, data, 0).Err(); err != nil {
+	// A commit rebuilt for another app leaves the index of its previous app
+	var previous types.Build
+	previousData, err := s.client.Get(ctx, key).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to get build: %w", err)
+	}
+	if previousData != nil {
+		_ = json.Unmarshal(previousData, &previous)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, data, 0)
+		if previous.AppName != "" && previous.AppName != req.AppName {
+			pipe.SRem(ctx, buildsByAppKey(previous.AppName), req.CommitHash)
+		}
+		pipe.SAdd(ctx, buildsByAppKey(req.AppName), req.CommitHash)
+		return nil
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to store build: %w", err)
 	}
 
@@ -410,8 +628,60 @@ func (s *Store) CreateBuild(ctx context.Context, req *types.BuildRequest) (*type
 	return build, nil
 }
 
-// GetBuild retrieves a build by commit hash
+// ResolveCommitHash returns the full commit hash of the build whose commit hash starts with prefix.
+// A prefix that is a stored commit hash is returned as is. Prefixes shorter than MinCommitPrefixLength
+// are not expanded, and a prefix matching several builds returns ErrAmbiguous.
+func (s *Store) ResolveCommitHash(ctx context.Context, prefix string) (string, error) {
+	exists, err := s.client.Exists(ctx, fmt.Sprintf("nina-build-%s", prefix)).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to get build: %w", err)
+	}
+	if exists > 0 || len(prefix) < MinCommitPrefixLength {
+		return prefix, nil
+	}
+
+	builds, err := s.ListBuilds(ctx)
+	if err != nil {
+		return "", err
+	}
+	matches := matchCommitPrefix(builds, prefix)
+	switch len(matches) {
+	case 0:
+		return prefix, nil
+	case 1:
+		return matches[0].CommitHash, nil
+	default:
+		return "", ambiguousCommitError(prefix, matches)
+	}
+}
+
+// matchCommitPrefix returns the builds whose commit hash starts with prefix
+func matchCommitPrefix(builds []*types.Build, prefix string) []*types.Build {
+	var matches []*types.Build
+	for _, build := range builds {
+		if strings.HasPrefix(build.CommitHash, prefix) {
+			matches = append(matches, build)
+		}
+	}
+	return matches
+}
+
+// ambiguousCommitError lists the commit hashes matched by an ambiguous prefix
+func ambiguousCommitError(prefix string, matches []*types.Build) error {
+	hashes := make([]string, 0, len(matches))
+	for _, build := range matches {
+		hashes = append(hashes, build.CommitHash)
+	}
+	sort.Strings(hashes)
+	return fmt.Errorf("commit hash %s %w, it matches %s", prefix, ErrAmbiguous, strings.Join(hashes, ", "))
+}
+
+// GetBuild retrieves a build by commit hash or an unambiguous prefix of it
 func (s *Store) GetBuild(ctx context.Context, commitHash string) (*types.Build, error) {
+	commitHash, err := s.ResolveCommitHash(ctx, commitHash)
+	if err != nil {
+		return nil, err
+	}
 	key := fmt.Sprintf("nina-build-%s", commitHash)
 
 	data, err := s.getItemByKey(ctx, key, "build")
@@ -427,7 +697,7 @@ func (s *Store) GetBuild(ctx context.Context, commitHash string) (*types.Build,
 	return &build, nil
 }
 
-// UpdateBuildStatus updates the status of a build
+// UpdateBuildStatus updates the status of a build, found by commit hash or an unambiguous prefix of it
 func (s *Store) UpdateBuildStatus(ctx context.Context, commitHash string, status types.BuildStatus) error {
 	build, err := s.GetBuild(ctx, commitHash)
 	if err != nil {
@@ -439,7 +709,7 @@ func (s *Store) UpdateBuildStatus(ctx context.Context, commitHash string, status
 		build.FinishedAt = time.Now()
 	}
 
-	key := fmt.Sprintf("nina-build-%s", commitHash)
+	key := fmt.Sprintf("nina-build-%s", build.CommitHash)
 	data, err := json.Marshal(build)
 	if err != nil {
 		return fmt.Errorf("failed to marshal build: %w", err)
@@ -449,13 +719,38 @@ func (s *Store) UpdateBuildStatus(ctx context.Context, commitHash string, status
 		return fmt.Errorf("failed to update build: %w", err)
 	}
 
-	s.logger.Info("Updated build status", "commit_hash", commitHash, "status", status)
+	s.logger.Info("Updated build status", "commit_hash", build.CommitHash, "status", status)
+	return nil
+}
+
+// UpdateBuildFailure marks a build as failed and records why it failed
+func (s *Store) UpdateBuildFailure(ctx context.Context, commitHash, failureLog string) error {
+	build, err := s.GetBuild(ctx, commitHash)
+	if err != nil {
+		return err
+	}
+
+	build.Status = types.BuildStatusFailed
+	build.FailureLog = failureLog
+	build.FinishedAt = time.Now()
+
+	key := fmt.Sprintf("nina-build-%s", build.CommitHash)
+	data, err := json.Marshal(build)
+	if err != nil {
+		return fmt.Errorf("failed to marshal build: %w", err)
+	}
+
+	if err := s.client.Set(ctx, key, data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to update build: %w", err)
+	}
+
+	s.logger.Info("Recorded build failure", "commit_hash", build.CommitHash)
 	return nil
 }
 
 // UpdateBuildWithImage updates a build with image information
 func (s *Store) UpdateBuildWithImage(ctx context.Context, commitHash string, status types.BuildStatus, imageTag, imageID string,
-	size int64,
+	size int64, exposedPorts []int,
 ) error {
 	build, err := s.GetBuild(ctx, commitHash)
 	if err != nil {
@@ -466,11 +761,12 @@ func (s *Store) UpdateBuildWithImage(ctx context.Context, commitHash string, sta
 	build.ImageTag = imageTag
 	build.ImageID = imageID
 	build.Size = size
+	build.ExposedPorts = exposedPorts
 	if status == types.BuildStatusBuilt || status == types.BuildStatusFailed {
 		build.FinishedAt = time.Now()
 	}
 
-	key := fmt.Sprintf("nina-build-%s", commitHash)
+	key := fmt.Sprintf("nina-build-%s", build.CommitHash)
 	data, err := json.Marshal(build)
 	if err != nil {
 		return fmt.Errorf("failed to marshal build: %w", err)
@@ -480,10 +776,32 @@ func (s *Store) UpdateBuildWithImage(ctx context.Context, commitHash string, sta
 		return fmt.Errorf("failed to update build: %w", err)
 	}
 
-	s.logger.Info("Updated build with image", "commit_hash", commitHash, "status", status, "image_tag", imageTag)
+	s.logger.Info("Updated build with image", "commit_hash", build.CommitHash, "status", status, "image_tag", imageTag)
+	return nil
+}
+
+// SaveBuildLog stores the output of a build, expiring it after ttl unless ttl is zero
+func (s *Store) SaveBuildLog(ctx context.Context, commitHash, log string, ttl time.Duration) error {
+	key := fmt.Sprintf("nina-buildlog-%s", commitHash)
+	if err := s.client.Set(ctx, key, log, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store build log: %w", err)
+	}
+
+	s.logger.Info("Stored build log", "commit_hash", commitHash, "size", len(log), "ttl", ttl)
 	return nil
 }
 
+// GetBuildLog retrieves the output of a build
+func (s *Store) GetBuildLog(ctx context.Context, commitHash string) (string, error) {
+	key := fmt.Sprintf("nina-buildlog-%s", commitHash)
+
+	data, err := s.getItemByKey(ctx, key, "build log")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 // ListBuilds retrieves all builds
 func (s *Store) ListBuilds(ctx context.Context) ([]*types.Build, error) {
 	items, err := s.listItems(ctx, "nina-build-*", "build", &types.Build{})
@@ -508,35 +826,48 @@ func (s *Store) ListBuildsByCommitHash(ctx context.Context, commitHash string) (
 	return []*types.Build{&build}, nil
 }
 
-// DeleteBuilds deletes builds by app name or commit hash
+// DeleteBuilds deletes builds by app name or commit hash.
+// When nothing matches exactly, id is resolved as a commit hash prefix like in GetBuild.
 func (s *Store) DeleteBuilds(ctx context.Context, id string) (deletedKeys []string, count int, err error) {
-	pattern := "nina-build-*"
-	keys, err := s.client.Keys(ctx, pattern).Result()
+	matches, err := s.ListBuildsByAppName(ctx, id)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list builds: %w", err)
+	}
+	byCommit, err := s.ListBuildsByCommitHash(ctx, id)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get build keys: %w", err)
+		return nil, 0, fmt.Errorf("failed to list builds: %w", err)
+	}
+	for _, build := range byCommit {
+		if build.AppName != id {
+			matches = append(matches, build)
+		}
 	}
 
-	for _, key := range keys {
-		data, err := s.client.Get(ctx, key).Bytes()
+	// Only prefixes need the full list of builds
+	if len(matches) == 0 && len(id) >= MinCommitPrefixLength {
+		builds, err := s.ListBuilds(ctx)
 		if err != nil {
-			s.logger.Warn("Failed to get build data", "key", key, "error", err)
-			continue
+			return nil, 0, fmt.Errorf("failed to list builds: %w", err)
 		}
-
-		var build types.Build
-		if err := json.Unmarshal(data, &build); err != nil {
-			s.logger.Warn("Failed to unmarshal build", "key", key, "error", err)
-			continue
+		matches = matchCommitPrefix(builds, id)
+		if len(matches) > 1 {
+			return nil, 0, ambiguousCommitError(id, matches)
 		}
+	}
 
-		// Check if this build matches the ID (app name or commit hash)
-		if build.AppName == id || build.CommitHash == id {
-			if err := s.client.Del(ctx, key).Err(); err != nil {
-				s.logger.Warn("Failed to delete build", "key", key, "error", err)
-				continue
-			}
-			deletedKeys = append(deletedKeys, key)
+	for _, build := range matches {
+		key := fmt.Sprintf("nina-build-%s", build.CommitHash)
+		logKey := fmt.Sprintf("nina-buildlog-%s", build.CommitHash)
+		_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key, logKey)
+			pipe.SRem(ctx, buildsByAppKey(build.AppName), build.CommitHash)
+			return nil
+		})
+		if err != nil {
+			s.logger.Warn("Failed to delete build", "key", key, "error", err)
+			continue
 		}
+		deletedKeys = append(deletedKeys, key)
 	}
 
 	return deletedKeys, len(deletedKeys), nil
@@ -547,7 +878,7 @@ func (s *Store) getItemByKey(ctx context.Context, key, itemType string) ([]byte,
 	data, err := s.client.Get(ctx, key).Bytes()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, fmt.Errorf("%s not found: %s", itemType, key)
+			return nil, fmt.Errorf("%s %w: %s", itemType, ErrNotFound, key)
 		}
 		return nil, fmt.Errorf("failed to get %s: %w", itemType, err)
 	}
@@ -99,7 +99,7 @@ func runConcurrencyTest(t *testing.T, st *MockStore) {
 
 	for i := 0; i < numGoroutines; i++ {
 		go func(id int) {
-			req := &ProvisionRequest{
+			req := &types.ProvisionRequest{
 				Name:  fmt.Sprintf("concurrent-app-%d", id),
 				Image: "nginx:latest",
 				Ports: []int{80 + id},
@@ -1,11 +1,17 @@
 package store
 
 import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/matiasinsaurralde/nina/pkg/config"
 	"github.com/matiasinsaurralde/nina/pkg/logger"
+	"github.com/matiasinsaurralde/nina/pkg/types"
 )
 
 func TestStoreWithMiniredis(t *testing.T) {
@@ -43,3 +49,317 @@ func TestStoreWithMiniredis(t *testing.T) {
 	// Run the same test suite as integration tests but with mock store
 	runStoreTestSuite(t, store)
 }
+
+// newMiniredisStore returns a store backed by a fresh Miniredis server
+func newMiniredisStore(t *testing.T) *Store {
+	t.Helper()
+	mockRedis, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start Miniredis: %v", err)
+	}
+	t.Cleanup(mockRedis.Close)
+
+	cfg := &config.Config{
+		Redis: config.RedisConfig{
+			Host: mockRedis.Host(),
+			Port: mockRedis.Server().Addr().Port,
+		},
+	}
+	store, err := NewStore(cfg, logger.New(logger.LevelInfo, "text"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := store.Close(); err != nil {
+			t.Logf("Failed to close store: %v", err)
+		}
+	})
+	return store
+}
+
+func TestUpdateNewDeployment_ConcurrentUpdatesAreNotLost(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	created, err := store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: "app", CommitHash: "abc123"})
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	// Every writer appends its own container, so a lost update shows up as a missing container
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(port int) {
+			defer wg.Done()
+			errs <- store.updateNewDeployment(ctx, "app", created.ID, func(deployment *types.Deployment) {
+				deployment.Containers = append(deployment.Containers, types.Container{Port: port})
+			})
+		}(10000 + i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Concurrent update failed: %v", err)
+		}
+	}
+
+	deployment, err := store.GetNewDeployment(ctx, "app")
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if len(deployment.Containers) != writers {
+		t.Errorf("Expected %d containers, got %d", writers, len(deployment.Containers))
+	}
+}
+
+func TestUpdateNewDeployment_Replaced(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	older, err := store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: "app", CommitHash: "abc123"})
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+	if _, err := store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: "app", CommitHash: "def456"}); err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	// The deployment of the older commit must not write into the one that replaced it
+	containers := []types.Container{{ContainerID: "old", Address: "localhost", Port: 8080}}
+	err = store.UpdateNewDeploymentWithContainers(ctx, "app", older.ID, containers, types.DeploymentStatusReady)
+	if !errors.Is(err, ErrReplaced) {
+		t.Errorf("Expected ErrReplaced, got %v", err)
+	}
+	deployment, err := store.GetNewDeployment(ctx, "app")
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if deployment.CommitHash != "def456" || len(deployment.Containers) != 0 || deployment.Status != types.DeploymentStatusUnavailable {
+		t.Errorf("Expected the newer deployment to be left alone, got %+v", deployment)
+	}
+}
+
+func TestListAllDeploymentsByAppName_Errors(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	deployments, err := store.ListAllDeploymentsByAppName(ctx, "missing-app")
+	if err != nil || deployments == nil || len(deployments) != 0 {
+		t.Errorf("Expected an empty list for a missing app, got %v (%v)", deployments, err)
+	}
+
+	// A legacy record that cannot be read is reported instead of listed as nothing
+	if err := store.client.Set(ctx, "deployment:name:corrupt-app", "corrupt-id", 0).Err(); err != nil {
+		t.Fatalf("Failed to write name index: %v", err)
+	}
+	if err := store.client.Set(ctx, "deployment:corrupt-id", "{", 0).Err(); err != nil {
+		t.Fatalf("Failed to write deployment: %v", err)
+	}
+	if _, err := store.ListAllDeploymentsByAppName(ctx, "corrupt-app"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the unreadable legacy deployment to fail the listing, got %v", err)
+	}
+}
+
+func TestUpdateNewDeployment_StatusAndContainers(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	created, err := store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: "app", CommitHash: "abc123"})
+	if err != nil {
+		t.Fatalf("Failed to create deployment: %v", err)
+	}
+
+	containers := []types.Container{{ContainerID: "c1", Address: "localhost", Port: 8080}}
+	var wg sync.WaitGroup
+	var statusErr, containersErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		statusErr = store.UpdateNewDeploymentStatus(ctx, "app", created.ID, types.DeploymentStatusDeploying)
+	}()
+	go func() {
+		defer wg.Done()
+		containersErr = store.UpdateNewDeploymentWithContainers(ctx, "app", created.ID, containers, types.DeploymentStatusReady)
+	}()
+	wg.Wait()
+	if statusErr != nil || containersErr != nil {
+		t.Fatalf("Concurrent updates failed: status=%v containers=%v", statusErr, containersErr)
+	}
+
+	// Whichever update commits last sets the status, but the containers must survive either order
+	deployment, err := store.GetNewDeployment(ctx, "app")
+	if err != nil {
+		t.Fatalf("Failed to get deployment: %v", err)
+	}
+	if len(deployment.Containers) != 1 || deployment.Containers[0].ContainerID != "c1" {
+		t.Errorf("Expected container c1 to be kept, got %v", deployment.Containers)
+	}
+	if deployment.Status != types.DeploymentStatusDeploying && deployment.Status != types.DeploymentStatusReady {
+		t.Errorf("Expected status deploying or ready, got %s", deployment.Status)
+	}
+
+	err = store.UpdateNewDeploymentStatus(ctx, "missing", created.ID, types.DeploymentStatusReady)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing deployment, got %v", err)
+	}
+}
+
+func TestBuildLogs(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	if _, err := store.CreateBuild(ctx, &types.BuildRequest{AppName: "app", CommitHash: "abc123"}); err != nil {
+		t.Fatalf("Failed to create build: %v", err)
+	}
+	if err := store.SaveBuildLog(ctx, "abc123", "Step 1/3 : FROM golang\n", time.Hour); err != nil {
+		t.Fatalf("Failed to save build log: %v", err)
+	}
+
+	log, err := store.GetBuildLog(ctx, "abc123")
+	if err != nil {
+		t.Fatalf("Failed to get build log: %v", err)
+	}
+	if log != "Step 1/3 : FROM golang\n" {
+		t.Errorf("Expected stored log, got %q", log)
+	}
+	if ttl := store.client.TTL(ctx, "nina-buildlog-abc123").Val(); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("Expected log to expire within an hour, got TTL %v", ttl)
+	}
+
+	// Logs are not mistaken for builds
+	builds, err := store.ListBuilds(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list builds: %v", err)
+	}
+	if len(builds) != 1 {
+		t.Errorf("Expected 1 build, got %d", len(builds))
+	}
+
+	// Deleting the build deletes its log
+	if _, _, err := store.DeleteBuilds(ctx, "abc123"); err != nil {
+		t.Fatalf("Failed to delete builds: %v", err)
+	}
+	if _, err := store.GetBuildLog(ctx, "abc123"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after deleting the build, got %v", err)
+	}
+}
+
+func TestResolveCommitHash(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	for _, commitHash := range []string{"abcd123def456", "abcd999aaa000", "fed321cba654"} {
+		if _, err := store.CreateBuild(ctx, &types.BuildRequest{AppName: "app", CommitHash: commitHash}); err != nil {
+			t.Fatalf("Failed to create build: %v", err)
+		}
+	}
+
+	// A unique prefix resolves to the full commit hash
+	build, err := store.GetBuild(ctx, "fed3")
+	if err != nil {
+		t.Fatalf("Failed to get build by prefix: %v", err)
+	}
+	if build.CommitHash != "fed321cba654" {
+		t.Errorf("Expected fed321cba654, got %s", build.CommitHash)
+	}
+	if commitHash, err := store.ResolveCommitHash(ctx, "abcd1"); err != nil || commitHash != "abcd123def456" {
+		t.Errorf("Expected abcd123def456, got %q (err %v)", commitHash, err)
+	}
+
+	// An ambiguous prefix names the candidates
+	_, err = store.GetBuild(ctx, "abcd")
+	if !errors.Is(err, ErrAmbiguous) {
+		t.Fatalf("Expected ErrAmbiguous, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "abcd123def456") || !strings.Contains(err.Error(), "abcd999aaa000") {
+		t.Errorf("Expected the matching commit hashes in %q", err)
+	}
+
+	// Prefixes shorter than the minimum and unknown prefixes are not found
+	if _, err := store.GetBuild(ctx, "fed"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a short prefix, got %v", err)
+	}
+	if _, err := store.GetBuild(ctx, "0000"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown prefix, got %v", err)
+	}
+}
+
+func TestUpdateBuild_CommitPrefix(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	if _, err := store.CreateBuild(ctx, &types.BuildRequest{AppName: "app", CommitHash: "abcd123def456"}); err != nil {
+		t.Fatalf("Failed to create build: %v", err)
+	}
+
+	if err := store.UpdateBuildStatus(ctx, "abcd12", types.BuildStatusBuilding); err != nil {
+		t.Fatalf("Failed to update build status: %v", err)
+	}
+	if err := store.UpdateBuildWithImage(ctx, "abcd12", types.BuildStatusBuilt, "nina-app-abcd123def456", "sha256:abc", 1024, []int{8080}); err != nil {
+		t.Fatalf("Failed to update build with image: %v", err)
+	}
+	build, err := store.GetBuild(ctx, "abcd123def456")
+	if err != nil {
+		t.Fatalf("Failed to get build: %v", err)
+	}
+	if build.Status != types.BuildStatusBuilt || build.ImageID != "sha256:abc" {
+		t.Errorf("Expected the build to be updated through its prefix, got %+v", build)
+	}
+
+	if err := store.UpdateBuildFailure(ctx, "abcd12", "exit code 1"); err != nil {
+		t.Fatalf("Failed to record build failure: %v", err)
+	}
+	build, err = store.GetBuild(ctx, "abcd123def456")
+	if err != nil {
+		t.Fatalf("Failed to get build: %v", err)
+	}
+	if build.Status != types.BuildStatusFailed || build.FailureLog != "exit code 1" {
+		t.Errorf("Expected the build failure to be recorded through its prefix, got %+v", build)
+	}
+
+	// No record is created under the prefix
+	if exists, err := store.client.Exists(ctx, "nina-build-abcd12").Result(); err != nil || exists != 0 {
+		t.Errorf("Expected no build record under the prefix, got %d (err %v)", exists, err)
+	}
+}
+
+func TestDeleteBuilds_CommitPrefix(t *testing.T) {
+	store := newMiniredisStore(t)
+	ctx := context.Background()
+
+	for _, commitHash := range []string{"abcd123def456", "abcd999aaa000", "fed321cba654"} {
+		if _, err := store.CreateBuild(ctx, &types.BuildRequest{AppName: "app-" + commitHash[:3], CommitHash: commitHash}); err != nil {
+			t.Fatalf("Failed to create build: %v", err)
+		}
+	}
+
+	// An ambiguous prefix deletes nothing
+	if _, _, err := store.DeleteBuilds(ctx, "abcd"); !errors.Is(err, ErrAmbiguous) {
+		t.Errorf("Expected ErrAmbiguous, got %v", err)
+	}
+
+	deleted, count, err := store.DeleteBuilds(ctx, "abcd9")
+	if err != nil {
+		t.Fatalf("Failed to delete builds: %v", err)
+	}
+	if count != 1 || deleted[0] != "nina-build-abcd999aaa000" {
+		t.Errorf("Expected only abcd999aaa000 to be deleted, got %v", deleted)
+	}
+
+	// App names still match exactly
+	if _, count, err := store.DeleteBuilds(ctx, "app-fed"); err != nil || count != 1 {
+		t.Errorf("Expected the app-fed build to be deleted, got %d (err %v)", count, err)
+	}
+
+	builds, err := store.ListBuilds(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list builds: %v", err)
+	}
+	if len(builds) != 1 || builds[0].CommitHash != "abcd123def456" {
+		t.Errorf("Expected only abcd123def456 to remain, got %+v", builds)
+	}
+}
@@ -2,7 +2,10 @@ package store
 
 import (
 	"context"
+	"errors"
 	"testing"
+
+	"github.com/matiasinsaurralde/nina/pkg/types"
 )
 
 // runStoreTestSuite runs the common test suite for both unit and integration tests
@@ -14,12 +17,14 @@ func runStoreTestSuite(t *testing.T, store *Store) {
 	runUpdateDeploymentStatusTest(t, store)
 	runListDeploymentsTest(t, store)
 	runDeleteDeploymentTest(t, store)
+	runListAllDeploymentsTest(t, store)
+	runMigrateLegacyDeploymentsTest(t, store)
 }
 
 func runCreateDeploymentTest(t *testing.T, store *Store) {
 	t.Helper()
 	t.Run("CreateDeployment", func(t *testing.T) {
-		req := &ProvisionRequest{
+		req := &types.ProvisionRequest{
 			Name:  "test-app",
 			Image: "nginx:latest",
 			Ports: []int{80, 443},
@@ -55,7 +60,7 @@ func runCreateDeploymentTest(t *testing.T, store *Store) {
 func runGetDeploymentTest(t *testing.T, store *Store) {
 	t.Helper()
 	t.Run("GetDeployment", func(t *testing.T) {
-		req := &ProvisionRequest{
+		req := &types.ProvisionRequest{
 			Name:  "test-get-app",
 			Image: "alpine:latest",
 			Ports: []int{8080},
@@ -90,7 +95,7 @@ func runGetDeploymentTest(t *testing.T, store *Store) {
 func runGetDeploymentByNameTest(t *testing.T, store *Store) {
 	t.Helper()
 	t.Run("GetDeploymentByName", func(t *testing.T) {
-		req := &ProvisionRequest{
+		req := &types.ProvisionRequest{
 			Name:  "test-name-app",
 			Image: "busybox:latest",
 			Ports: []int{9000},
@@ -121,7 +126,7 @@ func runGetDeploymentByNameTest(t *testing.T, store *Store) {
 func runUpdateDeploymentStatusTest(t *testing.T, store *Store) {
 	t.Helper()
 	t.Run("UpdateDeploymentStatus", func(t *testing.T) {
-		req := &ProvisionRequest{
+		req := &types.ProvisionRequest{
 			Name:  "test-status-app",
 			Image: "redis:alpine",
 			Ports: []int{6379},
@@ -158,7 +163,7 @@ func runListDeploymentsTest(t *testing.T, store *Store) {
 	t.Helper()
 	t.Run("ListDeployments", func(t *testing.T) {
 		// Create multiple deployments
-		deployments := []*ProvisionRequest{
+		deployments := []*types.ProvisionRequest{
 			{Name: "list-app-1", Image: "nginx:latest", Ports: []int{80}},
 			{Name: "list-app-2", Image: "alpine:latest", Ports: []int{8080}},
 			{Name: "list-app-3", Image: "busybox:latest", Ports: []int{9000}},
@@ -196,7 +201,7 @@ func runListDeploymentsTest(t *testing.T, store *Store) {
 func runDeleteDeploymentTest(t *testing.T, store *Store) {
 	t.Helper()
 	t.Run("DeleteDeployment", func(t *testing.T) {
-		req := &ProvisionRequest{
+		req := &types.ProvisionRequest{
 			Name:  "test-delete-app",
 			Image: "nginx:latest",
 			Ports: []int{80},
@@ -214,8 +219,126 @@ func runDeleteDeploymentTest(t *testing.T, store *Store) {
 
 		// Try to get it - should fail
 		_, err = store.GetDeployment(context.Background(), deployment.ID)
-		if err == nil {
-			t.Error("Expected error when getting deleted deployment, got nil")
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound when getting deleted deployment, got %v", err)
+		}
+
+		// Deleting it again reports that it is gone
+		if err := store.DeleteDeployment(context.Background(), deployment.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound when deleting a missing deployment, got %v", err)
+		}
+		if _, err := store.GetNewDeployment(context.Background(), "missing-app"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a missing deployment, got %v", err)
+		}
+	})
+}
+
+func runListAllDeploymentsTest(t *testing.T, store *Store) {
+	t.Helper()
+	t.Run("ListAllDeployments", func(t *testing.T) {
+		ctx := context.Background()
+
+		legacy, err := store.CreateDeployment(ctx, &types.ProvisionRequest{Name: "unified-legacy", Image: "nginx:latest", Ports: []int{80}})
+		if err != nil {
+			t.Fatalf("Failed to create legacy deployment: %v", err)
+		}
+		// A legacy record shadowed by a new deployment with the same name
+		shadowed, err := store.CreateDeployment(ctx, &types.ProvisionRequest{Name: "unified-new", Image: "alpine:latest"})
+		if err != nil {
+			t.Fatalf("Failed to create legacy deployment: %v", err)
+		}
+		if _, err := store.CreateNewDeployment(ctx, &types.DeploymentRequest{AppName: "unified-new", CommitHash: "abc123"}); err != nil {
+			t.Fatalf("Failed to create deployment: %v", err)
+		}
+
+		list, err := store.ListAllDeployments(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list all deployments: %v", err)
+		}
+
+		byName := make(map[string][]*types.Deployment)
+		for _, deployment := range list {
+			byName[deployment.AppName] = append(byName[deployment.AppName], deployment)
+		}
+
+		if len(byName["unified-new"]) != 1 || byName["unified-new"][0].CommitHash != "abc123" {
+			t.Errorf("Expected only the new unified-new deployment, got %+v", byName["unified-new"])
+		}
+		if len(byName["unified-legacy"]) != 1 {
+			t.Fatalf("Expected the legacy deployment to be listed once, got %d", len(byName["unified-legacy"]))
+		}
+		converted := byName["unified-legacy"][0]
+		if converted.ID != legacy.ID || converted.Status != types.DeploymentStatusDeploying {
+			t.Errorf("Unexpected converted deployment: %+v", converted)
+		}
+		if len(converted.Containers) != 1 || converted.Containers[0].ImageTag != "nginx:latest" || converted.Containers[0].Port != 80 {
+			t.Errorf("Unexpected converted containers: %+v", converted.Containers)
+		}
+
+		byApp, err := store.ListAllDeploymentsByAppName(ctx, "unified-legacy")
+		if err != nil {
+			t.Fatalf("Failed to list deployments by app name: %v", err)
+		}
+		if len(byApp) != 1 || byApp[0].ID != legacy.ID {
+			t.Errorf("Expected legacy deployment by app name, got %+v", byApp)
+		}
+
+		// Clean up
+		for _, id := range []string{legacy.ID, shadowed.ID} {
+			if deleteErr := store.DeleteDeployment(ctx, id); deleteErr != nil {
+				t.Errorf("Failed to clean up deployment %s: %v", id, deleteErr)
+			}
+		}
+		if deleteErr := store.DeleteNewDeployment(ctx, "unified-new"); deleteErr != nil {
+			t.Errorf("Failed to clean up deployment: %v", deleteErr)
+		}
+	})
+}
+
+func runMigrateLegacyDeploymentsTest(t *testing.T, store *Store) {
+	t.Helper()
+	t.Run("MigrateLegacyDeployments", func(t *testing.T) {
+		ctx := context.Background()
+
+		legacy, err := store.CreateDeployment(ctx, &types.ProvisionRequest{Name: "migrate-app", Image: "nginx:latest", Ports: []int{80}})
+		if err != nil {
+			t.Fatalf("Failed to create legacy deployment: %v", err)
+		}
+
+		result, err := store.MigrateLegacyDeployments(ctx, false)
+		if err != nil {
+			t.Fatalf("Failed to migrate deployments: %v", err)
+		}
+		if result.Migrated != 1 || result.Skipped != 0 || result.Deleted != 0 {
+			t.Errorf("Unexpected first migration result: %+v", result)
+		}
+
+		migrated, err := store.GetNewDeployment(ctx, "migrate-app")
+		if err != nil {
+			t.Fatalf("Failed to get migrated deployment: %v", err)
+		}
+		if migrated.ID != legacy.ID || len(migrated.Containers) != 1 {
+			t.Errorf("Unexpected migrated deployment: %+v", migrated)
+		}
+
+		// Running again skips the record and removes the legacy keys
+		result, err = store.MigrateLegacyDeployments(ctx, true)
+		if err != nil {
+			t.Fatalf("Failed to migrate deployments: %v", err)
+		}
+		if result.Migrated != 0 || result.Skipped != 1 || result.Deleted != 1 {
+			t.Errorf("Unexpected second migration result: %+v", result)
+		}
+		if _, err := store.GetDeployment(ctx, legacy.ID); err == nil {
+			t.Error("Expected legacy deployment to be deleted")
+		}
+		if _, err := store.GetDeploymentByName(ctx, "migrate-app"); err == nil {
+			t.Error("Expected legacy name mapping to be deleted")
+		}
+
+		// Clean up
+		if deleteErr := store.DeleteNewDeployment(ctx, "migrate-app"); deleteErr != nil {
+			t.Errorf("Failed to clean up deployment: %v", deleteErr)
 		}
 	})
 }
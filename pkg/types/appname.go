@@ -0,0 +1,31 @@
+package types
+
+import "strings"
+
+// MaxAppNameLength keeps app names within a DNS label so they can be used as ingress hosts
+const MaxAppNameLength = 63
+
+// SanitizeAppName turns a name into a form that is safe for container names and ingress hosts:
+// lowercase letters, digits and single dashes, without leading or trailing dashes, and at most
+// MaxAppNameLength characters. Names without usable characters become empty.
+func SanitizeAppName(name string) string {
+	var b strings.Builder
+	lastDash := true
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			lastDash = false
+			continue
+		}
+		if !lastDash {
+			b.WriteByte('-')
+			lastDash = true
+		}
+	}
+
+	sanitized := b.String()
+	if len(sanitized) > MaxAppNameLength {
+		sanitized = sanitized[:MaxAppNameLength]
+	}
+	return strings.TrimRight(sanitized, "-")
+}
@@ -0,0 +1,34 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeAppName(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"already valid", "my-app", "my-app"},
+		{"uppercase", "MyApp", "myapp"},
+		{"dots", "my.app.v2", "my-app-v2"},
+		{"underscores and spaces", "my_app name", "my-app-name"},
+		{"special characters", "app@#$%name!", "app-name"},
+		{"leading and trailing separators", "--.app.--", "app"},
+		{"unicode", "café-app", "caf-app"},
+		{"no usable characters", "!!!", ""},
+		{"empty", "", ""},
+		{"too long", strings.Repeat("a", 80), strings.Repeat("a", MaxAppNameLength)},
+		{"truncated at a separator", strings.Repeat("a", 62) + ".b", strings.Repeat("a", 62)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeAppName(tt.input); got != tt.expected {
+				t.Errorf("SanitizeAppName(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
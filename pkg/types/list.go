@@ -0,0 +1,113 @@
+package types
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// SortByCreatedAt orders records by creation time
+	SortByCreatedAt = "created_at"
+	// SortByAppName orders records by application name
+	SortByAppName = "app_name"
+	// OrderAsc sorts in ascending order
+	OrderAsc = "asc"
+	// OrderDesc sorts in descending order
+	OrderDesc = "desc"
+)
+
+// ListOptions controls how listed deployments and builds are filtered and ordered.
+// Records are sorted by creation time in ascending order unless set otherwise.
+type ListOptions struct {
+	SortBy string
+	Order  string
+	Status string
+	// Since keeps records created within a duration (e.g. "1h") or after an RFC3339 timestamp
+	Since string
+	// Labels keeps deployments that have all of these labels; builds have no labels
+	Labels map[string]string
+}
+
+// ParseSince converts a since value into a cutoff time, durations are relative to now
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("invalid since value %q, duration must not be negative", value)
+		}
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since value %q, expected a duration or an RFC3339 timestamp", value)
+	}
+	return t, nil
+}
+
+// ParseLabels parses key=value pairs into a label map
+func ParseLabels(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", pair)
+		}
+		labels[strings.TrimSpace(key)] = value
+	}
+	return labels, nil
+}
+
+// Validate checks that the sort field and order are supported
+func (o *ListOptions) Validate() error {
+	switch o.SortBy {
+	case "", SortByCreatedAt, SortByAppName:
+	default:
+		return fmt.Errorf("unsupported sort field %q, expected %s or %s", o.SortBy, SortByCreatedAt, SortByAppName)
+	}
+	switch strings.ToLower(o.Order) {
+	case "", OrderAsc, OrderDesc:
+	default:
+		return fmt.Errorf("unsupported order %q, expected %s or %s", o.Order, OrderAsc, OrderDesc)
+	}
+	if _, err := ParseSince(o.Since, time.Now()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Query returns the options encoded as URL query parameters
+func (o *ListOptions) Query() url.Values {
+	query := url.Values{}
+	if o == nil {
+		return query
+	}
+	if o.SortBy != "" {
+		query.Set("sort", o.SortBy)
+	}
+	if o.Order != "" {
+		query.Set("order", o.Order)
+	}
+	if o.Status != "" {
+		query.Set("status", o.Status)
+	}
+	if o.Since != "" {
+		query.Set("since", o.Since)
+	}
+	keys := make([]string, 0, len(o.Labels))
+	for key := range o.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		query.Add("label", key+"="+o.Labels[key])
+	}
+	return query
+}
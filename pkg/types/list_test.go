@@ -0,0 +1,69 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListOptionsValidate(t *testing.T) {
+	valid := []*ListOptions{
+		{},
+		{SortBy: SortByCreatedAt, Order: OrderDesc},
+		{SortBy: SortByAppName, Order: "ASC", Status: "ready"},
+		{Since: "90m"},
+		{Since: "2024-01-10T12:00:00Z"},
+	}
+	for _, opts := range valid {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", opts, err)
+		}
+	}
+
+	invalid := []*ListOptions{
+		{SortBy: "size"},
+		{Order: "sideways"},
+		{Since: "yesterday"},
+		{Since: "-1h"},
+	}
+	for _, opts := range invalid {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", opts)
+		}
+	}
+}
+
+func TestListOptionsQuery(t *testing.T) {
+	query := (&ListOptions{SortBy: SortByAppName, Since: "1h"}).Query()
+	if query.Get("sort") != SortByAppName || query.Get("since") != "1h" {
+		t.Errorf("Unexpected query %v", query)
+	}
+	if query.Has("status") {
+		t.Errorf("Expected unset status to be omitted, got %v", query)
+	}
+	var nilOpts *ListOptions
+	if len(nilOpts.Query()) != 0 {
+		t.Error("Expected nil options to produce an empty query")
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels([]string{"team=payments", "ticket=NINA-1", "note=a=b", "empty="})
+	if err != nil {
+		t.Fatalf("Failed to parse labels: %v", err)
+	}
+	expected := map[string]string{"team": "payments", "ticket": "NINA-1", "note": "a=b", "empty": ""}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected %v, got %v", expected, labels)
+	}
+
+	for _, pair := range []string{"team", "=payments"} {
+		if _, err := ParseLabels([]string{pair}); err == nil {
+			t.Errorf("Expected %q to be rejected", pair)
+		}
+	}
+
+	query := (&ListOptions{Labels: labels}).Query()
+	if got := query["label"]; !reflect.DeepEqual(got, []string{"empty=", "note=a=b", "team=payments", "ticket=NINA-1"}) {
+		t.Errorf("Unexpected label query %v", got)
+	}
+}
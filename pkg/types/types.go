@@ -16,6 +16,8 @@ const (
 	DeploymentStatusDeploying DeploymentStatus = "deploying"
 	// DeploymentStatusReady represents a deployment that is ready.
 	DeploymentStatusReady DeploymentStatus = "ready"
+	// DeploymentStatusPartiallyReady represents a deployment where only some replicas are healthy.
+	DeploymentStatusPartiallyReady DeploymentStatus = "partially_ready"
 	// DeploymentStatusFailed represents a deployment that failed.
 	DeploymentStatusFailed DeploymentStatus = "failed"
 
@@ -29,36 +31,176 @@ const (
 	BuildStatusFailed BuildStatus = "failed"
 )
 
+// PullPolicy controls whether the image of a deployment is pulled before its containers are created.
+type PullPolicy string
+
+const (
+	// PullPolicyAlways pulls the image before every deployment.
+	PullPolicyAlways PullPolicy = "always"
+	// PullPolicyIfNotPresent pulls the image only when the Docker daemon does not have it.
+	PullPolicyIfNotPresent PullPolicy = "if-not-present"
+	// PullPolicyNever never pulls, failing the deployment when the image is missing.
+	PullPolicyNever PullPolicy = "never"
+)
+
+// Valid reports whether the pull policy is known; the empty policy means PullPolicyIfNotPresent.
+func (p PullPolicy) Valid() bool {
+	switch p {
+	case "", PullPolicyAlways, PullPolicyIfNotPresent, PullPolicyNever:
+		return true
+	default:
+		return false
+	}
+}
+
+// DefaultReplicas is the number of replicas deployed when nothing else sets a replica count.
+// Both the CLI and the Engine fall back to it.
+const DefaultReplicas = 1
+
+// DefaultMaxBundleSize is the largest bundle, in bytes, a build accepts when no limit is configured.
+// The Engine enforces it and the CLI checks bundles against it before uploading them.
+const DefaultMaxBundleSize = 100 << 20
+
 // DeploymentRequest represents a request to deploy an application.
 type DeploymentRequest struct {
-	AppName       string `json:"app_name"`
-	CommitHash    string `json:"commit_hash"`
-	Author        string `json:"author"`
-	AuthorEmail   string `json:"author_email"`
-	CommitMessage string `json:"commit_message"`
-	Replicas      int    `json:"replicas"`
+	AppName       string            `json:"app_name"`
+	CommitHash    string            `json:"commit_hash"`
+	Author        string            `json:"author"`
+	AuthorEmail   string            `json:"author_email"`
+	CommitMessage string            `json:"commit_message"`
+	Replicas      int               `json:"replicas"`
+	Port          int               `json:"port,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+	CPU           float64           `json:"cpu,omitempty"`
+	Memory        int64             `json:"memory,omitempty"`
+	Node          string            `json:"node,omitempty"`
+	// Labels are free-form metadata, also applied to the containers as Docker labels
+	Labels map[string]string `json:"labels,omitempty"`
+	// PullPolicy controls whether the image is pulled before the containers are created,
+	// empty means PullPolicyIfNotPresent
+	PullPolicy PullPolicy `json:"pull_policy,omitempty"`
+	// Domains are additional hosts the ingress routes to the app; a leading "*." matches any single subdomain
+	Domains []string `json:"domains,omitempty"`
+	// Image is a pre-built image reference deployed as is, without a Nina build; the commit hash is then optional
+	Image string `json:"image,omitempty"`
+	// WebhookURLs receive a POST of the DeploymentWebhook once the deployment becomes ready or fails
+	WebhookURLs []string `json:"webhook_urls,omitempty"`
 }
 
 // Deployment represents a deployment configuration.
 type Deployment struct {
-	ID            string           `json:"id"`
-	AppName       string           `json:"app_name"`
-	RepoURL       string           `json:"repo_url"`
-	Author        string           `json:"author"`
-	AuthorEmail   string           `json:"author_email"`
-	CommitHash    string           `json:"commit_hash"`
-	CommitMessage string           `json:"commit_message"`
-	Containers    []Container      `json:"containers"`
-	Status        DeploymentStatus `json:"status"`
-	CreatedAt     time.Time        `json:"created_at"`
-	UpdatedAt     time.Time        `json:"updated_at"`
+	ID            string            `json:"id"`
+	AppName       string            `json:"app_name"`
+	RepoURL       string            `json:"repo_url"`
+	Author        string            `json:"author"`
+	AuthorEmail   string            `json:"author_email"`
+	CommitHash    string            `json:"commit_hash"`
+	CommitMessage string            `json:"commit_message"`
+	Containers    []Container       `json:"containers"`
+	Replicas      int               `json:"replicas,omitempty"`
+	Node          string            `json:"node,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Domains       []string          `json:"domains,omitempty"`
+	Status        DeploymentStatus  `json:"status"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	// Image is the pre-built image of a deployment created without a Nina build
+	Image string `json:"image,omitempty"`
+	// FailedReplicas are the replicas that did not come up in the last container update, with the reason
+	FailedReplicas []ReplicaFailure `json:"failed_replicas,omitempty"`
+	// WebhookURLs are notified when the deployment becomes ready or fails
+	WebhookURLs []string `json:"webhook_urls,omitempty"`
+	// URLs are the external URLs the ingress serves the app at, set on API responses and never stored
+	URLs []string `json:"urls,omitempty"`
+}
+
+// LegacyDeployment is a deployment created by the provision endpoint, kept by its ID.
+type LegacyDeployment struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Image       string            `json:"image"`
+	Status      string            `json:"status"`
+	Ports       []int             `json:"ports"`
+	Environment map[string]string `json:"environment"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// ProvisionRequest represents a request to provision a container.
+type ProvisionRequest struct {
+	Name        string            `json:"name"`
+	Image       string            `json:"image"`
+	Ports       []int             `json:"ports"`
+	Environment map[string]string `json:"environment"`
+}
+
+// App groups the builds and the current deployment of an application.
+type App struct {
+	Name string `json:"name"`
+	// Domains are the hosts the ingress routes to the app
+	Domains []string `json:"domains"`
+	// Env is the environment of the current deployment
+	Env         map[string]string `json:"env,omitempty"`
+	LatestBuild *Build            `json:"latest_build,omitempty"`
+	Deployment  *Deployment       `json:"deployment,omitempty"`
+	// Builds is the number of stored builds of the app
+	Builds int `json:"builds"`
+}
+
+// Stats aggregates the apps, deployments and builds known to the Engine.
+type Stats struct {
+	Apps                int                      `json:"apps"`
+	Deployments         int                      `json:"deployments"`
+	DeploymentsByStatus map[DeploymentStatus]int `json:"deployments_by_status"`
+	Builds              int                      `json:"builds"`
+	BuildsByStatus      map[BuildStatus]int      `json:"builds_by_status"`
+	// ImageBytes is the total size of the built images, counting an image reused by several builds once
+	ImageBytes int64 `json:"image_bytes"`
+	// RunningContainers is the number of running app containers reported by the Docker daemons,
+	// unset when a daemon could not be queried
+	RunningContainers *int `json:"running_containers,omitempty"`
 }
 
 // DeploymentImage represents a deployment image.
 type DeploymentImage struct {
-	ImageTag string `json:"image_tag"`
-	ImageID  string `json:"image_id"`
-	Size     int64  `json:"size"`
+	ImageTag     string `json:"image_tag"`
+	ImageID      string `json:"image_id"`
+	Size         int64  `json:"size"`
+	ExposedPorts []int  `json:"exposed_ports,omitempty"`
+	// Cached is set when the image of an earlier build of identical contents was reused
+	Cached bool `json:"cached,omitempty"`
+}
+
+// DeploymentStatusReport is a deployment as reported by the status endpoint, with a description of its status.
+type DeploymentStatusReport struct {
+	Deployment
+	// InProgress is true while the containers of the deployment are still being started
+	InProgress bool `json:"in_progress"`
+	// Message describes the status, e.g. how many replicas are up or why the deployment failed
+	Message string `json:"message"`
+	// ElapsedSeconds is the time since the deployment was created, set while it is in progress
+	ElapsedSeconds int64 `json:"elapsed_seconds,omitempty"`
+}
+
+// DeploymentWebhook is the outcome of a deployment, POSTed to the webhook URLs of the deployment.
+type DeploymentWebhook struct {
+	DeploymentID string           `json:"deployment_id"`
+	AppName      string           `json:"app_name"`
+	CommitHash   string           `json:"commit_hash,omitempty"`
+	Image        string           `json:"image,omitempty"`
+	Status       DeploymentStatus `json:"status"`
+	// Replicas is the requested replica count and ReadyReplicas the number of replicas that came up
+	Replicas      int       `json:"replicas"`
+	ReadyReplicas int       `json:"ready_replicas"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ReplicaFailure describes a replica that failed to start or to become ready.
+type ReplicaFailure struct {
+	// Replica is the 1-based number of the replica
+	Replica int    `json:"replica"`
+	Error   string `json:"error"`
 }
 
 // Container represents a container configuration.
@@ -67,17 +209,111 @@ type Container struct {
 	ImageTag    string `json:"image_tag"`
 	Address     string `json:"address"`
 	Port        int    `json:"port"`
+	// Node is the engine node the container runs on, empty for the default Docker daemon
+	Node string `json:"node,omitempty"`
+}
+
+// ContainerDetails summarizes the Docker state of a single replica container.
+type ContainerDetails struct {
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	Node        string `json:"node,omitempty"`
+	// Status is the Docker container state, e.g. running, restarting or exited
+	Status   string `json:"status"`
+	Running  bool   `json:"running"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+	// Health is the result of the image health check, empty when the image has none
+	Health       string        `json:"health,omitempty"`
+	StartedAt    time.Time     `json:"started_at"`
+	FinishedAt   time.Time     `json:"finished_at"`
+	RestartCount int           `json:"restart_count"`
+	Ports        []PortBinding `json:"ports"`
+}
+
+// ManagedContainer is a Docker container created by Nina, as found on the Docker daemons of the Engine.
+type ManagedContainer struct {
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	Node        string `json:"node,omitempty"`
+	// AppName is the app the container was created for, from its app label
+	AppName string `json:"app_name,omitempty"`
+	// State is the Docker container state, e.g. running or exited, and Status its description, e.g. Up 2 hours
+	State     string    `json:"state"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	// Orphaned is true when no deployment references the container
+	Orphaned bool `json:"orphaned"`
+}
+
+// ActivityType is the kind of record an activity event is about.
+type ActivityType string
+
+const (
+	// ActivityTypeBuild marks the status transitions of builds.
+	ActivityTypeBuild ActivityType = "build"
+	// ActivityTypeDeployment marks the status transitions of deployments.
+	ActivityTypeDeployment ActivityType = "deployment"
+)
+
+// ActivityEvent is a build or deployment status transition, as kept in the recent activity of the Engine.
+type ActivityEvent struct {
+	Time       time.Time    `json:"time"`
+	Type       ActivityType `json:"type"`
+	AppName    string       `json:"app_name"`
+	CommitHash string       `json:"commit_hash,omitempty"`
+	// Status is a BuildStatus or a DeploymentStatus, depending on the type
+	Status string `json:"status"`
+}
+
+// PortBinding is a container port published on the host.
+type PortBinding struct {
+	ContainerPort string `json:"container_port"`
+	HostIP        string `json:"host_ip"`
+	HostPort      string `json:"host_port"`
 }
 
+const (
+	// BuildFormMetadata is the multipart field holding the JSON encoded build request.
+	BuildFormMetadata = "metadata"
+	// BuildFormBundle is the multipart field holding the gzipped tarball.
+	BuildFormBundle = "bundle"
+)
+
 // BuildRequest represents a request to build a deployment.
 type BuildRequest struct {
-	AppName        string `json:"app_name"`
-	RepoURL        string `json:"repo_url"`
-	Author         string `json:"author"`
-	AuthorEmail    string `json:"author_email"`
-	CommitHash     string `json:"commit_hash"`
-	CommitMessage  string `json:"commit_message"`
-	BundleContents string `json:"bundle_content"`
+	AppName        string            `json:"app_name"`
+	RepoURL        string            `json:"repo_url"`
+	Author         string            `json:"author"`
+	AuthorEmail    string            `json:"author_email"`
+	CommitHash     string            `json:"commit_hash"`
+	CommitMessage  string            `json:"commit_message"`
+	BundleContents string            `json:"bundle_content"`
+	BuildArgs      map[string]string `json:"build_args,omitempty"`
+	Buildpack      string            `json:"buildpack,omitempty"`
+	// Secrets name build secrets of the Engine's secret store. They are available to the build but are
+	// never passed as build arguments nor copied to the built image.
+	Secrets []string `json:"secrets,omitempty"`
+	// BundleChecksum is the hex encoded SHA-256 of the gzipped bundle, verified before extraction when set
+	BundleChecksum string `json:"bundle_checksum,omitempty"`
+	// CallbackURL receives a POST of the BuildCallback once the build succeeds or fails
+	CallbackURL string `json:"callback_url,omitempty"`
+	// BundlePath points to a gzipped tarball on disk, set by the engine for streamed uploads
+	BundlePath string `json:"-"`
+}
+
+// BuildCallback is the result of a finished build, POSTed to the callback URL of its request.
+type BuildCallback struct {
+	AppName    string      `json:"app_name"`
+	CommitHash string      `json:"commit_hash"`
+	Status     BuildStatus `json:"status"`
+	ImageTag   string      `json:"image_tag,omitempty"`
+	Size       int64       `json:"size,omitempty"`
+	// LogsURL is the Engine URL serving the build output
+	LogsURL    string    `json:"logs_url"`
+	FinishedAt time.Time `json:"finished_at"`
 }
 
 // Build represents a build.
@@ -93,5 +329,7 @@ type Build struct {
 	ImageTag      string      `json:"image_tag"`
 	ImageID       string      `json:"image_id"`
 	Size          int64       `json:"size"`
+	ExposedPorts  []int       `json:"exposed_ports,omitempty"`
 	Status        BuildStatus `json:"status"`
+	FailureLog    string      `json:"failure_log,omitempty"`
 }